@@ -3,36 +3,106 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	kilobyte = 1024
+	megabyte = 1024 * kilobyte
+)
+
 type Config struct {
 	DatabasePath                string
+	DBMaxOpenConns             int
+	DBMaxIdleConns             int
 	Port                       string
 	AllowedOrigins             string
+	TrustedProxies             string
 	MailgunDomain              string
 	MailgunAPIKey              string
 	MailgunSenderEmail         string
 	MailgunSenderName          string
 	MailgunRegion              string
+	EmailProvider              string
+	SMTPHost                   string
+	SMTPPort                   string
+	SMTPUsername               string
+	SMTPPassword               string
+	SMTPSenderEmail            string
+	SMTPSenderName             string
 	SessionDuration            time.Duration
+	RememberMeDuration         time.Duration
+	SessionExtensionThreshold  time.Duration
 	LogLevel                   string
 	Environment                string
+	WeatherForecastURL         string
+	WeatherGeocodingURL        string
+	MetricsToken               string
+	RateLimitBackend           string
+	RateLimitRPS               int
+	AuthRateLimitPerMinute     int
+	Block404Threshold          int
+	BlockDuration              time.Duration
+	CleanupInterval            time.Duration
+	ShutdownTimeout            time.Duration
+	MaxGPXSize                 int64
+	MaxCSVSize                 int64
+	MaxImageSize               int64
+	RedisURL                   string
+	TOTPEncryptionKey          string
+	OAuthRedirectBaseURL       string
+	GoogleClientID             string
+	GoogleClientSecret         string
+	GitHubClientID             string
+	GitHubClientSecret         string
 }
 
 func Load() *Config {
 	cfg := &Config{
 		DatabasePath:               getEnv("DATABASE_PATH", "carryless.db"),
+		DBMaxOpenConns:            getPositiveIntEnv("DB_MAX_OPEN_CONNS", 0),
+		DBMaxIdleConns:            getPositiveIntEnv("DB_MAX_IDLE_CONNS", 5),
 		Port:                      getEnv("PORT", "8080"),
 		AllowedOrigins:            getEnv("ALLOWED_ORIGINS", "http://localhost:8080,http://127.0.0.1:8080,https://carryless.plop.name,https://carryless.org"),
+		TrustedProxies:            getEnv("TRUSTED_PROXIES", ""),
 		MailgunDomain:             getEnv("MAILGUN_DOMAIN", ""),
 		MailgunAPIKey:             getEnv("MAILGUN_API_KEY", ""),
 		MailgunSenderEmail:        getEnv("MAILGUN_SENDER_EMAIL", "noreply@carryless.org"),
 		MailgunSenderName:         getEnv("MAILGUN_SENDER_NAME", "Carryless"),
 		MailgunRegion:             getEnv("MAILGUN_REGION", "EU"),
+		EmailProvider:             getEnv("EMAIL_PROVIDER", ""),
+		SMTPHost:                  getEnv("SMTP_HOST", ""),
+		SMTPPort:                  getEnv("SMTP_PORT", "587"),
+		SMTPUsername:              getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:              getEnv("SMTP_PASSWORD", ""),
+		SMTPSenderEmail:           getEnv("SMTP_SENDER_EMAIL", "noreply@carryless.org"),
+		SMTPSenderName:            getEnv("SMTP_SENDER_NAME", "Carryless"),
 		SessionDuration:           getDurationEnv("SESSION_DURATION", 14*24*time.Hour),
+		RememberMeDuration:        getDurationEnv("REMEMBER_ME_DURATION", 30*24*time.Hour),
+		SessionExtensionThreshold: getDurationEnv("SESSION_EXTENSION_THRESHOLD", 7*24*time.Hour),
 		LogLevel:                  getEnv("LOG_LEVEL", "INFO"),
 		Environment:               getEnv("ENVIRONMENT", "production"),
+		WeatherForecastURL:        getEnv("WEATHER_FORECAST_URL", "https://api.open-meteo.com/v1/forecast"),
+		WeatherGeocodingURL:       getEnv("WEATHER_GEOCODING_URL", "https://geocoding-api.open-meteo.com/v1/search"),
+		MetricsToken:              getEnv("METRICS_TOKEN", ""),
+		RateLimitBackend:          getEnv("RATE_LIMIT_BACKEND", "memory"),
+		RateLimitRPS:              getPositiveIntEnv("RATE_LIMIT_RPS", 20),
+		AuthRateLimitPerMinute:    getPositiveIntEnv("AUTH_RATE_LIMIT_PER_MINUTE", 5),
+		Block404Threshold:         getPositiveIntEnv("BLOCK_404_THRESHOLD", 10),
+		BlockDuration:             getDurationEnv("BLOCK_DURATION", 15*time.Minute),
+		CleanupInterval:           getDurationEnv("CLEANUP_INTERVAL", time.Hour),
+		ShutdownTimeout:           getDurationEnv("SHUTDOWN_TIMEOUT", 15*time.Second),
+		MaxGPXSize:                getSizeEnv("MAX_GPX_SIZE", 5*megabyte),
+		MaxCSVSize:                getSizeEnv("MAX_CSV_SIZE", 10*megabyte),
+		MaxImageSize:              getSizeEnv("MAX_IMAGE_SIZE", 2*megabyte),
+		RedisURL:                  getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		TOTPEncryptionKey:         getEnv("TOTP_ENCRYPTION_KEY", ""),
+		OAuthRedirectBaseURL:      getEnv("OAUTH_REDIRECT_BASE_URL", "https://carryless.org"),
+		GoogleClientID:            getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:        getEnv("GOOGLE_CLIENT_SECRET", ""),
+		GitHubClientID:            getEnv("GITHUB_CLIENT_ID", ""),
+		GitHubClientSecret:        getEnv("GITHUB_CLIENT_SECRET", ""),
 	}
 	return cfg
 }
@@ -44,6 +114,14 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getPositiveIntEnv falls back to the default for missing, invalid or non-positive values
+func getPositiveIntEnv(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if hours, err := strconv.Atoi(value); err == nil {
@@ -56,8 +134,44 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// getSizeEnv reads a size in bytes, or with a KB or MB suffix such as "5MB". Missing, invalid
+// or non-positive values fall back to the default.
+func getSizeEnv(key string, defaultValue int64) int64 {
+	value := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(value, "MB"):
+		multiplier, value = megabyte, strings.TrimSuffix(value, "MB")
+	case strings.HasSuffix(value, "KB"):
+		multiplier, value = kilobyte, strings.TrimSuffix(value, "KB")
+	}
+
+	if size, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil && size > 0 {
+		return size * multiplier
+	}
+	return defaultValue
+}
+
+// FormatSize renders an upload limit for error messages, such as "5MB" or "512KB"
+func FormatSize(size int64) string {
+	switch {
+	case size >= megabyte && size%megabyte == 0:
+		return strconv.FormatInt(size/megabyte, 10) + "MB"
+	case size >= kilobyte && size%kilobyte == 0:
+		return strconv.FormatInt(size/kilobyte, 10) + "KB"
+	default:
+		return strconv.FormatInt(size, 10) + " bytes"
+	}
+}
+
 // IsDevelopment returns true if the environment is set to development.
 // In development mode, security measures like CSRF, rate limiting, and security headers are disabled.
 func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
+}
+
+// TOTPEnabled returns true if two-factor authentication can be offered, which requires a key
+// to encrypt the shared secrets with.
+func (c *Config) TOTPEnabled() bool {
+	return c.TOTPEncryptionKey != ""
 }
\ No newline at end of file
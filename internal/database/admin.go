@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"carryless/internal/models"
@@ -21,6 +22,7 @@ type UserWithStats struct {
 	Currency    string         `json:"currency"`
 	IsAdmin     bool           `json:"is_admin"`
 	IsActivated bool           `json:"is_activated"`
+	IsSuspended bool           `json:"is_suspended"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	PackCount   int            `json:"pack_count"`
@@ -98,7 +100,24 @@ func GetAllUsers(db *sql.DB) ([]models.User, error) {
 }
 
 func GetAllUsersWithStats(db *sql.DB) ([]UserWithStats, error) {
-	query := `
+	// A negative limit means no limit in SQLite
+	return SearchUsersWithStats(db, "", -1, 0)
+}
+
+// userSearchFilter matches users whose username or email contains the search query
+const userSearchFilter = `(? = '' OR u.username LIKE ? ESCAPE '\' OR u.email LIKE ? ESCAPE '\')`
+
+// userSearchArgs returns the arguments of userSearchFilter for a search query
+func userSearchArgs(query string) []interface{} {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query)
+	pattern := "%" + escaped + "%"
+	return []interface{}{query, pattern, pattern}
+}
+
+// SearchUsersWithStats returns a page of users matching query on username or email, oldest first.
+// An empty query matches every user.
+func SearchUsersWithStats(db *sql.DB, query string, limit, offset int) ([]UserWithStats, error) {
+	sqlQuery := `
 		SELECT
 			u.id,
 			u.username,
@@ -106,19 +125,21 @@ func GetAllUsersWithStats(db *sql.DB) ([]UserWithStats, error) {
 			COALESCE(u.currency, '$'),
 			COALESCE(u.is_admin, false),
 			COALESCE(u.is_activated, false),
+			COALESCE(u.is_suspended, false),
 			u.created_at,
 			u.updated_at,
 			u.last_seen,
-			COUNT(p.id) as pack_count,
-			(SELECT COUNT(*) FROM items i WHERE i.user_id = u.id) as item_count,
+			(SELECT COUNT(*) FROM packs p WHERE p.user_id = u.id) as pack_count,
+			(SELECT COUNT(*) FROM items i WHERE i.user_id = u.id AND i.deleted_at IS NULL) as item_count,
 			(SELECT COUNT(*) FROM trips t WHERE t.user_id = u.id) as trip_count
 		FROM users u
-		LEFT JOIN packs p ON u.id = p.user_id
-		GROUP BY u.id, u.username, u.email, u.currency, u.is_admin, u.is_activated, u.created_at, u.updated_at, u.last_seen
-		ORDER BY u.created_at ASC
+		WHERE ` + userSearchFilter + `
+		ORDER BY u.created_at ASC, u.id ASC
+		LIMIT ? OFFSET ?
 	`
 
-	rows, err := db.Query(query)
+	args := append(userSearchArgs(query), limit, offset)
+	rows, err := db.Query(sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query users with stats: %w", err)
 	}
@@ -134,6 +155,7 @@ func GetAllUsersWithStats(db *sql.DB) ([]UserWithStats, error) {
 			&user.Currency,
 			&user.IsAdmin,
 			&user.IsActivated,
+			&user.IsSuspended,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&user.LastSeen,
@@ -154,6 +176,36 @@ func GetAllUsersWithStats(db *sql.DB) ([]UserWithStats, error) {
 	return users, nil
 }
 
+// CountUsers returns how many users match a search query, see SearchUsersWithStats
+func CountUsers(db *sql.DB, query string) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM users u WHERE `+userSearchFilter, userSearchArgs(query)...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// AdminDeletePack deletes a pack regardless of its owner. Pack items, labels and likes
+// are removed through their ON DELETE CASCADE foreign keys.
+func AdminDeletePack(db *sql.DB, packID string) error {
+	result, err := db.Exec(`DELETE FROM packs WHERE id = ?`, packID)
+	if err != nil {
+		return fmt.Errorf("failed to delete pack: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("pack not found")
+	}
+
+	return nil
+}
+
 func ToggleUserAdmin(db *sql.DB, userID int) error {
 	query := `UPDATE users SET is_admin = NOT COALESCE(is_admin, false) WHERE id = ?`
 	_, err := db.Exec(query, userID)
@@ -282,6 +334,62 @@ func ToggleRegistration(db *sql.DB) error {
 	return nil
 }
 
+// SuspendUser blocks a user from logging in and ends their sessions, keeping their data intact
+func SuspendUser(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE users SET is_suspended = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to suspend user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM csrf_tokens WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete user CSRF tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UnsuspendUser lets a suspended user log in again
+func UnsuspendUser(db *sql.DB, userID int) error {
+	result, err := db.Exec(`UPDATE users SET is_suspended = FALSE, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unsuspend user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
 func ToggleUserActivation(db *sql.DB, userID int) error {
 	query := `UPDATE users SET is_activated = NOT COALESCE(is_activated, false), updated_at = CURRENT_TIMESTAMP WHERE id = ?`
 	result, err := db.Exec(query, userID)
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"carryless/internal/models"
+)
+
+// APITokenPrefix starts every API token so leaked tokens are easy to recognize
+const APITokenPrefix = "cl_"
+
+// maxAPITokensPerUser bounds how many API tokens a user can hold at once
+const maxAPITokensPerUser = 20
+
+// apiTokenUseInterval is how stale last_used_at can get before a request updates it, so
+// scripts calling the API in a loop don't write on every request
+const apiTokenUseInterval = time.Minute
+
+// CreateAPIToken issues a new API token for the user. The token is returned in clear only
+// here: it can't be recovered later.
+func CreateAPIToken(db *sql.DB, userID int, name string) (string, *models.APIToken, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM api_tokens WHERE user_id = ?", userID).Scan(&count); err != nil {
+		return "", nil, fmt.Errorf("failed to count api tokens: %w", err)
+	}
+	if count >= maxAPITokensPerUser {
+		return "", nil, fmt.Errorf("too many api tokens")
+	}
+
+	secret, err := generateSecureToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate api token: %w", err)
+	}
+	token := APITokenPrefix + secret
+
+	result, err := db.Exec(`
+		INSERT INTO api_tokens (user_id, name, token_hash) VALUES (?, ?, ?)
+	`, userID, name, hashAPIToken(token))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create api token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get api token ID: %w", err)
+	}
+
+	return token, &models.APIToken{
+		ID:        int(id),
+		UserID:    userID,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// GetAPITokens lists the user's API tokens, newest first
+func GetAPITokens(db *sql.DB, userID int) ([]models.APIToken, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, name, last_used_at, created_at
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		var token models.APIToken
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &lastUsedAt, &token.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan api token: %w", err)
+		}
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// DeleteAPIToken revokes one of the user's API tokens
+func DeleteAPIToken(db *sql.DB, userID, tokenID int) error {
+	result, err := db.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete api token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("api token not found")
+	}
+
+	return nil
+}
+
+// ValidateAPIToken returns the user the token was issued to, and records that it was used
+func ValidateAPIToken(db *sql.DB, token string) (*models.User, *models.APIToken, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return nil, nil, fmt.Errorf("invalid api token")
+	}
+
+	apiToken := &models.APIToken{}
+	var lastUsedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT id, user_id, name, last_used_at, created_at
+		FROM api_tokens
+		WHERE token_hash = ?
+	`, hashAPIToken(token)).Scan(&apiToken.ID, &apiToken.UserID, &apiToken.Name, &lastUsedAt, &apiToken.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("invalid api token")
+		}
+		return nil, nil, fmt.Errorf("failed to query api token: %w", err)
+	}
+
+	now := time.Now()
+	if !lastUsedAt.Valid || now.Sub(lastUsedAt.Time) > apiTokenUseInterval {
+		if _, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, apiToken.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to record api token use: %w", err)
+		}
+		lastUsedAt = sql.NullTime{Time: now, Valid: true}
+	}
+	apiToken.LastUsedAt = &lastUsedAt.Time
+
+	user, err := GetUserByID(db, apiToken.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, apiToken, nil
+}
+
+// hashAPIToken hashes an API token for storage. Tokens are random, so a fast unsalted hash
+// is enough.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Admin actions recorded in the audit log
+const (
+	AuditActionToggleAdmin        = "toggle_admin"
+	AuditActionBanUser            = "ban_user"
+	AuditActionToggleActivation   = "toggle_activation"
+	AuditActionToggleRegistration = "toggle_registration"
+	AuditActionSuspendUser        = "suspend_user"
+	AuditActionUnsuspendUser      = "unsuspend_user"
+	AuditActionDeletePack         = "delete_pack"
+	AuditActionCreateUser         = "create_user"
+	AuditActionToggleInviteMode   = "toggle_invite_mode"
+	AuditActionCreateInvite       = "create_invite"
+	AuditActionRevokeInvite       = "revoke_invite"
+)
+
+type AuditEvent struct {
+	ID            int       `json:"id"`
+	ActorID       int       `json:"actor_id"`
+	ActorUsername string    `json:"actor_username"`
+	Action        string    `json:"action"`
+	TargetID      string    `json:"target_id"`
+	Detail        string    `json:"detail"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// RecordAuditEvent stores an admin action. targetID identifies the user or pack acted upon
+// and is empty for instance-wide actions.
+func RecordAuditEvent(db *sql.DB, actorID int, action, targetID, detail string) error {
+	query := `
+		INSERT INTO admin_audit_log (actor_id, action, target_id, detail)
+		VALUES (?, ?, ?, ?)
+	`
+
+	_, err := db.Exec(query, actorID, action, targetID, detail)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditEvents returns a page of the audit log, newest first
+func GetAuditEvents(db *sql.DB, limit, offset int) ([]AuditEvent, error) {
+	query := `
+		SELECT a.id, a.actor_id, COALESCE(u.username, ''), a.action, COALESCE(a.target_id, ''), COALESCE(a.detail, ''), a.created_at
+		FROM admin_audit_log a
+		LEFT JOIN users u ON a.actor_id = u.id
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := db.Query(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var event AuditEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.ActorID,
+			&event.ActorUsername,
+			&event.Action,
+			&event.TargetID,
+			&event.Detail,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+func CountAuditEvents(db *sql.DB) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM admin_audit_log`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+	return count, nil
+}
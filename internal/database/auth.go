@@ -3,8 +3,10 @@ package database
 import (
 	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	"carryless/internal/logger"
@@ -17,8 +19,8 @@ import (
 func GetUserByID(db *sql.DB, userID int) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, email, password_hash, COALESCE(currency, '$'), COALESCE(is_admin, false),
-		       COALESCE(is_activated, false), created_at, updated_at
+		SELECT id, username, email, password_hash, COALESCE(currency, '$'), COALESCE(weight_unit, 'g'), COALESCE(is_admin, false),
+		       COALESCE(is_activated, false), COALESCE(is_suspended, false), created_at, updated_at
 		FROM users
 		WHERE id = ?
 	`
@@ -29,8 +31,10 @@ func GetUserByID(db *sql.DB, userID int) (*models.User, error) {
 		&user.Email,
 		&user.PasswordHash,
 		&user.Currency,
+		&user.WeightUnit,
 		&user.IsAdmin,
 		&user.IsActivated,
+		&user.IsSuspended,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -50,9 +54,28 @@ func CreateUser(db *sql.DB, username, email, password string) (*models.User, err
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// Check if this is the first user
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	user, err := insertUser(tx, username, email, string(hashedPassword))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// insertUser adds a not yet activated user. The first user of the instance becomes admin.
+func insertUser(tx *sql.Tx, username, email, hashedPassword string) (*models.User, error) {
 	var userCount int
-	err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount)
+	err := tx.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count users: %w", err)
 	}
@@ -64,7 +87,7 @@ func CreateUser(db *sql.DB, username, email, password string) (*models.User, err
 		VALUES (?, ?, ?, ?, ?)
 	`
 
-	result, err := db.Exec(query, username, email, string(hashedPassword), isAdmin, false)
+	result, err := tx.Exec(query, username, email, hashedPassword, isAdmin, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -78,7 +101,7 @@ func CreateUser(db *sql.DB, username, email, password string) (*models.User, err
 		ID:           int(id),
 		Username:     username,
 		Email:        email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 		IsAdmin:      isAdmin,
 		IsActivated:  false,
 		CreatedAt:    time.Now(),
@@ -88,10 +111,37 @@ func CreateUser(db *sql.DB, username, email, password string) (*models.User, err
 	return user, nil
 }
 
+func GetUserByEmail(db *sql.DB, email string) (*models.User, error) {
+	user := &models.User{}
+	query := `
+		SELECT id, username, email, COALESCE(is_activated, false), COALESCE(is_suspended, false), created_at, updated_at
+		FROM users
+		WHERE email = ?
+	`
+
+	err := db.QueryRow(query, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.IsActivated,
+		&user.IsSuspended,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+
+	return user, nil
+}
+
 func AuthenticateUser(db *sql.DB, email, password string) (*models.User, error) {
 	user := &models.User{}
 	query := `
-		SELECT id, username, email, password_hash, COALESCE(is_admin, false), COALESCE(is_activated, false), created_at, updated_at
+		SELECT id, username, email, password_hash, COALESCE(weight_unit, 'g'), COALESCE(is_admin, false), COALESCE(is_activated, false), COALESCE(is_suspended, false), created_at, updated_at
 		FROM users
 		WHERE email = ?
 	`
@@ -101,8 +151,10 @@ func AuthenticateUser(db *sql.DB, email, password string) (*models.User, error)
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
+		&user.WeightUnit,
 		&user.IsAdmin,
 		&user.IsActivated,
+		&user.IsSuspended,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -129,12 +181,13 @@ func CreateSession(db *sql.DB, userID int, sessionDuration time.Duration) (*mode
 
 	expiresAt := time.Now().Add(sessionDuration)
 
+	// The duration is kept with the session so renewals extend it by the same amount
 	query := `
-		INSERT INTO sessions (id, user_id, expires_at)
-		VALUES (?, ?, ?)
+		INSERT INTO sessions (id, user_id, expires_at, duration_seconds)
+		VALUES (?, ?, ?, ?)
 	`
 
-	_, err = db.Exec(query, sessionID, userID, expiresAt)
+	_, err = db.Exec(query, sessionID, userID, expiresAt, int64(sessionDuration.Seconds()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -149,11 +202,16 @@ func CreateSession(db *sql.DB, userID int, sessionDuration time.Duration) (*mode
 	return session, nil
 }
 
-func ValidateSession(db *sql.DB, sessionID string, sessionDuration time.Duration) (*models.User, error) {
+// ValidateSession returns the user owning an unexpired session. Sessions with less than
+// extensionThreshold left are extended so active users stay logged in; the returned duration
+// is how long the session was extended for, or zero when it was left untouched.
+func ValidateSession(db *sql.DB, sessionID string, sessionDuration, extensionThreshold time.Duration) (*models.User, time.Duration, error) {
 	user := &models.User{}
 	var lastSeen sql.NullTime
+	var durationSeconds int64
+	var expiresAt time.Time
 	query := `
-		SELECT u.id, u.username, u.email, COALESCE(u.currency, '$'), COALESCE(u.is_admin, false), COALESCE(u.is_activated, false), u.created_at, u.updated_at, u.last_seen
+		SELECT u.id, u.username, u.email, COALESCE(u.currency, '$'), COALESCE(u.weight_unit, 'g'), COALESCE(u.is_admin, false), COALESCE(u.is_activated, false), COALESCE(u.is_suspended, false), u.created_at, u.updated_at, u.last_seen, COALESCE(s.duration_seconds, 0), s.expires_at
 		FROM users u
 		INNER JOIN sessions s ON u.id = s.user_id
 		WHERE s.id = ? AND s.expires_at > CURRENT_TIMESTAMP
@@ -164,27 +222,26 @@ func ValidateSession(db *sql.DB, sessionID string, sessionDuration time.Duration
 		&user.Username,
 		&user.Email,
 		&user.Currency,
+		&user.WeightUnit,
 		&user.IsAdmin,
 		&user.IsActivated,
+		&user.IsSuspended,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&lastSeen,
+		&durationSeconds,
+		&expiresAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("session not found or expired")
+			return nil, 0, fmt.Errorf("session not found or expired")
 		}
-		return nil, fmt.Errorf("failed to validate session: %w", err)
+		return nil, 0, fmt.Errorf("failed to validate session: %w", err)
 	}
 
-	// Update last_seen if it's been more than 5 minutes since the last update
-	now := time.Now()
-	shouldUpdateLastSeen := !lastSeen.Valid || now.Sub(lastSeen.Time) > 5*time.Minute
-
-	if shouldUpdateLastSeen {
-		updateLastSeenQuery := `UPDATE users SET last_seen = CURRENT_TIMESTAMP WHERE id = ?`
-		_, err = db.Exec(updateLastSeenQuery, user.ID)
-		if err != nil {
+	// Update last_seen if it's been more than a few minutes since the last update
+	if !lastSeen.Valid || time.Since(lastSeen.Time) > lastSeenUpdateInterval {
+		if err := UpdateLastSeen(db, user.ID); err != nil {
 			// Log but don't fail the request if we can't update last_seen
 			logger.Warn("Failed to update last_seen",
 				"user_id", user.ID,
@@ -192,14 +249,37 @@ func ValidateSession(db *sql.DB, sessionID string, sessionDuration time.Duration
 		}
 	}
 
+	// Only write when the session nears expiry, not on every request
+	if time.Until(expiresAt) >= extensionThreshold {
+		return user, 0, nil
+	}
+
+	// Sessions created with their own duration ("remember me") keep it when renewed
+	if durationSeconds > 0 {
+		sessionDuration = time.Duration(durationSeconds) * time.Second
+	}
+
 	err = RenewSession(db, sessionID, sessionDuration)
 	if err != nil {
 		logger.Warn("Failed to renew session",
 			"session_id", sessionID,
 			"error", err)
+		return user, 0, nil
 	}
 
-	return user, nil
+	return user, sessionDuration, nil
+}
+
+// lastSeenUpdateInterval throttles last_seen writes so active users don't cause a write per request
+const lastSeenUpdateInterval = 5 * time.Minute
+
+// UpdateLastSeen records that the user was just active
+func UpdateLastSeen(db *sql.DB, userID int) error {
+	_, err := db.Exec(`UPDATE users SET last_seen = CURRENT_TIMESTAMP WHERE id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update last_seen: %w", err)
+	}
+	return nil
 }
 
 func VerifyPassword(db *sql.DB, userID int, password string) error {
@@ -243,6 +323,16 @@ func UpdateUserCurrency(db *sql.DB, userID int, currency string) error {
 	return nil
 }
 
+func UpdateUserWeightUnit(db *sql.DB, userID int, weightUnit string) error {
+	query := "UPDATE users SET weight_unit = ? WHERE id = ?"
+	_, err := db.Exec(query, weightUnit, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update weight unit: %w", err)
+	}
+
+	return nil
+}
+
 func UpdateUsername(db *sql.DB, userID int, username string) error {
 	query := "UPDATE users SET username = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?"
 	_, err := db.Exec(query, username, userID)
@@ -254,7 +344,6 @@ func UpdateUsername(db *sql.DB, userID int, username string) error {
 }
 
 func RenewSession(db *sql.DB, sessionID string, sessionDuration time.Duration) error {
-	// Implementing sliding window - always extend on activity
 	now := time.Now()
 	newExpiresAt := now.Add(sessionDuration)
 
@@ -276,15 +365,82 @@ func DeleteSession(db *sql.DB, sessionID string) error {
 	return nil
 }
 
-func CleanupExpiredSessions(db *sql.DB) error {
-	query := `DELETE FROM sessions WHERE expires_at < CURRENT_TIMESTAMP`
-	_, err := db.Exec(query)
+// sessionDisplayIDLength is how many characters of a session ID are exposed to the user.
+// The full ID is a credential and never leaves the session cookie.
+const sessionDisplayIDLength = 16
+
+// SessionDisplayID returns the truncated form of a session ID used in the account page
+func SessionDisplayID(sessionID string) string {
+	if len(sessionID) <= sessionDisplayIDLength {
+		return sessionID
+	}
+	return sessionID[:sessionDisplayIDLength]
+}
+
+// GetUserSessions returns the active sessions of a user, newest first, with truncated IDs
+func GetUserSessions(db *sql.DB, userID int) ([]models.Session, error) {
+	query := `
+		SELECT id, user_id, expires_at, created_at
+		FROM sessions
+		WHERE user_id = ? AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY created_at DESC
+	`
+
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.ExpiresAt, &session.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		session.ID = SessionDisplayID(session.ID)
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// DeleteUserSession deletes a session by its truncated ID, only if it belongs to the user
+func DeleteUserSession(db *sql.DB, userID int, sessionID string) error {
+	if len(sessionID) != sessionDisplayIDLength {
+		return fmt.Errorf("session not found")
+	}
+
+	query := `DELETE FROM sessions WHERE user_id = ? AND substr(id, 1, ?) = ?`
+	result, err := db.Exec(query, userID, sessionDisplayIDLength, sessionID)
 	if err != nil {
-		return fmt.Errorf("failed to cleanup expired sessions: %w", err)
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("session not found")
 	}
+
 	return nil
 }
 
+// CleanupExpiredSessions removes expired sessions and returns how many were removed
+func CleanupExpiredSessions(db *sql.DB) (int64, error) {
+	// Compare against a Go time so the stored expiry format and time zone match
+	query := `DELETE FROM sessions WHERE expires_at < ?`
+	result, err := db.Exec(query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 func CreateCSRFToken(db *sql.DB, userID int) (*models.CSRFToken, error) {
 	token, err := generateSecureToken()
 	if err != nil {
@@ -338,13 +494,15 @@ func ValidateCSRFToken(db *sql.DB, token string, userID int) error {
 	return nil
 }
 
-func CleanupExpiredCSRFTokens(db *sql.DB) error {
-	query := `DELETE FROM csrf_tokens WHERE expires_at < CURRENT_TIMESTAMP`
-	_, err := db.Exec(query)
+// CleanupExpiredCSRFTokens removes expired CSRF tokens and returns how many were removed
+func CleanupExpiredCSRFTokens(db *sql.DB) (int64, error) {
+	// Compare against a Go time so the stored expiry format and time zone match
+	query := `DELETE FROM csrf_tokens WHERE expires_at < ?`
+	result, err := db.Exec(query, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to cleanup expired CSRF tokens: %w", err)
+		return 0, fmt.Errorf("failed to cleanup expired CSRF tokens: %w", err)
 	}
-	return nil
+	return result.RowsAffected()
 }
 
 func generateSecureToken() (string, error) {
@@ -355,20 +513,50 @@ func generateSecureToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// GenerateTemporaryPassword returns a random password for accounts created on someone's behalf,
+// to be changed by its owner after the first login
+func GenerateTemporaryPassword() (string, error) {
+	bytes := make([]byte, 12)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// activationTokenLifetime matches the expiry announced in the welcome email
+const activationTokenLifetime = 24 * time.Hour
+
+// CreateActivationToken issues a new activation token for the user. Previous tokens are
+// invalidated so only the most recently sent link works.
 func CreateActivationToken(db *sql.DB, userID int) (*models.ActivationToken, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM activation_tokens WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete old activation tokens: %w", err)
+	}
+
 	tokenUUID := uuid.New().String()
-	expiresAt := time.Now().Add(24 * time.Hour)
+	expiresAt := time.Now().Add(activationTokenLifetime)
 
 	query := `
 		INSERT INTO activation_tokens (token, user_id, expires_at)
 		VALUES (?, ?, ?)
 	`
 
-	_, err := db.Exec(query, tokenUUID, userID, expiresAt)
+	_, err = tx.Exec(query, tokenUUID, userID, expiresAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create activation token: %w", err)
 	}
 
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	token := &models.ActivationToken{
 		Token:     tokenUUID,
 		UserID:    userID,
@@ -381,13 +569,14 @@ func CreateActivationToken(db *sql.DB, userID int) (*models.ActivationToken, err
 
 func ValidateActivationToken(db *sql.DB, token string) (*models.User, error) {
 	query := `
-		SELECT u.id, u.username, u.email, u.password_hash, COALESCE(u.is_admin, false), COALESCE(u.is_activated, false), u.created_at, u.updated_at
+		SELECT u.id, u.username, u.email, u.password_hash, COALESCE(u.is_admin, false), COALESCE(u.is_activated, false), u.created_at, u.updated_at, at.expires_at
 		FROM users u
 		JOIN activation_tokens at ON u.id = at.user_id
-		WHERE at.token = ? AND at.expires_at > CURRENT_TIMESTAMP
+		WHERE at.token = ?
 	`
 
 	user := &models.User{}
+	var expiresAt time.Time
 	err := db.QueryRow(query, token).Scan(
 		&user.ID,
 		&user.Username,
@@ -397,14 +586,20 @@ func ValidateActivationToken(db *sql.DB, token string) (*models.User, error) {
 		&user.IsActivated,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&expiresAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("activation token not found or expired")
+			return nil, fmt.Errorf("activation token not found")
 		}
 		return nil, fmt.Errorf("failed to validate activation token: %w", err)
 	}
 
+	// Expired tokens are reported separately so the user can be told to request a new link
+	if time.Now().After(expiresAt) {
+		return nil, fmt.Errorf("activation token expired")
+	}
+
 	return user, nil
 }
 
@@ -434,55 +629,132 @@ func ActivateUser(db *sql.DB, userID int, token string) error {
 	return nil
 }
 
+// ResendActivationToken replaces the user's activation tokens with a fresh one
 func ResendActivationToken(db *sql.DB, userID int) (*models.ActivationToken, error) {
-	// Start a transaction to ensure atomicity
+	return CreateActivationToken(db, userID)
+}
+
+// emailChangeTokenLifetime is how long the confirmation link for a new email address stays valid
+const emailChangeTokenLifetime = 24 * time.Hour
+
+// RequestEmailChange records a pending change of the user's email to newEmail and returns the
+// token to confirm it with. Only the latest request per user stays valid.
+func RequestEmailChange(db *sql.DB, userID int, newEmail string) (*models.EmailChangeToken, error) {
+	var currentEmail string
+	err := db.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&currentEmail)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user email: %w", err)
+	}
+
+	if strings.EqualFold(currentEmail, newEmail) {
+		return nil, fmt.Errorf("email unchanged")
+	}
+
+	if err := checkEmailAvailable(db, userID, newEmail); err != nil {
+		return nil, err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Delete any existing activation tokens for this user
-	deleteQuery := `DELETE FROM activation_tokens WHERE user_id = ?`
-	_, err = tx.Exec(deleteQuery, userID)
+	_, err = tx.Exec("DELETE FROM email_change_tokens WHERE user_id = ?", userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete old activation tokens: %w", err)
+		return nil, fmt.Errorf("failed to delete old email change tokens: %w", err)
 	}
 
-	// Generate new activation token
 	tokenUUID := uuid.New().String()
-	expiresAt := time.Now().Add(24 * time.Hour)
+	expiresAt := time.Now().Add(emailChangeTokenLifetime)
 
-	// Insert new token
-	insertQuery := `
-		INSERT INTO activation_tokens (token, user_id, expires_at)
-		VALUES (?, ?, ?)
-	`
-	_, err = tx.Exec(insertQuery, tokenUUID, userID, expiresAt)
+	_, err = tx.Exec(`
+		INSERT INTO email_change_tokens (token, user_id, new_email, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, tokenUUID, userID, newEmail, expiresAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create new activation token: %w", err)
+		return nil, fmt.Errorf("failed to create email change token: %w", err)
 	}
 
-	// Commit transaction
 	if err = tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	token := &models.ActivationToken{
+	return &models.EmailChangeToken{
 		Token:     tokenUUID,
 		UserID:    userID,
+		NewEmail:  newEmail,
 		ExpiresAt: expiresAt,
 		CreatedAt: time.Now(),
+	}, nil
+}
+
+// ConfirmEmailChange applies the pending email change for the token and returns the updated user
+func ConfirmEmailChange(db *sql.DB, token string) (*models.User, error) {
+	var userID int
+	var newEmail string
+	err := db.QueryRow(`
+		SELECT user_id, new_email FROM email_change_tokens
+		WHERE token = ? AND expires_at > CURRENT_TIMESTAMP
+	`, token).Scan(&userID, &newEmail)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("email change token not found or expired")
+		}
+		return nil, fmt.Errorf("failed to validate email change token: %w", err)
 	}
 
-	return token, nil
+	// The address may have been registered by someone else since the change was requested
+	if err := checkEmailAvailable(db, userID, newEmail); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET email = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", newEmail, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update email: %w", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM email_change_tokens WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete email change tokens: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit email change: %w", err)
+	}
+
+	return GetUserByID(db, userID)
 }
 
-func CleanupExpiredActivationTokens(db *sql.DB) error {
-	query := `DELETE FROM activation_tokens WHERE expires_at < CURRENT_TIMESTAMP`
-	_, err := db.Exec(query)
+func checkEmailAvailable(db *sql.DB, userID int, email string) error {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM users WHERE LOWER(email) = LOWER(?) AND id != ?", email, userID).Scan(&count)
 	if err != nil {
-		return fmt.Errorf("failed to cleanup expired activation tokens: %w", err)
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("email already in use")
 	}
 	return nil
+}
+
+// CleanupExpiredActivationTokens removes activation links that can no longer be used and
+// returns how many were removed
+func CleanupExpiredActivationTokens(db *sql.DB) (int64, error) {
+	// Compare against a Go time so the stored expiry format and time zone match
+	query := `DELETE FROM activation_tokens WHERE expires_at < ?`
+	result, err := db.Exec(query, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired activation tokens: %w", err)
+	}
+	return result.RowsAffected()
 }
\ No newline at end of file
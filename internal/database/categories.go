@@ -29,13 +29,25 @@ func normalizeCategoryName(name string) string {
 func CreateCategory(db *sql.DB, userID int, name string) (*models.Category, error) {
 	// Normalize the category name to title case
 	normalizedName := normalizeCategoryName(name)
-	
+
+	// Once the user has ordered their categories, new ones go last. Until then they all
+	// stay at 0 and sort by name.
+	var sortOrder int
+	err := db.QueryRow(`
+		SELECT CASE WHEN MAX(sort_order) > 0 THEN MAX(sort_order) + 1 ELSE 0 END
+		FROM categories
+		WHERE user_id = ?
+	`, userID).Scan(&sortOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category sort order: %w", err)
+	}
+
 	query := `
-		INSERT INTO categories (user_id, name)
-		VALUES (?, ?)
+		INSERT INTO categories (user_id, name, sort_order)
+		VALUES (?, ?, ?)
 	`
 
-	result, err := db.Exec(query, userID, normalizedName)
+	result, err := db.Exec(query, userID, normalizedName, sortOrder)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create category: %w", err)
 	}
@@ -46,9 +58,10 @@ func CreateCategory(db *sql.DB, userID int, name string) (*models.Category, erro
 	}
 
 	category := &models.Category{
-		ID:     int(id),
-		UserID: userID,
-		Name:   normalizedName,
+		ID:        int(id),
+		UserID:    userID,
+		Name:      normalizedName,
+		SortOrder: sortOrder,
 	}
 
 	return category, nil
@@ -56,10 +69,10 @@ func CreateCategory(db *sql.DB, userID int, name string) (*models.Category, erro
 
 func GetCategories(db *sql.DB, userID int) ([]models.Category, error) {
 	query := `
-		SELECT id, user_id, name, created_at, updated_at
+		SELECT id, user_id, name, sort_order, created_at, updated_at
 		FROM categories
 		WHERE user_id = ?
-		ORDER BY name
+		ORDER BY sort_order, name
 	`
 
 	rows, err := db.Query(query, userID)
@@ -75,6 +88,7 @@ func GetCategories(db *sql.DB, userID int) ([]models.Category, error) {
 			&category.ID,
 			&category.UserID,
 			&category.Name,
+			&category.SortOrder,
 			&category.CreatedAt,
 			&category.UpdatedAt,
 		)
@@ -94,7 +108,7 @@ func GetCategories(db *sql.DB, userID int) ([]models.Category, error) {
 func GetCategory(db *sql.DB, userID, categoryID int) (*models.Category, error) {
 	category := &models.Category{}
 	query := `
-		SELECT id, user_id, name, created_at, updated_at
+		SELECT id, user_id, name, sort_order, created_at, updated_at
 		FROM categories
 		WHERE id = ? AND user_id = ?
 	`
@@ -103,6 +117,7 @@ func GetCategory(db *sql.DB, userID, categoryID int) (*models.Category, error) {
 		&category.ID,
 		&category.UserID,
 		&category.Name,
+		&category.SortOrder,
 		&category.CreatedAt,
 		&category.UpdatedAt,
 	)
@@ -146,7 +161,7 @@ func DeleteCategory(db *sql.DB, userID, categoryID int) error {
 
 func DeleteCategoryWithForce(db *sql.DB, userID, categoryID int, force bool) error {
 	var itemCount int
-	countQuery := `SELECT COUNT(*) FROM items WHERE category_id = ? AND user_id = ?`
+	countQuery := `SELECT COUNT(*) FROM items WHERE category_id = ? AND user_id = ? AND deleted_at IS NULL`
 	err := db.QueryRow(countQuery, categoryID, userID).Scan(&itemCount)
 	if err != nil {
 		return fmt.Errorf("failed to check items in category: %w", err)
@@ -156,24 +171,21 @@ func DeleteCategoryWithForce(db *sql.DB, userID, categoryID int, force bool) err
 		return fmt.Errorf("cannot delete category with %d items", itemCount)
 	}
 
-	// If force is true and category has items, delete all items first
-	if force && itemCount > 0 {
-		// First remove items from any packs
-		removeFromPacksQuery := `
-			DELETE FROM pack_items 
-			WHERE item_id IN (SELECT id FROM items WHERE category_id = ? AND user_id = ?)
-		`
-		_, err := db.Exec(removeFromPacksQuery, categoryID, userID)
-		if err != nil {
-			return fmt.Errorf("failed to remove items from packs: %w", err)
-		}
+	// Delete the category's items first, including any still in the trash.
+	// Remove them from any packs before deleting the items themselves
+	removeFromPacksQuery := `
+		DELETE FROM pack_items 
+		WHERE item_id IN (SELECT id FROM items WHERE category_id = ? AND user_id = ?)
+	`
+	_, err = db.Exec(removeFromPacksQuery, categoryID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove items from packs: %w", err)
+	}
 
-		// Then delete all items in the category
-		deleteItemsQuery := `DELETE FROM items WHERE category_id = ? AND user_id = ?`
-		_, err = db.Exec(deleteItemsQuery, categoryID, userID)
-		if err != nil {
-			return fmt.Errorf("failed to delete items in category: %w", err)
-		}
+	deleteItemsQuery := `DELETE FROM items WHERE category_id = ? AND user_id = ?`
+	_, err = db.Exec(deleteItemsQuery, categoryID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete items in category: %w", err)
 	}
 
 	query := `
@@ -198,11 +210,44 @@ func DeleteCategoryWithForce(db *sql.DB, userID, categoryID int, force bool) err
 	return nil
 }
 
+// ReorderCategories sets the order categories are listed in, in the inventory and in packs
+func ReorderCategories(db *sql.DB, userID int, categoryIDs []int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE categories SET sort_order = ? WHERE id = ? AND user_id = ?`
+
+	for i, categoryID := range categoryIDs {
+		result, err := tx.Exec(query, i, categoryID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to update sort order: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("category not found")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
 func GetItemsInCategory(db *sql.DB, userID, categoryID int) ([]models.ItemInfo, error) {
 	query := `
 		SELECT name, note 
 		FROM items 
-		WHERE category_id = ? AND user_id = ?
+		WHERE category_id = ? AND user_id = ? AND deleted_at IS NULL
 		ORDER BY name
 	`
 	
@@ -249,4 +294,34 @@ func GetOrCreateCategory(db *sql.DB, userID int, name string) (*models.Category,
 	
 	// Category doesn't exist, create it with normalized case (Title case)
 	return CreateCategory(db, userID, normalizedName)
-}
\ No newline at end of file
+}
+
+// getOrCreateCategoryTx is GetOrCreateCategory inside a transaction, so a category created
+// for an import that fails is rolled back with it
+func getOrCreateCategoryTx(tx *sql.Tx, userID int, name string) (int, error) {
+	normalizedName := normalizeCategoryName(name)
+
+	var categoryID int
+	err := tx.QueryRow(`SELECT id FROM categories WHERE user_id = ? AND LOWER(name) = LOWER(?)`, userID, normalizedName).Scan(&categoryID)
+	if err == nil {
+		return categoryID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to query category: %w", err)
+	}
+
+	// New categories go last once the user has ordered them, like CreateCategory
+	result, err := tx.Exec(`
+		INSERT INTO categories (user_id, name, sort_order)
+		SELECT ?, ?, COALESCE((SELECT CASE WHEN MAX(sort_order) > 0 THEN MAX(sort_order) + 1 ELSE 0 END FROM categories WHERE user_id = ?), 0)
+	`, userID, normalizedName, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create category: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get category ID: %w", err)
+	}
+	return int(id), nil
+}
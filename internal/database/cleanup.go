@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"carryless/internal/logger"
+)
+
+// defaultCleanupInterval is used when the configured interval isn't positive
+const defaultCleanupInterval = time.Hour
+
+// cleanupTasks remove rows that have expired and can never be used again
+var cleanupTasks = []struct {
+	name string
+	run  func(db *sql.DB) (int64, error)
+}{
+	{"sessions", CleanupExpiredSessions},
+	{"csrf_tokens", CleanupExpiredCSRFTokens},
+	{"activation_tokens", CleanupExpiredActivationTokens},
+	{"invite_tokens", CleanupExpiredInviteTokens},
+	{"login_challenges", CleanupExpiredLoginChallenges},
+}
+
+// RunCleanup removes expired sessions, tokens and login challenges, logging how many rows
+// each cleanup removed. A failing cleanup doesn't stop the others.
+func RunCleanup(db *sql.DB) {
+	for _, task := range cleanupTasks {
+		removed, err := task.run(db)
+		if err != nil {
+			logger.Warn("Failed to cleanup expired rows", "table", task.name, "error", err)
+			continue
+		}
+		if removed > 0 {
+			logger.Info("Cleaned up expired rows", "table", task.name, "removed", removed)
+		}
+	}
+}
+
+// StartCleanupJob runs RunCleanup right away and then every interval, in the background.
+// The returned function stops the job and waits for a cleanup in progress to finish.
+func StartCleanupJob(db *sql.DB, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		RunCleanup(db)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				RunCleanup(db)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
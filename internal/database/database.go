@@ -1,20 +1,27 @@
 package database
 
 import (
-	"crypto/rand"
 	"database/sql"
 	"fmt"
-	"math/big"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func Initialize(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+// Initialize opens the SQLite database in WAL mode, so reads don't wait for writes, and
+// makes connections wait up to 5s for a lock instead of failing with "database is locked".
+// SQLite still allows a single writer at a time: more open connections help concurrent
+// reads, not writes. A maxOpenConns of 0 leaves the pool unlimited.
+func Initialize(dbPath string, maxOpenConns, maxIdleConns int) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// Queries close their rows before the next one runs, so a request only ever holds one
+	// connection and a capped pool makes requests queue, not deadlock
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
@@ -116,6 +123,11 @@ func Migrate(db *sql.DB) error {
 		return fmt.Errorf("failed to add currency column: %w", err)
 	}
 
+	// Add weight_unit column to users table if it doesn't exist
+	if err := addUserWeightUnitColumn(db); err != nil {
+		return fmt.Errorf("failed to add weight_unit column: %w", err)
+	}
+
 	// Remove purchase_date column from items table if it exists
 	if err := removePurchaseDateColumn(db); err != nil {
 		return fmt.Errorf("failed to remove purchase_date column: %w", err)
@@ -196,6 +208,141 @@ func Migrate(db *sql.DB) error {
 		return fmt.Errorf("failed to create user_pack_labels tables: %w", err)
 	}
 
+	// Add is_consumable column to pack_items table if it doesn't exist
+	if err := addPackItemIsConsumableColumn(db); err != nil {
+		return fmt.Errorf("failed to add is_consumable column to pack_items: %w", err)
+	}
+
+	// Add GPX stats columns to trips table if they don't exist
+	if err := addTripGPXStatsColumns(db); err != nil {
+		return fmt.Errorf("failed to add GPX stats columns to trips: %w", err)
+	}
+
+	// Create pack weight history table
+	if err := createPackWeightSnapshotsTable(db); err != nil {
+		return fmt.Errorf("failed to create pack weight snapshots table: %w", err)
+	}
+
+	// Add sort_order column to pack_items table if it doesn't exist
+	if err := addPackItemSortOrderColumn(db); err != nil {
+		return fmt.Errorf("failed to add sort_order column to pack_items: %w", err)
+	}
+
+	// Add deleted_at column to items table if it doesn't exist
+	if err := addItemDeletedAtColumn(db); err != nil {
+		return fmt.Errorf("failed to add deleted_at column to items: %w", err)
+	}
+
+	// Add is_template column to packs table if it doesn't exist
+	if err := addPackIsTemplateColumn(db); err != nil {
+		return fmt.Errorf("failed to add is_template column to packs: %w", err)
+	}
+
+	// Add image_path column to items table if it doesn't exist
+	if err := addItemImagePathColumn(db); err != nil {
+		return fmt.Errorf("failed to add image_path column to items: %w", err)
+	}
+
+	// Add is_favorite column to packs table if it doesn't exist
+	if err := addPackIsFavoriteColumn(db); err != nil {
+		return fmt.Errorf("failed to add is_favorite column to packs: %w", err)
+	}
+
+	// Add cost and currency columns to trip_transport_steps table if they don't exist
+	if err := addTransportStepCostColumns(db); err != nil {
+		return fmt.Errorf("failed to add cost columns to trip_transport_steps: %w", err)
+	}
+
+	// Create pack likes table if it doesn't exist
+	if err := createPackLikesTable(db); err != nil {
+		return fmt.Errorf("failed to create pack_likes table: %w", err)
+	}
+
+	// Add duration_seconds column to sessions table if it doesn't exist
+	if err := addSessionDurationColumn(db); err != nil {
+		return fmt.Errorf("failed to add duration_seconds column: %w", err)
+	}
+
+	// Add is_suspended column to users table if it doesn't exist
+	if err := addUserIsSuspendedColumn(db); err != nil {
+		return fmt.Errorf("failed to add is_suspended column: %w", err)
+	}
+
+	// Create admin audit log table if it doesn't exist
+	if err := createAdminAuditLogTable(db); err != nil {
+		return fmt.Errorf("failed to create admin_audit_log table: %w", err)
+	}
+
+	// Create email change tokens table if it doesn't exist
+	if err := createEmailChangeTokensTable(db); err != nil {
+		return fmt.Errorf("failed to create email_change_tokens table: %w", err)
+	}
+
+	// Add note column to pack_items table if it doesn't exist
+	if err := addPackItemNoteColumn(db); err != nil {
+		return fmt.Errorf("failed to add note column to pack_items: %w", err)
+	}
+
+	// Create trip GPX files table and move single-track GPX data into it
+	if err := createTripGPXFilesTable(db); err != nil {
+		return fmt.Errorf("failed to create trip_gpx_files table: %w", err)
+	}
+
+	// Create two-factor authentication tables if they don't exist
+	if err := createTOTPTables(db); err != nil {
+		return fmt.Errorf("failed to create two-factor authentication tables: %w", err)
+	}
+
+	// Add OAuth identity columns to users table if they don't exist
+	if err := addUserOAuthColumns(db); err != nil {
+		return fmt.Errorf("failed to add OAuth columns to users: %w", err)
+	}
+
+	// Add is_anonymous column to packs table if it doesn't exist
+	if err := addPackIsAnonymousColumn(db); err != nil {
+		return fmt.Errorf("failed to add is_anonymous column to packs: %w", err)
+	}
+
+	// Create pack collaborators table if it doesn't exist
+	if err := createPackCollaboratorsTable(db); err != nil {
+		return fmt.Errorf("failed to create pack_collaborators table: %w", err)
+	}
+
+	// Regenerate duplicate trip short IDs and index them like pack short IDs
+	if err := addTripShortIDUniqueIndex(db); err != nil {
+		return fmt.Errorf("failed to add unique index on trips short_id: %w", err)
+	}
+
+	// Repair pack items left with more worn than packed by the old count decrement
+	if err := clampPackItemWornCounts(db); err != nil {
+		return fmt.Errorf("failed to clamp pack item worn counts: %w", err)
+	}
+
+	// Create API tokens table if it doesn't exist
+	if err := createAPITokensTable(db); err != nil {
+		return fmt.Errorf("failed to create api_tokens table: %w", err)
+	}
+
+	// Add sort_order column to categories table if it doesn't exist
+	if err := addCategorySortOrderColumn(db); err != nil {
+		return fmt.Errorf("failed to add category sort_order column: %w", err)
+	}
+
+	// Create invite tokens table and the invite mode setting if they don't exist
+	if err := createInviteTokensTable(db); err != nil {
+		return fmt.Errorf("failed to create invite_tokens table: %w", err)
+	}
+
+	// Add public_expires_at column to packs table if it doesn't exist
+	if err := addPackPublicExpiresAtColumn(db); err != nil {
+		return fmt.Errorf("failed to add pack public_expires_at column: %w", err)
+	}
+
+	// Create user email preferences table if it doesn't exist
+	if err := createUserEmailPrefsTable(db); err != nil {
+		return fmt.Errorf("failed to create user_email_prefs table: %w", err)
+	}
+
 	return nil
 }
 
@@ -231,6 +378,24 @@ func addUserCurrencyColumn(db *sql.DB) error {
 	return nil
 }
 
+func addUserWeightUnitColumn(db *sql.DB) error {
+	// Check if weight_unit column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('users') WHERE name='weight_unit'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = db.Exec("ALTER TABLE users ADD COLUMN weight_unit TEXT DEFAULT 'g'")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func removePurchaseDateColumn(db *sql.DB) error {
 	// Check if purchase_date column exists in items table
 	rows, err := db.Query("PRAGMA table_info(items)")
@@ -593,9 +758,6 @@ func addPackShortIDColumn(db *sql.DB) error {
 }
 
 func migrateExistingPublicPacks(db *sql.DB) error {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	const idLength = 8
-	
 	// Get all public packs without short_id
 	query := `SELECT id FROM packs WHERE is_public = 1 AND (short_id IS NULL OR short_id = '')`
 	rows, err := db.Query(query)
@@ -615,7 +777,7 @@ func migrateExistingPublicPacks(db *sql.DB) error {
 
 	// Generate short IDs for each pack
 	for _, packID := range packIDs {
-		shortID, err := generateUniqueShortID(db, charset, idLength)
+		shortID, err := generateShortID(db)
 		if err != nil {
 			return fmt.Errorf("failed to generate short ID for pack %s: %w", packID, err)
 		}
@@ -631,37 +793,6 @@ func migrateExistingPublicPacks(db *sql.DB) error {
 	return nil
 }
 
-func generateUniqueShortID(db *sql.DB, charset string, idLength int) (string, error) {
-	const maxRetries = 10
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		// Generate random ID
-		b := make([]byte, idLength)
-		for i := range b {
-			num, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
-			if err != nil {
-				return "", fmt.Errorf("failed to generate random number: %w", err)
-			}
-			b[i] = charset[num.Int64()]
-		}
-		
-		shortID := string(b)
-		
-		// Check if this ID already exists
-		var exists bool
-		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM packs WHERE short_id = ?)", shortID).Scan(&exists)
-		if err != nil {
-			return "", fmt.Errorf("failed to check short ID existence: %w", err)
-		}
-		
-		if !exists {
-			return shortID, nil
-		}
-	}
-	
-	return "", fmt.Errorf("failed to generate unique short ID after %d attempts", maxRetries)
-}
-
 func addUserActivationColumn(db *sql.DB) error {
 	rows, err := db.Query("PRAGMA table_info(users)")
 	if err != nil {
@@ -884,7 +1015,6 @@ func createTripsTable(db *sql.DB) error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (trip_id) REFERENCES trips(id) ON DELETE CASCADE
 		)`,
-		`CREATE UNIQUE INDEX IF NOT EXISTS idx_trips_short_id ON trips(short_id) WHERE short_id IS NOT NULL`,
 		`CREATE INDEX IF NOT EXISTS idx_trips_user_id ON trips(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_trip_packs_trip_id ON trip_packs(trip_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_trip_packs_pack_id ON trip_packs(pack_id)`,
@@ -1020,4 +1150,632 @@ func migrateTransportStepsDepartureArrival(db *sql.DB) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func addPackItemIsConsumableColumn(db *sql.DB) error {
+	// Check if is_consumable column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('pack_items') WHERE name='is_consumable'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = db.Exec("ALTER TABLE pack_items ADD COLUMN is_consumable BOOLEAN DEFAULT FALSE")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addTripGPXStatsColumns(db *sql.DB) error {
+	columns := []string{
+		"gpx_distance_m",
+		"gpx_ascent_m",
+		"gpx_descent_m",
+		"gpx_min_lat",
+		"gpx_min_lon",
+		"gpx_max_lat",
+		"gpx_max_lon",
+	}
+
+	for _, column := range columns {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('trips') WHERE name=?", column).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			_, err = db.Exec("ALTER TABLE trips ADD COLUMN " + column + " REAL")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func createPackWeightSnapshotsTable(db *sql.DB) error {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS pack_weight_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			pack_id TEXT NOT NULL,
+			total_grams INTEGER NOT NULL,
+			base_grams INTEGER NOT NULL,
+			recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (pack_id) REFERENCES packs(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_pack_weight_snapshots_pack_id ON pack_weight_snapshots(pack_id, recorded_at)`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addPackItemSortOrderColumn(db *sql.DB) error {
+	// Check if sort_order column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('pack_items') WHERE name='sort_order'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		// NULL means no custom order, items fall back to alphabetical order
+		_, err = db.Exec("ALTER TABLE pack_items ADD COLUMN sort_order INTEGER")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addItemDeletedAtColumn(db *sql.DB) error {
+	// Check if deleted_at column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('items') WHERE name='deleted_at'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		// NULL means the item is active, otherwise it is in the trash
+		_, err = db.Exec("ALTER TABLE items ADD COLUMN deleted_at DATETIME")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addPackIsTemplateColumn(db *sql.DB) error {
+	// Check if is_template column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('packs') WHERE name='is_template'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = db.Exec("ALTER TABLE packs ADD COLUMN is_template BOOLEAN DEFAULT FALSE")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addItemImagePathColumn(db *sql.DB) error {
+	// Check if image_path column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('items') WHERE name='image_path'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = db.Exec("ALTER TABLE items ADD COLUMN image_path TEXT")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addPackIsFavoriteColumn(db *sql.DB) error {
+	// Check if is_favorite column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('packs') WHERE name='is_favorite'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = db.Exec("ALTER TABLE packs ADD COLUMN is_favorite BOOLEAN DEFAULT FALSE")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addTransportStepCostColumns(db *sql.DB) error {
+	columns := []struct {
+		name      string
+		statement string
+	}{
+		{"cost", "ALTER TABLE trip_transport_steps ADD COLUMN cost REAL"},
+		{"currency", "ALTER TABLE trip_transport_steps ADD COLUMN currency TEXT"},
+	}
+
+	for _, column := range columns {
+		// Check if the column exists
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('trip_transport_steps') WHERE name = ?", column.name).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			if _, err := db.Exec(column.statement); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func createPackLikesTable(db *sql.DB) error {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS pack_likes (
+			pack_id TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (pack_id) REFERENCES packs(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			UNIQUE(pack_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_pack_likes_user_id ON pack_likes(user_id)`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addSessionDurationColumn(db *sql.DB) error {
+	// Check if duration_seconds column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('sessions') WHERE name = 'duration_seconds'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = db.Exec("ALTER TABLE sessions ADD COLUMN duration_seconds INTEGER")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addUserIsSuspendedColumn(db *sql.DB) error {
+	// Check if is_suspended column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('users') WHERE name = 'is_suspended'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = db.Exec("ALTER TABLE users ADD COLUMN is_suspended BOOLEAN DEFAULT FALSE")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createAdminAuditLogTable(db *sql.DB) error {
+	migrations := []string{
+		// actor_id has no foreign key so the log outlives deleted admin accounts
+		`CREATE TABLE IF NOT EXISTS admin_audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			target_id TEXT,
+			detail TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created_at ON admin_audit_log(created_at)`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createEmailChangeTokensTable(db *sql.DB) error {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS email_change_tokens (
+			token TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			new_email TEXT NOT NULL,
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_change_tokens_user_id ON email_change_tokens(user_id)`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addPackItemNoteColumn(db *sql.DB) error {
+	// Check if note column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('pack_items') WHERE name='note'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		// Notes specific to an item's use in a pack, the item's own note is left untouched
+		_, err = db.Exec("ALTER TABLE pack_items ADD COLUMN note TEXT")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createTripGPXFilesTable(db *sql.DB) error {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS trip_gpx_files (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			trip_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			gpx_data TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (trip_id) REFERENCES trips(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_trip_gpx_files_trip_id ON trip_gpx_files(trip_id)`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	// Trips used to hold a single track in trips.gpx_data. Move it over and clear the
+	// old column in one transaction so a track is never copied twice.
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO trip_gpx_files (trip_id, name, gpx_data, created_at)
+		SELECT id, 'Track', gpx_data, updated_at
+		FROM trips
+		WHERE gpx_data IS NOT NULL AND gpx_data != ''
+	`)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE trips SET gpx_data = NULL WHERE gpx_data IS NOT NULL`); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func createTOTPTables(db *sql.DB) error {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS user_totp (
+			user_id INTEGER PRIMARY KEY,
+			secret TEXT NOT NULL,
+			enabled BOOLEAN DEFAULT FALSE,
+			confirmed_at DATETIME,
+			last_used_step INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_totp_recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_totp_recovery_codes_user_id ON user_totp_recovery_codes(user_id)`,
+		`CREATE TABLE IF NOT EXISTS login_challenges (
+			token TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			remember_me BOOLEAN DEFAULT FALSE,
+			attempts INTEGER DEFAULT 0,
+			expires_at DATETIME NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_login_challenges_user_id ON login_challenges(user_id)`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addUserOAuthColumns(db *sql.DB) error {
+	columns := map[string]string{
+		"oauth_provider": "ALTER TABLE users ADD COLUMN oauth_provider TEXT",
+		"oauth_subject":  "ALTER TABLE users ADD COLUMN oauth_subject TEXT",
+	}
+
+	for _, name := range []string{"oauth_provider", "oauth_subject"} {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('users') WHERE name = ?", name).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			if _, err := db.Exec(columns[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	// An identity at a provider maps to a single user
+	_, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oauth_identity
+		ON users(oauth_provider, oauth_subject) WHERE oauth_provider IS NOT NULL`)
+	return err
+}
+
+func addPackIsAnonymousColumn(db *sql.DB) error {
+	// Check if is_anonymous column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('packs') WHERE name='is_anonymous'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		// Existing public packs keep showing their owner
+		_, err = db.Exec("ALTER TABLE packs ADD COLUMN is_anonymous BOOLEAN DEFAULT FALSE")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addPackPublicExpiresAtColumn(db *sql.DB) error {
+	// Check if public_expires_at column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('packs') WHERE name='public_expires_at'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		// NULL keeps the public link working until the pack is made private
+		_, err = db.Exec("ALTER TABLE packs ADD COLUMN public_expires_at DATETIME")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createPackCollaboratorsTable(db *sql.DB) error {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS pack_collaborators (
+			pack_id TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL DEFAULT 'editor',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (pack_id, user_id),
+			FOREIGN KEY (pack_id) REFERENCES packs(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_pack_collaborators_user_id ON pack_collaborators(user_id)`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addTripShortIDUniqueIndex(db *sql.DB) error {
+	// An empty short_id means none was generated yet
+	if _, err := db.Exec("UPDATE trips SET short_id = NULL WHERE short_id = ''"); err != nil {
+		return err
+	}
+
+	// Trips used to draw their short IDs from the packs table, so two of them could share
+	// one. The oldest keeps it and the others get a new one.
+	rows, err := db.Query(`
+		SELECT t.id FROM trips t
+		WHERE t.short_id IS NOT NULL AND EXISTS (
+			SELECT 1 FROM trips o
+			WHERE o.short_id = t.short_id
+			AND (o.created_at < t.created_at OR (o.created_at = t.created_at AND o.id < t.id))
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	var duplicateIDs []string
+	for rows.Next() {
+		var tripID string
+		if err := rows.Scan(&tripID); err != nil {
+			rows.Close()
+			return err
+		}
+		duplicateIDs = append(duplicateIDs, tripID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, tripID := range duplicateIDs {
+		shortID, err := generateTripShortID(db)
+		if err != nil {
+			return fmt.Errorf("failed to generate short ID for trip %s: %w", tripID, err)
+		}
+		if _, err := db.Exec("UPDATE trips SET short_id = ? WHERE id = ?", shortID, tripID); err != nil {
+			return fmt.Errorf("failed to update trip %s with short ID: %w", tripID, err)
+		}
+	}
+
+	migrations := []string{
+		`DROP INDEX IF EXISTS idx_trips_short_id`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_trips_short_id_unique ON trips(short_id) WHERE short_id IS NOT NULL AND short_id != ''`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func clampPackItemWornCounts(db *sql.DB) error {
+	_, err := db.Exec(`
+		UPDATE pack_items
+		SET worn_count = count, is_worn = count > 0
+		WHERE worn_count > count
+	`)
+	return err
+}
+
+func createAPITokensTable(db *sql.DB) error {
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			last_used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id)`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createInviteTokensTable(db *sql.DB) error {
+	migrations := []string{
+		// created_by has no foreign key so pending invites outlive deleted admin accounts
+		`CREATE TABLE IF NOT EXISTS invite_tokens (
+			token TEXT PRIMARY KEY,
+			created_by INTEGER NOT NULL,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
+			used_by INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (used_by) REFERENCES users(id) ON DELETE SET NULL
+		)`,
+		`INSERT OR IGNORE INTO system_settings (key, value) VALUES ('invite_mode', 'false')`,
+	}
+
+	for _, migration := range migrations {
+		if _, err := db.Exec(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addCategorySortOrderColumn(db *sql.DB) error {
+	// Check if sort_order column exists
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('categories') WHERE name='sort_order'").Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		// Existing categories all start at 0, so they stay sorted by name
+		_, err = db.Exec("ALTER TABLE categories ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createUserEmailPrefsTable(db *sql.DB) error {
+	// Rows are created on first use, a missing row means every optional email is wanted
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS user_email_prefs (
+		user_id INTEGER PRIMARY KEY,
+		admin_notifications BOOLEAN NOT NULL DEFAULT TRUE,
+		product_emails BOOLEAN NOT NULL DEFAULT TRUE,
+		trip_reminders BOOLEAN NOT NULL DEFAULT TRUE,
+		unsubscribe_token TEXT UNIQUE NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	)`)
+	return err
+}
@@ -2,17 +2,27 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"carryless/internal/models"
+	"carryless/internal/totp"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite3", ":memory:")
+	// A file-backed database lets queries that run while rows are still open use a second
+	// connection, which would otherwise get its own empty in-memory database.
+	// Foreign keys are enforced like in Initialize so cascading deletes behave the same.
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
 	if err != nil {
 		t.Fatal("Failed to open test database:", err)
 	}
@@ -76,7 +86,7 @@ func TestSessionManagement(t *testing.T) {
 		t.Error("Session ID should not be empty")
 	}
 
-	validatedUser, err := ValidateSession(db, session.ID, sessionDuration)
+	validatedUser, _, err := ValidateSession(db, session.ID, sessionDuration, time.Hour)
 	if err != nil {
 		t.Fatal("Failed to validate session:", err)
 	}
@@ -90,12 +100,165 @@ func TestSessionManagement(t *testing.T) {
 		t.Fatal("Failed to delete session:", err)
 	}
 
-	_, err = ValidateSession(db, session.ID, sessionDuration)
+	_, _, err = ValidateSession(db, session.ID, sessionDuration, time.Hour)
 	if err == nil {
 		t.Error("Expected session validation to fail after deletion")
 	}
 }
 
+// sessionExpiry reads the stored expiry of a session
+func sessionExpiry(t *testing.T, db *sql.DB, sessionID string) time.Time {
+	t.Helper()
+	var expiresAt time.Time
+	if err := db.QueryRow(`SELECT expires_at FROM sessions WHERE id = ?`, sessionID).Scan(&expiresAt); err != nil {
+		t.Fatal("Failed to read session expiry:", err)
+	}
+	return expiresAt
+}
+
+func TestCreateSessionHonorsDuration(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	duration := 3 * time.Hour
+	session, err := CreateSession(db, user.ID, duration)
+	if err != nil {
+		t.Fatal("Failed to create session:", err)
+	}
+
+	// Far from expiry, validation must leave the expiry alone
+	_, renewedFor, err := ValidateSession(db, session.ID, 14*24*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal("Failed to validate session:", err)
+	}
+	if renewedFor != 0 {
+		t.Errorf("Expected session far from expiry not to be renewed, renewed for %v", renewedFor)
+	}
+
+	remaining := time.Until(sessionExpiry(t, db, session.ID))
+	if remaining > duration || remaining < duration-time.Minute {
+		t.Errorf("Expected session to expire in about %v, expires in %v", duration, remaining)
+	}
+}
+
+func TestSessionExtendedNearExpiry(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	rememberMe := 30 * 24 * time.Hour
+	session, err := CreateSession(db, user.ID, rememberMe)
+	if err != nil {
+		t.Fatal("Failed to create session:", err)
+	}
+	if _, err := db.Exec(`UPDATE sessions SET expires_at = ? WHERE id = ?`, time.Now().Add(30*time.Minute), session.ID); err != nil {
+		t.Fatal("Failed to age session:", err)
+	}
+
+	// The session is renewed with the duration it was created with, not the shorter default
+	_, renewedFor, err := ValidateSession(db, session.ID, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal("Failed to validate session:", err)
+	}
+	if renewedFor != rememberMe {
+		t.Errorf("Expected session to be renewed for %v, got %v", rememberMe, renewedFor)
+	}
+
+	if remaining := time.Until(sessionExpiry(t, db, session.ID)); remaining < rememberMe-time.Minute {
+		t.Errorf("Expected session to be extended to about %v, expires in %v", rememberMe, remaining)
+	}
+}
+
+func TestValidateSessionUpdatesLastSeen(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	session, err := CreateSession(db, user.ID, time.Hour)
+	if err != nil {
+		t.Fatal("Failed to create session:", err)
+	}
+
+	if _, err := db.Exec(`UPDATE users SET last_seen = datetime('now', '-1 day') WHERE id = ?`, user.ID); err != nil {
+		t.Fatal("Failed to age last_seen:", err)
+	}
+
+	if _, _, err := ValidateSession(db, session.ID, time.Hour, time.Minute); err != nil {
+		t.Fatal("Failed to validate session:", err)
+	}
+
+	var lastSeen time.Time
+	if err := db.QueryRow(`SELECT last_seen FROM users WHERE id = ?`, user.ID).Scan(&lastSeen); err != nil {
+		t.Fatal("Failed to read last_seen:", err)
+	}
+	if time.Since(lastSeen) > time.Minute {
+		t.Errorf("Expected last_seen to advance to now, got %v", lastSeen)
+	}
+
+	stats, err := GetAdminStats(db)
+	if err != nil {
+		t.Fatal("Failed to get admin stats:", err)
+	}
+	if stats.ActiveUsers != 1 {
+		t.Errorf("Expected 1 active user, got %d", stats.ActiveUsers)
+	}
+}
+
+func TestUserSessionRevocation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	owner, err := CreateUser(db, "owner", "owner@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "other", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	session, err := CreateSession(db, owner.ID, time.Hour)
+	if err != nil {
+		t.Fatal("Failed to create session:", err)
+	}
+
+	sessions, err := GetUserSessions(db, owner.ID)
+	if err != nil {
+		t.Fatal("Failed to get sessions:", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("Expected 1 session, got %d", len(sessions))
+	}
+	if sessions[0].ID == session.ID || sessions[0].ID != SessionDisplayID(session.ID) {
+		t.Errorf("Expected truncated session ID, got %s", sessions[0].ID)
+	}
+
+	if err := DeleteUserSession(db, other.ID, sessions[0].ID); err == nil {
+		t.Error("Expected revoking another user's session to fail")
+	}
+
+	if err := DeleteUserSession(db, owner.ID, sessions[0].ID); err != nil {
+		t.Fatal("Failed to revoke session:", err)
+	}
+
+	if _, _, err := ValidateSession(db, session.ID, time.Hour, time.Hour); err == nil {
+		t.Error("Expected session validation to fail after revocation")
+	}
+}
+
 func TestCategoryOperations(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -225,6 +388,66 @@ func TestItemOperations(t *testing.T) {
 	}
 }
 
+func TestSearchItemsByBrand(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	sleeping, err := CreateCategory(db, user.ID, "Sleeping")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	shelter, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+
+	brand := "Nemo"
+	otherBrand := "Zpacks"
+	if _, err := CreateItem(db, user.ID, models.Item{CategoryID: sleeping.ID, Name: "Pillow", WeightGrams: 60, Brand: &brand}); err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	if _, err := CreateItem(db, user.ID, models.Item{CategoryID: shelter.ID, Name: "Tent", WeightGrams: 900, Brand: &brand}); err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	if _, err := CreateItem(db, user.ID, models.Item{CategoryID: shelter.ID, Name: "Tarp", WeightGrams: 300, Brand: &otherBrand}); err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	items, err := SearchItems(db, user.ID, "nemo", nil)
+	if err != nil {
+		t.Fatal("Failed to search items:", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items matching brand, got %d", len(items))
+	}
+	for _, item := range items {
+		if item.Brand == nil || *item.Brand != brand {
+			t.Errorf("Expected only %s items, got %s", brand, item.Name)
+		}
+	}
+
+	items, err = SearchItems(db, user.ID, "nemo", &shelter.ID)
+	if err != nil {
+		t.Fatal("Failed to search items:", err)
+	}
+	if len(items) != 1 || items[0].Name != "Tent" {
+		t.Errorf("Expected only the Tent when filtering by category, got %d items", len(items))
+	}
+
+	items, err = SearchItems(db, user.ID, "%", nil)
+	if err != nil {
+		t.Fatal("Failed to search items:", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected wildcard characters to match literally, got %d items", len(items))
+	}
+}
+
 func TestPackOperations(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -256,7 +479,7 @@ func TestPackOperations(t *testing.T) {
 		t.Errorf("Expected 1 pack, got %d", len(packs))
 	}
 
-	err = UpdatePack(db, user.ID, pack.ID, "Extended Weekend Trip", true)
+	err = UpdatePack(db, user.ID, pack.ID, "Extended Weekend Trip", true, false, nil)
 	if err != nil {
 		t.Fatal("Failed to update pack:", err)
 	}
@@ -285,7 +508,3890 @@ func TestPackOperations(t *testing.T) {
 	}
 }
 
-func TestMain(m *testing.M) {
-	code := m.Run()
-	os.Exit(code)
-}
\ No newline at end of file
+func TestPackWeightHistoryThrottle(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 1000})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	pack, err := CreatePack(db, user.ID, "Weekend Trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	if _, err := db.Exec("INSERT INTO pack_items (pack_id, item_id, count) VALUES (?, ?, 1)", pack.ID, tent.ID); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+	if err := SetPackItemCount(db, pack.ID, tent.ID, user.ID, 3); err != nil {
+		t.Fatal("Failed to set item count:", err)
+	}
+	if err := SetPackItemCount(db, pack.ID, tent.ID, user.ID, 2); err != nil {
+		t.Fatal("Failed to set item count:", err)
+	}
+
+	history, err := GetPackWeightHistory(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get weight history:", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("Expected changes within the hour to share one snapshot, got %d", len(history))
+	}
+	if history[0].TotalGrams != 2000 {
+		t.Errorf("Expected latest snapshot to hold 2000g, got %dg", history[0].TotalGrams)
+	}
+
+	// Legacy rows can have more worn than packed copies, the base weight never goes negative
+	if _, err := db.Exec("UPDATE pack_items SET worn_count = 5 WHERE pack_id = ? AND item_id = ?", pack.ID, tent.ID); err != nil {
+		t.Fatal("Failed to set worn count:", err)
+	}
+	if err := recordPackWeightSnapshot(db, pack.ID); err != nil {
+		t.Fatal("Failed to record snapshot:", err)
+	}
+	history, err = GetPackWeightHistory(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get weight history:", err)
+	}
+	if history[len(history)-1].BaseGrams != 0 {
+		t.Errorf("Expected a base weight of 0g with every copy worn, got %dg", history[len(history)-1].BaseGrams)
+	}
+}
+
+func TestDeleteItemMovesToTrash(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 1000})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	pack, err := CreatePack(db, user.ID, "Weekend Trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if _, err := db.Exec("INSERT INTO pack_items (pack_id, item_id, count) VALUES (?, ?, 1)", pack.ID, tent.ID); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+
+	// Items used in packs can be deleted
+	if err := DeleteItem(db, user.ID, tent.ID); err != nil {
+		t.Fatal("Failed to delete item used in a pack:", err)
+	}
+
+	items, err := GetItems(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get items:", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected deleted item to be hidden, got %d items", len(items))
+	}
+
+	trash, err := GetDeletedItems(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get deleted items:", err)
+	}
+	if len(trash) != 1 || trash[0].DeletedAt == nil {
+		t.Fatalf("Expected 1 item in the trash, got %d", len(trash))
+	}
+
+	if err := RestoreItem(db, user.ID, tent.ID); err != nil {
+		t.Fatal("Failed to restore item:", err)
+	}
+	if _, err := GetItem(db, user.ID, tent.ID); err != nil {
+		t.Error("Expected restored item to be retrievable:", err)
+	}
+
+	var packCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pack_items WHERE item_id = ?", tent.ID).Scan(&packCount); err != nil {
+		t.Fatal("Failed to count pack items:", err)
+	}
+	if packCount != 1 {
+		t.Errorf("Expected restored item to keep its pack membership, got %d", packCount)
+	}
+
+	if err := RestoreItem(db, user.ID, tent.ID); err == nil {
+		t.Error("Expected restoring an active item to fail")
+	}
+}
+
+func TestBulkDeleteItemsSkipsItemsInPacks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 1000})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	tarp, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tarp", WeightGrams: 300})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	pack, err := CreatePack(db, user.ID, "Weekend Trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if _, err := db.Exec("INSERT INTO pack_items (pack_id, item_id, count) VALUES (?, ?, 1)", pack.ID, tent.ID); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+
+	deleted, skipped, err := BulkDeleteItems(db, user.ID, []int{tent.ID, tarp.ID})
+	if err != nil {
+		t.Fatal("Failed to bulk delete items:", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 item deleted, got %d", deleted)
+	}
+	if len(skipped) != 1 || skipped[0] != tent.ID {
+		t.Errorf("Expected item %d to be skipped, got %v", tent.ID, skipped)
+	}
+
+	if _, err := GetItem(db, user.ID, tent.ID); err != nil {
+		t.Error("Expected item used in a pack to remain:", err)
+	}
+	if _, err := GetItem(db, user.ID, tarp.ID); err == nil {
+		t.Error("Expected unused item to be deleted")
+	}
+
+	other, err := CreateUser(db, "otheruser", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	otherCategory, err := CreateCategory(db, other.ID, "Cooking")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	if err := BulkUpdateItemCategory(db, user.ID, []int{tent.ID}, otherCategory.ID); err == nil {
+		t.Error("Expected moving items to another user's category to fail")
+	}
+}
+
+func TestPackTemplatesHiddenFromPackList(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	pack, err := CreatePack(db, user.ID, "Weekend Trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	if _, err := CreatePackFromTemplate(db, user.ID, pack.ID, ""); err == nil {
+		t.Error("Expected creating from a regular pack to fail")
+	}
+
+	if err := SetPackTemplate(db, user.ID, pack.ID, true); err != nil {
+		t.Fatal("Failed to mark pack as template:", err)
+	}
+
+	packs, err := GetPacks(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs:", err)
+	}
+	if len(packs) != 0 {
+		t.Errorf("Expected templates to be hidden from packs, got %d", len(packs))
+	}
+
+	templates, err := GetPackTemplates(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack templates:", err)
+	}
+	if len(templates) != 1 || !templates[0].IsTemplate {
+		t.Fatalf("Expected 1 template, got %d", len(templates))
+	}
+}
+
+func TestFavoritePacksListedFirst(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	older, err := CreatePack(db, user.ID, "Older")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if _, err := CreatePack(db, user.ID, "Newer"); err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if _, err := db.Exec(`UPDATE packs SET updated_at = datetime('now', '-1 day') WHERE id = ?`, older.ID); err != nil {
+		t.Fatal("Failed to age pack:", err)
+	}
+
+	if err := TogglePackFavorite(db, user.ID, older.ID, true); err != nil {
+		t.Fatal("Failed to favorite pack:", err)
+	}
+
+	packs, err := GetPacks(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs:", err)
+	}
+	if len(packs) != 2 || packs[0].ID != older.ID || !packs[0].IsFavorite {
+		t.Fatalf("Expected favorite pack to be listed first, got %+v", packs)
+	}
+
+	if err := TogglePackFavorite(db, user.ID+1, older.ID, false); err == nil {
+		t.Error("Expected toggling another user's pack to fail")
+	}
+}
+
+func TestSetItemImageReturnsPreviousPath(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 1000})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	if _, err := SetItemImage(db, user.ID, tent.ID, "/static/uploads/1/first.png"); err != nil {
+		t.Fatal("Failed to set item image:", err)
+	}
+
+	previous, err := SetItemImage(db, user.ID, tent.ID, "")
+	if err != nil {
+		t.Fatal("Failed to clear item image:", err)
+	}
+	if previous != "/static/uploads/1/first.png" {
+		t.Errorf("Expected previous image path, got %q", previous)
+	}
+
+	item, err := GetItem(db, user.ID, tent.ID)
+	if err != nil {
+		t.Fatal("Failed to get item:", err)
+	}
+	if item.ImagePath != nil {
+		t.Errorf("Expected image path to be cleared, got %q", *item.ImagePath)
+	}
+}
+
+func TestExportImportPackRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	owner, err := CreateUser(db, "owner", "owner@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "other", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, owner.ID, "Clothing")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	socks, err := CreateItem(db, owner.ID, models.Item{CategoryID: category.ID, Name: "Socks", WeightGrams: 60})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	pack, err := CreatePack(db, owner.ID, "Hut Tour")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	result, err := db.Exec(`INSERT INTO pack_items (pack_id, item_id, count, worn_count, is_worn) VALUES (?, ?, 3, 1, TRUE)`, pack.ID, socks.ID)
+	if err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+	packItemID, _ := result.LastInsertId()
+	result, err = db.Exec(`INSERT INTO pack_labels (pack_id, name, color) VALUES (?, 'Spare', '#ff0000')`, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to create label:", err)
+	}
+	labelID, _ := result.LastInsertId()
+	if _, err := db.Exec(`INSERT INTO item_labels (pack_item_id, pack_label_id, count) VALUES (?, ?, 2)`, packItemID, labelID); err != nil {
+		t.Fatal("Failed to assign label:", err)
+	}
+
+	export, err := ExportPack(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to export pack:", err)
+	}
+	if len(export.Items) != 1 || export.Items[0].Category != "Clothing" || export.Items[0].WornCount != 1 {
+		t.Fatalf("Unexpected exported items: %+v", export.Items)
+	}
+	if len(export.Items[0].Labels) != 1 || export.Items[0].Labels[0].Label != "Spare" {
+		t.Fatalf("Expected label assignment to be exported by name, got %+v", export.Items[0].Labels)
+	}
+
+	imported, err := ImportPack(db, other.ID, export)
+	if err != nil {
+		t.Fatal("Failed to import pack:", err)
+	}
+
+	packWithItems, err := GetPackWithItems(db, imported.ID)
+	if err != nil {
+		t.Fatal("Failed to load imported pack:", err)
+	}
+	if packWithItems.UserID != other.ID || len(packWithItems.Items) != 1 {
+		t.Fatalf("Expected imported pack with 1 item for the importing user")
+	}
+	item := packWithItems.Items[0]
+	if item.Item.UserID != other.ID || item.Count != 3 || item.WornCount != 1 {
+		t.Errorf("Unexpected imported pack item: %+v", item)
+	}
+	if len(item.Labels) != 1 || item.Labels[0].Count != 2 {
+		t.Errorf("Expected label assignment to be restored, got %+v", item.Labels)
+	}
+}
+
+func TestImportItemsMerge(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Sleeping")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+
+	existing, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Sleeping Bag", WeightGrams: 800, Price: 299.99})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	if _, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Pillow", WeightGrams: 60}); err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	matchID, err := FindItemIDByNameAndCategory(db, user.ID, "Sleeping Bag", category.ID)
+	if err != nil {
+		t.Fatal("Failed to find item:", err)
+	}
+	if matchID != existing.ID {
+		t.Errorf("Expected matching item ID %d, got %d", existing.ID, matchID)
+	}
+
+	imported := []models.Item{
+		{ID: matchID, CategoryID: category.ID, Name: "Sleeping Bag", WeightGrams: 750, Price: 250, Note: "Resized"},
+		{CategoryID: category.ID, Name: "Sleeping Pad", WeightGrams: 400},
+	}
+
+	created, updated, err := ImportItems(db, user.ID, imported, false)
+	if err != nil {
+		t.Fatal("Failed to import items:", err)
+	}
+	if created != 1 || updated != 1 {
+		t.Errorf("Expected 1 created and 1 updated, got %d created and %d updated", created, updated)
+	}
+
+	items, err := GetItems(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get items:", err)
+	}
+	if len(items) != 3 {
+		t.Errorf("Expected 3 items after merge, got %d", len(items))
+	}
+
+	bag, err := GetItem(db, user.ID, existing.ID)
+	if err != nil {
+		t.Fatal("Failed to get merged item:", err)
+	}
+	if bag.WeightGrams != 750 || bag.Note != "Resized" {
+		t.Errorf("Expected merged item to be updated, got %dg and note %q", bag.WeightGrams, bag.Note)
+	}
+
+	if _, _, err := ImportItems(db, user.ID, imported, true); err != nil {
+		t.Fatal("Failed to import items in replace mode:", err)
+	}
+
+	items, err = GetItems(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get items:", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("Expected 2 items after replace, got %d", len(items))
+	}
+}
+
+func TestTripCostSummaryUsesOwnerCurrency(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	if err := UpdateUserCurrency(db, user.ID, "€"); err != nil {
+		t.Fatal("Failed to update currency:", err)
+	}
+
+	trip, err := CreateTrip(db, user.ID, "Alps", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+
+	train, bus := 42.5, 7.5
+	pounds := "£"
+	if _, err := AddTransportStep(db, trip.ID, "outbound", "Paris", nil, nil, nil, nil, nil, nil, &train, nil, user.ID); err != nil {
+		t.Fatal("Failed to add transport step:", err)
+	}
+	if _, err := AddTransportStep(db, trip.ID, "outbound", "Geneva", nil, nil, nil, nil, nil, nil, &bus, nil, user.ID); err != nil {
+		t.Fatal("Failed to add transport step:", err)
+	}
+	if _, err := AddTransportStep(db, trip.ID, "return", "London", nil, nil, nil, nil, nil, nil, &train, &pounds, user.ID); err != nil {
+		t.Fatal("Failed to add transport step:", err)
+	}
+	if _, err := AddTransportStep(db, trip.ID, "return", "Paris", nil, nil, nil, nil, nil, nil, nil, nil, user.ID); err != nil {
+		t.Fatal("Failed to add transport step:", err)
+	}
+
+	totals, err := GetTripCostSummary(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get trip cost summary:", err)
+	}
+	if len(totals) != 2 {
+		t.Fatalf("Expected totals in 2 currencies, got %+v", totals)
+	}
+	if totals[0].Currency != "€" || totals[0].Total != 50 || totals[0].Steps != 2 {
+		t.Errorf("Expected steps without a currency to total 50 in the owner's currency, got %+v", totals[0])
+	}
+	if totals[1].Currency != "£" || totals[1].Total != 42.5 {
+		t.Errorf("Expected 42.5 in pounds, got %+v", totals[1])
+	}
+}
+
+func TestGenerateChecklistFromPacksSkipsExistingItems(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Gear")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 1000})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	socks, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Socks", WeightGrams: 60})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for _, itemID := range []int{tent.ID, socks.ID} {
+		if _, err := db.Exec(`INSERT INTO pack_items (pack_id, item_id) VALUES (?, ?)`, pack.ID, itemID); err != nil {
+			t.Fatal("Failed to add item to pack:", err)
+		}
+	}
+
+	trip, err := CreateTrip(db, user.ID, "Alps", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+	if _, err := db.Exec(`INSERT INTO trip_packs (trip_id, pack_id) VALUES (?, ?)`, trip.ID, pack.ID); err != nil {
+		t.Fatal("Failed to add pack to trip:", err)
+	}
+
+	existing, err := AddChecklistItem(db, trip.ID, "socks", user.ID)
+	if err != nil {
+		t.Fatal("Failed to add checklist item:", err)
+	}
+	if err := ToggleChecklistItem(db, existing.ID, user.ID); err != nil {
+		t.Fatal("Failed to check checklist item:", err)
+	}
+
+	added, err := GenerateChecklistFromPacks(db, trip.ID, user.ID)
+	if err != nil {
+		t.Fatal("Failed to generate checklist:", err)
+	}
+	if added != 1 {
+		t.Errorf("Expected only the tent to be added, got %d items", added)
+	}
+
+	added, err = GenerateChecklistFromPacks(db, trip.ID, user.ID)
+	if err != nil {
+		t.Fatal("Failed to generate checklist again:", err)
+	}
+	if added != 0 {
+		t.Errorf("Expected nothing to be added the second time, got %d items", added)
+	}
+
+	if _, err := GenerateChecklistFromPacks(db, trip.ID, user.ID+1); err == nil {
+		t.Error("Expected generating another user's checklist to fail")
+	}
+}
+
+func TestUpdateTripRejectsInvertedDates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	start := time.Date(2025, 7, 10, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 7, 14, 0, 0, 0, 0, time.UTC)
+
+	trip, err := CreateTrip(db, user.ID, "Alps", nil, nil, &start, &end, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+
+	before := time.Date(2025, 7, 5, 0, 0, 0, 0, time.UTC)
+	err = UpdateTrip(db, user.ID, trip.ID, "Alps", nil, nil, &start, &before, false)
+	if err == nil {
+		t.Fatal("Expected update to fail when end date is before start date")
+	}
+
+	farFuture := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = UpdateTrip(db, user.ID, trip.ID, "Alps", nil, nil, &start, &farFuture, false)
+	if err == nil {
+		t.Fatal("Expected update to fail with an out of range date")
+	}
+
+	storedTrip, err := GetTrip(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get trip:", err)
+	}
+	if storedTrip.EndDate == nil || !storedTrip.EndDate.Equal(end) {
+		t.Errorf("Expected end date to remain %v, got %v", end, storedTrip.EndDate)
+	}
+
+	err = UpdateTrip(db, user.ID, trip.ID, "Alps", nil, nil, &start, &start, false)
+	if err != nil {
+		t.Errorf("Expected same-day trip to be valid, got %v", err)
+	}
+}
+
+func TestPackLikesAreIdempotent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	owner, err := CreateUser(db, "owner", "owner@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	fan, err := CreateUser(db, "fan", "fan@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	pack, err := CreatePack(db, owner.ID, "Shared")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := LikePack(db, pack.ID, fan.ID); err != nil {
+			t.Fatal("Failed to like pack:", err)
+		}
+	}
+	if err := LikePack(db, pack.ID, owner.ID); err != nil {
+		t.Fatal("Failed to like pack:", err)
+	}
+
+	count, err := GetPackLikeCount(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to count likes:", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 likes, got %d", count)
+	}
+
+	if err := UnlikePack(db, pack.ID, fan.ID); err != nil {
+		t.Fatal("Failed to unlike pack:", err)
+	}
+	liked, err := HasUserLikedPack(db, pack.ID, fan.ID)
+	if err != nil {
+		t.Fatal("Failed to check like:", err)
+	}
+	if liked {
+		t.Error("Expected like to be removed")
+	}
+	if count, _ := GetPackLikeCount(db, pack.ID); count != 1 {
+		t.Errorf("Expected 1 like after unlike, got %d", count)
+	}
+}
+
+func TestGetPublicPacksFiltersAndSorts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 900})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	heavy, err := CreatePackWithPublic(db, user.ID, "Heavy", true)
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, heavy.ID, tent.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+	if err := LikePack(db, heavy.ID, user.ID); err != nil {
+		t.Fatal("Failed to like pack:", err)
+	}
+
+	light, err := CreatePackWithPublic(db, user.ID, "Light", true)
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	if _, err := CreatePack(db, user.ID, "Private"); err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	archived, err := CreatePackWithPublic(db, user.ID, "Archived", true)
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := TogglePackLock(db, user.ID, archived.ID, true); err != nil {
+		t.Fatal("Failed to archive pack:", err)
+	}
+
+	count, err := CountPublicPacks(db)
+	if err != nil {
+		t.Fatal("Failed to count public packs:", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 public packs, got %d", count)
+	}
+
+	lightest, err := GetPublicPacks(db, 10, 0, PublicPackSortLightest)
+	if err != nil {
+		t.Fatal("Failed to get public packs:", err)
+	}
+	if len(lightest) != 2 || lightest[0].ID != light.ID || lightest[1].TotalWeight != 900 {
+		t.Fatalf("Expected light pack first when sorting by weight, got %+v", lightest)
+	}
+	if lightest[0].OwnerUsername != "testuser" {
+		t.Errorf("Expected owner username 'testuser', got %q", lightest[0].OwnerUsername)
+	}
+
+	liked, err := GetPublicPacks(db, 10, 0, PublicPackSortLiked)
+	if err != nil {
+		t.Fatal("Failed to get public packs:", err)
+	}
+	if len(liked) != 2 || liked[0].ID != heavy.ID || liked[0].LikeCount != 1 {
+		t.Fatalf("Expected liked pack first when sorting by likes, got %+v", liked)
+	}
+
+	page, err := GetPublicPacks(db, 1, 1, PublicPackSortLightest)
+	if err != nil {
+		t.Fatal("Failed to get public packs:", err)
+	}
+	if len(page) != 1 || page[0].ID != heavy.ID {
+		t.Errorf("Expected second page to hold the heavy pack, got %+v", page)
+	}
+}
+
+func TestSearchUsersWithStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	for _, name := range []string{"alice", "bob", "alicia", "al_x"} {
+		if _, err := CreateUser(db, name, name+"@example.com", "password123"); err != nil {
+			t.Fatal("Failed to create user:", err)
+		}
+	}
+
+	users, err := SearchUsersWithStats(db, "ali", 10, 0)
+	if err != nil {
+		t.Fatal("Failed to search users:", err)
+	}
+	if len(users) != 2 || users[0].Username != "alice" || users[1].Username != "alicia" {
+		t.Fatalf("Expected alice and alicia, got %+v", users)
+	}
+
+	// Wildcards in the query are matched literally
+	if count, err := CountUsers(db, "l_"); err != nil || count != 1 {
+		t.Errorf("Expected 1 user matching 'l_', got %d (err: %v)", count, err)
+	}
+
+	if count, err := CountUsers(db, ""); err != nil || count != 4 {
+		t.Errorf("Expected an empty query to match all 4 users, got %d (err: %v)", count, err)
+	}
+
+	page, err := SearchUsersWithStats(db, "example.com", 2, 2)
+	if err != nil {
+		t.Fatal("Failed to search users:", err)
+	}
+	if len(page) != 2 || page[0].Username != "alicia" {
+		t.Errorf("Expected second page to start with alicia, got %+v", page)
+	}
+}
+
+func TestAdminDeletePackCascades(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	item, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 900})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePackWithPublic(db, user.ID, "Offensive", true)
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, pack.ID, item.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+	if err := LikePack(db, pack.ID, user.ID); err != nil {
+		t.Fatal("Failed to like pack:", err)
+	}
+
+	if err := AdminDeletePack(db, pack.ID); err != nil {
+		t.Fatal("Failed to delete pack:", err)
+	}
+
+	var remaining int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM pack_items) + (SELECT COUNT(*) FROM pack_likes)`).Scan(&remaining); err != nil {
+		t.Fatal("Failed to count pack rows:", err)
+	}
+	if remaining != 0 {
+		t.Errorf("Expected pack items and likes to be deleted, %d rows left", remaining)
+	}
+
+	if _, err := GetItem(db, user.ID, item.ID); err != nil {
+		t.Error("Expected the owner's item to be kept:", err)
+	}
+
+	if err := AdminDeletePack(db, pack.ID); err == nil || err.Error() != "pack not found" {
+		t.Errorf("Expected 'pack not found' deleting a missing pack, got %v", err)
+	}
+}
+
+func TestAddItemToPackIncludesLinkedItemsOnce(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 900})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	stakes, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Stakes", WeightGrams: 80})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	if err := CreateItemLink(db, user.ID, tent.ID, stakes.ID); err != nil {
+		t.Fatal("Failed to link items:", err)
+	}
+
+	countOf := func(packID string, itemID int) int {
+		var count int
+		err := db.QueryRow(`SELECT COALESCE(SUM(count), 0) FROM pack_items WHERE pack_id = ? AND item_id = ?`, packID, itemID).Scan(&count)
+		if err != nil {
+			t.Fatal("Failed to count pack items:", err)
+		}
+		return count
+	}
+
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := AddItemToPack(db, pack.ID, tent.ID, user.ID, true); err != nil {
+			t.Fatal("Failed to add item to pack:", err)
+		}
+	}
+	if count := countOf(pack.ID, tent.ID); count != 2 {
+		t.Errorf("Expected 2 tents, got %d", count)
+	}
+	if count := countOf(pack.ID, stakes.ID); count != 1 {
+		t.Errorf("Expected linked stakes to be added once, got %d", count)
+	}
+
+	other, err := CreatePack(db, user.ID, "Minimal")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, other.ID, tent.ID, user.ID, false); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+	if count := countOf(other.ID, stakes.ID); count != 0 {
+		t.Errorf("Expected linked stakes to be left out, got %d", count)
+	}
+
+	// Linked items in the trash stay out, and the lookup runs on the transaction's own
+	// connection so a single connection is enough
+	if err := DeleteItem(db, user.ID, stakes.ID); err != nil {
+		t.Fatal("Failed to trash item:", err)
+	}
+	db.SetMaxOpenConns(1)
+	trashed, err := CreatePack(db, user.ID, "Trashed")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, trashed.ID, tent.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+	if count := countOf(trashed.ID, stakes.ID); count != 0 {
+		t.Errorf("Expected trashed linked stakes to be left out, got %d", count)
+	}
+}
+
+func TestGetPacksByLabel(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	bikepacking, err := CreateUserPackLabel(db, user.ID, "bikepacking", "#2563eb")
+	if err != nil {
+		t.Fatal("Failed to create pack label:", err)
+	}
+	labeled, err := CreatePack(db, user.ID, "Gravel weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if _, err := CreatePack(db, user.ID, "Day hike"); err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AssignLabelToPack(db, labeled.ID, bikepacking.ID, user.ID); err != nil {
+		t.Fatal("Failed to assign label:", err)
+	}
+
+	packs, err := GetPacksByLabel(db, user.ID, bikepacking.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs by label:", err)
+	}
+	if len(packs) != 1 || packs[0].ID != labeled.ID {
+		t.Fatalf("Expected only the labeled pack, got %d packs", len(packs))
+	}
+	if len(packs[0].PackLevelLabels) != 1 {
+		t.Errorf("Expected filtered pack to keep its labels, got %d", len(packs[0].PackLevelLabels))
+	}
+
+	// Unlabeled packs still show up in the unfiltered list
+	all, err := GetPacks(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs:", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 packs without a filter, got %d", len(all))
+	}
+}
+
+func TestFindSimilarItems(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	for _, name := range []string{"Tent - 2P Ultralight", "Pot"} {
+		if _, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: name, WeightGrams: 100}); err != nil {
+			t.Fatal("Failed to create item:", err)
+		}
+	}
+
+	cases := map[string]int{
+		"tent 2p ultralight": 1, // case and punctuation are ignored
+		"Tent 2P Ulralight":  1, // a typo on a long name
+		"POT":                1,
+		"Pan":                0, // short names must match exactly
+		"Sleeping bag":       0,
+	}
+	for name, expected := range cases {
+		similar, err := FindSimilarItems(db, user.ID, name)
+		if err != nil {
+			t.Fatal("Failed to find similar items:", err)
+		}
+		if len(similar) != expected {
+			t.Errorf("Expected %d similar items for %q, got %d", expected, name, len(similar))
+		}
+	}
+}
+
+func TestGetInventoryStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	stats, err := GetInventoryStats(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get stats for empty inventory:", err)
+	}
+	if stats.TotalItems != 0 || len(stats.Categories) != 0 {
+		t.Errorf("Expected empty stats, got %d items in %d categories", stats.TotalItems, len(stats.Categories))
+	}
+
+	sleep, err := CreateCategory(db, user.ID, "Sleep")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	kitchen, err := CreateCategory(db, user.ID, "Kitchen")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	items := []models.Item{
+		{CategoryID: sleep.ID, Name: "Quilt", WeightGrams: 600, Price: 300},
+		{CategoryID: sleep.ID, Name: "Pad", WeightGrams: 400, Price: 150.5},
+		{CategoryID: kitchen.ID, Name: "Pot", WeightGrams: 100, Price: 40},
+	}
+	for _, item := range items {
+		if _, err := CreateItem(db, user.ID, item); err != nil {
+			t.Fatal("Failed to create item:", err)
+		}
+	}
+
+	stats, err = GetInventoryStats(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get inventory stats:", err)
+	}
+	if len(stats.Categories) != 2 {
+		t.Fatalf("Expected 2 categories, got %d", len(stats.Categories))
+	}
+	// Categories are sorted by name, so Kitchen comes first
+	if got := stats.Categories[1]; got.Name != "Sleep" || got.ItemCount != 2 || got.TotalWeight != 1000 || got.TotalPrice != 450.5 {
+		t.Errorf("Unexpected sleep stats: %+v", got)
+	}
+	if stats.TotalItems != 3 || stats.TotalWeight != 1100 || stats.TotalPrice != 490.5 {
+		t.Errorf("Unexpected totals: %d items, %dg, %.2f", stats.TotalItems, stats.TotalWeight, stats.TotalPrice)
+	}
+}
+
+func TestGetUserStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	stats, err := GetUserStats(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get stats for empty account:", err)
+	}
+	if stats.TotalItems != 0 || stats.TotalValue != 0 || stats.LightestPack != nil || stats.HeaviestPack != nil {
+		t.Errorf("Expected zero stats for empty account, got %+v", stats)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 900, Price: 350})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	tarp, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tarp", WeightGrams: 300, Price: 120})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	heavy, err := CreatePack(db, user.ID, "Heavy")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	light, err := CreatePack(db, user.ID, "Light")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, heavy.ID, tent.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+	if err := AddItemToPack(db, light.ID, tarp.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+
+	stats, err = GetUserStats(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get user stats:", err)
+	}
+	if stats.TotalItems != 2 || stats.TotalValue != 470 {
+		t.Errorf("Expected 2 items worth 470, got %d worth %.2f", stats.TotalItems, stats.TotalValue)
+	}
+	if stats.HeaviestPack == nil || stats.HeaviestPack.ID != heavy.ID || stats.HeaviestWeight != 900 {
+		t.Errorf("Expected heaviest pack %s at 900g, got %+v at %dg", heavy.ID, stats.HeaviestPack, stats.HeaviestWeight)
+	}
+	if stats.LightestPack == nil || stats.LightestPack.ID != light.ID || stats.LightestWeight != 300 {
+		t.Errorf("Expected lightest pack %s at 300g, got %+v at %dg", light.ID, stats.LightestPack, stats.LightestWeight)
+	}
+}
+
+func TestPackItemNoteCopiedOnDuplicate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Sleep")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	bag, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Sleeping bag", Note: "down, 3-season", WeightGrams: 800})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Winter")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, pack.ID, bag.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+
+	if err := UpdatePackItemNote(db, pack.ID, bag.ID, user.ID+1, "nope"); err == nil {
+		t.Error("Expected another user's note update to be rejected")
+	}
+	if err := UpdatePackItemNote(db, pack.ID, bag.ID, user.ID, "bring liner for cold"); err != nil {
+		t.Fatal("Failed to update pack item note:", err)
+	}
+
+	duplicate, err := DuplicatePack(db, user.ID, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to duplicate pack:", err)
+	}
+	withItems, err := GetPackWithItems(db, duplicate.ID)
+	if err != nil {
+		t.Fatal("Failed to get duplicated pack:", err)
+	}
+	if len(withItems.Items) != 1 {
+		t.Fatalf("Expected 1 item in duplicated pack, got %d", len(withItems.Items))
+	}
+	if note := withItems.Items[0].Note; note != "bring liner for cold" {
+		t.Errorf("Expected pack note to be copied, got %q", note)
+	}
+	if note := withItems.Items[0].Item.Note; note != "down, 3-season" {
+		t.Errorf("Expected inventory note to be untouched, got %q", note)
+	}
+}
+
+func TestSuspendUserKeepsData(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	if _, err := CreatePack(db, user.ID, "Kept"); err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	session, err := CreateSession(db, user.ID, time.Hour)
+	if err != nil {
+		t.Fatal("Failed to create session:", err)
+	}
+
+	if err := SuspendUser(db, user.ID); err != nil {
+		t.Fatal("Failed to suspend user:", err)
+	}
+
+	if _, _, err := ValidateSession(db, session.ID, time.Hour, time.Hour); err == nil {
+		t.Error("Expected sessions to be deleted on suspension")
+	}
+
+	authenticated, err := AuthenticateUser(db, "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to authenticate user:", err)
+	}
+	if !authenticated.IsSuspended {
+		t.Error("Expected user to be suspended")
+	}
+
+	packs, err := GetPacks(db, user.ID)
+	if err != nil || len(packs) != 1 {
+		t.Errorf("Expected the user's pack to be kept, got %d (err: %v)", len(packs), err)
+	}
+
+	if err := UnsuspendUser(db, user.ID); err != nil {
+		t.Fatal("Failed to unsuspend user:", err)
+	}
+	authenticated, err = AuthenticateUser(db, "test@example.com", "password123")
+	if err != nil || authenticated.IsSuspended {
+		t.Errorf("Expected user to be unsuspended (err: %v)", err)
+	}
+
+	if err := SuspendUser(db, user.ID+100); err == nil || err.Error() != "user not found" {
+		t.Errorf("Expected 'user not found' suspending a missing user, got %v", err)
+	}
+}
+
+func TestAuditEventsNewestFirst(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	admin, err := CreateUser(db, "admin", "admin@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	if err := RecordAuditEvent(db, admin.ID, AuditActionToggleRegistration, "", "registration disabled"); err != nil {
+		t.Fatal("Failed to record audit event:", err)
+	}
+	if err := RecordAuditEvent(db, admin.ID, AuditActionSuspendUser, "42", "someone: suspended"); err != nil {
+		t.Fatal("Failed to record audit event:", err)
+	}
+
+	count, err := CountAuditEvents(db)
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 audit events, got %d (err: %v)", count, err)
+	}
+
+	events, err := GetAuditEvents(db, 10, 0)
+	if err != nil {
+		t.Fatal("Failed to get audit events:", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 audit events, got %d", len(events))
+	}
+	if events[0].Action != AuditActionSuspendUser || events[0].TargetID != "42" {
+		t.Errorf("Expected newest event first, got %+v", events[0])
+	}
+	if events[0].ActorUsername != "admin" {
+		t.Errorf("Expected actor username 'admin', got %q", events[0].ActorUsername)
+	}
+
+	page, err := GetAuditEvents(db, 1, 1)
+	if err != nil || len(page) != 1 || page[0].Action != AuditActionToggleRegistration {
+		t.Errorf("Expected second page to hold the oldest event, got %+v (err: %v)", page, err)
+	}
+}
+
+func TestExportUserDataOnlyIncludesOwnData(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "other", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	item, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 900})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, pack.ID, item.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+	trip, err := CreateTrip(db, user.ID, "Hike", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+	if _, err := AddChecklistItem(db, trip.ID, "Water", user.ID); err != nil {
+		t.Fatal("Failed to add checklist item:", err)
+	}
+
+	if _, err := CreatePack(db, other.ID, "Not mine"); err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	export, err := ExportUserData(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to export user data:", err)
+	}
+
+	if export.Profile.Email != "test@example.com" || export.Profile.Username != "testuser" {
+		t.Errorf("Unexpected profile: %+v", export.Profile)
+	}
+	if len(export.Items) != 1 || export.Items[0].Name != "Tent" {
+		t.Errorf("Expected the user's single item, got %+v", export.Items)
+	}
+	if len(export.Packs) != 1 || export.Packs[0].Name != "Weekend" || len(export.Packs[0].Items) != 1 {
+		t.Errorf("Expected the user's pack with its item, got %+v", export.Packs)
+	}
+	if len(export.Trips) != 1 || len(export.Trips[0].ChecklistItems) != 1 {
+		t.Errorf("Expected the user's trip with its checklist, got %+v", export.Trips)
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal("Failed to marshal export:", err)
+	}
+	if strings.Contains(string(data), "password") || strings.Contains(string(data), "Not mine") {
+		t.Error("Expected export to exclude credentials and other users' data")
+	}
+}
+
+func TestEmailChangeRequiresConfirmation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	if _, err := CreateUser(db, "other", "other@example.com", "password123"); err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	if _, err := RequestEmailChange(db, user.ID, "Other@Example.com"); err == nil || err.Error() != "email already in use" {
+		t.Errorf("Expected 'email already in use', got %v", err)
+	}
+
+	first, err := RequestEmailChange(db, user.ID, "new@example.com")
+	if err != nil {
+		t.Fatal("Failed to request email change:", err)
+	}
+	second, err := RequestEmailChange(db, user.ID, "newer@example.com")
+	if err != nil {
+		t.Fatal("Failed to request email change:", err)
+	}
+
+	unchanged, err := GetUserByID(db, user.ID)
+	if err != nil || unchanged.Email != "test@example.com" {
+		t.Errorf("Expected email to stay unchanged before confirmation, got %q (err: %v)", unchanged.Email, err)
+	}
+
+	if _, err := ConfirmEmailChange(db, first.Token); err == nil {
+		t.Error("Expected a superseded token to be rejected")
+	}
+
+	updated, err := ConfirmEmailChange(db, second.Token)
+	if err != nil {
+		t.Fatal("Failed to confirm email change:", err)
+	}
+	if updated.Email != "newer@example.com" {
+		t.Errorf("Expected email 'newer@example.com', got %q", updated.Email)
+	}
+
+	if _, err := ConfirmEmailChange(db, second.Token); err == nil {
+		t.Error("Expected a token to be usable only once")
+	}
+}
+
+func TestCreateActivationTokenInvalidatesPrevious(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	first, err := CreateActivationToken(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to create activation token:", err)
+	}
+	second, err := CreateActivationToken(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to create activation token:", err)
+	}
+
+	if _, err := ValidateActivationToken(db, first.Token); err == nil {
+		t.Error("Expected the previous activation token to be invalidated")
+	}
+	if _, err := ValidateActivationToken(db, second.Token); err != nil {
+		t.Errorf("Expected the latest activation token to be valid, got %v", err)
+	}
+
+	found, err := GetUserByEmail(db, "test@example.com")
+	if err != nil || found.ID != user.ID || found.IsActivated {
+		t.Errorf("Expected to find the unactivated user by email, got %+v (err: %v)", found, err)
+	}
+	if _, err := GetUserByEmail(db, "missing@example.com"); err == nil || err.Error() != "user not found" {
+		t.Errorf("Expected 'user not found', got %v", err)
+	}
+}
+
+func TestExpiredActivationTokenRejected(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	token, err := CreateActivationToken(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to create activation token:", err)
+	}
+	if token.ExpiresAt.Sub(time.Now()) > activationTokenLifetime {
+		t.Errorf("Expected token to expire within %v, expires at %v", activationTokenLifetime, token.ExpiresAt)
+	}
+
+	// Age the token past its 24h lifetime
+	_, err = db.Exec("UPDATE activation_tokens SET expires_at = ? WHERE token = ?", time.Now().Add(-time.Hour), token.Token)
+	if err != nil {
+		t.Fatal("Failed to age activation token:", err)
+	}
+
+	if _, err := ValidateActivationToken(db, token.Token); err == nil || err.Error() != "activation token expired" {
+		t.Errorf("Expected 'activation token expired', got %v", err)
+	}
+	if _, err := ValidateActivationToken(db, "missing"); err == nil || err.Error() != "activation token not found" {
+		t.Errorf("Expected 'activation token not found', got %v", err)
+	}
+
+	if _, err := CleanupExpiredActivationTokens(db); err != nil {
+		t.Fatal("Failed to cleanup activation tokens:", err)
+	}
+	if _, err := ValidateActivationToken(db, token.Token); err == nil || err.Error() != "activation token not found" {
+		t.Errorf("Expected expired token to be cleaned up, got %v", err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Exit(code)
+}
+
+func TestImportPackItems(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Sleep")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	bag, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Sleeping bag", WeightGrams: 800})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, pack.ID, bag.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to add item to pack:", err)
+	}
+
+	items := []models.PackExportItem{
+		{Name: "Sleeping bag", Category: "Sleep", WeightGrams: 800, Count: 2, WornCount: 0},
+		{Name: "Rain jacket", Category: "Clothing", WeightGrams: 250, Price: 120, Count: 1, WornCount: 1},
+	}
+
+	if _, err := ImportPackItems(db, pack.ID, user.ID+1, items); err == nil {
+		t.Error("Expected import into another user's pack to be rejected")
+	}
+
+	imported, err := ImportPackItems(db, pack.ID, user.ID, items)
+	if err != nil {
+		t.Fatal("Failed to import pack items:", err)
+	}
+	if imported != 2 {
+		t.Errorf("Expected 2 imported rows, got %d", imported)
+	}
+
+	withItems, err := GetPackWithItems(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	if len(withItems.Items) != 2 {
+		t.Fatalf("Expected 2 items in pack, got %d", len(withItems.Items))
+	}
+	for _, packItem := range withItems.Items {
+		switch packItem.Item.Name {
+		case "Sleeping bag":
+			if packItem.ItemID != bag.ID || packItem.Count != 2 {
+				t.Errorf("Expected existing sleeping bag count set to 2, got item %d count %d", packItem.ItemID, packItem.Count)
+			}
+		case "Rain jacket":
+			if packItem.Item.Category.Name != "Clothing" || packItem.WornCount != 1 || !packItem.IsWorn {
+				t.Errorf("Expected new worn rain jacket in Clothing, got %+v", packItem)
+			}
+		default:
+			t.Errorf("Unexpected item %q in pack", packItem.Item.Name)
+		}
+	}
+
+	inventory, err := GetItems(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get items:", err)
+	}
+	if len(inventory) != 2 {
+		t.Errorf("Expected 2 inventory items after import, got %d", len(inventory))
+	}
+
+	duplicates := []models.PackExportItem{
+		{Name: "Stove", Category: "Cooking", Count: 1},
+		{Name: "Sleeping bag", Category: "Sleep", Count: 1},
+		{Name: "Sleeping bag", Category: "Sleep", Count: 3},
+	}
+	if _, err := ImportPackItems(db, pack.ID, user.ID, duplicates); err == nil {
+		t.Error("Expected duplicate rows to be rejected")
+	}
+
+	// The rejected import leaves no category behind
+	categories, err := GetCategories(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get categories:", err)
+	}
+	for _, c := range categories {
+		if c.Name == "Cooking" {
+			t.Error("Expected the Cooking category of the rejected import to be rolled back")
+		}
+	}
+}
+
+func testGPXTrack(lat float64) string {
+	return fmt.Sprintf(`<?xml version="1.0"?>
+<gpx version="1.1" creator="test"><trk><trkseg>
+<trkpt lat="%.4f" lon="6.0000"><ele>1000</ele></trkpt>
+<trkpt lat="%.4f" lon="6.0000"><ele>1100</ele></trkpt>
+</trkseg></trk></gpx>`, lat, lat+0.01)
+}
+
+func TestTripGPXFiles(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	trip, err := CreateTrip(db, user.ID, "Traverse", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+
+	if _, err := AddTripGPXFile(db, user.ID+1, trip.ID, "Day 1", testGPXTrack(45.0)); err == nil {
+		t.Error("Expected another user's upload to be rejected")
+	}
+
+	day1, err := AddTripGPXFile(db, user.ID, trip.ID, "Day 1", testGPXTrack(45.0))
+	if err != nil {
+		t.Fatal("Failed to add GPX file:", err)
+	}
+	day2, err := AddTripGPXFile(db, user.ID, trip.ID, "Day 2", testGPXTrack(46.0))
+	if err != nil {
+		t.Fatal("Failed to add GPX file:", err)
+	}
+
+	withDetails, err := GetTripWithDetails(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get trip:", err)
+	}
+	if len(withDetails.GPXFiles) != 2 || withDetails.GPXFiles[0].ID != day1.ID || withDetails.GPXFiles[1].Name != "Day 2" {
+		t.Fatalf("Expected Day 1 and Day 2 tracks, got %+v", withDetails.GPXFiles)
+	}
+	if !withDetails.HasGPXStats() || withDetails.GPXElevationGain() != 200 {
+		t.Errorf("Expected combined ascent of 200 m, got %v", withDetails.GPXElevationGain())
+	}
+	if *withDetails.GPXMinLat != 45.0 || *withDetails.GPXMaxLat != 46.01 {
+		t.Errorf("Expected bounding box to cover both tracks, got %v to %v", *withDetails.GPXMinLat, *withDetails.GPXMaxLat)
+	}
+
+	duplicate, err := DuplicateTrip(db, user.ID, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to duplicate trip:", err)
+	}
+	duplicateFiles, err := GetTripGPXFiles(db, duplicate.ID)
+	if err != nil {
+		t.Fatal("Failed to get duplicated GPX files:", err)
+	}
+	if len(duplicateFiles) != 2 {
+		t.Errorf("Expected 2 GPX files on duplicated trip, got %d", len(duplicateFiles))
+	}
+
+	if err := DeleteTripGPXFile(db, user.ID, duplicate.ID, day2.ID); err == nil {
+		t.Error("Expected deleting a track of another trip to fail")
+	}
+	if err := DeleteTripGPXFile(db, user.ID, trip.ID, day2.ID); err != nil {
+		t.Fatal("Failed to delete GPX file:", err)
+	}
+	trip, err = GetTrip(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get trip:", err)
+	}
+	if trip.GPXElevationGain() != 100 {
+		t.Errorf("Expected ascent of the remaining track only, got %v", trip.GPXElevationGain())
+	}
+
+	if err := DeleteTripGPXFile(db, user.ID, trip.ID, day1.ID); err != nil {
+		t.Fatal("Failed to delete GPX file:", err)
+	}
+	trip, err = GetTrip(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get trip:", err)
+	}
+	if trip.HasGPXStats() {
+		t.Error("Expected GPX stats to be cleared with the last track")
+	}
+
+	for i := 0; i < maxTripGPXFiles; i++ {
+		if _, err := AddTripGPXFile(db, user.ID, trip.ID, fmt.Sprintf("Day %d", i+1), testGPXTrack(45.0)); err != nil {
+			t.Fatalf("Failed to add GPX file %d: %v", i+1, err)
+		}
+	}
+	if _, err := AddTripGPXFile(db, user.ID, trip.ID, "One too many", testGPXTrack(45.0)); err == nil || !strings.Contains(err.Error(), "too many") {
+		t.Errorf("Expected the upload beyond the limit to be rejected, got %v", err)
+	}
+	files, err := GetTripGPXFiles(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get GPX files:", err)
+	}
+	if len(files) != maxTripGPXFiles {
+		t.Errorf("Expected %d GPX files, got %d", maxTripGPXFiles, len(files))
+	}
+}
+
+func TestTripGPXDataMigratedToFiles(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	trip, err := CreateTrip(db, user.ID, "Legacy", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+	if _, err := db.Exec(`UPDATE trips SET gpx_data = ? WHERE id = ?`, testGPXTrack(45.0), trip.ID); err != nil {
+		t.Fatal("Failed to set legacy GPX data:", err)
+	}
+
+	// Running the migration twice must not copy the track twice
+	for i := 0; i < 2; i++ {
+		if err := createTripGPXFilesTable(db); err != nil {
+			t.Fatal("Failed to migrate GPX data:", err)
+		}
+	}
+
+	files, err := GetTripGPXFiles(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get GPX files:", err)
+	}
+	if len(files) != 1 || files[0].GPXData != testGPXTrack(45.0) {
+		t.Fatalf("Expected the legacy track to be migrated once, got %d files", len(files))
+	}
+}
+
+func TestPublishTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	trip, err := CreateTrip(db, user.ID, "Alps", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+
+	if _, err := PublishTrip(db, user.ID+1, trip.ID); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected unauthorized error, got %v", err)
+	}
+
+	shortID, err := PublishTrip(db, user.ID, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to publish trip:", err)
+	}
+	if shortID == "" {
+		t.Fatal("Expected a short ID to be generated")
+	}
+
+	published, err := GetTripByShortID(db, shortID)
+	if err != nil {
+		t.Fatal("Failed to get published trip:", err)
+	}
+	if !published.IsPublic || published.ID != trip.ID {
+		t.Errorf("Expected trip %s to be public, got %+v", trip.ID, published)
+	}
+
+	// Publishing again keeps the existing link working
+	again, err := PublishTrip(db, user.ID, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to publish trip again:", err)
+	}
+	if again != shortID {
+		t.Errorf("Expected short ID %s to be kept, got %s", shortID, again)
+	}
+}
+
+func TestTOTPEnrollmentAndLogin(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal("Failed to generate secret:", err)
+	}
+	encrypted, err := totp.Encrypt("test-key", secret)
+	if err != nil {
+		t.Fatal("Failed to encrypt secret:", err)
+	}
+	if encrypted == secret {
+		t.Fatal("Expected the stored secret to be encrypted")
+	}
+
+	if err := BeginTOTPEnrollment(db, user.ID, encrypted); err != nil {
+		t.Fatal("Failed to begin enrollment:", err)
+	}
+	if enabled, _ := IsTOTPEnabled(db, user.ID); enabled {
+		t.Error("Expected 2FA to stay disabled until the enrollment is confirmed")
+	}
+
+	stored, err := GetUserTOTP(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get enrollment:", err)
+	}
+	decrypted, err := totp.Decrypt("test-key", stored.Secret)
+	if err != nil || decrypted != secret {
+		t.Fatalf("Expected stored secret to decrypt to the original, got %q (%v)", decrypted, err)
+	}
+	if _, err := totp.Decrypt("other-key", stored.Secret); err == nil {
+		t.Error("Expected decryption with the wrong key to fail")
+	}
+
+	now := time.Now()
+	code, err := totp.Code(secret, totp.Step(now))
+	if err != nil {
+		t.Fatal("Failed to compute code:", err)
+	}
+	step, ok := totp.Validate(secret, code, now)
+	if !ok {
+		t.Fatal("Expected the current code to validate")
+	}
+
+	recoveryCodes := []string{"aaaa-bbbb", "cccc-dddd"}
+	if err := EnableTOTP(db, user.ID, step, recoveryCodes); err != nil {
+		t.Fatal("Failed to enable 2FA:", err)
+	}
+	if enabled, _ := IsTOTPEnabled(db, user.ID); !enabled {
+		t.Fatal("Expected 2FA to be enabled")
+	}
+	if err := BeginTOTPEnrollment(db, user.ID, encrypted); err == nil {
+		t.Error("Expected a new enrollment to be refused while 2FA is enabled")
+	}
+
+	// The code used to enable 2FA can't be replayed, a later one can
+	if err := UseTOTPStep(db, user.ID, step); err == nil {
+		t.Error("Expected a used code to be rejected")
+	}
+	if err := UseTOTPStep(db, user.ID, step+1); err != nil {
+		t.Error("Expected the next code to be accepted:", err)
+	}
+
+	// Recovery codes are single use and tolerate case and separator differences
+	if err := UseRecoveryCode(db, user.ID, "AAAA BBBB"); err != nil {
+		t.Error("Expected recovery code to be accepted:", err)
+	}
+	if err := UseRecoveryCode(db, user.ID, "aaaa-bbbb"); err == nil {
+		t.Error("Expected a used recovery code to be rejected")
+	}
+	if count, _ := CountUnusedRecoveryCodes(db, user.ID); count != 1 {
+		t.Errorf("Expected 1 unused recovery code, got %d", count)
+	}
+
+	challenge, err := CreateLoginChallenge(db, user.ID, true)
+	if err != nil {
+		t.Fatal("Failed to create login challenge:", err)
+	}
+	got, err := GetLoginChallenge(db, challenge.Token)
+	if err != nil {
+		t.Fatal("Failed to get login challenge:", err)
+	}
+	if got.UserID != user.ID || !got.RememberMe {
+		t.Errorf("Unexpected login challenge %+v", got)
+	}
+
+	for i := 0; i < maxLoginChallengeAttempts; i++ {
+		if err := RecordLoginChallengeFailure(db, challenge.Token); err != nil {
+			t.Fatal("Failed to record failure:", err)
+		}
+	}
+	if _, err := GetLoginChallenge(db, challenge.Token); err == nil {
+		t.Error("Expected the challenge to be closed after too many wrong codes")
+	}
+
+	if err := DisableTOTP(db, user.ID); err != nil {
+		t.Fatal("Failed to disable 2FA:", err)
+	}
+	if enabled, _ := IsTOTPEnabled(db, user.ID); enabled {
+		t.Error("Expected 2FA to be disabled")
+	}
+	if err := UseRecoveryCode(db, user.ID, "cccc-dddd"); err == nil {
+		t.Error("Expected recovery codes to be removed with 2FA")
+	}
+}
+
+func TestOAuthIdentityLinking(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateOAuthUser(db, "alice", "alice@example.com", "github", "42")
+	if err != nil {
+		t.Fatal("Failed to create OAuth user:", err)
+	}
+	if !user.IsActivated || !user.IsAdmin {
+		t.Errorf("Expected the first OAuth user to be an activated admin, got %+v", user)
+	}
+
+	found, err := GetUserByOAuthIdentity(db, "github", "42")
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("Expected identity to map to user %d, got %+v (%v)", user.ID, found, err)
+	}
+	if _, err := GetUserByOAuthIdentity(db, "google", "42"); err == nil {
+		t.Error("Expected the subject to be scoped to its provider")
+	}
+
+	// The random password can't be guessed, the account only logs in through the provider
+	if _, err := AuthenticateUser(db, "alice@example.com", ""); err == nil {
+		t.Error("Expected password login to fail for an OAuth user")
+	}
+
+	second, err := CreateOAuthUser(db, "alice", "alice2@example.com", "google", "abc")
+	if err != nil {
+		t.Fatal("Failed to create second OAuth user:", err)
+	}
+	if second.Username != "alice2" || second.IsAdmin {
+		t.Errorf("Expected a non-admin user with a suffixed username, got %+v", second)
+	}
+
+	passwordUser, err := CreateUser(db, "bob", "bob@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	if err := LinkOAuthIdentity(db, passwordUser.ID, "github", "42"); err == nil {
+		t.Error("Expected linking an identity used by another user to fail")
+	}
+	if err := LinkOAuthIdentity(db, passwordUser.ID, "google", "xyz"); err != nil {
+		t.Fatal("Failed to link identity:", err)
+	}
+	if err := LinkOAuthIdentity(db, passwordUser.ID, "github", "7"); err == nil {
+		t.Error("Expected a user to be linked to a single identity")
+	}
+	if found, err := GetUserByOAuthIdentity(db, "google", "xyz"); err != nil || found.ID != passwordUser.ID {
+		t.Errorf("Expected linked identity to map to user %d, got %+v (%v)", passwordUser.ID, found, err)
+	}
+}
+
+func TestAnonymousPublicPack(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Shared")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	if err := UpdatePack(db, user.ID, pack.ID, "Shared", true, false, nil); err != nil {
+		t.Fatal("Failed to publish pack:", err)
+	}
+	packs, err := GetPublicPacks(db, 10, 0, PublicPackSortNewest)
+	if err != nil || len(packs) != 1 {
+		t.Fatalf("Expected 1 public pack, got %d (%v)", len(packs), err)
+	}
+	if packs[0].OwnerUsername != "testuser" {
+		t.Errorf("Expected owner to be shown by default, got %q", packs[0].OwnerUsername)
+	}
+
+	if err := UpdatePack(db, user.ID, pack.ID, "Shared", true, true, nil); err != nil {
+		t.Fatal("Failed to make pack anonymous:", err)
+	}
+	updated, err := GetPack(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	if !updated.IsAnonymous || !updated.IsPublic {
+		t.Errorf("Expected an anonymous public pack, got %+v", updated)
+	}
+
+	packs, err = GetPublicPacks(db, 10, 0, PublicPackSortNewest)
+	if err != nil || len(packs) != 1 {
+		t.Fatalf("Expected 1 public pack, got %d (%v)", len(packs), err)
+	}
+	if packs[0].OwnerUsername != "" {
+		t.Errorf("Expected anonymous pack to hide its owner, got %q", packs[0].OwnerUsername)
+	}
+}
+
+func TestPackCollaborators(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	owner, err := CreateUser(db, "owner", "owner@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create owner:", err)
+	}
+	editor, err := CreateUser(db, "editor", "editor@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create editor:", err)
+	}
+	stranger, err := CreateUser(db, "stranger", "stranger@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create stranger:", err)
+	}
+
+	category, err := CreateCategory(db, owner.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, owner.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 900})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, owner.ID, "Shared trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	if err := AddItemToPack(db, pack.ID, tent.ID, editor.ID, false); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected unauthorized before the pack is shared, got %v", err)
+	}
+
+	if _, err := AddPackCollaborator(db, editor.ID, pack.ID, "stranger@example.com", PackRoleEditor); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected only the owner to share the pack, got %v", err)
+	}
+	if _, err := AddPackCollaborator(db, owner.ID, pack.ID, "nobody@example.com", PackRoleEditor); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected unknown email to be rejected, got %v", err)
+	}
+	if _, err := AddPackCollaborator(db, owner.ID, pack.ID, "owner@example.com", PackRoleEditor); err == nil {
+		t.Error("Expected sharing with the owner to fail")
+	}
+	if _, err := AddPackCollaborator(db, owner.ID, pack.ID, "editor@example.com", "admin"); err == nil || !strings.Contains(err.Error(), "invalid role") {
+		t.Errorf("Expected invalid role to be rejected, got %v", err)
+	}
+
+	collaborator, err := AddPackCollaborator(db, owner.ID, pack.ID, "editor@example.com", PackRoleEditor)
+	if err != nil {
+		t.Fatal("Failed to add collaborator:", err)
+	}
+	if collaborator.UserID != editor.ID {
+		t.Errorf("Expected collaborator %d, got %d", editor.ID, collaborator.UserID)
+	}
+	// Sharing twice keeps a single collaborator
+	if _, err := AddPackCollaborator(db, owner.ID, pack.ID, "editor@example.com", PackRoleEditor); err != nil {
+		t.Fatal("Failed to share pack again:", err)
+	}
+	collaborators, err := GetPackCollaborators(db, pack.ID)
+	if err != nil || len(collaborators) != 1 {
+		t.Fatalf("Expected 1 collaborator, got %d (%v)", len(collaborators), err)
+	}
+
+	// The editor packs the owner's gear and edits the notes
+	if err := AddItemToPack(db, pack.ID, tent.ID, editor.ID, false); err != nil {
+		t.Fatal("Editor failed to add item:", err)
+	}
+	if err := UpdatePackNote(db, editor.ID, pack.ID, "Bring the stakes"); err != nil {
+		t.Fatal("Editor failed to update note:", err)
+	}
+	if _, err := CreatePackLabel(db, pack.ID, "Shelter", "#123456", editor.ID); err != nil {
+		t.Fatal("Editor failed to create label:", err)
+	}
+	if err := UpdatePackNote(db, stranger.ID, pack.ID, "Hijacked"); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected stranger to be unauthorized, got %v", err)
+	}
+
+	// Deleting and changing visibility stay with the owner
+	if err := UpdatePack(db, editor.ID, pack.ID, "Renamed", true, false, nil); err == nil {
+		t.Error("Expected editor not to change the pack visibility")
+	}
+	if err := DeletePack(db, editor.ID, pack.ID); err == nil {
+		t.Error("Expected editor not to delete the pack")
+	}
+
+	own, err := GetPacks(db, editor.ID)
+	if err != nil || len(own) != 0 {
+		t.Fatalf("Expected shared packs to be left out of GetPacks, got %d (%v)", len(own), err)
+	}
+	packs, err := GetPacksIncludingShared(db, editor.ID)
+	if err != nil || len(packs) != 1 {
+		t.Fatalf("Expected 1 shared pack, got %d (%v)", len(packs), err)
+	}
+	if packs[0].SharedRole != PackRoleEditor || packs[0].OwnerUsername != "owner" {
+		t.Errorf("Expected pack shared by owner as editor, got role %q owner %q", packs[0].SharedRole, packs[0].OwnerUsername)
+	}
+
+	if err := RemovePackCollaborator(db, stranger.ID, pack.ID, editor.ID); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected stranger not to remove collaborators, got %v", err)
+	}
+	// Collaborators can leave on their own
+	if err := RemovePackCollaborator(db, editor.ID, pack.ID, editor.ID); err != nil {
+		t.Fatal("Failed to leave pack:", err)
+	}
+	if err := UpdatePackNote(db, editor.ID, pack.ID, "Too late"); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected former editor to be unauthorized, got %v", err)
+	}
+
+	// Collaborators go away with the pack
+	if _, err := AddPackCollaborator(db, owner.ID, pack.ID, "editor@example.com", PackRoleEditor); err != nil {
+		t.Fatal("Failed to share pack again:", err)
+	}
+	if err := DeletePack(db, owner.ID, pack.ID); err != nil {
+		t.Fatal("Failed to delete pack:", err)
+	}
+	shared, err := GetSharedPacks(db, editor.ID)
+	if err != nil || len(shared) != 0 {
+		t.Errorf("Expected no shared packs after deletion, got %d (%v)", len(shared), err)
+	}
+}
+
+func TestShortIDGeneration(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	// Public trips get their short IDs from the trips table, many in a row never collide
+	seen := make(map[string]bool)
+	for i := 0; i < 500; i++ {
+		trip, err := CreateTrip(db, user.ID, fmt.Sprintf("Trip %d", i), nil, nil, nil, nil, true)
+		if err != nil {
+			t.Fatalf("Failed to create trip %d: %v", i, err)
+		}
+		if len(trip.ShortID) != DefaultShortIDConfig.Length {
+			t.Fatalf("Expected a %d character short ID, got %q", DefaultShortIDConfig.Length, trip.ShortID)
+		}
+		if seen[trip.ShortID] {
+			t.Fatalf("Short ID %q generated twice", trip.ShortID)
+		}
+		seen[trip.ShortID] = true
+	}
+
+	// With a single possible ID, the generator checks the table it was given
+	single := ShortIDConfig{Charset: "xy", Length: 1, MaxRetries: 20}
+	trip, err := CreateTrip(db, user.ID, "Taken", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+	if _, err := db.Exec("UPDATE trips SET short_id = 'x' WHERE id = ?", trip.ID); err != nil {
+		t.Fatal("Failed to set trip short ID:", err)
+	}
+	for i := 0; i < 10; i++ {
+		shortID, err := generateShortIDWithConfig(db, shortIDTableTrips, single)
+		if err != nil {
+			t.Fatal("Failed to generate trip short ID:", err)
+		}
+		if shortID != "y" {
+			t.Fatalf("Expected the only free trip short ID, got %q", shortID)
+		}
+	}
+	if _, err := generateShortIDWithConfig(db, shortIDTablePacks, ShortIDConfig{Charset: "x", Length: 1, MaxRetries: 1}); err == nil {
+		t.Error("Expected a single character charset to be rejected")
+	}
+
+	// Once every ID is taken the caller is told retries ran out
+	if _, err := db.Exec("UPDATE trips SET short_id = 'y' WHERE id = (SELECT id FROM trips WHERE short_id != 'x' LIMIT 1)"); err != nil {
+		t.Fatal("Failed to set trip short ID:", err)
+	}
+	_, err = generateShortIDWithConfig(db, shortIDTableTrips, single)
+	if !errors.Is(err, ErrShortIDExhausted) {
+		t.Errorf("Expected ErrShortIDExhausted, got %v", err)
+	}
+}
+
+func TestTripShortIDDuplicatesBackfill(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	// Recreate a database from before the unique index, where trips could share a short ID
+	if _, err := db.Exec("DROP INDEX idx_trips_short_id_unique"); err != nil {
+		t.Fatal("Failed to drop index:", err)
+	}
+	var tripIDs []string
+	for i, created := range []string{"2024-01-01 10:00:00", "2024-02-01 10:00:00", "2024-03-01 10:00:00"} {
+		trip, err := CreateTrip(db, user.ID, fmt.Sprintf("Trip %d", i), nil, nil, nil, nil, false)
+		if err != nil {
+			t.Fatal("Failed to create trip:", err)
+		}
+		if _, err := db.Exec("UPDATE trips SET short_id = 'dupe1234', is_public = TRUE, created_at = ? WHERE id = ?", created, trip.ID); err != nil {
+			t.Fatal("Failed to set short ID:", err)
+		}
+		tripIDs = append(tripIDs, trip.ID)
+	}
+	empty, err := CreateTrip(db, user.ID, "Empty", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+	if _, err := db.Exec("UPDATE trips SET short_id = '' WHERE id = ?", empty.ID); err != nil {
+		t.Fatal("Failed to set short ID:", err)
+	}
+
+	if err := addTripShortIDUniqueIndex(db); err != nil {
+		t.Fatal("Failed to run migration:", err)
+	}
+
+	// The oldest trip keeps the short ID
+	kept, err := GetTripByShortID(db, "dupe1234")
+	if err != nil {
+		t.Fatal("Failed to get trip by short ID:", err)
+	}
+	if kept.ID != tripIDs[0] {
+		t.Errorf("Expected oldest trip %s to keep its short ID, got %s", tripIDs[0], kept.ID)
+	}
+
+	var distinct int
+	if err := db.QueryRow("SELECT COUNT(DISTINCT short_id) FROM trips WHERE short_id IS NOT NULL").Scan(&distinct); err != nil {
+		t.Fatal("Failed to count short IDs:", err)
+	}
+	if distinct != 3 {
+		t.Errorf("Expected 3 distinct short IDs, got %d", distinct)
+	}
+
+	var emptyShortID sql.NullString
+	if err := db.QueryRow("SELECT short_id FROM trips WHERE id = ?", empty.ID).Scan(&emptyShortID); err != nil {
+		t.Fatal("Failed to get short ID:", err)
+	}
+	if emptyShortID.Valid {
+		t.Errorf("Expected empty short ID to be cleared, got %q", emptyShortID.String)
+	}
+
+	if _, err := db.Exec("UPDATE trips SET short_id = 'dupe1234' WHERE id = ?", tripIDs[1]); err == nil {
+		t.Error("Expected the unique index to reject a duplicate short ID")
+	}
+}
+
+func TestDuplicatePackCopyName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"Weekend Trip", "Weekend Trip Copy"},
+		{"Weekend Trip Copy", "Weekend Trip Copy 2"},
+		{"Weekend Trip Copy 2", "Weekend Trip Copy 3"},
+		{"Weekend Trip Copy 19", "Weekend Trip Copy 20"},
+		{"Copycat", "Copycat Copy"},
+		{"Trip Copy two", "Trip Copy two Copy"},
+	}
+	for _, tt := range tests {
+		if got := nextCopyName(tt.name); got != tt.expected {
+			t.Errorf("nextCopyName(%q) = %q, expected %q", tt.name, got, tt.expected)
+		}
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Weekend Trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	// Duplicating the base pack again skips names already taken
+	for _, expected := range []string{"Weekend Trip Copy", "Weekend Trip Copy 2", "Weekend Trip Copy 3"} {
+		duplicate, err := DuplicatePack(db, user.ID, pack.ID)
+		if err != nil {
+			t.Fatal("Failed to duplicate pack:", err)
+		}
+		if duplicate.Name != expected {
+			t.Errorf("Expected %q, got %q", expected, duplicate.Name)
+		}
+	}
+
+	copies, err := GetPacks(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs:", err)
+	}
+	var copy2 string
+	for _, p := range copies {
+		if p.Name == "Weekend Trip Copy 2" {
+			copy2 = p.ID
+		}
+	}
+	duplicate, err := DuplicatePack(db, user.ID, copy2)
+	if err != nil {
+		t.Fatal("Failed to duplicate copy:", err)
+	}
+	if duplicate.Name != "Weekend Trip Copy 4" {
+		t.Errorf("Expected duplicating a copy to increment its number, got %q", duplicate.Name)
+	}
+}
+
+func TestAddItemsToPack(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "other", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create other user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 900})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	stakes, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Stakes", WeightGrams: 60})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, pack.ID, tent.ID, user.ID, false); err != nil {
+		t.Fatal("Failed to add item:", err)
+	}
+
+	added, present, err := AddItemsToPack(db, pack.ID, []int{tent.ID, stakes.ID, stakes.ID}, user.ID)
+	if err != nil {
+		t.Fatal("Failed to add items:", err)
+	}
+	if len(added) != 1 || added[0] != stakes.ID {
+		t.Errorf("Expected stakes to be added, got %v", added)
+	}
+	if len(present) != 1 || present[0] != tent.ID {
+		t.Errorf("Expected tent to be already present, got %v", present)
+	}
+
+	counts := make(map[int]int)
+	withItems, err := GetPackWithItems(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	for _, packItem := range withItems.Items {
+		counts[packItem.Item.ID] = packItem.Count
+	}
+	if counts[tent.ID] != 2 || counts[stakes.ID] != 1 {
+		t.Errorf("Expected tent x2 and stakes x1, got %v", counts)
+	}
+
+	// An unknown item rolls back the whole batch
+	if _, _, err := AddItemsToPack(db, pack.ID, []int{stakes.ID, 9999}, user.ID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected unknown item to be rejected, got %v", err)
+	}
+	withItems, err = GetPackWithItems(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	for _, packItem := range withItems.Items {
+		if packItem.Item.ID == stakes.ID && packItem.Count != 1 {
+			t.Errorf("Expected failed batch not to change counts, got %d", packItem.Count)
+		}
+	}
+
+	if _, _, err := AddItemsToPack(db, pack.ID, []int{tent.ID}, other.ID); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("Expected another user to be unauthorized, got %v", err)
+	}
+
+	// Items in the trash can't be packed
+	stove, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Stove", WeightGrams: 80})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	if err := DeleteItem(db, user.ID, stove.ID); err != nil {
+		t.Fatal("Failed to trash item:", err)
+	}
+	if _, _, err := AddItemsToPack(db, pack.ID, []int{stove.ID}, user.ID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a trashed item to be rejected, got %v", err)
+	}
+	var rows int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pack_items WHERE item_id = ?", stove.ID).Scan(&rows); err != nil || rows != 0 {
+		t.Errorf("Expected no pack row for the trashed item, got %d (%v)", rows, err)
+	}
+}
+
+func TestRemoveItemFromPackClampsWornCount(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Clothing")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	socks, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Socks", WeightGrams: 50})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := AddItemToPack(db, pack.ID, socks.ID, user.ID, false); err != nil {
+		t.Fatal("Failed to add item:", err)
+	}
+	if err := SetPackItemCount(db, pack.ID, socks.ID, user.ID, 2); err != nil {
+		t.Fatal("Failed to set count:", err)
+	}
+	if err := SetPackItemWornCount(db, pack.ID, socks.ID, user.ID, 2); err != nil {
+		t.Fatal("Failed to set worn count:", err)
+	}
+
+	packItem := func() models.PackItem {
+		t.Helper()
+		withItems, err := GetPackWithItems(db, pack.ID)
+		if err != nil {
+			t.Fatal("Failed to get pack:", err)
+		}
+		if len(withItems.Items) != 1 {
+			t.Fatalf("Expected 1 item in pack, got %d", len(withItems.Items))
+		}
+		return withItems.Items[0]
+	}
+
+	if err := RemoveItemFromPack(db, pack.ID, socks.ID, user.ID); err != nil {
+		t.Fatal("Failed to remove item:", err)
+	}
+	item := packItem()
+	if item.Count != 1 || item.WornCount != 1 || !item.IsWorn {
+		t.Errorf("Expected count 1 worn 1, got count %d worn %d (is_worn %v)", item.Count, item.WornCount, item.IsWorn)
+	}
+	if item.WornCount > item.Count {
+		t.Errorf("Expected worn_count <= count, got %d > %d", item.WornCount, item.Count)
+	}
+
+	// A partly worn item keeps its worn count while there are enough left
+	if err := SetPackItemCount(db, pack.ID, socks.ID, user.ID, 3); err != nil {
+		t.Fatal("Failed to set count:", err)
+	}
+	if err := RemoveItemFromPack(db, pack.ID, socks.ID, user.ID); err != nil {
+		t.Fatal("Failed to remove item:", err)
+	}
+	item = packItem()
+	if item.Count != 2 || item.WornCount != 1 {
+		t.Errorf("Expected count 2 worn 1, got count %d worn %d", item.Count, item.WornCount)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "otheruser", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	token, apiToken, err := CreateAPIToken(db, user.ID, "Sync script")
+	if err != nil {
+		t.Fatal("Failed to create API token:", err)
+	}
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		t.Errorf("Expected token to start with %q, got %q", APITokenPrefix, token)
+	}
+
+	// Only the hash is stored
+	var stored string
+	if err := db.QueryRow("SELECT token_hash FROM api_tokens WHERE id = ?", apiToken.ID).Scan(&stored); err != nil {
+		t.Fatal("Failed to read stored token:", err)
+	}
+	if stored == token || strings.Contains(stored, token) {
+		t.Error("Expected the token to be stored hashed")
+	}
+
+	tokenUser, validated, err := ValidateAPIToken(db, token)
+	if err != nil {
+		t.Fatal("Failed to validate API token:", err)
+	}
+	if tokenUser.ID != user.ID || validated.ID != apiToken.ID {
+		t.Errorf("Expected token of user %d, got user %d", user.ID, tokenUser.ID)
+	}
+
+	tokens, err := GetAPITokens(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get API tokens:", err)
+	}
+	if len(tokens) != 1 || tokens[0].Name != "Sync script" || tokens[0].LastUsedAt == nil {
+		t.Fatalf("Expected one used token named Sync script, got %+v", tokens)
+	}
+
+	for _, invalid := range []string{"", "cl_", token + "x", strings.TrimPrefix(token, APITokenPrefix)} {
+		if _, _, err := ValidateAPIToken(db, invalid); err == nil {
+			t.Errorf("Expected token %q to be rejected", invalid)
+		}
+	}
+
+	if err := DeleteAPIToken(db, other.ID, apiToken.ID); err == nil {
+		t.Error("Expected another user to be unable to revoke the token")
+	}
+	if err := DeleteAPIToken(db, user.ID, apiToken.ID); err != nil {
+		t.Fatal("Failed to revoke API token:", err)
+	}
+	if _, _, err := ValidateAPIToken(db, token); err == nil {
+		t.Error("Expected a revoked token to be rejected")
+	}
+}
+
+func TestReorderCategories(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "otheruser", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	var categories []*models.Category
+	for _, name := range []string{"Cooking", "Shelter", "Sleep"} {
+		category, err := CreateCategory(db, user.ID, name)
+		if err != nil {
+			t.Fatal("Failed to create category:", err)
+		}
+		categories = append(categories, category)
+	}
+	cooking, shelter, sleep := categories[0], categories[1], categories[2]
+
+	names := func() []string {
+		t.Helper()
+		categories, err := GetCategories(db, user.ID)
+		if err != nil {
+			t.Fatal("Failed to get categories:", err)
+		}
+		var names []string
+		for _, category := range categories {
+			names = append(names, category.Name)
+		}
+		return names
+	}
+
+	// Categories sort by name until they are reordered
+	if got := strings.Join(names(), ","); got != "Cooking,Shelter,Sleep" {
+		t.Errorf("Expected categories by name, got %s", got)
+	}
+
+	if err := ReorderCategories(db, user.ID, []int{shelter.ID, sleep.ID, cooking.ID}); err != nil {
+		t.Fatal("Failed to reorder categories:", err)
+	}
+	if got := strings.Join(names(), ","); got != "Shelter,Sleep,Cooking" {
+		t.Errorf("Expected custom category order, got %s", got)
+	}
+
+	// New categories go after the ordered ones
+	if _, err := CreateCategory(db, user.ID, "Bags"); err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	if got := strings.Join(names(), ","); got != "Shelter,Sleep,Cooking,Bags" {
+		t.Errorf("Expected new category last, got %s", got)
+	}
+
+	// Pack items follow the category order
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for _, category := range []*models.Category{cooking, shelter} {
+		item, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: category.Name + " item", WeightGrams: 100})
+		if err != nil {
+			t.Fatal("Failed to create item:", err)
+		}
+		if err := AddItemToPack(db, pack.ID, item.ID, user.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+	}
+	withItems, err := GetPackWithItems(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	if len(withItems.Items) != 2 || withItems.Items[0].Item.Category.Name != "Shelter" {
+		t.Errorf("Expected Shelter items first in the pack, got %+v", withItems.Items)
+	}
+
+	if err := ReorderCategories(db, other.ID, []int{cooking.ID}); err == nil {
+		t.Error("Expected another user to be unable to reorder the categories")
+	}
+}
+
+func TestPackItemCapacityLiters(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Water")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Canoe trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+
+	capacity := func(value float64, unit string) (*float64, *string) {
+		return &value, &unit
+	}
+	bottleCapacity, bottleUnit := capacity(750, "mL")
+	bagCapacity, bagUnit := capacity(20, "L")
+	batteryCapacity, batteryUnit := capacity(10000, "mAh")
+	for _, item := range []models.Item{
+		{CategoryID: category.ID, Name: "Bottle", WeightGrams: 100, Capacity: bottleCapacity, CapacityUnit: bottleUnit},
+		{CategoryID: category.ID, Name: "Dry bag", WeightGrams: 200, Capacity: bagCapacity, CapacityUnit: bagUnit},
+		{CategoryID: category.ID, Name: "Battery", WeightGrams: 180, Capacity: batteryCapacity, CapacityUnit: batteryUnit},
+	} {
+		created, err := CreateItem(db, user.ID, item)
+		if err != nil {
+			t.Fatal("Failed to create item:", err)
+		}
+		if err := AddItemToPack(db, pack.ID, created.ID, user.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+	}
+
+	withItems, err := GetPackWithItems(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+
+	total := 0.0
+	for _, packItem := range withItems.Items {
+		liters, ok := packItem.Item.CapacityLiters()
+		if packItem.Item.Name == "Battery" {
+			if ok {
+				t.Error("Expected battery capacity not to count as volume")
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("Expected %s to have a volume", packItem.Item.Name)
+		}
+		total += liters * float64(packItem.Count)
+	}
+	if math.Abs(total-20.75) > 0.001 {
+		t.Errorf("Expected 20.75 L, got %f", total)
+	}
+}
+
+func TestRunCleanup(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	expired, err := CreateSession(db, user.ID, -time.Hour)
+	if err != nil {
+		t.Fatal("Failed to create session:", err)
+	}
+	valid, err := CreateSession(db, user.ID, time.Hour)
+	if err != nil {
+		t.Fatal("Failed to create session:", err)
+	}
+	if _, err := db.Exec("INSERT INTO csrf_tokens (token, user_id, expires_at) VALUES (?, ?, ?)", "expired", user.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal("Failed to create CSRF token:", err)
+	}
+	csrfToken, err := CreateCSRFToken(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to create CSRF token:", err)
+	}
+
+	// The job cleans up right away, and stopping it waits for that run
+	stop := StartCleanupJob(db, time.Hour)
+	stop()
+	stop()
+
+	sessionExists := func(id string) bool {
+		t.Helper()
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM sessions WHERE id = ?", id).Scan(&count); err != nil {
+			t.Fatal("Failed to count sessions:", err)
+		}
+		return count > 0
+	}
+	if sessionExists(expired.ID) {
+		t.Error("Expected the expired session to be removed")
+	}
+	if !sessionExists(valid.ID) {
+		t.Error("Expected the valid session to be kept")
+	}
+
+	var tokens []string
+	rows, err := db.Query("SELECT token FROM csrf_tokens")
+	if err != nil {
+		t.Fatal("Failed to query CSRF tokens:", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			t.Fatal("Failed to scan CSRF token:", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if len(tokens) != 1 || tokens[0] != csrfToken.Token {
+		t.Errorf("Expected only the valid CSRF token to be kept, got %v", tokens)
+	}
+
+	if removed, err := CleanupExpiredSessions(db); err != nil || removed != 0 {
+		t.Errorf("Expected nothing left to clean up, got %d (%v)", removed, err)
+	}
+}
+
+func TestInitializeUsesWAL(t *testing.T) {
+	db, err := Initialize(filepath.Join(t.TempDir(), "test.db"), 0, 1)
+	if err != nil {
+		t.Fatal("Failed to initialize database:", err)
+	}
+	defer db.Close()
+
+	var journalMode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Fatal("Failed to read journal mode:", err)
+	}
+	if journalMode != "wal" {
+		t.Errorf("Expected WAL journal mode, got %s", journalMode)
+	}
+
+	var busyTimeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+		t.Fatal("Failed to read busy timeout:", err)
+	}
+	if busyTimeout != 5000 {
+		t.Errorf("Expected a 5000ms busy timeout, got %d", busyTimeout)
+	}
+
+	if open := db.Stats().MaxOpenConnections; open != 0 {
+		t.Errorf("Expected an unlimited pool by default, got %d connections", open)
+	}
+}
+
+func TestClonePackToUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	organizer, err := CreateUser(db, "organizer", "organizer@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	participant, err := CreateUser(db, "participant", "participant@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	shelter, err := CreateCategory(db, organizer.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, organizer.ID, models.Item{CategoryID: shelter.ID, Name: "Tent", WeightGrams: 1200})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	stakes, err := CreateItem(db, organizer.ID, models.Item{CategoryID: shelter.ID, Name: "Stakes", WeightGrams: 10})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, organizer.ID, "Group trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for _, item := range []*models.Item{tent, stakes} {
+		if err := AddItemToPack(db, pack.ID, item.ID, organizer.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+	}
+	if err := SetPackItemCount(db, pack.ID, stakes.ID, organizer.ID, 8); err != nil {
+		t.Fatal("Failed to set count:", err)
+	}
+
+	// Private packs can't be cloned
+	if _, err := ClonePackToUser(db, pack.ID, participant.ID); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("Expected cloning a private pack to be unauthorized, got %v", err)
+	}
+	if _, err := db.Exec("UPDATE packs SET is_public = TRUE WHERE id = ?", pack.ID); err != nil {
+		t.Fatal("Failed to make pack public:", err)
+	}
+
+	// The participant already owns a tent, which is reused
+	participantShelter, err := CreateCategory(db, participant.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	ownTent, err := CreateItem(db, participant.ID, models.Item{CategoryID: participantShelter.ID, Name: "Tent", WeightGrams: 900})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	clone, err := ClonePackToUser(db, pack.ID, participant.ID)
+	if err != nil {
+		t.Fatal("Failed to clone pack:", err)
+	}
+	if clone.UserID != participant.ID || clone.Name != "Group trip" {
+		t.Errorf("Expected the participant's Group trip, got user %d %q", clone.UserID, clone.Name)
+	}
+
+	withItems, err := GetPackWithItems(db, clone.ID)
+	if err != nil {
+		t.Fatal("Failed to get cloned pack:", err)
+	}
+	if len(withItems.Items) != 2 {
+		t.Fatalf("Expected 2 items in the clone, got %d", len(withItems.Items))
+	}
+	for _, packItem := range withItems.Items {
+		if packItem.Item.UserID != participant.ID {
+			t.Errorf("Expected %s to belong to the participant", packItem.Item.Name)
+		}
+		switch packItem.Item.Name {
+		case "Tent":
+			if packItem.Item.ID != ownTent.ID {
+				t.Error("Expected the participant's own tent to be reused")
+			}
+		case "Stakes":
+			if packItem.Count != 8 {
+				t.Errorf("Expected 8 stakes, got %d", packItem.Count)
+			}
+		}
+	}
+
+	items, err := GetItems(db, participant.ID)
+	if err != nil {
+		t.Fatal("Failed to get items:", err)
+	}
+	if len(items) != 2 {
+		t.Errorf("Expected the participant to own 2 items, got %d", len(items))
+	}
+}
+
+func TestClonePackToUserMergesSameItems(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	organizer, err := CreateUser(db, "organizer", "organizer@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	participant, err := CreateUser(db, "participant", "participant@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	shelter, err := CreateCategory(db, organizer.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	pack, err := CreatePack(db, organizer.ID, "Group trip")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	// Two distinct items with the same name would both map to one item of the participant
+	for _, count := range []int{4, 6} {
+		stakes, err := CreateItem(db, organizer.ID, models.Item{CategoryID: shelter.ID, Name: "Stakes", WeightGrams: 10})
+		if err != nil {
+			t.Fatal("Failed to create item:", err)
+		}
+		if err := AddItemToPack(db, pack.ID, stakes.ID, organizer.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+		if err := SetPackItemCount(db, pack.ID, stakes.ID, organizer.ID, count); err != nil {
+			t.Fatal("Failed to set count:", err)
+		}
+	}
+	if _, err := db.Exec("UPDATE packs SET is_public = TRUE WHERE id = ?", pack.ID); err != nil {
+		t.Fatal("Failed to make pack public:", err)
+	}
+
+	clone, err := ClonePackToUser(db, pack.ID, participant.ID)
+	if err != nil {
+		t.Fatal("Failed to clone pack:", err)
+	}
+
+	withItems, err := GetPackWithItems(db, clone.ID)
+	if err != nil {
+		t.Fatal("Failed to get cloned pack:", err)
+	}
+	if len(withItems.Items) != 1 {
+		t.Fatalf("Expected the stakes to be merged into 1 item, got %d", len(withItems.Items))
+	}
+	if withItems.Items[0].Count != 10 {
+		t.Errorf("Expected 10 stakes, got %d", withItems.Items[0].Count)
+	}
+}
+
+func TestSetPackItemWornCount(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Clothing")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	jacket, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Jacket", WeightGrams: 300})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	socks, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Socks", WeightGrams: 50})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for _, item := range []*models.Item{jacket, socks} {
+		if err := AddItemToPack(db, pack.ID, item.ID, user.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+	}
+	if err := SetPackItemCount(db, pack.ID, socks.ID, user.ID, 5); err != nil {
+		t.Fatal("Failed to set count:", err)
+	}
+
+	packItem := func(itemID int) models.PackItem {
+		t.Helper()
+		withItems, err := GetPackWithItems(db, pack.ID)
+		if err != nil {
+			t.Fatal("Failed to get pack:", err)
+		}
+		for _, packItem := range withItems.Items {
+			if packItem.Item.ID == itemID {
+				return packItem
+			}
+		}
+		t.Fatalf("Item %d not in pack", itemID)
+		return models.PackItem{}
+	}
+
+	// Count 1: worn is 0 or 1
+	if err := SetPackItemWornCount(db, pack.ID, jacket.ID, user.ID, 1); err != nil {
+		t.Fatal("Failed to set worn count:", err)
+	}
+	if item := packItem(jacket.ID); item.WornCount != 1 || !item.IsWorn {
+		t.Errorf("Expected the jacket to be worn, got worn_count %d is_worn %v", item.WornCount, item.IsWorn)
+	}
+	if err := SetPackItemWornCount(db, pack.ID, jacket.ID, user.ID, 2); err == nil || !strings.Contains(err.Error(), "invalid worn count") {
+		t.Errorf("Expected a worn count above the count to be rejected, got %v", err)
+	}
+	if err := SetPackItemWornCount(db, pack.ID, jacket.ID, user.ID, 0); err != nil {
+		t.Fatal("Failed to set worn count:", err)
+	}
+	if item := packItem(jacket.ID); item.WornCount != 0 || item.IsWorn {
+		t.Errorf("Expected the jacket to be carried, got worn_count %d is_worn %v", item.WornCount, item.IsWorn)
+	}
+	if err := TogglePackItemWorn(db, pack.ID, jacket.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to toggle worn:", err)
+	}
+	if item := packItem(jacket.ID); item.WornCount != 1 {
+		t.Errorf("Expected toggling to wear the jacket, got worn_count %d", item.WornCount)
+	}
+
+	// Count 5: any worn count up to 5
+	if err := SetPackItemWornCount(db, pack.ID, socks.ID, user.ID, 3); err != nil {
+		t.Fatal("Failed to set worn count:", err)
+	}
+	if item := packItem(socks.ID); item.WornCount != 3 || !item.IsWorn {
+		t.Errorf("Expected 3 worn socks, got worn_count %d is_worn %v", item.WornCount, item.IsWorn)
+	}
+	for _, wornCount := range []int{-1, 6} {
+		if err := SetPackItemWornCount(db, pack.ID, socks.ID, user.ID, wornCount); err == nil || !strings.Contains(err.Error(), "invalid worn count") {
+			t.Errorf("Expected worn count %d to be rejected, got %v", wornCount, err)
+		}
+	}
+	// A checkbox can't say how many socks are worn
+	if err := TogglePackItemWorn(db, pack.ID, socks.ID, user.ID, true); err == nil || !strings.Contains(err.Error(), "invalid worn count") {
+		t.Errorf("Expected toggling an item packed 5 times to be rejected, got %v", err)
+	}
+	if item := packItem(socks.ID); item.WornCount != 3 {
+		t.Errorf("Expected the worn count to stay at 3, got %d", item.WornCount)
+	}
+	if err := TogglePackItemWorn(db, pack.ID, socks.ID, user.ID, false); err != nil {
+		t.Fatal("Failed to toggle worn:", err)
+	}
+	if item := packItem(socks.ID); item.WornCount != 0 || item.IsWorn {
+		t.Errorf("Expected no worn socks, got worn_count %d is_worn %v", item.WornCount, item.IsWorn)
+	}
+
+	if err := SetPackItemWornCount(db, pack.ID, 9999, user.ID, 0); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected an item not in the pack to be not found, got %v", err)
+	}
+}
+
+func TestTogglePackItemConsumable(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Kitchen")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	stove, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Stove", WeightGrams: 80})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	food, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Food", WeightGrams: 600})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for _, item := range []*models.Item{stove, food} {
+		if err := AddItemToPack(db, pack.ID, item.ID, user.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+	}
+
+	if err := TogglePackItemConsumable(db, pack.ID, food.ID, user.ID+1, true); err == nil {
+		t.Error("Expected another user's change to be rejected")
+	}
+	if err := TogglePackItemConsumable(db, pack.ID, food.ID+100, user.ID, true); err == nil {
+		t.Error("Expected an item outside the pack to be rejected")
+	}
+
+	if err := TogglePackItemConsumable(db, pack.ID, food.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to mark consumable:", err)
+	}
+	withItems, err := GetPackWithItems(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	for _, packItem := range withItems.Items {
+		if packItem.IsConsumable != (packItem.ItemID == food.ID) {
+			t.Errorf("Expected only the food to be consumable, got %s consumable=%v", packItem.Item.Name, packItem.IsConsumable)
+		}
+	}
+
+	// The food still counts towards the carried weight, not towards the base weight
+	breakdown := models.NewPackWeightBreakdown(withItems.Items)
+	if breakdown.Weight != 680 || breakdown.ConsumableWeight != 600 || breakdown.BaseWeight != 80 {
+		t.Errorf("Expected 680g carried with an 80g base weight, got %+v", breakdown)
+	}
+	history, err := GetPackWeightHistory(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get weight history:", err)
+	}
+	if len(history) != 1 || history[0].TotalGrams != 680 || history[0].BaseGrams != 80 {
+		t.Errorf("Expected a 680g snapshot with an 80g base weight, got %+v", history)
+	}
+
+	if err := TogglePackItemConsumable(db, pack.ID, food.ID, user.ID, false); err != nil {
+		t.Fatal("Failed to unmark consumable:", err)
+	}
+	withItems, err = GetPackWithItems(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	if breakdown := models.NewPackWeightBreakdown(withItems.Items); breakdown.BaseWeight != 680 {
+		t.Errorf("Expected the food back in the base weight, got %+v", breakdown)
+	}
+}
+
+func TestGetInventorySummary(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	summary, err := GetInventorySummary(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get summary for empty inventory:", err)
+	}
+	if summary.ItemCount != 0 || summary.TotalWeight != 0 || summary.TotalPrice != 0 || summary.Currency != "$" {
+		t.Errorf("Expected an empty summary in $, got %+v", summary)
+	}
+
+	if err := UpdateUserCurrency(db, user.ID, "€"); err != nil {
+		t.Fatal("Failed to update currency:", err)
+	}
+	sleep, err := CreateCategory(db, user.ID, "Sleep")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	kitchen, err := CreateCategory(db, user.ID, "Kitchen")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	items := []models.Item{
+		{CategoryID: sleep.ID, Name: "Quilt", WeightGrams: 600, Price: 300},
+		{CategoryID: sleep.ID, Name: "Pad", WeightGrams: 400, Price: 150.5},
+		{CategoryID: kitchen.ID, Name: "Pot", WeightGrams: 100, Price: 40},
+		{CategoryID: kitchen.ID, Name: "Old stove", WeightGrams: 250, Price: 60},
+	}
+	var created []*models.Item
+	for _, item := range items {
+		newItem, err := CreateItem(db, user.ID, item)
+		if err != nil {
+			t.Fatal("Failed to create item:", err)
+		}
+		created = append(created, newItem)
+	}
+	if _, err := db.Exec("UPDATE items SET weight_to_verify = TRUE WHERE id IN (?, ?)", created[1].ID, created[3].ID); err != nil {
+		t.Fatal("Failed to flag items:", err)
+	}
+	// Deleted items don't count
+	if err := DeleteItem(db, user.ID, created[3].ID); err != nil {
+		t.Fatal("Failed to delete item:", err)
+	}
+
+	summary, err = GetInventorySummary(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get inventory summary:", err)
+	}
+	if summary.ItemCount != 3 || summary.TotalWeight != 1100 || summary.TotalPrice != 490.5 || summary.ItemsToVerify != 1 {
+		t.Errorf("Unexpected totals: %+v", summary)
+	}
+	if summary.Currency != "€" || summary.TotalPriceFormatted != "490,50 €" {
+		t.Errorf("Expected the total in euros, got %q", summary.TotalPriceFormatted)
+	}
+
+	if _, err := GetInventorySummary(db, 9999); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected an unknown user to be not found, got %v", err)
+	}
+}
+
+func TestMarkItemsVerified(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "other", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 1000, WeightToVerify: true})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	stakes, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Stakes", WeightGrams: 80, WeightToVerify: true})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	if _, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Footprint", WeightGrams: 150}); err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	toVerify, err := GetItemsToVerify(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get items to verify:", err)
+	}
+	if len(toVerify) != 2 {
+		t.Fatalf("Expected 2 items to verify, got %d", len(toVerify))
+	}
+
+	// An item of another user makes the whole batch fail
+	if err := MarkItemsVerified(db, other.ID, []int{tent.ID}, nil); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected another user's item to be not found, got %v", err)
+	}
+	if err := MarkItemsVerified(db, user.ID, []int{tent.ID, 9999}, nil); err == nil {
+		t.Error("Expected an unknown item to fail the batch")
+	}
+	if err := MarkItemsVerified(db, user.ID, []int{tent.ID}, map[int]int{tent.ID: -1}); err == nil {
+		t.Error("Expected a negative weight to be rejected")
+	}
+	if item, _ := GetItem(db, user.ID, tent.ID); !item.WeightToVerify {
+		t.Error("Expected failed batches to leave the tent flagged")
+	}
+
+	if err := MarkItemsVerified(db, user.ID, []int{tent.ID, stakes.ID}, map[int]int{tent.ID: 1120}); err != nil {
+		t.Fatal("Failed to mark items verified:", err)
+	}
+	tentAfter, err := GetItem(db, user.ID, tent.ID)
+	if err != nil {
+		t.Fatal("Failed to get item:", err)
+	}
+	if tentAfter.WeightToVerify || tentAfter.WeightGrams != 1120 {
+		t.Errorf("Expected the tent verified at 1120g, got %dg flagged %v", tentAfter.WeightGrams, tentAfter.WeightToVerify)
+	}
+	stakesAfter, err := GetItem(db, user.ID, stakes.ID)
+	if err != nil {
+		t.Fatal("Failed to get item:", err)
+	}
+	if stakesAfter.WeightToVerify || stakesAfter.WeightGrams != 80 {
+		t.Errorf("Expected the stakes verified at 80g, got %dg flagged %v", stakesAfter.WeightGrams, stakesAfter.WeightToVerify)
+	}
+
+	toVerify, err = GetItemsToVerify(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get items to verify:", err)
+	}
+	if len(toVerify) != 0 {
+		t.Errorf("Expected no items left to verify, got %d", len(toVerify))
+	}
+}
+
+func TestGetTripChecklistProgress(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	trip, err := CreateTrip(db, user.ID, "Alps", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+
+	// An empty checklist is 0%, not a division by zero
+	progress, err := GetTripChecklistProgress(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get progress:", err)
+	}
+	if progress.Checked != 0 || progress.Total != 0 || progress.Percent() != 0 {
+		t.Errorf("Expected empty progress, got %+v at %d%%", progress, progress.Percent())
+	}
+
+	var items []*models.TripChecklistItem
+	for _, content := range []string{"Passport", "Tickets", "Map"} {
+		item, err := AddChecklistItem(db, trip.ID, content, user.ID)
+		if err != nil {
+			t.Fatal("Failed to add checklist item:", err)
+		}
+		items = append(items, item)
+	}
+	for _, item := range items[:2] {
+		if err := ToggleChecklistItem(db, item.ID, user.ID); err != nil {
+			t.Fatal("Failed to toggle checklist item:", err)
+		}
+	}
+
+	progress, err = GetTripChecklistProgress(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get progress:", err)
+	}
+	if progress.Checked != 2 || progress.Total != 3 || progress.Percent() != 66 {
+		t.Errorf("Expected 2 of 3 checked at 66%%, got %+v at %d%%", progress, progress.Percent())
+	}
+
+	trips, err := GetTrips(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get trips:", err)
+	}
+	if len(trips) != 1 || trips[0].Progress != *progress {
+		t.Errorf("Expected the trip list to carry the same progress, got %+v", trips)
+	}
+	withDetails, err := GetTripWithDetails(db, trip.ID)
+	if err != nil {
+		t.Fatal("Failed to get trip:", err)
+	}
+	if withDetails.Progress != *progress {
+		t.Errorf("Expected the trip details to carry the same progress, got %+v", withDetails.Progress)
+	}
+}
+
+func TestGetPackWeightBreakdown(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	clothing, err := CreateCategory(db, user.ID, "Clothing")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	kitchen, err := CreateCategory(db, user.ID, "Kitchen")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	socks, err := CreateItem(db, user.ID, models.Item{CategoryID: clothing.ID, Name: "Socks", WeightGrams: 50})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	stove, err := CreateItem(db, user.ID, models.Item{CategoryID: kitchen.ID, Name: "Stove", WeightGrams: 80})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	fuel, err := CreateItem(db, user.ID, models.Item{CategoryID: kitchen.ID, Name: "Fuel", WeightGrams: 230})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Weekend")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for _, item := range []*models.Item{socks, stove, fuel} {
+		if err := AddItemToPack(db, pack.ID, item.ID, user.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+	}
+	if err := SetPackItemCount(db, pack.ID, socks.ID, user.ID, 3); err != nil {
+		t.Fatal("Failed to set count:", err)
+	}
+	if err := SetPackItemWornCount(db, pack.ID, socks.ID, user.ID, 1); err != nil {
+		t.Fatal("Failed to set worn count:", err)
+	}
+	if err := TogglePackItemConsumable(db, pack.ID, fuel.ID, user.ID, true); err != nil {
+		t.Fatal("Failed to mark consumable:", err)
+	}
+
+	label, err := CreatePackLabel(db, pack.ID, "Shared", "#ff0000", user.ID)
+	if err != nil {
+		t.Fatal("Failed to create label:", err)
+	}
+	withItems, err := GetPackWithItems(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	for _, packItem := range withItems.Items {
+		if packItem.Item.ID == stove.ID {
+			if err := AssignLabelToPackItem(db, packItem.ID, label.ID, user.ID); err != nil {
+				t.Fatal("Failed to assign label:", err)
+			}
+		}
+	}
+
+	breakdown, err := GetPackWeightBreakdown(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get breakdown:", err)
+	}
+
+	// 2 carried socks, the stove and the fuel; 1 pair of socks worn
+	if breakdown.Weight != 410 || breakdown.WornWeight != 50 || breakdown.ConsumableWeight != 230 || breakdown.BaseWeight != 180 || breakdown.ItemCount != 5 {
+		t.Errorf("Unexpected totals: %+v", breakdown)
+	}
+	wantCategories := []models.CategoryWeight{
+		{Name: "Clothing", Weight: 100, WornWeight: 50},
+		{Name: "Kitchen", Weight: 310},
+	}
+	if len(breakdown.Categories) != len(wantCategories) {
+		t.Fatalf("Expected %d categories, got %+v", len(wantCategories), breakdown.Categories)
+	}
+	for i, want := range wantCategories {
+		if breakdown.Categories[i] != want {
+			t.Errorf("Expected category %d to be %+v, got %+v", i, want, breakdown.Categories[i])
+		}
+	}
+	if len(breakdown.Labels) != 1 || breakdown.Labels[0] != (models.LabelWeight{Name: "Shared", Color: "#ff0000", Weight: 80}) {
+		t.Errorf("Unexpected labels: %+v", breakdown.Labels)
+	}
+	if worn := breakdown.CategoryWornWeights(); len(worn) != 1 || worn["Clothing"] != 50 {
+		t.Errorf("Expected only clothing to have worn weight, got %v", worn)
+	}
+
+	if _, err := GetPackWeightBreakdown(db, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a missing pack to be not found, got %v", err)
+	}
+}
+
+func TestGetPacksContainingItem(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "otheruser", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 1200})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	stakes, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Stakes", WeightGrams: 10})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	usages, err := GetPacksContainingItem(db, user.ID, tent.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs:", err)
+	}
+	if usages == nil || len(usages) != 0 {
+		t.Errorf("Expected an empty list for an unpacked item, got %v", usages)
+	}
+
+	summer, err := CreatePack(db, user.ID, "Summer")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	alpine, err := CreatePack(db, user.ID, "Alpine")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for _, pack := range []*models.Pack{summer, alpine} {
+		if err := AddItemToPack(db, pack.ID, tent.ID, user.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+		if err := AddItemToPack(db, pack.ID, stakes.ID, user.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+	}
+	if err := SetPackItemCount(db, alpine.ID, stakes.ID, user.ID, 8); err != nil {
+		t.Fatal("Failed to set count:", err)
+	}
+
+	usages, err = GetPacksContainingItem(db, user.ID, stakes.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs:", err)
+	}
+	if len(usages) != 2 {
+		t.Fatalf("Expected 2 packs, got %d", len(usages))
+	}
+	if usages[0].PackID != alpine.ID || usages[0].PackName != "Alpine" || usages[0].Count != 8 {
+		t.Errorf("Expected Alpine with 8 stakes first, got %+v", usages[0])
+	}
+	if usages[1].PackID != summer.ID || usages[1].Count != 1 {
+		t.Errorf("Expected Summer with 1 stake second, got %+v", usages[1])
+	}
+
+	if _, err := GetPacksContainingItem(db, other.ID, stakes.ID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found for another user's item, got %v", err)
+	}
+
+	// Rows from before worn counts existed hold NULL
+	if _, err := db.Exec("UPDATE pack_items SET worn_count = NULL WHERE pack_id = ? AND item_id = ?", summer.ID, tent.ID); err != nil {
+		t.Fatal("Failed to clear worn count:", err)
+	}
+	usages, err = GetPacksContainingItem(db, user.ID, tent.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs with a NULL worn count:", err)
+	}
+	for _, usage := range usages {
+		if usage.WornCount != 0 {
+			t.Errorf("Expected no worn tent, got %+v", usage)
+		}
+	}
+}
+
+func TestSearchAll(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "otheruser", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	brand := "Tarptent"
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Notch", Brand: &brand, WeightGrams: 800})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	trashed, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Old tent", WeightGrams: 2000})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	if err := DeleteItem(db, user.ID, trashed.ID); err != nil {
+		t.Fatal("Failed to delete item:", err)
+	}
+	if _, err := CreatePack(db, user.ID, "Tent camping"); err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if _, err := CreatePack(db, other.ID, "Tent trip"); err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	location := "Tentsmuir Forest"
+	if _, err := CreateTrip(db, user.ID, "Coast walk", nil, &location, nil, nil, false); err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+
+	results, err := SearchAll(db, user.ID, "  TENT ")
+	if err != nil {
+		t.Fatal("Failed to search:", err)
+	}
+	if results.Query != "TENT" {
+		t.Errorf("Expected trimmed query, got %q", results.Query)
+	}
+	if len(results.Items) != 1 || results.Items[0].ID != fmt.Sprint(tent.ID) || results.Items[0].Type != SearchTypeItem {
+		t.Errorf("Expected only the Notch item (matched on brand), got %+v", results.Items)
+	}
+	if len(results.Packs) != 1 || results.Packs[0].Name != "Tent camping" {
+		t.Errorf("Expected only the user's pack, got %+v", results.Packs)
+	}
+	if len(results.Trips) != 1 || results.Trips[0].Detail != location {
+		t.Errorf("Expected the trip matched on location, got %+v", results.Trips)
+	}
+	if results.Total() != 3 {
+		t.Errorf("Expected 3 hits, got %d", results.Total())
+	}
+
+	results, err = SearchAll(db, user.ID, "%")
+	if err != nil {
+		t.Fatal("Failed to search:", err)
+	}
+	if results.Total() != 0 {
+		t.Errorf("Expected wildcards to match literally, got %d hits", results.Total())
+	}
+
+	results, err = SearchAll(db, user.ID, "")
+	if err != nil {
+		t.Fatal("Failed to search:", err)
+	}
+	if results.Total() != 0 || results.Items == nil {
+		t.Errorf("Expected empty, non-nil results for an empty query, got %+v", results)
+	}
+}
+
+func TestGetPacksTotalWeight(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Shelter")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	tent, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Tent", WeightGrams: 1000})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	stakes, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Stakes", WeightGrams: 10})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	jacket, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Jacket", WeightGrams: 300})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+	trashed, err := CreateItem(db, user.ID, models.Item{CategoryID: category.ID, Name: "Old tarp", WeightGrams: 500})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	full, err := CreatePack(db, user.ID, "Full")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	empty, err := CreatePack(db, user.ID, "Empty")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	for _, item := range []*models.Item{tent, stakes, jacket, trashed} {
+		if err := AddItemToPack(db, full.ID, item.ID, user.ID, false); err != nil {
+			t.Fatal("Failed to add item:", err)
+		}
+	}
+	if err := SetPackItemCount(db, full.ID, stakes.ID, user.ID, 6); err != nil {
+		t.Fatal("Failed to set count:", err)
+	}
+	if err := SetPackItemWornCount(db, full.ID, jacket.ID, user.ID, 1); err != nil {
+		t.Fatal("Failed to set worn count:", err)
+	}
+	if err := DeleteItem(db, user.ID, trashed.ID); err != nil {
+		t.Fatal("Failed to delete item:", err)
+	}
+
+	packs, err := GetPacks(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get packs:", err)
+	}
+	totals := make(map[string]int)
+	for _, pack := range packs {
+		totals[pack.ID] = pack.TotalWeight
+	}
+	if totals[full.ID] != 1060 {
+		t.Errorf("Expected 1060g without worn and trashed items, got %d", totals[full.ID])
+	}
+	if totals[empty.ID] != 0 {
+		t.Errorf("Expected 0g for an empty pack, got %d", totals[empty.ID])
+	}
+
+	friend, err := CreateUser(db, "friend", "friend@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	if _, err := AddPackCollaborator(db, user.ID, full.ID, "friend@example.com", PackRoleEditor); err != nil {
+		t.Fatal("Failed to add collaborator:", err)
+	}
+	shared, err := GetSharedPacks(db, friend.ID)
+	if err != nil {
+		t.Fatal("Failed to get shared packs:", err)
+	}
+	if len(shared) != 1 || shared[0].TotalWeight != 1060 {
+		t.Errorf("Expected the shared pack to weigh 1060g, got %+v", shared)
+	}
+}
+
+func TestDuplicateItem(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "otheruser", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	category, err := CreateCategory(db, user.ID, "Storage")
+	if err != nil {
+		t.Fatal("Failed to create category:", err)
+	}
+	brand, capacity, unit := "Sea to Summit", 8.0, "L"
+	original, err := CreateItem(db, user.ID, models.Item{
+		CategoryID:     category.ID,
+		Name:           "Stuff sack",
+		Note:           "Red",
+		WeightGrams:    30,
+		WeightToVerify: true,
+		Price:          19.5,
+		Brand:          &brand,
+		Capacity:       &capacity,
+		CapacityUnit:   &unit,
+	})
+	if err != nil {
+		t.Fatal("Failed to create item:", err)
+	}
+
+	duplicate, err := DuplicateItem(db, user.ID, original.ID)
+	if err != nil {
+		t.Fatal("Failed to duplicate item:", err)
+	}
+	copied, err := GetItem(db, user.ID, duplicate.ID)
+	if err != nil {
+		t.Fatal("Failed to get duplicate:", err)
+	}
+	if copied.Name != "Stuff sack Copy" {
+		t.Errorf("Expected name 'Stuff sack Copy', got %q", copied.Name)
+	}
+	if copied.CategoryID != category.ID || copied.Note != "Red" || copied.WeightGrams != 30 || copied.Price != 19.5 {
+		t.Errorf("Expected the fields to be copied, got %+v", copied)
+	}
+	if !copied.WeightToVerify {
+		t.Error("Expected weight_to_verify to be kept")
+	}
+	if copied.Brand == nil || *copied.Brand != brand || copied.Capacity == nil || *copied.Capacity != capacity || copied.CapacityUnit == nil || *copied.CapacityUnit != unit {
+		t.Errorf("Expected brand and capacity to be copied, got %+v", copied)
+	}
+
+	second, err := DuplicateItem(db, user.ID, original.ID)
+	if err != nil {
+		t.Fatal("Failed to duplicate item:", err)
+	}
+	if second.Name != "Stuff sack Copy 2" {
+		t.Errorf("Expected name 'Stuff sack Copy 2', got %q", second.Name)
+	}
+
+	if _, err := DuplicateItem(db, other.ID, original.ID); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found when duplicating another user's item, got %v", err)
+	}
+}
+
+func TestGenerateTemporaryPassword(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	password, err := GenerateTemporaryPassword()
+	if err != nil {
+		t.Fatal("Failed to generate password:", err)
+	}
+	if len(password) < 16 {
+		t.Errorf("Expected at least 16 characters, got %q", password)
+	}
+	other, err := GenerateTemporaryPassword()
+	if err != nil {
+		t.Fatal("Failed to generate password:", err)
+	}
+	if password == other {
+		t.Error("Expected different passwords on each call")
+	}
+
+	user, err := CreateUser(db, "invited", "invited@example.com", password)
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	if err := VerifyPassword(db, user.ID, password); err != nil {
+		t.Errorf("Expected the temporary password to verify, got %v", err)
+	}
+}
+
+func TestInviteTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	admin, err := CreateUser(db, "admin", "admin@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create admin:", err)
+	}
+
+	enabled, err := IsInviteModeEnabled(db)
+	if err != nil || enabled {
+		t.Fatalf("Expected invite mode off by default, got %v (%v)", enabled, err)
+	}
+	if err := ToggleInviteMode(db); err != nil {
+		t.Fatal("Failed to toggle invite mode:", err)
+	}
+	if enabled, _ := IsInviteModeEnabled(db); !enabled {
+		t.Error("Expected invite mode on after toggling")
+	}
+
+	invite, err := CreateInviteToken(db, admin.ID)
+	if err != nil {
+		t.Fatal("Failed to create invite:", err)
+	}
+	if err := ValidateInviteToken(db, invite.Token); err != nil {
+		t.Errorf("Expected a new invite to be valid, got %v", err)
+	}
+	if err := ValidateInviteToken(db, "unknown"); err == nil {
+		t.Error("Expected an unknown invite to be invalid")
+	}
+
+	pending, err := GetPendingInviteTokens(db)
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("Expected 1 pending invite, got %d (%v)", len(pending), err)
+	}
+
+	user, err := CreateUserWithInvite(db, "guest", "guest@example.com", "password123", invite.Token)
+	if err != nil {
+		t.Fatal("Failed to register with invite:", err)
+	}
+	var usedBy int
+	if err := db.QueryRow("SELECT used_by FROM invite_tokens WHERE token = ?", invite.Token).Scan(&usedBy); err != nil || usedBy != user.ID {
+		t.Errorf("Expected invite used by %d, got %d (%v)", user.ID, usedBy, err)
+	}
+
+	// An invite registers a single account, and the failed attempt leaves no user behind
+	if _, err := CreateUserWithInvite(db, "second", "second@example.com", "password123", invite.Token); err == nil || err.Error() != "invalid invite" {
+		t.Errorf("Expected a used invite to be rejected, got %v", err)
+	}
+	if _, err := GetUserByEmail(db, "second@example.com"); err == nil {
+		t.Error("Expected no user created with a used invite")
+	}
+	if err := RevokeInviteToken(db, invite.Token); err == nil {
+		t.Error("Expected revoking a used invite to fail")
+	}
+
+	revoked, err := CreateInviteToken(db, admin.ID)
+	if err != nil {
+		t.Fatal("Failed to create invite:", err)
+	}
+	if err := RevokeInviteToken(db, revoked.Token); err != nil {
+		t.Fatal("Failed to revoke invite:", err)
+	}
+	if err := ValidateInviteToken(db, revoked.Token); err == nil {
+		t.Error("Expected a revoked invite to be invalid")
+	}
+
+	expired, err := CreateInviteToken(db, admin.ID)
+	if err != nil {
+		t.Fatal("Failed to create invite:", err)
+	}
+	if _, err := db.Exec("UPDATE invite_tokens SET expires_at = ? WHERE token = ?", time.Now().Add(-time.Hour), expired.Token); err != nil {
+		t.Fatal("Failed to expire invite:", err)
+	}
+	if err := ValidateInviteToken(db, expired.Token); err == nil {
+		t.Error("Expected an expired invite to be invalid")
+	}
+	removed, err := CleanupExpiredInviteTokens(db)
+	if err != nil || removed != 1 {
+		t.Errorf("Expected 1 expired invite removed, got %d (%v)", removed, err)
+	}
+}
+
+func TestPublicPackLinkRotationAndExpiry(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	other, err := CreateUser(db, "other", "other@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	pack, err := CreatePack(db, user.ID, "Shared")
+	if err != nil {
+		t.Fatal("Failed to create pack:", err)
+	}
+	if err := UpdatePack(db, user.ID, pack.ID, "Shared", true, false, nil); err != nil {
+		t.Fatal("Failed to publish pack:", err)
+	}
+	published, err := GetPack(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	oldShortID := published.ShortID
+
+	if _, err := RotatePackShortID(db, other.ID, pack.ID); err == nil {
+		t.Error("Expected another user to be unable to rotate the link")
+	}
+	newShortID, err := RotatePackShortID(db, user.ID, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to rotate link:", err)
+	}
+	if newShortID == "" || newShortID == oldShortID {
+		t.Fatalf("Expected a new short ID, got %q (was %q)", newShortID, oldShortID)
+	}
+	if _, err := GetPackByShortID(db, oldShortID); err == nil {
+		t.Error("Expected the previous link to stop working")
+	}
+	if _, err := GetPackByShortID(db, newShortID); err != nil {
+		t.Errorf("Expected the new link to work, got %v", err)
+	}
+
+	tomorrow := time.Now().Add(24 * time.Hour)
+	if err := UpdatePack(db, user.ID, pack.ID, "Shared", true, false, &tomorrow); err != nil {
+		t.Fatal("Failed to set link expiry:", err)
+	}
+	if _, err := GetPackByShortID(db, newShortID); err != nil {
+		t.Errorf("Expected the link to work before its expiry, got %v", err)
+	}
+	if packs, _ := GetPublicPacks(db, 10, 0, PublicPackSortNewest); len(packs) != 1 {
+		t.Errorf("Expected the pack in Explore before its expiry, got %d packs", len(packs))
+	}
+
+	yesterday := time.Now().Add(-24 * time.Hour)
+	if err := UpdatePack(db, user.ID, pack.ID, "Shared", true, false, &yesterday); err != nil {
+		t.Fatal("Failed to set link expiry:", err)
+	}
+	if _, err := GetPackByShortID(db, newShortID); err == nil || err.Error() != "pack not found" {
+		t.Errorf("Expected an expired link to be not found, got %v", err)
+	}
+	if packs, _ := GetPublicPacks(db, 10, 0, PublicPackSortNewest); len(packs) != 0 {
+		t.Errorf("Expected an expired pack to leave Explore, got %d packs", len(packs))
+	}
+	expired, err := GetPack(db, pack.ID)
+	if err != nil {
+		t.Fatal("Failed to get pack:", err)
+	}
+	if !expired.IsPublic || !expired.PublicLinkExpired() {
+		t.Errorf("Expected a public pack with an expired link, got %+v", expired)
+	}
+}
+
+func TestGetTripsByArchived(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	active, err := CreateTrip(db, user.ID, "Coast", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+	archived, err := CreateTrip(db, user.ID, "Last summer", nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatal("Failed to create trip:", err)
+	}
+	if err := ArchiveTrip(db, user.ID, archived.ID, true); err != nil {
+		t.Fatal("Failed to archive trip:", err)
+	}
+
+	trips, err := GetTripsByArchived(db, user.ID, false)
+	if err != nil {
+		t.Fatal("Failed to get active trips:", err)
+	}
+	if len(trips) != 1 || trips[0].ID != active.ID {
+		t.Errorf("Expected only the active trip, got %+v", trips)
+	}
+
+	trips, err = GetTripsByArchived(db, user.ID, true)
+	if err != nil {
+		t.Fatal("Failed to get archived trips:", err)
+	}
+	if len(trips) != 1 || trips[0].ID != archived.ID || !trips[0].IsArchived {
+		t.Errorf("Expected only the archived trip, got %+v", trips)
+	}
+
+	count, err := CountArchivedTrips(db, user.ID)
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 archived trip, got %d (%v)", count, err)
+	}
+	if all, err := GetTrips(db, user.ID); err != nil || len(all) != 2 {
+		t.Errorf("Expected GetTrips to keep listing every trip, got %d (%v)", len(all), err)
+	}
+}
+
+func TestEmailPreferences(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+
+	prefs, err := GetEmailPreferences(db, user.ID)
+	if err != nil {
+		t.Fatal("Failed to get email preferences:", err)
+	}
+	if !prefs.AdminNotifications || !prefs.ProductEmails || !prefs.TripReminders {
+		t.Errorf("Expected every optional email on by default, got %+v", prefs)
+	}
+	if prefs.UnsubscribeToken == "" {
+		t.Fatal("Expected an unsubscribe token")
+	}
+	again, err := GetEmailPreferences(db, user.ID)
+	if err != nil || again.UnsubscribeToken != prefs.UnsubscribeToken {
+		t.Errorf("Expected the unsubscribe token to stay the same, got %+v (%v)", again, err)
+	}
+
+	if err := UpdateEmailPreferences(db, user.ID, true, false, true); err != nil {
+		t.Fatal("Failed to update email preferences:", err)
+	}
+	prefs, _ = GetEmailPreferences(db, user.ID)
+	if !prefs.Allows(models.EmailCategoryAdminNotifications) || prefs.Allows(models.EmailCategoryProduct) || !prefs.Allows(models.EmailCategoryTripReminders) {
+		t.Errorf("Expected only product emails off, got %+v", prefs)
+	}
+
+	if err := UnsubscribeByToken(db, prefs.UnsubscribeToken, models.EmailCategoryTripReminders); err != nil {
+		t.Fatal("Failed to unsubscribe:", err)
+	}
+	prefs, _ = GetEmailPreferences(db, user.ID)
+	if prefs.TripReminders || !prefs.AdminNotifications {
+		t.Errorf("Expected only trip reminders turned off by the link, got %+v", prefs)
+	}
+
+	if err := UnsubscribeByToken(db, prefs.UnsubscribeToken, ""); err != nil {
+		t.Fatal("Failed to unsubscribe from everything:", err)
+	}
+	prefs, _ = GetEmailPreferences(db, user.ID)
+	if prefs.AdminNotifications || prefs.ProductEmails || prefs.TripReminders {
+		t.Errorf("Expected every optional email off, got %+v", prefs)
+	}
+
+	if err := UnsubscribeByToken(db, "unknown", ""); err == nil {
+		t.Error("Expected an unknown unsubscribe token to be rejected")
+	}
+	if err := UnsubscribeByToken(db, prefs.UnsubscribeToken, "is_admin"); err == nil {
+		t.Error("Expected an unknown category to be rejected")
+	}
+}
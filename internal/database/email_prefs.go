@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"carryless/internal/models"
+)
+
+// GetEmailPreferences returns the user's email preferences, creating the defaults (every
+// optional email on) and the unsubscribe token the first time
+func GetEmailPreferences(db *sql.DB, userID int) (*models.EmailPreferences, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate unsubscribe token: %w", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT OR IGNORE INTO user_email_prefs (user_id, unsubscribe_token)
+		VALUES (?, ?)
+	`, userID, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create email preferences: %w", err)
+	}
+
+	prefs := &models.EmailPreferences{}
+	err = db.QueryRow(`
+		SELECT user_id, admin_notifications, product_emails, trip_reminders, unsubscribe_token
+		FROM user_email_prefs
+		WHERE user_id = ?
+	`, userID).Scan(&prefs.UserID, &prefs.AdminNotifications, &prefs.ProductEmails, &prefs.TripReminders, &prefs.UnsubscribeToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email preferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// UpdateEmailPreferences sets which optional emails the user receives
+func UpdateEmailPreferences(db *sql.DB, userID int, adminNotifications, productEmails, tripReminders bool) error {
+	// Make sure the row exists, with its unsubscribe token
+	if _, err := GetEmailPreferences(db, userID); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		UPDATE user_email_prefs
+		SET admin_notifications = ?, product_emails = ?, trip_reminders = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = ?
+	`, adminNotifications, productEmails, tripReminders, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update email preferences: %w", err)
+	}
+
+	return nil
+}
+
+// UnsubscribeByToken turns off a category of optional emails for the owner of an unsubscribe
+// link. An empty category turns off all of them.
+func UnsubscribeByToken(db *sql.DB, token, category string) error {
+	var query string
+	switch category {
+	case "":
+		query = `UPDATE user_email_prefs SET admin_notifications = FALSE, product_emails = FALSE, trip_reminders = FALSE, updated_at = CURRENT_TIMESTAMP WHERE unsubscribe_token = ?`
+	case models.EmailCategoryAdminNotifications, models.EmailCategoryProduct, models.EmailCategoryTripReminders:
+		// category is one of the known column names
+		query = `UPDATE user_email_prefs SET ` + category + ` = FALSE, updated_at = CURRENT_TIMESTAMP WHERE unsubscribe_token = ?`
+	default:
+		return fmt.Errorf("unknown email category")
+	}
+
+	result, err := db.Exec(query, token)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("invalid unsubscribe link")
+	}
+
+	return nil
+}
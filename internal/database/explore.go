@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Sort orders accepted by GetPublicPacks
+const (
+	PublicPackSortNewest   = "newest"
+	PublicPackSortLightest = "lightest"
+	PublicPackSortLiked    = "liked"
+)
+
+// PublicPack is a pack listed on the explore page. OwnerUsername is empty for anonymous packs.
+type PublicPack struct {
+	ID            string    `json:"id"`
+	ShortID       string    `json:"short_id"`
+	Name          string    `json:"name"`
+	OwnerUsername string    `json:"owner_username"`
+	CreatedAt     time.Time `json:"created_at"`
+	ItemCount     int       `json:"item_count"`
+	TotalWeight   int       `json:"total_weight"`
+	LikeCount     int       `json:"like_count"`
+}
+
+// publicPackFilter keeps the packs anyone can browse: shared with an unexpired link, not
+// archived and not templates
+const publicPackFilter = `
+	p.is_public = TRUE
+	AND COALESCE(p.is_locked, FALSE) = FALSE
+	AND COALESCE(p.is_template, FALSE) = FALSE
+	AND COALESCE(p.short_id, '') != ''
+	AND ` + publicLinkActiveCondition + `
+`
+
+// GetPublicPacks lists the packs shared publicly by all users. Unknown sort orders fall back to newest first.
+func GetPublicPacks(db *sql.DB, limit, offset int, sort string) ([]PublicPack, error) {
+	orderBy := "p.created_at DESC"
+	switch sort {
+	case PublicPackSortLightest:
+		orderBy = "total_weight ASC, p.created_at DESC"
+	case PublicPackSortLiked:
+		orderBy = "like_count DESC, p.created_at DESC"
+	}
+
+	query := `
+		SELECT
+			p.id,
+			p.short_id,
+			p.name,
+			CASE WHEN COALESCE(p.is_anonymous, FALSE) THEN '' ELSE u.username END,
+			p.created_at,
+			COALESCE(SUM(CASE WHEN i.id IS NOT NULL THEN pi.count ELSE 0 END), 0) as item_count,
+			COALESCE(SUM(CASE WHEN i.id IS NOT NULL THEN i.weight_grams * pi.count ELSE 0 END), 0) as total_weight,
+			(SELECT COUNT(*) FROM pack_likes pl WHERE pl.pack_id = p.id) as like_count
+		FROM packs p
+		JOIN users u ON p.user_id = u.id
+		LEFT JOIN pack_items pi ON p.id = pi.pack_id
+		LEFT JOIN items i ON pi.item_id = i.id AND i.deleted_at IS NULL
+		WHERE ` + publicPackFilter + `
+		GROUP BY p.id, p.short_id, p.name, u.username, p.created_at
+		ORDER BY ` + orderBy + `
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := db.Query(query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query public packs: %w", err)
+	}
+	defer rows.Close()
+
+	var packs []PublicPack
+	for rows.Next() {
+		var pack PublicPack
+		err := rows.Scan(
+			&pack.ID,
+			&pack.ShortID,
+			&pack.Name,
+			&pack.OwnerUsername,
+			&pack.CreatedAt,
+			&pack.ItemCount,
+			&pack.TotalWeight,
+			&pack.LikeCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan public pack: %w", err)
+		}
+		packs = append(packs, pack)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate public packs: %w", err)
+	}
+
+	return packs, nil
+}
+
+func CountPublicPacks(db *sql.DB) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM packs p WHERE ` + publicPackFilter).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count public packs: %w", err)
+	}
+	return count, nil
+}
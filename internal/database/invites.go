@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"carryless/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// inviteTokenLifetime is how long an invite link can be used
+const inviteTokenLifetime = 7 * 24 * time.Hour
+
+// IsInviteModeEnabled reports whether registering requires an invite
+func IsInviteModeEnabled(db *sql.DB) (bool, error) {
+	var value string
+	err := db.QueryRow("SELECT value FROM system_settings WHERE key = 'invite_mode'").Scan(&value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to query invite mode setting: %w", err)
+	}
+	return value == "true", nil
+}
+
+// ToggleInviteMode switches between open registration and registration by invite only
+func ToggleInviteMode(db *sql.DB) error {
+	query := `UPDATE system_settings SET value = CASE WHEN value = 'true' THEN 'false' ELSE 'true' END, updated_at = CURRENT_TIMESTAMP WHERE key = 'invite_mode'`
+	_, err := db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to toggle invite mode setting: %w", err)
+	}
+	return nil
+}
+
+// CreateInviteToken issues a single-use invite created by the given admin
+func CreateInviteToken(db *sql.DB, adminID int) (*models.InviteToken, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invite token: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(inviteTokenLifetime)
+	_, err = db.Exec(`
+		INSERT INTO invite_tokens (token, created_by, expires_at, created_at)
+		VALUES (?, ?, ?, ?)
+	`, token, adminID, expiresAt, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invite token: %w", err)
+	}
+
+	return &models.InviteToken{
+		Token:     token,
+		CreatedBy: adminID,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}, nil
+}
+
+// GetPendingInviteTokens lists the invites that can still be used, newest first
+func GetPendingInviteTokens(db *sql.DB) ([]models.InviteToken, error) {
+	rows, err := db.Query(`
+		SELECT token, created_by, expires_at, created_at
+		FROM invite_tokens
+		WHERE used_at IS NULL AND expires_at > ?
+		ORDER BY created_at DESC
+	`, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invite tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []models.InviteToken
+	for rows.Next() {
+		var invite models.InviteToken
+		if err := rows.Scan(&invite.Token, &invite.CreatedBy, &invite.ExpiresAt, &invite.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan invite token: %w", err)
+		}
+		invites = append(invites, invite)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invite tokens: %w", err)
+	}
+
+	return invites, nil
+}
+
+// RevokeInviteToken deletes an invite that hasn't been used yet
+func RevokeInviteToken(db *sql.DB, token string) error {
+	result, err := db.Exec(`DELETE FROM invite_tokens WHERE token = ? AND used_at IS NULL`, token)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invite token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("invite not found")
+	}
+
+	return nil
+}
+
+// ValidateInviteToken checks that an invite exists, hasn't been used and hasn't expired
+func ValidateInviteToken(db *sql.DB, token string) error {
+	var valid bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM invite_tokens WHERE token = ? AND used_at IS NULL AND expires_at > ?)
+	`, token, time.Now()).Scan(&valid)
+	if err != nil {
+		return fmt.Errorf("failed to check invite token: %w", err)
+	}
+	if !valid {
+		return fmt.Errorf("invalid invite")
+	}
+	return nil
+}
+
+// CreateUserWithInvite creates a user like CreateUser and uses up the invite in the same
+// transaction, so an invite can never register two accounts
+func CreateUserWithInvite(db *sql.DB, username, email, password, token string) (*models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	user, err := insertUser(tx, username, email, string(hashedPassword))
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE invite_tokens SET used_at = ?, used_by = ?
+		WHERE token = ? AND used_at IS NULL AND expires_at > ?
+	`, time.Now(), user.ID, token, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to use invite token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("invalid invite")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// CleanupExpiredInviteTokens removes invites that expired unused and returns how many were removed
+func CleanupExpiredInviteTokens(db *sql.DB) (int64, error) {
+	// Compare against a Go time so the stored expiry format and time zone match
+	result, err := db.Exec(`DELETE FROM invite_tokens WHERE used_at IS NULL AND expires_at < ?`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired invite tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
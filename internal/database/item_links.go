@@ -99,7 +99,7 @@ func GetLinkedItems(db *sql.DB, parentItemID int) ([]models.ItemLink, error) {
 		FROM item_links il
 		JOIN items i ON il.linked_item_id = i.id
 		LEFT JOIN categories c ON i.category_id = c.id
-		WHERE il.parent_item_id = ?
+		WHERE il.parent_item_id = ? AND i.deleted_at IS NULL
 		ORDER BY i.name
 	`
 
@@ -163,12 +163,17 @@ func GetLinkedItems(db *sql.DB, parentItemID int) ([]models.ItemLink, error) {
 	return links, nil
 }
 
-// GetLinkedItemIDs returns just the IDs of linked items for a parent item
-// Used for pack operations when we only need the IDs
-func GetLinkedItemIDs(db *sql.DB, parentItemID int) ([]int, error) {
-	query := `SELECT linked_item_id FROM item_links WHERE parent_item_id = ?`
+// getLinkedItemIDsTx returns just the IDs of the linked items of a parent item, leaving out
+// those in the trash. Used for pack operations when we only need the IDs.
+func getLinkedItemIDsTx(tx *sql.Tx, parentItemID int) ([]int, error) {
+	query := `
+		SELECT il.linked_item_id
+		FROM item_links il
+		JOIN items i ON i.id = il.linked_item_id AND i.deleted_at IS NULL
+		WHERE il.parent_item_id = ?
+	`
 
-	rows, err := db.Query(query, parentItemID)
+	rows, err := tx.Query(query, parentItemID)
 	if err != nil {
 		return nil, err
 	}
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode"
 
 	"carryless/internal/models"
 )
 
+// deletedItemRetention is how long trashed items are kept before PurgeDeletedItems removes them
+const deletedItemRetention = "-30 days"
+
 // validItemUpdateColumns is an allowlist of columns that can be updated via PatchItem and BulkUpdateItems.
 // This prevents SQL injection via dynamic column names.
 var validItemUpdateColumns = map[string]bool{
@@ -61,17 +65,20 @@ func CreateItem(db *sql.DB, userID int, item models.Item) (*models.Item, error)
 	return &item, nil
 }
 
+// itemListQuery selects the user's items that aren't in the trash, in the column order
+// scanItems reads
+const itemListQuery = `
+	SELECT i.id, i.user_id, i.category_id, i.name, i.note, i.weight_grams, COALESCE(i.weight_to_verify, false), i.price,
+	       i.brand, i.model, i.purchase_date, i.capacity, i.capacity_unit, i.link, i.image_path,
+	       i.created_at, i.updated_at,
+	       c.id, c.name
+	FROM items i
+	LEFT JOIN categories c ON i.category_id = c.id
+	WHERE i.user_id = ? AND i.deleted_at IS NULL
+`
+
 func GetItems(db *sql.DB, userID int) ([]models.Item, error) {
-	query := `
-		SELECT i.id, i.user_id, i.category_id, i.name, i.note, i.weight_grams, COALESCE(i.weight_to_verify, false), i.price,
-		       i.brand, i.model, i.purchase_date, i.capacity, i.capacity_unit, i.link,
-		       i.created_at, i.updated_at,
-		       c.id, c.name
-		FROM items i
-		LEFT JOIN categories c ON i.category_id = c.id
-		WHERE i.user_id = ?
-		ORDER BY c.name, i.name
-	`
+	query := itemListQuery + ` ORDER BY c.sort_order, c.name, i.name`
 
 	rows, err := db.Query(query, userID)
 	if err != nil {
@@ -79,11 +86,47 @@ func GetItems(db *sql.DB, userID int) ([]models.Item, error) {
 	}
 	defer rows.Close()
 
+	return scanItems(rows)
+}
+
+// SearchItems returns the user's items matching a case-insensitive query on name, note, brand or model,
+// optionally restricted to a category. An empty query matches every item.
+func SearchItems(db *sql.DB, userID int, query string, categoryID *int) ([]models.Item, error) {
+	sqlQuery := itemListQuery
+	args := []interface{}{userID}
+
+	if query != "" {
+		// Escape LIKE wildcards so they match literally
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(query))
+		pattern := "%" + escaped + "%"
+		sqlQuery += ` AND (LOWER(i.name) LIKE ? ESCAPE '\' OR LOWER(COALESCE(i.note, '')) LIKE ? ESCAPE '\'
+		              OR LOWER(COALESCE(i.brand, '')) LIKE ? ESCAPE '\' OR LOWER(COALESCE(i.model, '')) LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+
+	if categoryID != nil {
+		sqlQuery += ` AND i.category_id = ?`
+		args = append(args, *categoryID)
+	}
+
+	sqlQuery += ` ORDER BY c.sort_order, c.name, i.name`
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search items: %w", err)
+	}
+	defer rows.Close()
+
+	return scanItems(rows)
+}
+
+// scanItems reads the rows of an itemListQuery
+func scanItems(rows *sql.Rows) ([]models.Item, error) {
 	var items []models.Item
 	for rows.Next() {
 		var item models.Item
 		var category models.Category
-		var brand, model, capacityUnit, link sql.NullString
+		var brand, model, capacityUnit, link, imagePath sql.NullString
 		var purchaseDate sql.NullTime
 		var capacity sql.NullFloat64
 
@@ -102,6 +145,7 @@ func GetItems(db *sql.DB, userID int) ([]models.Item, error) {
 			&capacity,
 			&capacityUnit,
 			&link,
+			&imagePath,
 			&item.CreatedAt,
 			&item.UpdatedAt,
 			&category.ID,
@@ -130,12 +174,15 @@ func GetItems(db *sql.DB, userID int) ([]models.Item, error) {
 		if link.Valid {
 			item.Link = &link.String
 		}
+		if imagePath.Valid {
+			item.ImagePath = &imagePath.String
+		}
 
 		item.Category = &category
 		items = append(items, item)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating items: %w", err)
 	}
 
@@ -145,18 +192,18 @@ func GetItems(db *sql.DB, userID int) ([]models.Item, error) {
 func GetItem(db *sql.DB, userID, itemID int) (*models.Item, error) {
 	item := &models.Item{}
 	category := &models.Category{}
-	var brand, model, capacityUnit, link sql.NullString
+	var brand, model, capacityUnit, link, imagePath sql.NullString
 	var purchaseDate sql.NullTime
 	var capacity sql.NullFloat64
 
 	query := `
 		SELECT i.id, i.user_id, i.category_id, i.name, i.note, i.weight_grams, COALESCE(i.weight_to_verify, false), i.price,
-		       i.brand, i.model, i.purchase_date, i.capacity, i.capacity_unit, i.link,
+		       i.brand, i.model, i.purchase_date, i.capacity, i.capacity_unit, i.link, i.image_path,
 		       i.created_at, i.updated_at,
 		       c.id, c.name
 		FROM items i
 		LEFT JOIN categories c ON i.category_id = c.id
-		WHERE i.id = ? AND i.user_id = ?
+		WHERE i.id = ? AND i.user_id = ? AND i.deleted_at IS NULL
 	`
 
 	err := db.QueryRow(query, itemID, userID).Scan(
@@ -174,6 +221,7 @@ func GetItem(db *sql.DB, userID, itemID int) (*models.Item, error) {
 		&capacity,
 		&capacityUnit,
 		&link,
+		&imagePath,
 		&item.CreatedAt,
 		&item.UpdatedAt,
 		&category.ID,
@@ -205,6 +253,9 @@ func GetItem(db *sql.DB, userID, itemID int) (*models.Item, error) {
 	if link.Valid {
 		item.Link = &link.String
 	}
+	if imagePath.Valid {
+		item.ImagePath = &imagePath.String
+	}
 
 	item.Category = category
 	return item, nil
@@ -216,7 +267,7 @@ func UpdateItem(db *sql.DB, userID, itemID int, updatedItem models.Item) error {
 		SET category_id = ?, name = ?, note = ?, weight_grams = ?, weight_to_verify = ?, price = ?,
 		    brand = ?, model = ?, purchase_date = ?, capacity = ?, capacity_unit = ?, link = ?,
 		    updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND user_id = ?
+		WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 	`
 
 	result, err := db.Exec(query, updatedItem.CategoryID, updatedItem.Name, updatedItem.Note, updatedItem.WeightGrams, updatedItem.WeightToVerify, updatedItem.Price,
@@ -238,39 +289,60 @@ func UpdateItem(db *sql.DB, userID, itemID int, updatedItem models.Item) error {
 	return nil
 }
 
+// DeleteItem moves an item to the trash. Trashed items keep their pack memberships
+// but are hidden from inventory and pack views until restored.
 func DeleteItem(db *sql.DB, userID, itemID int) error {
 	return DeleteItemWithForce(db, userID, itemID, false)
 }
 
+// DeleteItemWithForce moves an item to the trash. When force is true the item is
+// also removed from every pack, so restoring it will not bring those entries back.
 func DeleteItemWithForce(db *sql.DB, userID, itemID int, force bool) error {
-	var packCount int
-	countQuery := `SELECT COUNT(*) FROM pack_items WHERE item_id = ?`
-	err := db.QueryRow(countQuery, itemID).Scan(&packCount)
+	if force {
+		removeQuery := `
+			DELETE FROM pack_items
+			WHERE item_id = (SELECT id FROM items WHERE id = ? AND user_id = ? AND deleted_at IS NULL)
+		`
+		_, err := db.Exec(removeQuery, itemID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to remove item from packs: %w", err)
+		}
+	}
+
+	query := `
+		UPDATE items
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+	`
+
+	result, err := db.Exec(query, itemID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to check item usage in packs: %w", err)
+		return fmt.Errorf("failed to delete item: %w", err)
 	}
 
-	if packCount > 0 && !force {
-		return fmt.Errorf("cannot delete item used in %d pack(s)", packCount)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	// If force is true and item is in packs, remove it from all packs first
-	if force && packCount > 0 {
-		removeQuery := `DELETE FROM pack_items WHERE item_id = ?`
-		_, err := db.Exec(removeQuery, itemID)
-		if err != nil {
-			return fmt.Errorf("failed to remove item from packs: %w", err)
-		}
+	if rowsAffected == 0 {
+		return fmt.Errorf("item not found")
 	}
 
+	return nil
+}
+
+// RestoreItem takes an item out of the trash.
+func RestoreItem(db *sql.DB, userID, itemID int) error {
 	query := `
-		DELETE FROM items
-		WHERE id = ? AND user_id = ?
+		UPDATE items
+		SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL
 	`
 
 	result, err := db.Exec(query, itemID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to delete item: %w", err)
+		return fmt.Errorf("failed to restore item: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -285,35 +357,164 @@ func DeleteItemWithForce(db *sql.DB, userID, itemID int, force bool) error {
 	return nil
 }
 
-func GetPacksUsingItem(db *sql.DB, userID, itemID int) ([]string, error) {
+// GetDeletedItems returns the user's trashed items, most recently deleted first.
+func GetDeletedItems(db *sql.DB, userID int) ([]models.Item, error) {
 	query := `
-		SELECT p.name 
-		FROM packs p 
-		JOIN pack_items pi ON p.id = pi.pack_id 
+		SELECT i.id, i.user_id, i.category_id, i.name, i.note, i.weight_grams, i.brand, i.model,
+		       i.created_at, i.updated_at, i.deleted_at,
+		       c.id, c.name
+		FROM items i
+		LEFT JOIN categories c ON i.category_id = c.id
+		WHERE i.user_id = ? AND i.deleted_at IS NOT NULL
+		ORDER BY i.deleted_at DESC, i.name
+	`
+
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.Item
+	for rows.Next() {
+		var item models.Item
+		var category models.Category
+		var brand, model sql.NullString
+		var deletedAt time.Time
+
+		err := rows.Scan(
+			&item.ID,
+			&item.UserID,
+			&item.CategoryID,
+			&item.Name,
+			&item.Note,
+			&item.WeightGrams,
+			&brand,
+			&model,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+			&deletedAt,
+			&category.ID,
+			&category.Name,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+
+		if brand.Valid {
+			item.Brand = &brand.String
+		}
+		if model.Valid {
+			item.Model = &model.String
+		}
+		item.DeletedAt = &deletedAt
+
+		item.Category = &category
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating items: %w", err)
+	}
+
+	return items, nil
+}
+
+// PurgeDeletedItems permanently deletes items that have been in the trash longer than the retention period
+func PurgeDeletedItems(db *sql.DB) error {
+	query := `DELETE FROM items WHERE deleted_at IS NOT NULL AND deleted_at < datetime('now', ?)`
+	_, err := db.Exec(query, deletedItemRetention)
+	if err != nil {
+		return fmt.Errorf("failed to purge deleted items: %w", err)
+	}
+	return nil
+}
+
+// SetItemImage stores the public path of an item's image, or clears it when imagePath is empty.
+// Returns the previous path so the caller can remove the old file.
+func SetItemImage(db *sql.DB, userID, itemID int, imagePath string) (string, error) {
+	var previous sql.NullString
+	err := db.QueryRow(`SELECT image_path FROM items WHERE id = ? AND user_id = ? AND deleted_at IS NULL`, itemID, userID).Scan(&previous)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("item not found")
+		}
+		return "", fmt.Errorf("failed to query item image: %w", err)
+	}
+
+	newPath := sql.NullString{String: imagePath, Valid: imagePath != ""}
+	_, err = db.Exec(`UPDATE items SET image_path = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, newPath, itemID, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to update item image: %w", err)
+	}
+
+	return previous.String, nil
+}
+
+// GetItemImagePaths returns every image path still referenced by an item, including trashed ones
+func GetItemImagePaths(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT image_path FROM items WHERE image_path IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query item image paths: %w", err)
+	}
+	defer rows.Close()
+
+	paths := make(map[string]bool)
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan item image path: %w", err)
+		}
+		paths[path] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating item image paths: %w", err)
+	}
+
+	return paths, nil
+}
+
+// GetPacksContainingItem lists the packs an item of the user is in, with how many of it each
+// one holds
+func GetPacksContainingItem(db *sql.DB, userID, itemID int) ([]models.ItemPackUsage, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM items WHERE id = ? AND user_id = ?)", itemID, userID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify item ownership: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("item not found")
+	}
+
+	query := `
+		SELECT p.id, p.name, pi.count, COALESCE(pi.worn_count, 0)
+		FROM packs p
+		JOIN pack_items pi ON p.id = pi.pack_id
 		WHERE pi.item_id = ? AND p.user_id = ?
 		ORDER BY p.name
 	`
-	
+
 	rows, err := db.Query(query, itemID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query packs using item: %w", err)
+		return nil, fmt.Errorf("failed to query packs containing item: %w", err)
 	}
 	defer rows.Close()
 
-	var packNames []string
+	usages := []models.ItemPackUsage{}
 	for rows.Next() {
-		var packName string
-		if err := rows.Scan(&packName); err != nil {
-			return nil, fmt.Errorf("failed to scan pack name: %w", err)
+		var usage models.ItemPackUsage
+		if err := rows.Scan(&usage.PackID, &usage.PackName, &usage.Count, &usage.WornCount); err != nil {
+			return nil, fmt.Errorf("failed to scan pack: %w", err)
 		}
-		packNames = append(packNames, packName)
+		usages = append(usages, usage)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating pack names: %w", err)
+		return nil, fmt.Errorf("error iterating packs: %w", err)
 	}
 
-	return packNames, nil
+	return usages, nil
 }
 
 func GetItemsByCategory(db *sql.DB, userID, categoryID int) ([]models.Item, error) {
@@ -324,7 +525,7 @@ func GetItemsByCategory(db *sql.DB, userID, categoryID int) ([]models.Item, erro
 		       c.id, c.name
 		FROM items i
 		LEFT JOIN categories c ON i.category_id = c.id
-		WHERE i.user_id = ? AND i.category_id = ?
+		WHERE i.user_id = ? AND i.category_id = ? AND i.deleted_at IS NULL
 		ORDER BY i.name
 	`
 
@@ -418,6 +619,95 @@ func DeleteAllItems(db *sql.DB, userID int) error {
 	return nil
 }
 
+// FindItemIDByNameAndCategory returns the ID of the user's item matching name and category,
+// or 0 if there is none.
+func FindItemIDByNameAndCategory(db *sql.DB, userID int, name string, categoryID int) (int, error) {
+	var itemID int
+	query := `SELECT id FROM items WHERE user_id = ? AND name = ? AND category_id = ? AND deleted_at IS NULL ORDER BY id LIMIT 1`
+	err := db.QueryRow(query, userID, name, categoryID).Scan(&itemID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find item: %w", err)
+	}
+	return itemID, nil
+}
+
+func findItemIDByNameAndCategoryTx(tx *sql.Tx, userID int, name string, categoryID int) (int, error) {
+	var itemID int
+	query := `SELECT id FROM items WHERE user_id = ? AND name = ? AND category_id = ? AND deleted_at IS NULL ORDER BY id LIMIT 1`
+	err := tx.QueryRow(query, userID, name, categoryID).Scan(&itemID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find item: %w", err)
+	}
+	return itemID, nil
+}
+
+// ImportItems writes imported items in a single transaction. When replace is true the
+// user's inventory is wiped first and every item is inserted. Otherwise items with a
+// non-zero ID update the weight, price and note of that existing item, and the rest
+// are inserted. Returns the number of items created and updated.
+func ImportItems(db *sql.DB, userID int, items []models.Item, replace bool) (int, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		_, err = tx.Exec(`DELETE FROM pack_items WHERE item_id IN (SELECT id FROM items WHERE user_id = ?)`, userID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to delete pack items: %w", err)
+		}
+		_, err = tx.Exec(`DELETE FROM items WHERE user_id = ?`, userID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to delete items: %w", err)
+		}
+	}
+
+	created, updated := 0, 0
+	for _, item := range items {
+		if !replace && item.ID != 0 {
+			result, err := tx.Exec(`
+				UPDATE items SET weight_grams = ?, price = ?, note = ?, updated_at = CURRENT_TIMESTAMP
+				WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+			`, item.WeightGrams, item.Price, item.Note, item.ID, userID)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to update item: %w", err)
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to get rows affected: %w", err)
+			}
+			if rowsAffected == 0 {
+				return 0, 0, fmt.Errorf("item not found")
+			}
+			updated++
+			continue
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO items (user_id, category_id, name, note, weight_grams, weight_to_verify, price, brand, model, purchase_date, capacity, capacity_unit, link)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, userID, item.CategoryID, item.Name, item.Note, item.WeightGrams, item.WeightToVerify, item.Price,
+			item.Brand, item.Model, item.PurchaseDate, item.Capacity, item.CapacityUnit, item.Link)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to create item: %w", err)
+		}
+		created++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return created, updated, nil
+}
+
 func GetItemsToVerify(db *sql.DB, userID int) ([]models.Item, error) {
 	query := `
 		SELECT i.id, i.user_id, i.category_id, i.name, i.note, i.weight_grams, i.weight_to_verify, i.price,
@@ -426,8 +716,8 @@ func GetItemsToVerify(db *sql.DB, userID int) ([]models.Item, error) {
 		       c.id, c.name
 		FROM items i
 		LEFT JOIN categories c ON i.category_id = c.id
-		WHERE i.user_id = ? AND i.weight_to_verify = true
-		ORDER BY c.name, i.name
+		WHERE i.user_id = ? AND i.weight_to_verify = true AND i.deleted_at IS NULL
+		ORDER BY c.sort_order, c.name, i.name
 	`
 
 	rows, err := db.Query(query, userID)
@@ -499,6 +789,57 @@ func GetItemsToVerify(db *sql.DB, userID int) ([]models.Item, error) {
 	return items, nil
 }
 
+// MarkItemsVerified clears the weight_to_verify flag of the items, saving the measured weight
+// of those found in weights. Either all items are updated or none are.
+func MarkItemsVerified(db *sql.DB, userID int, itemIDs []int, weights map[int]int) error {
+	if len(itemIDs) == 0 {
+		return fmt.Errorf("no items specified")
+	}
+	for _, weight := range weights {
+		if weight < 0 {
+			return fmt.Errorf("invalid weight %d", weight)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, itemID := range itemIDs {
+		var result sql.Result
+		if weight, measured := weights[itemID]; measured {
+			result, err = tx.Exec(`
+				UPDATE items SET weight_to_verify = FALSE, weight_grams = ?, updated_at = CURRENT_TIMESTAMP
+				WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+			`, weight, itemID, userID)
+		} else {
+			result, err = tx.Exec(`
+				UPDATE items SET weight_to_verify = FALSE, updated_at = CURRENT_TIMESTAMP
+				WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+			`, itemID, userID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to mark item verified: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("item %d not found", itemID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
 func GetItemsWithEmptyBrand(db *sql.DB, userID int) ([]models.Item, error) {
 	query := `
 		SELECT i.id, i.user_id, i.category_id, i.name, i.note, i.weight_grams, COALESCE(i.weight_to_verify, false), i.price,
@@ -507,8 +848,8 @@ func GetItemsWithEmptyBrand(db *sql.DB, userID int) ([]models.Item, error) {
 		       c.id, c.name
 		FROM items i
 		LEFT JOIN categories c ON i.category_id = c.id
-		WHERE i.user_id = ? AND (i.brand IS NULL OR i.brand = '')
-		ORDER BY c.name, i.name
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND (i.brand IS NULL OR i.brand = '')
+		ORDER BY c.sort_order, c.name, i.name
 	`
 
 	rows, err := db.Query(query, userID)
@@ -588,8 +929,8 @@ func GetItemsWithEmptyModel(db *sql.DB, userID int) ([]models.Item, error) {
 		       c.id, c.name
 		FROM items i
 		LEFT JOIN categories c ON i.category_id = c.id
-		WHERE i.user_id = ? AND (i.model IS NULL OR i.model = '')
-		ORDER BY c.name, i.name
+		WHERE i.user_id = ? AND i.deleted_at IS NULL AND (i.model IS NULL OR i.model = '')
+		ORDER BY c.sort_order, c.name, i.name
 	`
 
 	rows, err := db.Query(query, userID)
@@ -665,7 +1006,7 @@ func GetItemsWithEmptyModel(db *sql.DB, userID int) ([]models.Item, error) {
 // Multiple filters can be combined (AND logic).
 func GetItemsWithFilters(db *sql.DB, userID int, verifyOnly, emptyBrand, emptyModel bool) ([]models.Item, error) {
 	// Build WHERE clause dynamically
-	conditions := []string{"i.user_id = ?"}
+	conditions := []string{"i.user_id = ?", "i.deleted_at IS NULL"}
 	args := []interface{}{userID}
 
 	if verifyOnly {
@@ -688,7 +1029,7 @@ func GetItemsWithFilters(db *sql.DB, userID int, verifyOnly, emptyBrand, emptyMo
 		FROM items i
 		LEFT JOIN categories c ON i.category_id = c.id
 		WHERE %s
-		ORDER BY c.name, i.name
+		ORDER BY c.sort_order, c.name, i.name
 	`, whereClause)
 
 	rows, err := db.Query(query, args...)
@@ -759,8 +1100,8 @@ func GetItemsWithFilters(db *sql.DB, userID int, verifyOnly, emptyBrand, emptyMo
 	return items, nil
 }
 
-// DuplicateItem creates a copy of an item with "(duplicate)" appended to the name.
-// If a duplicate already exists, it will be named "(duplicate 2)", "(duplicate 3)", etc.
+// DuplicateItem creates a copy of an item named like duplicated packs: "Tent Copy", then
+// "Tent Copy 2", "Tent Copy 3", etc. if the name is taken.
 func DuplicateItem(db *sql.DB, userID, itemID int) (*models.Item, error) {
 	// Get the original item
 	original, err := GetItem(db, userID, itemID)
@@ -791,30 +1132,20 @@ func DuplicateItem(db *sql.DB, userID, itemID int) (*models.Item, error) {
 	return CreateItem(db, userID, duplicate)
 }
 
-// generateDuplicateName generates a unique duplicate name for an item
+// generateDuplicateName names the copy of an item like copies of packs are named, "Name Copy"
+// then "Name Copy 2", skipping names the user's items already use
 func generateDuplicateName(db *sql.DB, userID int, baseName string) string {
-	// First try "Name (duplicate)"
-	candidateName := baseName + " (duplicate)"
-	if !itemNameExists(db, userID, candidateName) {
-		return candidateName
+	candidateName := nextCopyName(baseName)
+	for i := 0; i < maxCopyNameAttempts && itemNameExists(db, userID, candidateName); i++ {
+		candidateName = nextCopyName(candidateName)
 	}
-
-	// Try "Name (duplicate 2)", "Name (duplicate 3)", etc.
-	for i := 2; i < 1000; i++ {
-		candidateName = fmt.Sprintf("%s (duplicate %d)", baseName, i)
-		if !itemNameExists(db, userID, candidateName) {
-			return candidateName
-		}
-	}
-
-	// Fallback with timestamp if somehow we hit 1000 duplicates
-	return fmt.Sprintf("%s (duplicate %d)", baseName, time.Now().Unix())
+	return candidateName
 }
 
 // itemNameExists checks if an item with the given name exists for the user
 func itemNameExists(db *sql.DB, userID int, name string) bool {
 	var count int
-	query := `SELECT COUNT(*) FROM items WHERE user_id = ? AND name = ?`
+	query := `SELECT COUNT(*) FROM items WHERE user_id = ? AND name = ? AND deleted_at IS NULL`
 	err := db.QueryRow(query, userID, name).Scan(&count)
 	if err != nil {
 		return false
@@ -822,17 +1153,112 @@ func itemNameExists(db *sql.DB, userID int, name string) bool {
 	return count > 0
 }
 
-// BulkDeleteItems deletes multiple items atomically.
-// Returns the number of items deleted.
-func BulkDeleteItems(db *sql.DB, userID int, itemIDs []int) (int, error) {
+// FindSimilarItems returns the user's items whose name looks like a duplicate of name.
+// Names are compared case-insensitively with punctuation and extra spaces ignored, and
+// a couple of typos are tolerated on longer names.
+func FindSimilarItems(db *sql.DB, userID int, name string) ([]models.Item, error) {
+	target := normalizeItemName(name)
+	if target == "" {
+		return nil, nil
+	}
+
+	query := `
+		SELECT i.id, i.name, i.weight_grams, COALESCE(c.name, '')
+		FROM items i
+		LEFT JOIN categories c ON i.category_id = c.id
+		WHERE i.user_id = ? AND i.deleted_at IS NULL
+		ORDER BY i.name
+	`
+
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query items: %w", err)
+	}
+	defer rows.Close()
+
+	var similar []models.Item
+	for rows.Next() {
+		var item models.Item
+		var categoryName string
+		if err := rows.Scan(&item.ID, &item.Name, &item.WeightGrams, &categoryName); err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+
+		if !similarItemNames(target, normalizeItemName(item.Name)) {
+			continue
+		}
+		item.Category = &models.Category{Name: categoryName}
+		similar = append(similar, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating items: %w", err)
+	}
+
+	return similar, nil
+}
+
+// normalizeItemName lowercases a name and reduces anything that isn't a letter or digit
+// to single spaces, so "Tent - 2P" and "tent 2p" compare equal
+func normalizeItemName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// similarItemNames reports whether two normalized names are equal or within a small edit
+// distance. Short names must match exactly, otherwise "pot" and "hat" would be flagged.
+func similarItemNames(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	maxDistance := 0
+	switch length := len([]rune(a)); {
+	case length >= 12:
+		maxDistance = 2
+	case length >= 6:
+		maxDistance = 1
+	}
+
+	return maxDistance > 0 && levenshtein(a, b) <= maxDistance
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// BulkDeleteItems moves multiple items to the trash atomically. Items that are used in
+// a pack are left untouched and returned as skipped so they can be reviewed one by one.
+// Returns the number of items deleted and the IDs that were skipped.
+func BulkDeleteItems(db *sql.DB, userID int, itemIDs []int) (int, []int, error) {
 	if len(itemIDs) == 0 {
-		return 0, fmt.Errorf("no items specified")
+		return 0, nil, fmt.Errorf("no items specified")
 	}
 
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
-		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -847,7 +1273,7 @@ func BulkDeleteItems(db *sql.DB, userID int, itemIDs []int) (int, error) {
 
 	// First, verify ALL items belong to the user
 	countQuery := fmt.Sprintf(
-		"SELECT COUNT(*) FROM items WHERE user_id = ? AND id IN (%s)",
+		"SELECT COUNT(*) FROM items WHERE user_id = ? AND id IN (%s) AND deleted_at IS NULL",
 		placeholderStr,
 	)
 
@@ -855,46 +1281,80 @@ func BulkDeleteItems(db *sql.DB, userID int, itemIDs []int) (int, error) {
 	var count int
 	err = tx.QueryRow(countQuery, countArgs...).Scan(&count)
 	if err != nil {
-		return 0, fmt.Errorf("failed to verify item ownership: %w", err)
+		return 0, nil, fmt.Errorf("failed to verify item ownership: %w", err)
 	}
 
 	if count != len(itemIDs) {
-		return 0, fmt.Errorf("some items not found or not owned by user (found %d of %d)", count, len(itemIDs))
+		return 0, nil, fmt.Errorf("some items not found or not owned by user (found %d of %d)", count, len(itemIDs))
 	}
 
-	// Remove items from all packs first
-	removePackItemsQuery := fmt.Sprintf(
-		"DELETE FROM pack_items WHERE item_id IN (%s)",
+	// Skip items that are still used in packs
+	inUseQuery := fmt.Sprintf(
+		"SELECT DISTINCT item_id FROM pack_items WHERE item_id IN (%s) ORDER BY item_id",
 		placeholderStr,
 	)
-	_, err = tx.Exec(removePackItemsQuery, idArgs...)
+	rows, err := tx.Query(inUseQuery, idArgs...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to remove items from packs: %w", err)
+		return 0, nil, fmt.Errorf("failed to check item usage in packs: %w", err)
+	}
+	inUse := make(map[int]bool)
+	var skipped []int
+	for rows.Next() {
+		var itemID int
+		if err := rows.Scan(&itemID); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("failed to scan item usage: %w", err)
+		}
+		inUse[itemID] = true
+		skipped = append(skipped, itemID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("error iterating item usage: %w", err)
+	}
+
+	deleteArgs := []interface{}{userID}
+	for _, id := range itemIDs {
+		if !inUse[id] {
+			deleteArgs = append(deleteArgs, id)
+		}
+	}
+	if len(deleteArgs) == 1 {
+		return 0, skipped, nil
 	}
 
-	// Delete the items
+	// Move the remaining items to the trash
 	deleteQuery := fmt.Sprintf(
-		"DELETE FROM items WHERE user_id = ? AND id IN (%s)",
-		placeholderStr,
+		"UPDATE items SET deleted_at = CURRENT_TIMESTAMP WHERE user_id = ? AND id IN (%s) AND deleted_at IS NULL",
+		strings.TrimSuffix(strings.Repeat("?,", len(deleteArgs)-1), ","),
 	)
-	deleteArgs := append([]interface{}{userID}, idArgs...)
 
 	result, err := tx.Exec(deleteQuery, deleteArgs...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to bulk delete items: %w", err)
+		return 0, nil, fmt.Errorf("failed to bulk delete items: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return 0, nil, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
-		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(rowsAffected), skipped, nil
+}
+
+// BulkUpdateItemCategory moves multiple items to a category atomically.
+// Both the category and every item must belong to the user.
+func BulkUpdateItemCategory(db *sql.DB, userID int, itemIDs []int, categoryID int) error {
+	if _, err := GetCategory(db, userID, categoryID); err != nil {
+		return err
 	}
 
-	return int(rowsAffected), nil
+	return BulkUpdateItems(db, userID, itemIDs, map[string]interface{}{"category_id": categoryID})
 }
 
 // PatchItem updates a single item with the specified field updates.
@@ -911,7 +1371,7 @@ func PatchItem(db *sql.DB, userID int, itemID int, updates map[string]interface{
 
 	// Verify item belongs to user
 	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM items WHERE id = ? AND user_id = ?)", itemID, userID).Scan(&exists)
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM items WHERE id = ? AND user_id = ? AND deleted_at IS NULL)", itemID, userID).Scan(&exists)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify item ownership: %w", err)
 	}
@@ -976,7 +1436,7 @@ func BulkUpdateItems(db *sql.DB, userID int, itemIDs []int, updates map[string]i
 
 	// First, verify ALL items belong to the user
 	countQuery := fmt.Sprintf(
-		"SELECT COUNT(*) FROM items WHERE user_id = ? AND id IN (%s)",
+		"SELECT COUNT(*) FROM items WHERE user_id = ? AND id IN (%s) AND deleted_at IS NULL",
 		placeholderStr,
 	)
 
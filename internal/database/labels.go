@@ -8,15 +8,10 @@ import (
 )
 
 func CreatePackLabel(db *sql.DB, packID string, name, color string, userID int) (*models.PackLabel, error) {
-	pack, err := GetPack(db, packID)
-	if err != nil {
+	if _, err := getEditablePack(db, packID, userID); err != nil {
 		return nil, err
 	}
 
-	if pack.UserID != userID {
-		return nil, fmt.Errorf("unauthorized")
-	}
-
 	query := `
 		INSERT INTO pack_labels (pack_id, name, color)
 		VALUES (?, ?, ?)
@@ -48,8 +43,14 @@ func GetPackLabels(db *sql.DB, packID string, userID int) ([]models.PackLabel, e
 		return nil, err
 	}
 
-	if pack.UserID != userID && !pack.IsPublic {
-		return nil, fmt.Errorf("unauthorized")
+	if !pack.IsPublic {
+		canEdit, err := CanEditPack(db, pack, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canEdit {
+			return nil, fmt.Errorf("unauthorized")
+		}
 	}
 
 	query := `
@@ -90,16 +91,15 @@ func GetPackLabels(db *sql.DB, packID string, userID int) ([]models.PackLabel, e
 }
 
 func UpdatePackLabel(db *sql.DB, labelID int, name, color string, userID int) error {
-	// First verify the user owns the pack this label belongs to
+	// First verify the user can edit the pack this label belongs to
 	checkQuery := `
-		SELECT p.user_id
+		SELECT pl.pack_id
 		FROM pack_labels pl
-		JOIN packs p ON pl.pack_id = p.id
 		WHERE pl.id = ?
 	`
 	
-	var packUserID int
-	err := db.QueryRow(checkQuery, labelID).Scan(&packUserID)
+	var packID string
+	err := db.QueryRow(checkQuery, labelID).Scan(&packID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("label not found")
@@ -107,8 +107,8 @@ func UpdatePackLabel(db *sql.DB, labelID int, name, color string, userID int) er
 		return fmt.Errorf("failed to check label ownership: %w", err)
 	}
 
-	if packUserID != userID {
-		return fmt.Errorf("unauthorized")
+	if _, err := getEditablePack(db, packID, userID); err != nil {
+		return err
 	}
 
 	query := `
@@ -135,16 +135,15 @@ func UpdatePackLabel(db *sql.DB, labelID int, name, color string, userID int) er
 }
 
 func DeletePackLabel(db *sql.DB, labelID int, userID int) error {
-	// First verify the user owns the pack this label belongs to
+	// First verify the user can edit the pack this label belongs to
 	checkQuery := `
-		SELECT p.user_id
+		SELECT pl.pack_id
 		FROM pack_labels pl
-		JOIN packs p ON pl.pack_id = p.id
 		WHERE pl.id = ?
 	`
 	
-	var packUserID int
-	err := db.QueryRow(checkQuery, labelID).Scan(&packUserID)
+	var packID string
+	err := db.QueryRow(checkQuery, labelID).Scan(&packID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("label not found")
@@ -152,8 +151,8 @@ func DeletePackLabel(db *sql.DB, labelID int, userID int) error {
 		return fmt.Errorf("failed to check label ownership: %w", err)
 	}
 
-	if packUserID != userID {
-		return fmt.Errorf("unauthorized")
+	if _, err := getEditablePack(db, packID, userID); err != nil {
+		return err
 	}
 
 	query := `DELETE FROM pack_labels WHERE id = ?`
@@ -176,17 +175,15 @@ func DeletePackLabel(db *sql.DB, labelID int, userID int) error {
 }
 
 func AssignLabelToPackItem(db *sql.DB, packItemID, labelID int, userID int) error {
-	// Verify user owns both the pack item and the label
+	// Verify user can edit the pack item's pack and the label belongs to it
 	checkQuery := `
-		SELECT p.user_id, p.id as pack_id
+		SELECT pi.pack_id
 		FROM pack_items pi
-		JOIN packs p ON pi.pack_id = p.id
 		WHERE pi.id = ?
 	`
 	
-	var packUserID int
 	var packID string
-	err := db.QueryRow(checkQuery, packItemID).Scan(&packUserID, &packID)
+	err := db.QueryRow(checkQuery, packItemID).Scan(&packID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("pack item not found")
@@ -194,8 +191,8 @@ func AssignLabelToPackItem(db *sql.DB, packItemID, labelID int, userID int) erro
 		return fmt.Errorf("failed to check pack item ownership: %w", err)
 	}
 
-	if packUserID != userID {
-		return fmt.Errorf("unauthorized")
+	if _, err := getEditablePack(db, packID, userID); err != nil {
+		return err
 	}
 
 	// Verify the label belongs to the same pack
@@ -240,16 +237,15 @@ func AssignLabelToPackItem(db *sql.DB, packItemID, labelID int, userID int) erro
 }
 
 func RemoveLabelFromPackItem(db *sql.DB, packItemID, labelID int, userID int) error {
-	// Verify user owns the pack item
+	// Verify user can edit the pack item's pack
 	checkQuery := `
-		SELECT p.user_id
+		SELECT pi.pack_id
 		FROM pack_items pi
-		JOIN packs p ON pi.pack_id = p.id
 		WHERE pi.id = ?
 	`
 	
-	var packUserID int
-	err := db.QueryRow(checkQuery, packItemID).Scan(&packUserID)
+	var packID string
+	err := db.QueryRow(checkQuery, packItemID).Scan(&packID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("pack item not found")
@@ -257,8 +253,8 @@ func RemoveLabelFromPackItem(db *sql.DB, packItemID, labelID int, userID int) er
 		return fmt.Errorf("failed to check pack item ownership: %w", err)
 	}
 
-	if packUserID != userID {
-		return fmt.Errorf("unauthorized")
+	if _, err := getEditablePack(db, packID, userID); err != nil {
+		return err
 	}
 
 	// Check current count and decrement or delete
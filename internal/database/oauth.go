@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"carryless/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxUsernameSuffix bounds the numbered variants tried when an OAuth user's name is taken
+const maxUsernameSuffix = 100
+
+// GetUserByOAuthIdentity returns the user linked to the identity at the provider
+func GetUserByOAuthIdentity(db *sql.DB, provider, subject string) (*models.User, error) {
+	var userID int
+	err := db.QueryRow(`
+		SELECT id FROM users WHERE oauth_provider = ? AND oauth_subject = ?
+	`, provider, subject).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+
+	return GetUserByID(db, userID)
+}
+
+// LinkOAuthIdentity lets the user log in with the identity at the provider. A user can be
+// linked to a single identity.
+func LinkOAuthIdentity(db *sql.DB, userID int, provider, subject string) error {
+	result, err := db.Exec(`
+		UPDATE users SET oauth_provider = ?, oauth_subject = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND (oauth_provider IS NULL OR (oauth_provider = ? AND oauth_subject = ?))
+	`, provider, subject, userID, provider, subject)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("oauth identity already linked to another user")
+		}
+		return fmt.Errorf("failed to link oauth identity: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user already linked to another oauth identity")
+	}
+
+	return nil
+}
+
+// CreateOAuthUser registers a user from an identity whose email the provider verified, so
+// the account is activated right away. It has no usable password: logging in goes through
+// the provider. username is suffixed with a number if it is already taken.
+func CreateOAuthUser(db *sql.DB, username, email, provider, subject string) (*models.User, error) {
+	// Nobody knows this password, it only fills the required column
+	randomPassword, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	username, err = availableUsername(tx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var userCount int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users").Scan(&userCount); err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	isAdmin := userCount == 0 // First user becomes admin
+
+	result, err := tx.Exec(`
+		INSERT INTO users (username, email, password_hash, is_admin, is_activated, oauth_provider, oauth_subject)
+		VALUES (?, ?, ?, ?, TRUE, ?, ?)
+	`, username, email, string(hashedPassword), isAdmin, provider, subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user ID: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetUserByID(db, int(id))
+}
+
+func availableUsername(tx *sql.Tx, username string) (string, error) {
+	candidate := username
+	for i := 2; i <= maxUsernameSuffix; i++ {
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", candidate).Scan(&count); err != nil {
+			return "", fmt.Errorf("failed to check username: %w", err)
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", username, i)
+	}
+	return "", fmt.Errorf("username not available")
+}
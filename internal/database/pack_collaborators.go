@@ -0,0 +1,241 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"carryless/internal/models"
+)
+
+// PackRoleEditor lets a collaborator change a pack's items, labels and notes. Deleting the
+// pack, changing its visibility and managing collaborators stay with the owner.
+const PackRoleEditor = "editor"
+
+// packRoles are the roles a pack can be shared with
+var packRoles = map[string]bool{
+	PackRoleEditor: true,
+}
+
+// IsValidPackRole reports whether role can be given to a collaborator
+func IsValidPackRole(role string) bool {
+	return packRoles[role]
+}
+
+// CanEditPack reports whether the user may change the pack's contents: its owner or an editor
+func CanEditPack(db *sql.DB, pack *models.Pack, userID int) (bool, error) {
+	if pack.UserID == userID {
+		return true, nil
+	}
+
+	role, err := GetPackCollaboratorRole(db, pack.ID, userID)
+	if err != nil {
+		return false, err
+	}
+	return role == PackRoleEditor, nil
+}
+
+// getEditablePack returns the pack when the user may change its contents
+func getEditablePack(db *sql.DB, packID string, userID int) (*models.Pack, error) {
+	pack, err := GetPack(db, packID)
+	if err != nil {
+		return nil, err
+	}
+
+	canEdit, err := CanEditPack(db, pack, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canEdit {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	return pack, nil
+}
+
+// GetPackCollaboratorRole returns the role the pack was shared with the user with, or "" if it wasn't
+func GetPackCollaboratorRole(db *sql.DB, packID string, userID int) (string, error) {
+	var role string
+	err := db.QueryRow(`
+		SELECT role FROM pack_collaborators WHERE pack_id = ? AND user_id = ?
+	`, packID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get collaborator role: %w", err)
+	}
+	return role, nil
+}
+
+// AddPackCollaborator shares the pack with the user registered with email. Sharing again
+// with the same user changes their role.
+func AddPackCollaborator(db *sql.DB, ownerID int, packID, email, role string) (*models.PackCollaborator, error) {
+	if !IsValidPackRole(role) {
+		return nil, fmt.Errorf("invalid role")
+	}
+
+	pack, err := GetPack(db, packID)
+	if err != nil {
+		return nil, err
+	}
+	if pack.UserID != ownerID {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	collaborator, err := GetUserByEmail(db, email)
+	if err != nil {
+		return nil, err
+	}
+	if collaborator.ID == ownerID {
+		return nil, fmt.Errorf("cannot share a pack with its owner")
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO pack_collaborators (pack_id, user_id, role)
+		VALUES (?, ?, ?)
+		ON CONFLICT(pack_id, user_id) DO UPDATE SET role = excluded.role
+	`, packID, collaborator.ID, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add collaborator: %w", err)
+	}
+
+	return &models.PackCollaborator{
+		PackID:    packID,
+		UserID:    collaborator.ID,
+		Username:  collaborator.Username,
+		Email:     collaborator.Email,
+		Role:      role,
+		CreatedAt: collaborator.CreatedAt,
+	}, nil
+}
+
+// RemovePackCollaborator stops sharing the pack with a collaborator. The owner can remove
+// anyone and a collaborator can remove themselves.
+func RemovePackCollaborator(db *sql.DB, userID int, packID string, collaboratorID int) error {
+	pack, err := GetPack(db, packID)
+	if err != nil {
+		return err
+	}
+	if pack.UserID != userID && collaboratorID != userID {
+		return fmt.Errorf("unauthorized")
+	}
+
+	result, err := db.Exec(`
+		DELETE FROM pack_collaborators WHERE pack_id = ? AND user_id = ?
+	`, packID, collaboratorID)
+	if err != nil {
+		return fmt.Errorf("failed to remove collaborator: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("collaborator not found")
+	}
+
+	return nil
+}
+
+// GetPackCollaborators lists the users the pack is shared with
+func GetPackCollaborators(db *sql.DB, packID string) ([]models.PackCollaborator, error) {
+	rows, err := db.Query(`
+		SELECT pc.pack_id, pc.user_id, u.username, u.email, pc.role, pc.created_at
+		FROM pack_collaborators pc
+		JOIN users u ON pc.user_id = u.id
+		WHERE pc.pack_id = ?
+		ORDER BY u.username
+	`, packID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collaborators: %w", err)
+	}
+	defer rows.Close()
+
+	var collaborators []models.PackCollaborator
+	for rows.Next() {
+		var collaborator models.PackCollaborator
+		err := rows.Scan(
+			&collaborator.PackID,
+			&collaborator.UserID,
+			&collaborator.Username,
+			&collaborator.Email,
+			&collaborator.Role,
+			&collaborator.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collaborator: %w", err)
+		}
+		collaborators = append(collaborators, collaborator)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating collaborators: %w", err)
+	}
+
+	return collaborators, nil
+}
+
+// GetSharedPacks lists the packs other users shared with the user, with their role and owner
+func GetSharedPacks(db *sql.DB, userID int) ([]models.Pack, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.user_id, p.name, COALESCE(p.note, ''), p.is_public, COALESCE(p.is_locked, FALSE), COALESCE(p.is_template, FALSE), COALESCE(p.is_favorite, FALSE), COALESCE(p.is_anonymous, FALSE), COALESCE(p.short_id, ''), p.created_at, p.updated_at,
+		       pc.role, u.username, `+packTotalWeightColumn+`
+		FROM pack_collaborators pc
+		JOIN packs p ON pc.pack_id = p.id
+		JOIN users u ON p.user_id = u.id
+		WHERE pc.user_id = ? AND COALESCE(p.is_template, FALSE) = FALSE
+		ORDER BY COALESCE(p.is_locked, FALSE) ASC, p.updated_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shared packs: %w", err)
+	}
+	defer rows.Close()
+
+	var packs []models.Pack
+	for rows.Next() {
+		var pack models.Pack
+		err := rows.Scan(
+			&pack.ID,
+			&pack.UserID,
+			&pack.Name,
+			&pack.Note,
+			&pack.IsPublic,
+			&pack.IsLocked,
+			&pack.IsTemplate,
+			&pack.IsFavorite,
+			&pack.IsAnonymous,
+			&pack.ShortID,
+			&pack.CreatedAt,
+			&pack.UpdatedAt,
+			&pack.SharedRole,
+			&pack.OwnerUsername,
+			&pack.TotalWeight,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shared pack: %w", err)
+		}
+		packs = append(packs, pack)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shared packs: %w", err)
+	}
+
+	return packs, nil
+}
+
+// GetPacksIncludingShared lists the user's own packs followed by the packs shared with them
+func GetPacksIncludingShared(db *sql.DB, userID int) ([]models.Pack, error) {
+	packs, err := GetPacks(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := GetSharedPacks(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(packs, shared...), nil
+}
@@ -0,0 +1,313 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"carryless/internal/models"
+)
+
+// ExportPack builds a self-contained copy of a pack with its items, labels and label assignments
+func ExportPack(db *sql.DB, packID string) (*models.PackExport, error) {
+	pack, err := GetPackWithItems(db, packID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.PackExport{
+		Version: models.PackExportVersion,
+		Name:    pack.Name,
+		Note:    pack.Note,
+		Labels:  []models.PackExportLabel{},
+		Items:   []models.PackExportItem{},
+	}
+
+	for _, label := range pack.Labels {
+		export.Labels = append(export.Labels, models.PackExportLabel{Name: label.Name, Color: label.Color})
+	}
+
+	for _, packItem := range pack.Items {
+		item := models.PackExportItem{
+			Name:         packItem.Item.Name,
+			Category:     packItem.Item.Category.Name,
+			Note:         packItem.Item.Note,
+			WeightGrams:  packItem.Item.WeightGrams,
+			Price:        packItem.Item.Price,
+			Brand:        packItem.Item.Brand,
+			Model:        packItem.Item.Model,
+			Count:        packItem.Count,
+			WornCount:    packItem.WornCount,
+			IsConsumable: packItem.IsConsumable,
+			SortOrder:    packItem.SortOrder,
+			PackNote:     packItem.Note,
+		}
+		for _, itemLabel := range packItem.Labels {
+			if itemLabel.PackLabel == nil {
+				continue
+			}
+			item.Labels = append(item.Labels, models.PackExportItemLabel{Label: itemLabel.PackLabel.Name, Count: itemLabel.Count})
+		}
+		export.Items = append(export.Items, item)
+	}
+
+	return export, nil
+}
+
+// ImportPack recreates an exported pack for the user. Categories are created when missing,
+// items are matched by name and category and created when they don't exist yet.
+// The export is expected to have been validated by the caller.
+func ImportPack(db *sql.DB, userID int, data *models.PackExport) (*models.Pack, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Resolve categories and existing items first, in the transaction so a failed import
+	// leaves no empty categories behind
+	categoryIDs := make([]int, len(data.Items))
+	itemIDs := make([]int, len(data.Items))
+	for i, exported := range data.Items {
+		categoryID, err := getOrCreateCategoryTx(tx, userID, exported.Category)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get or create category: %w", err)
+		}
+		categoryIDs[i] = categoryID
+
+		itemID, err := findItemIDByNameAndCategoryTx(tx, userID, exported.Name, categoryID)
+		if err != nil {
+			return nil, err
+		}
+		itemIDs[i] = itemID
+	}
+
+	usedItems := make(map[int]bool)
+	for i, exported := range data.Items {
+		if itemIDs[i] == 0 {
+			result, err := tx.Exec(`
+				INSERT INTO items (user_id, category_id, name, note, weight_grams, price, brand, model)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, userID, categoryIDs[i], exported.Name, exported.Note, exported.WeightGrams, exported.Price, exported.Brand, exported.Model)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create item: %w", err)
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get item ID: %w", err)
+			}
+			itemIDs[i] = int(id)
+		}
+
+		if usedItems[itemIDs[i]] {
+			return nil, fmt.Errorf("duplicate item %q in category %q", exported.Name, exported.Category)
+		}
+		usedItems[itemIDs[i]] = true
+	}
+
+	newPack, err := createPackWithTx(tx, userID, data.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Note != "" {
+		if _, err := tx.Exec(`UPDATE packs SET note = ? WHERE id = ?`, data.Note, newPack.ID); err != nil {
+			return nil, fmt.Errorf("failed to set pack note: %w", err)
+		}
+		newPack.Note = data.Note
+	}
+
+	labelIDs := make(map[string]int)
+	for _, label := range data.Labels {
+		result, err := tx.Exec(`INSERT INTO pack_labels (pack_id, name, color) VALUES (?, ?, ?)`, newPack.ID, label.Name, label.Color)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pack label: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get label ID: %w", err)
+		}
+		labelIDs[label.Name] = int(id)
+	}
+
+	for i, exported := range data.Items {
+		result, err := tx.Exec(`
+			INSERT INTO pack_items (pack_id, item_id, count, worn_count, is_worn, is_consumable, sort_order, note)
+			VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))
+		`, newPack.ID, itemIDs[i], exported.Count, exported.WornCount, exported.WornCount > 0, exported.IsConsumable, exported.SortOrder, exported.PackNote)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add item to pack: %w", err)
+		}
+		packItemID, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pack item ID: %w", err)
+		}
+
+		for _, itemLabel := range exported.Labels {
+			labelID, ok := labelIDs[itemLabel.Label]
+			if !ok {
+				return nil, fmt.Errorf("unknown label %q", itemLabel.Label)
+			}
+			_, err := tx.Exec(`INSERT INTO item_labels (pack_item_id, pack_label_id, count) VALUES (?, ?, ?)`, packItemID, labelID, itemLabel.Count)
+			if err != nil {
+				return nil, fmt.Errorf("failed to assign label: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return newPack, nil
+}
+
+// ClonePackToUser copies a public pack into another user's account, for example a pack a
+// trip organizer recommends to participants. Categories and items are matched by name in
+// the target's inventory and created when missing. Unlike DuplicatePack, the copy doesn't
+// reference the source owner's items.
+func ClonePackToUser(db *sql.DB, sourcePackID string, targetUserID int) (*models.Pack, error) {
+	pack, err := GetPack(db, sourcePackID)
+	if err != nil {
+		return nil, err
+	}
+	if !pack.IsPublic {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	export, err := ExportPack(db, sourcePackID)
+	if err != nil {
+		return nil, err
+	}
+	export.Items = mergeSameExportItems(export.Items)
+
+	return ImportPack(db, targetUserID, export)
+}
+
+// mergeSameExportItems folds items with the same name and category into one, adding up their
+// counts and labels. The source owner may have two such items, but they'd both be matched to
+// the same item in the target's inventory.
+func mergeSameExportItems(items []models.PackExportItem) []models.PackExportItem {
+	var merged []models.PackExportItem
+	index := make(map[string]int)
+	for _, item := range items {
+		key := strings.ToLower(normalizeCategoryName(item.Category)) + "\x00" + item.Name
+		i, exists := index[key]
+		if !exists {
+			index[key] = len(merged)
+			merged = append(merged, item)
+			continue
+		}
+
+		merged[i].Count += item.Count
+		merged[i].WornCount += item.WornCount
+		for _, label := range item.Labels {
+			found := false
+			for j := range merged[i].Labels {
+				if merged[i].Labels[j].Label == label.Label {
+					merged[i].Labels[j].Count += label.Count
+					found = true
+					break
+				}
+			}
+			if !found {
+				merged[i].Labels = append(merged[i].Labels, label)
+			}
+		}
+	}
+	return merged
+}
+
+// ImportPackItems adds items to an existing pack, matching inventory items by name and category.
+// Missing categories and items are created. Items already in the pack have their counts replaced.
+// Returns the number of rows imported. The items are expected to have been validated by the caller.
+func ImportPackItems(db *sql.DB, packID string, userID int, items []models.PackExportItem) (int, error) {
+	pack, err := getEditablePack(db, packID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Items always land in the owner's inventory, even when a collaborator imports them
+	ownerID := pack.UserID
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Resolve categories and existing items first, in the transaction so a failed import
+	// leaves no empty categories behind
+	categoryIDs := make([]int, len(items))
+	itemIDs := make([]int, len(items))
+	for i, imported := range items {
+		categoryID, err := getOrCreateCategoryTx(tx, ownerID, imported.Category)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get or create category: %w", err)
+		}
+		categoryIDs[i] = categoryID
+
+		itemID, err := findItemIDByNameAndCategoryTx(tx, ownerID, imported.Name, categoryID)
+		if err != nil {
+			return 0, err
+		}
+		itemIDs[i] = itemID
+	}
+
+	usedItems := make(map[int]bool)
+	for i, imported := range items {
+		if itemIDs[i] == 0 {
+			result, err := tx.Exec(`
+				INSERT INTO items (user_id, category_id, name, note, weight_grams, price)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, ownerID, categoryIDs[i], imported.Name, imported.Note, imported.WeightGrams, imported.Price)
+			if err != nil {
+				return 0, fmt.Errorf("failed to create item: %w", err)
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return 0, fmt.Errorf("failed to get item ID: %w", err)
+			}
+			itemIDs[i] = int(id)
+		}
+
+		if usedItems[itemIDs[i]] {
+			return 0, fmt.Errorf("duplicate item %q in category %q", imported.Name, imported.Category)
+		}
+		usedItems[itemIDs[i]] = true
+
+		result, err := tx.Exec(`
+			UPDATE pack_items SET count = ?, worn_count = ?, is_worn = ?
+			WHERE pack_id = ? AND item_id = ?
+		`, imported.Count, imported.WornCount, imported.WornCount > 0, packID, itemIDs[i])
+		if err != nil {
+			return 0, fmt.Errorf("failed to update pack item: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected > 0 {
+			continue
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO pack_items (pack_id, item_id, count, worn_count, is_worn)
+			VALUES (?, ?, ?, ?, ?)
+		`, packID, itemIDs[i], imported.Count, imported.WornCount, imported.WornCount > 0)
+		if err != nil {
+			return 0, fmt.Errorf("failed to add item to pack: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Update pack timestamp since items were modified
+	if err := updatePackTimestamp(db, packID); err != nil {
+		return 0, err
+	}
+
+	return len(items), nil
+}
@@ -1,10 +1,11 @@
 package database
 
 import (
-	"crypto/rand"
 	"database/sql"
 	"fmt"
-	"math/big"
+	"regexp"
+	"strconv"
+	"time"
 
 	"carryless/internal/logger"
 	"carryless/internal/models"
@@ -12,45 +13,107 @@ import (
 	"github.com/google/uuid"
 )
 
-const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-
 // Helper function to update pack timestamp when items are modified
 func updatePackTimestamp(db *sql.DB, packID string) error {
 	query := `UPDATE packs SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`
 	_, err := db.Exec(query, packID)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Weight history is a nice-to-have, don't fail the pack update over it
+	if err := recordPackWeightSnapshot(db, packID); err != nil {
+		logger.Warn("Failed to record pack weight snapshot",
+			"pack_id", packID,
+			"error", err)
+	}
+	return nil
 }
 
-func generateShortID(db *sql.DB) (string, error) {
-	const idLength = 8
-	const maxRetries = 10
+// packWeightSnapshotRetention is how long weight snapshots are kept before CleanupOldPackWeightSnapshots prunes them
+const packWeightSnapshotRetention = "-90 days"
+
+// recordPackWeightSnapshot stores the current total and base weight of a pack.
+// At most one snapshot is kept per pack and hour: changes within the hour update the latest snapshot.
+func recordPackWeightSnapshot(db *sql.DB, packID string) error {
+	var totalGrams, baseGrams int
+	weightQuery := `
+		SELECT
+			COALESCE(SUM(i.weight_grams * pi.count), 0),
+			COALESCE(SUM(CASE WHEN COALESCE(pi.is_consumable, 0) = 0 THEN i.weight_grams * MAX(pi.count - pi.worn_count, 0) ELSE 0 END), 0)
+		FROM pack_items pi
+		JOIN items i ON pi.item_id = i.id
+		WHERE pi.pack_id = ? AND i.deleted_at IS NULL
+	`
+	if err := db.QueryRow(weightQuery, packID).Scan(&totalGrams, &baseGrams); err != nil {
+		return fmt.Errorf("failed to compute pack weight: %w", err)
+	}
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		// Generate random 8-character ID
-		b := make([]byte, idLength)
-		for i := range b {
-			num, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
-			if err != nil {
-				return "", fmt.Errorf("failed to generate random number: %w", err)
-			}
-			b[i] = charset[num.Int64()]
+	updateQuery := `
+		UPDATE pack_weight_snapshots
+		SET total_grams = ?, base_grams = ?
+		WHERE id = (
+			SELECT id FROM pack_weight_snapshots
+			WHERE pack_id = ? AND recorded_at > datetime('now', '-1 hour')
+			ORDER BY recorded_at DESC LIMIT 1
+		)
+	`
+	result, err := db.Exec(updateQuery, totalGrams, baseGrams, packID)
+	if err != nil {
+		return fmt.Errorf("failed to update pack weight snapshot: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		insertQuery := `INSERT INTO pack_weight_snapshots (pack_id, total_grams, base_grams) VALUES (?, ?, ?)`
+		if _, err := db.Exec(insertQuery, packID, totalGrams, baseGrams); err != nil {
+			return fmt.Errorf("failed to insert pack weight snapshot: %w", err)
 		}
-		
-		shortID := string(b)
-		
-		// Check if this ID already exists
-		var exists bool
-		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM packs WHERE short_id = ?)", shortID).Scan(&exists)
+	}
+
+	return nil
+}
+
+// GetPackWeightHistory returns the weight snapshots of a pack, oldest first
+func GetPackWeightHistory(db *sql.DB, packID string) ([]models.PackWeightSnapshot, error) {
+	query := `
+		SELECT id, pack_id, total_grams, base_grams, recorded_at
+		FROM pack_weight_snapshots
+		WHERE pack_id = ?
+		ORDER BY recorded_at ASC
+	`
+
+	rows, err := db.Query(query, packID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pack weight history: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []models.PackWeightSnapshot
+	for rows.Next() {
+		var snapshot models.PackWeightSnapshot
+		err := rows.Scan(&snapshot.ID, &snapshot.PackID, &snapshot.TotalGrams, &snapshot.BaseGrams, &snapshot.RecordedAt)
 		if err != nil {
-			return "", fmt.Errorf("failed to check short ID existence: %w", err)
-		}
-		
-		if !exists {
-			return shortID, nil
+			return nil, fmt.Errorf("failed to scan pack weight snapshot: %w", err)
 		}
+		snapshots = append(snapshots, snapshot)
 	}
-	
-	return "", fmt.Errorf("failed to generate unique short ID after %d attempts", maxRetries)
+
+	return snapshots, nil
+}
+
+// CleanupOldPackWeightSnapshots deletes weight snapshots older than the retention period
+func CleanupOldPackWeightSnapshots(db *sql.DB) error {
+	query := `DELETE FROM pack_weight_snapshots WHERE recorded_at < datetime('now', ?)`
+	_, err := db.Exec(query, packWeightSnapshotRetention)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup old pack weight snapshots: %w", err)
+	}
+	return nil
 }
 
 func CreatePack(db *sql.DB, userID int, name string) (*models.Pack, error) {
@@ -113,15 +176,49 @@ func CreatePackWithPublic(db *sql.DB, userID int, name string, isPublic bool) (*
 	return pack, nil
 }
 
+// GetPacks returns the user's regular packs. Template packs are left out, see GetPackTemplates.
 func GetPacks(db *sql.DB, userID int) ([]models.Pack, error) {
+	return getPacks(db, userID, false, 0)
+}
+
+// GetPackTemplates returns the user's template packs.
+func GetPackTemplates(db *sql.DB, userID int) ([]models.Pack, error) {
+	return getPacks(db, userID, true, 0)
+}
+
+// GetPacksByLabel returns the user's regular packs that have the given pack-level label assigned.
+func GetPacksByLabel(db *sql.DB, userID, labelID int) ([]models.Pack, error) {
+	return getPacks(db, userID, false, labelID)
+}
+
+// packTotalWeightColumn selects the carried weight of pack p, worn items left out like the
+// "Pack" weight of the pack page, so pack lists get their totals in the same query
+const packTotalWeightColumn = `(
+	SELECT COALESCE(SUM(i.weight_grams * MAX(pi.count - MAX(COALESCE(pi.worn_count, 0), 0), 0)), 0)
+	FROM pack_items pi
+	JOIN items i ON pi.item_id = i.id AND i.deleted_at IS NULL
+	WHERE pi.pack_id = p.id
+)`
+
+// getPacks lists the user's packs or templates. A non-zero labelID restricts the list to
+// packs carrying that pack-level label.
+func getPacks(db *sql.DB, userID int, templates bool, labelID int) ([]models.Pack, error) {
 	query := `
-		SELECT id, user_id, name, COALESCE(note, ''), is_public, COALESCE(is_locked, FALSE), COALESCE(short_id, ''), created_at, updated_at
-		FROM packs
-		WHERE user_id = ?
-		ORDER BY COALESCE(is_locked, FALSE) ASC, updated_at DESC
+		SELECT id, user_id, name, COALESCE(note, ''), is_public, COALESCE(is_locked, FALSE), COALESCE(is_template, FALSE), COALESCE(is_favorite, FALSE), COALESCE(is_anonymous, FALSE), COALESCE(short_id, ''), created_at, updated_at,
+		       ` + packTotalWeightColumn + `
+		FROM packs p
+		WHERE user_id = ? AND COALESCE(is_template, FALSE) = ?
 	`
+	args := []interface{}{userID, templates}
+
+	if labelID != 0 {
+		query += ` AND id IN (SELECT pack_id FROM pack_label_assignments WHERE user_pack_label_id = ?)`
+		args = append(args, labelID)
+	}
 
-	rows, err := db.Query(query, userID)
+	query += ` ORDER BY COALESCE(is_favorite, FALSE) DESC, COALESCE(is_locked, FALSE) ASC, updated_at DESC`
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query packs: %w", err)
 	}
@@ -137,9 +234,13 @@ func GetPacks(db *sql.DB, userID int) ([]models.Pack, error) {
 			&pack.Note,
 			&pack.IsPublic,
 			&pack.IsLocked,
+			&pack.IsTemplate,
+			&pack.IsFavorite,
+			&pack.IsAnonymous,
 			&pack.ShortID,
 			&pack.CreatedAt,
 			&pack.UpdatedAt,
+			&pack.TotalWeight,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan pack: %w", err)
@@ -163,51 +264,40 @@ func GetPacks(db *sql.DB, userID int) ([]models.Pack, error) {
 	return packs, nil
 }
 
-func GetPack(db *sql.DB, packID string) (*models.Pack, error) {
-	pack := &models.Pack{}
-	query := `
-		SELECT id, user_id, name, COALESCE(note, ''), is_public, COALESCE(is_locked, FALSE), COALESCE(short_id, ''), created_at, updated_at
-		FROM packs
-		WHERE id = ?
-	`
+// publicLinkActiveCondition keeps the packs whose public link hasn't expired. Expiry times are
+// stored in UTC so they compare with CURRENT_TIMESTAMP.
+const publicLinkActiveCondition = `(p.public_expires_at IS NULL OR p.public_expires_at > CURRENT_TIMESTAMP)`
 
-	err := db.QueryRow(query, packID).Scan(
-		&pack.ID,
-		&pack.UserID,
-		&pack.Name,
-		&pack.Note,
-		&pack.IsPublic,
-		&pack.IsLocked,
-		&pack.ShortID,
-		&pack.CreatedAt,
-		&pack.UpdatedAt,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("pack not found")
-		}
-		return nil, fmt.Errorf("failed to query pack: %w", err)
-	}
-
-	return pack, nil
+func GetPack(db *sql.DB, packID string) (*models.Pack, error) {
+	return getPack(db, `p.id = ?`, packID)
 }
 
+// GetPackByShortID finds a pack by its public link. A link past its expiry time is not found.
 func GetPackByShortID(db *sql.DB, shortID string) (*models.Pack, error) {
+	return getPack(db, `p.short_id = ? AND `+publicLinkActiveCondition, shortID)
+}
+
+// getPack loads the pack matching the given condition on packs p
+func getPack(db *sql.DB, condition string, args ...interface{}) (*models.Pack, error) {
 	pack := &models.Pack{}
 	query := `
-		SELECT id, user_id, name, COALESCE(note, ''), is_public, COALESCE(is_locked, FALSE), COALESCE(short_id, ''), created_at, updated_at
-		FROM packs
-		WHERE short_id = ?
-	`
+		SELECT id, user_id, name, COALESCE(note, ''), is_public, COALESCE(is_locked, FALSE), COALESCE(is_template, FALSE), COALESCE(is_favorite, FALSE), COALESCE(is_anonymous, FALSE), COALESCE(short_id, ''), public_expires_at, created_at, updated_at
+		FROM packs p
+		WHERE ` + condition
 
-	err := db.QueryRow(query, shortID).Scan(
+	var publicExpiresAt sql.NullTime
+	err := db.QueryRow(query, args...).Scan(
 		&pack.ID,
 		&pack.UserID,
 		&pack.Name,
 		&pack.Note,
 		&pack.IsPublic,
 		&pack.IsLocked,
+		&pack.IsTemplate,
+		&pack.IsFavorite,
+		&pack.IsAnonymous,
 		&pack.ShortID,
+		&publicExpiresAt,
 		&pack.CreatedAt,
 		&pack.UpdatedAt,
 	)
@@ -217,6 +307,9 @@ func GetPackByShortID(db *sql.DB, shortID string) (*models.Pack, error) {
 		}
 		return nil, fmt.Errorf("failed to query pack: %w", err)
 	}
+	if publicExpiresAt.Valid {
+		pack.PublicExpiresAt = &publicExpiresAt.Time
+	}
 
 	return pack, nil
 }
@@ -235,14 +328,14 @@ func GetPackWithItems(db *sql.DB, packID string) (*models.Pack, error) {
 	pack.Labels = labels
 
 	query := `
-		SELECT pi.id, pi.pack_id, pi.item_id, pi.is_worn, pi.count, COALESCE(pi.worn_count, 0), pi.created_at,
+		SELECT pi.id, pi.pack_id, pi.item_id, pi.is_worn, pi.count, COALESCE(pi.worn_count, 0), COALESCE(pi.is_consumable, 0), pi.sort_order, COALESCE(pi.note, ''), pi.created_at,
 		       i.id, i.user_id, i.category_id, i.name, i.note, i.weight_grams, i.weight_to_verify, i.price, i.brand, i.model, i.capacity, i.capacity_unit, i.created_at, i.updated_at,
-		       c.id, c.name
+		       c.id, c.name, c.sort_order
 		FROM pack_items pi
 		INNER JOIN items i ON pi.item_id = i.id
 		LEFT JOIN categories c ON i.category_id = c.id
-		WHERE pi.pack_id = ?
-		ORDER BY c.name, i.name
+		WHERE pi.pack_id = ? AND i.deleted_at IS NULL
+		ORDER BY c.sort_order, c.name, pi.sort_order IS NULL, pi.sort_order, i.name
 	`
 
 	rows, err := db.Query(query, packID)
@@ -265,6 +358,9 @@ func GetPackWithItems(db *sql.DB, packID string) (*models.Pack, error) {
 			&packItem.IsWorn,
 			&packItem.Count,
 			&packItem.WornCount,
+			&packItem.IsConsumable,
+			&packItem.SortOrder,
+			&packItem.Note,
 			&packItem.CreatedAt,
 			&item.ID,
 			&item.UserID,
@@ -282,6 +378,7 @@ func GetPackWithItems(db *sql.DB, packID string) (*models.Pack, error) {
 			&item.UpdatedAt,
 			&category.ID,
 			&category.Name,
+			&category.SortOrder,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan pack item: %w", err)
@@ -301,26 +398,32 @@ func GetPackWithItems(db *sql.DB, packID string) (*models.Pack, error) {
 			item.CapacityUnit = &capacityUnit.String
 		}
 
-		// Get labels for this pack item
-		itemLabels, err := GetPackItemLabels(db, packItem.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get pack item labels: %w", err)
-		}
-
 		item.Category = &category
 		packItem.Item = &item
-		packItem.Labels = itemLabels
 		pack.Items = append(pack.Items, packItem)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating pack items: %w", err)
 	}
+	rows.Close()
+
+	// Labels are read once the rows are closed, so the request never holds two connections
+	for i := range pack.Items {
+		itemLabels, err := GetPackItemLabels(db, pack.Items[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pack item labels: %w", err)
+		}
+		pack.Items[i].Labels = itemLabels
+	}
 
 	return pack, nil
 }
 
-func UpdatePack(db *sql.DB, userID int, packID, name string, isPublic bool) error {
+// UpdatePack renames the pack and sets how it is shared. An anonymous pack is listed in Explore
+// without its owner's username. A nil publicExpiresAt keeps the public link working until the
+// pack is made private.
+func UpdatePack(db *sql.DB, userID int, packID, name string, isPublic, isAnonymous bool, publicExpiresAt *time.Time) error {
 	// First, get the current pack to check if it's being made public and needs a short ID
 	currentPack, err := GetPack(db, packID)
 	if err != nil {
@@ -343,13 +446,18 @@ func UpdatePack(db *sql.DB, userID int, packID, name string, isPublic bool) erro
 		shortIDToSet = sql.NullString{String: currentPack.ShortID, Valid: true}
 	}
 
+	var expiresAtToSet sql.NullTime
+	if publicExpiresAt != nil {
+		expiresAtToSet = sql.NullTime{Time: publicExpiresAt.UTC(), Valid: true}
+	}
+
 	query := `
 		UPDATE packs
-		SET name = ?, is_public = ?, short_id = ?, updated_at = CURRENT_TIMESTAMP
+		SET name = ?, is_public = ?, is_anonymous = ?, short_id = ?, public_expires_at = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND user_id = ?
 	`
 
-	result, err := db.Exec(query, name, isPublic, shortIDToSet, packID, userID)
+	result, err := db.Exec(query, name, isPublic, isAnonymous, shortIDToSet, expiresAtToSet, packID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to update pack: %w", err)
 	}
@@ -366,14 +474,47 @@ func UpdatePack(db *sql.DB, userID int, packID, name string, isPublic bool) erro
 	return nil
 }
 
+// RotatePackShortID gives the user's pack a new public link, so the previous one stops
+// working, and returns the new short ID
+func RotatePackShortID(db *sql.DB, userID int, packID string) (string, error) {
+	shortID, err := generateShortID(db)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate short ID: %w", err)
+	}
+
+	result, err := db.Exec(`
+		UPDATE packs
+		SET short_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?
+	`, shortID, packID, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate short ID: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return "", fmt.Errorf("pack not found")
+	}
+
+	return shortID, nil
+}
+
 func UpdatePackNote(db *sql.DB, userID int, packID, note string) error {
+	if _, err := getEditablePack(db, packID, userID); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE packs
 		SET note = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND user_id = ?
+		WHERE id = ?
 	`
 
-	result, err := db.Exec(query, note, packID, userID)
+	result, err := db.Exec(query, note, packID)
 	if err != nil {
 		return fmt.Errorf("failed to update pack note: %w", err)
 	}
@@ -413,17 +554,16 @@ func DeletePack(db *sql.DB, userID int, packID string) error {
 	return nil
 }
 
-func AddItemToPack(db *sql.DB, packID string, itemID int, userID int) error {
-	pack, err := GetPack(db, packID)
+// AddItemToPack adds one of the item to the pack. With includeLinked, the item's linked
+// items are added alongside it, unless they are already in the pack.
+func AddItemToPack(db *sql.DB, packID string, itemID int, userID int, includeLinked bool) error {
+	pack, err := getEditablePack(db, packID, userID)
 	if err != nil {
 		return err
 	}
 
-	if pack.UserID != userID {
-		return fmt.Errorf("unauthorized")
-	}
-
-	_, err = GetItem(db, userID, itemID)
+	// Collaborators pack the owner's gear, not their own
+	_, err = GetItem(db, pack.UserID, itemID)
 	if err != nil {
 		return fmt.Errorf("item not found")
 	}
@@ -446,18 +586,18 @@ func AddItemToPack(db *sql.DB, packID string, itemID int, userID int) error {
 		return err
 	}
 
-	// Get linked item IDs and add them too
-	linkedItemIDs, err := GetLinkedItemIDs(db, itemID)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to get linked items: %w", err)
-	}
-
-	for _, linkedItemID := range linkedItemIDs {
-		// Add each linked item (ignore if already in pack - just increment count)
-		if err := addSingleItemToPackTx(tx, packID, linkedItemID); err != nil {
+	if includeLinked {
+		linkedItemIDs, err := getLinkedItemIDsTx(tx, itemID)
+		if err != nil {
 			tx.Rollback()
-			return fmt.Errorf("failed to add linked item %d to pack: %w", linkedItemID, err)
+			return fmt.Errorf("failed to get linked items: %w", err)
+		}
+
+		for _, linkedItemID := range linkedItemIDs {
+			if err := addLinkedItemToPackTx(tx, packID, linkedItemID); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to add linked item %d to pack: %w", linkedItemID, err)
+			}
 		}
 	}
 
@@ -474,6 +614,67 @@ func AddItemToPack(db *sql.DB, packID string, itemID int, userID int) error {
 	return nil
 }
 
+// AddItemsToPack adds one of each item to the pack in a single transaction. Repeated IDs
+// count once. Items already in the pack have their count incremented and are returned in
+// alreadyPresent, the others in added. Nothing is added if any item isn't the owner's.
+func AddItemsToPack(db *sql.DB, packID string, itemIDs []int, userID int) (added, alreadyPresent []int, err error) {
+	pack, err := getEditablePack(db, packID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	seen := make(map[int]bool)
+	for _, itemID := range itemIDs {
+		if seen[itemID] {
+			continue
+		}
+		seen[itemID] = true
+
+		// Collaborators pack the owner's gear, not their own, and never items in the trash
+		var owned bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM items WHERE id = ? AND user_id = ? AND deleted_at IS NULL)", itemID, pack.UserID).Scan(&owned)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check item: %w", err)
+		}
+		if !owned {
+			return nil, nil, fmt.Errorf("item %d not found", itemID)
+		}
+
+		var inPack bool
+		err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM pack_items WHERE pack_id = ? AND item_id = ?)", packID, itemID).Scan(&inPack)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check existing item: %w", err)
+		}
+
+		if err := addSingleItemToPackTx(tx, packID, itemID); err != nil {
+			return nil, nil, err
+		}
+
+		if inPack {
+			alreadyPresent = append(alreadyPresent, itemID)
+		} else {
+			added = append(added, itemID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Update pack timestamp since items were modified
+	if err := updatePackTimestamp(db, packID); err != nil {
+		return nil, nil, fmt.Errorf("failed to update pack timestamp: %w", err)
+	}
+
+	return added, alreadyPresent, nil
+}
+
 // addSingleItemToPackTx adds a single item to a pack within a transaction
 func addSingleItemToPackTx(tx *sql.Tx, packID string, itemID int) error {
 	// Check if item already exists in pack
@@ -506,14 +707,33 @@ func addSingleItemToPackTx(tx *sql.Tx, packID string, itemID int) error {
 	return nil
 }
 
-func RemoveItemFromPack(db *sql.DB, packID string, itemID, userID int) error {
-	pack, err := GetPack(db, packID)
+// addLinkedItemToPackTx adds a linked item with a count of 1, leaving it untouched when
+// it is already in the pack so adding a parent twice doesn't duplicate its accessories
+func addLinkedItemToPackTx(tx *sql.Tx, packID string, itemID int) error {
+	var exists bool
+	err := tx.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM pack_items WHERE pack_id = ? AND item_id = ?)",
+		packID, itemID,
+	).Scan(&exists)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to check existing item: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = tx.Exec(`INSERT INTO pack_items (pack_id, item_id, count) VALUES (?, ?, 1)`, packID, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to add item to pack: %w", err)
 	}
 
-	if pack.UserID != userID {
-		return fmt.Errorf("unauthorized")
+	return nil
+}
+
+func RemoveItemFromPack(db *sql.DB, packID string, itemID, userID int) error {
+	_, err := getEditablePack(db, packID, userID)
+	if err != nil {
+		return err
 	}
 
 	// Check current count
@@ -535,8 +755,15 @@ func RemoveItemFromPack(db *sql.DB, packID string, itemID, userID int) error {
 			return fmt.Errorf("failed to remove item from pack: %w", err)
 		}
 	} else {
-		// Decrement the count
-		updateQuery := `UPDATE pack_items SET count = count - 1 WHERE id = ?`
+		// Decrement the count, clamping worn_count so it never exceeds it. The right-hand
+		// sides all see the row as it was before the update.
+		updateQuery := `
+			UPDATE pack_items
+			SET count = count - 1,
+				worn_count = MIN(COALESCE(worn_count, 0), count - 1),
+				is_worn = MIN(COALESCE(worn_count, 0), count - 1) > 0
+			WHERE id = ?
+		`
 		_, err = db.Exec(updateQuery, packItemID)
 		if err != nil {
 			return fmt.Errorf("failed to decrement item count: %w", err)
@@ -551,39 +778,71 @@ func RemoveItemFromPack(db *sql.DB, packID string, itemID, userID int) error {
 	return nil
 }
 
-func UpdatePackItemWornCount(db *sql.DB, packID string, itemID, userID int, wornCount int) error {
-	pack, err := GetPack(db, packID)
-	if err != nil {
-		return err
+// SetPackItemCount sets the quantity of an item in a pack directly. A count of 0 removes
+// the item from the pack, and worn_count is clamped to the new count.
+func SetPackItemCount(db *sql.DB, packID string, itemID, userID int, count int) error {
+	if count < 0 {
+		return fmt.Errorf("invalid count")
 	}
 
-	if pack.UserID != userID {
-		return fmt.Errorf("unauthorized")
+	_, err := getEditablePack(db, packID, userID)
+	if err != nil {
+		return err
 	}
 
-	// Get current count to validate worn_count
-	var currentCount int
 	var packItemID int
-	checkQuery := `SELECT id, count FROM pack_items WHERE pack_id = ? AND item_id = ?`
-	err = db.QueryRow(checkQuery, packID, itemID).Scan(&packItemID, &currentCount)
+	var wornCount int
+	checkQuery := `SELECT id, COALESCE(worn_count, 0) FROM pack_items WHERE pack_id = ? AND item_id = ?`
+	err = db.QueryRow(checkQuery, packID, itemID).Scan(&packItemID, &wornCount)
 	if err == sql.ErrNoRows {
 		return fmt.Errorf("item not found in pack")
 	} else if err != nil {
-		return fmt.Errorf("failed to check item: %w", err)
+		return fmt.Errorf("failed to check item count: %w", err)
+	}
+
+	if count == 0 {
+		deleteQuery := `DELETE FROM pack_items WHERE id = ?`
+		_, err = db.Exec(deleteQuery, packItemID)
+		if err != nil {
+			return fmt.Errorf("failed to remove item from pack: %w", err)
+		}
+	} else {
+		if wornCount > count {
+			wornCount = count
+		}
+		updateQuery := `UPDATE pack_items SET count = ?, worn_count = ?, is_worn = ? WHERE id = ?`
+		_, err = db.Exec(updateQuery, count, wornCount, wornCount > 0, packItemID)
+		if err != nil {
+			return fmt.Errorf("failed to update item count: %w", err)
+		}
+	}
+
+	// Update pack timestamp since items were modified
+	if err := updatePackTimestamp(db, packID); err != nil {
+		return fmt.Errorf("failed to update pack timestamp: %w", err)
+	}
+
+	return nil
+}
+
+// SetPackItemWornCount sets how many of an item in a pack are worn. An item packed once is
+// either worn (1) or not (0), one packed 5 times takes anything from 0 to 5.
+func SetPackItemWornCount(db *sql.DB, packID string, itemID, userID int, wornCount int) error {
+	_, err := getEditablePack(db, packID, userID)
+	if err != nil {
+		return err
 	}
 
-	// Validate worn count doesn't exceed total count
-	if wornCount < 0 {
-		wornCount = 0
+	packItemID, count, err := getPackItemCount(db, packID, itemID)
+	if err != nil {
+		return err
 	}
-	if wornCount > currentCount {
-		wornCount = currentCount
+	if wornCount < 0 || wornCount > count {
+		return fmt.Errorf("invalid worn count %d: item is packed %d times", wornCount, count)
 	}
 
-	// Update worn_count and is_worn flag
-	isWorn := wornCount > 0
 	updateQuery := `UPDATE pack_items SET worn_count = ?, is_worn = ? WHERE id = ?`
-	_, err = db.Exec(updateQuery, wornCount, isWorn, packItemID)
+	_, err = db.Exec(updateQuery, wornCount, wornCount > 0, packItemID)
 	if err != nil {
 		return fmt.Errorf("failed to update worn count: %w", err)
 	}
@@ -596,40 +855,131 @@ func UpdatePackItemWornCount(db *sql.DB, packID string, itemID, userID int, worn
 	return nil
 }
 
+// TogglePackItemWorn marks an item packed once as worn or not.
+//
+// Deprecated: use SetPackItemWornCount. A yes/no can't say how many of an item packed more
+// than once are worn, so marking one as worn fails instead of marking all of them.
 func TogglePackItemWorn(db *sql.DB, packID string, itemID, userID int, isWorn bool) error {
-	pack, err := GetPack(db, packID)
+	if !isWorn {
+		return SetPackItemWornCount(db, packID, itemID, userID, 0)
+	}
+
+	_, count, err := getPackItemCount(db, packID, itemID)
 	if err != nil {
 		return err
 	}
-
-	if pack.UserID != userID {
-		return fmt.Errorf("unauthorized")
+	if count > 1 {
+		return fmt.Errorf("invalid worn count: item is packed %d times, set a worn count instead", count)
 	}
 
-	// Get current count to determine worn_count
-	var currentCount int
-	var packItemID int
+	return SetPackItemWornCount(db, packID, itemID, userID, 1)
+}
+
+// getPackItemCount returns the pack_items row of an item in a pack and how many of it are packed
+func getPackItemCount(db *sql.DB, packID string, itemID int) (int, int, error) {
+	var packItemID, count int
 	checkQuery := `SELECT id, count FROM pack_items WHERE pack_id = ? AND item_id = ?`
-	err = db.QueryRow(checkQuery, packID, itemID).Scan(&packItemID, &currentCount)
+	err := db.QueryRow(checkQuery, packID, itemID).Scan(&packItemID, &count)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("item not found in pack")
+		return 0, 0, fmt.Errorf("item not found in pack")
 	} else if err != nil {
-		return fmt.Errorf("failed to check item: %w", err)
+		return 0, 0, fmt.Errorf("failed to check item: %w", err)
 	}
+	return packItemID, count, nil
+}
 
-	// For checkbox behavior (count = 1), set worn_count to 0 or 1
-	// For counter behavior (count > 1), this shouldn't be called, but handle gracefully
-	var wornCount int
-	if isWorn {
-		wornCount = currentCount // Set all items as worn
-	} else {
-		wornCount = 0 // Set no items as worn
+// UpdatePackItemNote sets the note explaining why an item is in this particular pack.
+// An empty note clears it.
+func UpdatePackItemNote(db *sql.DB, packID string, itemID, userID int, note string) error {
+	_, err := getEditablePack(db, packID, userID)
+	if err != nil {
+		return err
+	}
+
+	var noteValue sql.NullString
+	if note != "" {
+		noteValue = sql.NullString{String: note, Valid: true}
+	}
+
+	result, err := db.Exec(`UPDATE pack_items SET note = ? WHERE pack_id = ? AND item_id = ?`, noteValue, packID, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to update pack item note: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("item not found in pack")
+	}
+
+	// Update pack timestamp since items were modified
+	if err := updatePackTimestamp(db, packID); err != nil {
+		return fmt.Errorf("failed to update pack timestamp: %w", err)
+	}
+
+	return nil
+}
+
+// TogglePackItemConsumable marks a pack item as consumable (food, fuel, water) so it is
+// excluded from the pack's base weight.
+func TogglePackItemConsumable(db *sql.DB, packID string, itemID, userID int, isConsumable bool) error {
+	_, err := getEditablePack(db, packID, userID)
+	if err != nil {
+		return err
+	}
+
+	updateQuery := `UPDATE pack_items SET is_consumable = ? WHERE pack_id = ? AND item_id = ?`
+	result, err := db.Exec(updateQuery, isConsumable, packID, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to update consumable status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("item not found in pack")
+	}
+
+	// Update pack timestamp since items were modified
+	if err := updatePackTimestamp(db, packID); err != nil {
+		return fmt.Errorf("failed to update pack timestamp: %w", err)
+	}
+
+	return nil
+}
+
+// ReorderPackItems sets the custom order of pack items. Items keep being grouped by category,
+// the order applies within each category.
+func ReorderPackItems(db *sql.DB, packID string, packItemIDs []int, userID int) error {
+	_, err := getEditablePack(db, packID, userID)
+	if err != nil {
+		return err
 	}
 
-	updateQuery := `UPDATE pack_items SET is_worn = ?, worn_count = ? WHERE id = ?`
-	_, err = db.Exec(updateQuery, isWorn, wornCount, packItemID)
+	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to update worn status: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE pack_items SET sort_order = ? WHERE id = ? AND pack_id = ?`
+
+	for i, packItemID := range packItemIDs {
+		_, err := tx.Exec(query, i, packItemID, packID)
+		if err != nil {
+			return fmt.Errorf("failed to update sort order: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	// Update pack timestamp since items were modified
@@ -664,11 +1014,177 @@ func TogglePackLock(db *sql.DB, userID int, packID string, isLocked bool) error
 	return nil
 }
 
+// TogglePackFavorite pins a pack to the top of the pack list, or unpins it.
+// Favoriting is not an edit, so updated_at is left alone.
+func TogglePackFavorite(db *sql.DB, userID int, packID string, favorite bool) error {
+	query := `
+		UPDATE packs
+		SET is_favorite = ?
+		WHERE id = ? AND user_id = ?
+	`
+
+	result, err := db.Exec(query, favorite, packID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update pack favorite status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("pack not found or unauthorized")
+	}
+
+	return nil
+}
+
+// LikePack records that a user likes a pack. Liking a pack twice is a no-op.
+func LikePack(db *sql.DB, packID string, userID int) error {
+	_, err := db.Exec(`INSERT OR IGNORE INTO pack_likes (pack_id, user_id) VALUES (?, ?)`, packID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to like pack: %w", err)
+	}
+	return nil
+}
+
+// UnlikePack removes a user's like from a pack, if any.
+func UnlikePack(db *sql.DB, packID string, userID int) error {
+	_, err := db.Exec(`DELETE FROM pack_likes WHERE pack_id = ? AND user_id = ?`, packID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unlike pack: %w", err)
+	}
+	return nil
+}
+
+func GetPackLikeCount(db *sql.DB, packID string) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pack_likes WHERE pack_id = ?`, packID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pack likes: %w", err)
+	}
+	return count, nil
+}
+
+func HasUserLikedPack(db *sql.DB, packID string, userID int) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pack_likes WHERE pack_id = ? AND user_id = ?`, packID, userID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check pack like: %w", err)
+	}
+	return count > 0, nil
+}
+
+// SetPackTemplate marks a pack as a template, or turns a template back into a regular pack.
+func SetPackTemplate(db *sql.DB, userID int, packID string, isTemplate bool) error {
+	query := `
+		UPDATE packs
+		SET is_template = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?
+	`
+
+	result, err := db.Exec(query, isTemplate, packID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update pack template status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("pack not found or unauthorized")
+	}
+
+	return nil
+}
+
+// CreatePackFromTemplate copies a template's items and labels into a new regular pack.
+// An empty newName falls back to the template's name.
+func CreatePackFromTemplate(db *sql.DB, userID int, templateID, newName string) (*models.Pack, error) {
+	template, err := GetPack(db, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if template.UserID != userID {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	if !template.IsTemplate {
+		return nil, fmt.Errorf("template not found")
+	}
+
+	if newName == "" {
+		newName = template.Name
+	}
+
+	return duplicatePack(db, userID, templateID, newName)
+}
+
 func DuplicatePack(db *sql.DB, userID int, originalPackID string) (*models.Pack, error) {
+	return duplicatePack(db, userID, originalPackID, "")
+}
+
+// copySuffix matches the " Copy" or " Copy N" suffix given to duplicated packs
+var copySuffix = regexp.MustCompile(`^(.*) Copy(?: ([0-9]{1,9}))?$`)
+
+// maxCopyNameAttempts bounds the numbers tried when the next copy name is already taken
+const maxCopyNameAttempts = 100
+
+// nextCopyName names the copy of a pack: "Trip" becomes "Trip Copy" and "Trip Copy" becomes
+// "Trip Copy 2", then "Trip Copy 3", instead of stacking suffixes.
+func nextCopyName(name string) string {
+	m := copySuffix.FindStringSubmatch(name)
+	if m == nil {
+		return name + " Copy"
+	}
+
+	n := 1
+	if m[2] != "" {
+		n, _ = strconv.Atoi(m[2])
+	}
+	return fmt.Sprintf("%s Copy %d", m[1], n+1)
+}
+
+// availableCopyName returns the first copy name of name that none of the user's packs uses
+func availableCopyName(tx *sql.Tx, userID int, name string) (string, error) {
+	candidate := nextCopyName(name)
+	for i := 0; i < maxCopyNameAttempts; i++ {
+		var exists bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM packs WHERE user_id = ? AND name = ?)", userID, candidate).Scan(&exists)
+		if err != nil {
+			return "", fmt.Errorf("failed to check pack name: %w", err)
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = nextCopyName(candidate)
+	}
+	return candidate, nil
+}
+
+// duplicatePack copies a pack with its items, labels and label assignments into a new
+// pack named newName, or a numbered " Copy" name when newName is empty.
+func duplicatePack(db *sql.DB, userID int, originalPackID, newName string) (*models.Pack, error) {
 	logger.Debug("Starting pack duplication",
 		"user_id", userID,
 		"original_pack_id", originalPackID)
 	
+	// Get the original pack with all its items before the transaction takes a connection
+	originalPack, err := GetPackWithItems(db, originalPackID)
+	if err != nil {
+		logger.Error("Failed to get original pack",
+			"pack_id", originalPackID,
+			"error", err)
+		return nil, fmt.Errorf("failed to get original pack: %w", err)
+	}
+	logger.Debug("Retrieved original pack",
+		"pack_name", originalPack.Name,
+		"item_count", len(originalPack.Items))
+
 	// Start a database transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -686,19 +1202,6 @@ func DuplicatePack(db *sql.DB, userID int, originalPackID string) (*models.Pack,
 		}
 	}()
 
-	// Get the original pack with all its items
-	originalPack, err := GetPackWithItems(db, originalPackID)
-	if err != nil {
-		logger.Error("Failed to get original pack",
-			"pack_id", originalPackID,
-			"error", err)
-		tx.Rollback()
-		return nil, fmt.Errorf("failed to get original pack: %w", err)
-	}
-	logger.Debug("Retrieved original pack",
-		"pack_name", originalPack.Name,
-		"item_count", len(originalPack.Items))
-
 	// Check if user owns the pack
 	if originalPack.UserID != userID {
 		logger.Warn("Unauthorized pack duplication attempt",
@@ -708,8 +1211,15 @@ func DuplicatePack(db *sql.DB, userID int, originalPackID string) (*models.Pack,
 		return nil, fmt.Errorf("unauthorized")
 	}
 
-	// Create new pack with "Copy" appended to name
-	newPackName := originalPack.Name + " Copy"
+	// Create new pack with a "Copy" suffix unless a name was given
+	newPackName := newName
+	if newPackName == "" {
+		newPackName, err = availableCopyName(tx, userID, originalPack.Name)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
 	logger.Debug("Creating new pack", "pack_name", newPackName)
 	newPack, err := createPackWithTx(tx, userID, newPackName)
 	if err != nil {
@@ -787,10 +1297,10 @@ func DuplicatePack(db *sql.DB, userID int, originalPackID string) (*models.Pack,
 
 		// Insert the pack item with the same count and worn_count
 		insertQuery := `
-			INSERT INTO pack_items (pack_id, item_id, count, worn_count, is_worn)
-			VALUES (?, ?, ?, ?, ?)
+			INSERT INTO pack_items (pack_id, item_id, count, worn_count, is_worn, is_consumable, sort_order, note)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 		`
-		result, err := tx.Exec(insertQuery, newPack.ID, packItem.ItemID, packItem.Count, packItem.WornCount, packItem.IsWorn)
+		result, err := tx.Exec(insertQuery, newPack.ID, packItem.ItemID, packItem.Count, packItem.WornCount, packItem.IsWorn, packItem.IsConsumable, packItem.SortOrder, packItem.Note)
 		if err != nil {
 			logger.Error("Failed to copy pack item",
 				"item_id", packItem.ItemID,
@@ -891,4 +1401,15 @@ func DuplicatePack(db *sql.DB, userID int, originalPackID string) (*models.Pack,
 		"pack_name", newPack.Name)
 
 	return newPack, nil
-}
\ No newline at end of file
+}
+
+// GetPackWeightBreakdown loads a pack's items and splits its weight by category and label
+func GetPackWeightBreakdown(db *sql.DB, packID string) (*models.PackWeightBreakdown, error) {
+	pack, err := GetPackWithItems(db, packID)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := models.NewPackWeightBreakdown(pack.Items)
+	return &breakdown, nil
+}
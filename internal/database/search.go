@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Kinds of search hits returned by SearchAll
+const (
+	SearchTypeItem = "item"
+	SearchTypePack = "pack"
+	SearchTypeTrip = "trip"
+)
+
+// SearchResultLimit caps the number of hits returned for each kind
+const SearchResultLimit = 20
+
+// SearchHit is an item, pack or trip matching a search query
+type SearchHit struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Detail string `json:"detail"`
+	URL    string `json:"url"`
+}
+
+// SearchResults groups the hits of a search query by kind
+type SearchResults struct {
+	Query string      `json:"query"`
+	Items []SearchHit `json:"items"`
+	Packs []SearchHit `json:"packs"`
+	Trips []SearchHit `json:"trips"`
+}
+
+// Total returns the number of hits across all kinds
+func (r *SearchResults) Total() int {
+	return len(r.Items) + len(r.Packs) + len(r.Trips)
+}
+
+// Truncated reports whether a kind of hits was cut at SearchResultLimit
+func (r *SearchResults) Truncated() bool {
+	return len(r.Items) >= SearchResultLimit || len(r.Packs) >= SearchResultLimit || len(r.Trips) >= SearchResultLimit
+}
+
+// searchPattern turns a query into a case-insensitive LIKE pattern matching it anywhere
+func searchPattern(query string) string {
+	// Escape LIKE wildcards so they match literally
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(query))
+	return "%" + escaped + "%"
+}
+
+// SearchAll looks for the query in the user's items (name, note, brand), packs (name, note) and
+// trips (name, description, location). Items in the trash are left out. An empty query returns no hits.
+func SearchAll(db *sql.DB, userID int, query string) (*SearchResults, error) {
+	query = strings.TrimSpace(query)
+	results := &SearchResults{
+		Query: query,
+		Items: []SearchHit{},
+		Packs: []SearchHit{},
+		Trips: []SearchHit{},
+	}
+	if query == "" {
+		return results, nil
+	}
+	pattern := searchPattern(query)
+
+	var err error
+	results.Items, err = searchHits(db, SearchTypeItem, `
+		SELECT CAST(i.id AS TEXT), i.name,
+		       TRIM(COALESCE(i.brand, '') || ' ' || COALESCE(i.model, '')),
+		       '/inventory/items/' || i.id || '/edit'
+		FROM items i
+		WHERE i.user_id = ? AND i.deleted_at IS NULL
+		AND (LOWER(i.name) LIKE ? ESCAPE '\' OR LOWER(COALESCE(i.note, '')) LIKE ? ESCAPE '\'
+		     OR LOWER(COALESCE(i.brand, '')) LIKE ? ESCAPE '\')
+		ORDER BY i.name
+		LIMIT ?
+	`, userID, pattern, pattern, pattern, SearchResultLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	results.Packs, err = searchHits(db, SearchTypePack, `
+		SELECT p.id, p.name, COALESCE(p.note, ''), '/packs/' || p.id
+		FROM packs p
+		WHERE p.user_id = ?
+		AND (LOWER(p.name) LIKE ? ESCAPE '\' OR LOWER(COALESCE(p.note, '')) LIKE ? ESCAPE '\')
+		ORDER BY p.name
+		LIMIT ?
+	`, userID, pattern, pattern, SearchResultLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	results.Trips, err = searchHits(db, SearchTypeTrip, `
+		SELECT t.id, t.name, COALESCE(t.location, ''), '/trips/' || t.id
+		FROM trips t
+		WHERE t.user_id = ?
+		AND (LOWER(t.name) LIKE ? ESCAPE '\' OR LOWER(COALESCE(t.description, '')) LIKE ? ESCAPE '\'
+		     OR LOWER(COALESCE(t.location, '')) LIKE ? ESCAPE '\')
+		ORDER BY t.name
+		LIMIT ?
+	`, userID, pattern, pattern, pattern, SearchResultLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// searchHits runs a query selecting id, name, detail and URL, and returns its rows as hits of the given kind
+func searchHits(db *sql.DB, hitType, query string, args ...interface{}) ([]SearchHit, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %ss: %w", hitType, err)
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		hit := SearchHit{Type: hitType}
+		if err := rows.Scan(&hit.ID, &hit.Name, &hit.Detail, &hit.URL); err != nil {
+			return nil, fmt.Errorf("failed to scan %s search hit: %w", hitType, err)
+		}
+		hits = append(hits, hit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s search hits: %w", hitType, err)
+	}
+
+	return hits, nil
+}
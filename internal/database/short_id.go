@@ -0,0 +1,74 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrShortIDExhausted is returned when every generated short ID was already taken. It means
+// the ID space is getting crowded and ShortIDConfig should be widened.
+var ErrShortIDExhausted = errors.New("short ID retries exhausted")
+
+// ShortIDConfig sets how the short IDs of public packs and trips are generated
+type ShortIDConfig struct {
+	Charset    string
+	Length     int
+	MaxRetries int
+}
+
+// DefaultShortIDConfig gives 62^8 possible IDs, so collisions stay rare for a long time
+var DefaultShortIDConfig = ShortIDConfig{
+	Charset:    "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	Length:     8,
+	MaxRetries: 10,
+}
+
+// shortIDTable is a table whose short_id column holds generated short IDs. Only the
+// constants below are valid, as the name is written into the query.
+type shortIDTable string
+
+const (
+	shortIDTablePacks shortIDTable = "packs"
+	shortIDTableTrips shortIDTable = "trips"
+)
+
+func generateShortID(db *sql.DB) (string, error) {
+	return generateShortIDWithConfig(db, shortIDTablePacks, DefaultShortIDConfig)
+}
+
+// generateShortIDWithConfig returns a random ID that no row of table uses yet
+func generateShortIDWithConfig(db *sql.DB, table shortIDTable, cfg ShortIDConfig) (string, error) {
+	if len(cfg.Charset) < 2 || cfg.Length < 1 || cfg.MaxRetries < 1 {
+		return "", fmt.Errorf("invalid short ID config: %+v", cfg)
+	}
+
+	query := fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE short_id = ?)", table)
+	charsetSize := big.NewInt(int64(len(cfg.Charset)))
+
+	for attempt := 0; attempt < cfg.MaxRetries; attempt++ {
+		b := make([]byte, cfg.Length)
+		for i := range b {
+			num, err := rand.Int(rand.Reader, charsetSize)
+			if err != nil {
+				return "", fmt.Errorf("failed to generate random number: %w", err)
+			}
+			b[i] = cfg.Charset[num.Int64()]
+		}
+
+		shortID := string(b)
+
+		var exists bool
+		if err := db.QueryRow(query, shortID).Scan(&exists); err != nil {
+			return "", fmt.Errorf("failed to check short ID existence: %w", err)
+		}
+
+		if !exists {
+			return shortID, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to generate unique %s short ID after %d attempts: %w", table, cfg.MaxRetries, ErrShortIDExhausted)
+}
@@ -13,6 +13,7 @@ type UserStats struct {
 	TotalItems      int     `json:"total_items"`
 	TotalCategories int     `json:"total_categories"`
 	TotalWeight     int     `json:"total_weight"`
+	TotalValue      float64 `json:"total_value"`
 	ItemsToVerify   int     `json:"items_to_verify"`
 	LightestPack    *models.Pack `json:"lightest_pack,omitempty"`
 	LightestWeight  int     `json:"lightest_weight"`
@@ -25,6 +26,7 @@ type RecentPack struct {
 	Name        string    `json:"name"`
 	IsPublic    bool      `json:"is_public"`
 	IsLocked    bool      `json:"is_locked"`
+	IsFavorite  bool      `json:"is_favorite"`
 	ShortID     string    `json:"short_id"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	ItemCount   int       `json:"item_count"`
@@ -37,13 +39,13 @@ func GetUserStats(db *sql.DB, userID int) (*UserStats, error) {
 	stats := &UserStats{}
 	
 	// Get total packs
-	err := db.QueryRow("SELECT COUNT(*) FROM packs WHERE user_id = ?", userID).Scan(&stats.TotalPacks)
+	err := db.QueryRow("SELECT COUNT(*) FROM packs WHERE user_id = ? AND COALESCE(is_template, FALSE) = FALSE", userID).Scan(&stats.TotalPacks)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pack count: %w", err)
 	}
 	
 	// Get total items
-	err = db.QueryRow("SELECT COUNT(*) FROM items WHERE user_id = ?", userID).Scan(&stats.TotalItems)
+	err = db.QueryRow("SELECT COUNT(*) FROM items WHERE user_id = ? AND deleted_at IS NULL", userID).Scan(&stats.TotalItems)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get item count: %w", err)
 	}
@@ -55,13 +57,19 @@ func GetUserStats(db *sql.DB, userID int) (*UserStats, error) {
 	}
 	
 	// Get total weight of all items
-	err = db.QueryRow("SELECT COALESCE(SUM(weight_grams), 0) FROM items WHERE user_id = ?", userID).Scan(&stats.TotalWeight)
+	err = db.QueryRow("SELECT COALESCE(SUM(weight_grams), 0) FROM items WHERE user_id = ? AND deleted_at IS NULL", userID).Scan(&stats.TotalWeight)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total weight: %w", err)
 	}
+
+	// Get total value of all items, in the user's currency
+	err = db.QueryRow("SELECT COALESCE(SUM(price), 0) FROM items WHERE user_id = ? AND deleted_at IS NULL", userID).Scan(&stats.TotalValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total value: %w", err)
+	}
 	
 	// Get items needing weight verification
-	err = db.QueryRow("SELECT COUNT(*) FROM items WHERE user_id = ? AND weight_to_verify = true", userID).Scan(&stats.ItemsToVerify)
+	err = db.QueryRow("SELECT COUNT(*) FROM items WHERE user_id = ? AND weight_to_verify = true AND deleted_at IS NULL", userID).Scan(&stats.ItemsToVerify)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get items to verify count: %w", err)
 	}
@@ -74,10 +82,10 @@ func GetUserStats(db *sql.DB, userID int) (*UserStats, error) {
 			COALESCE(SUM(CASE WHEN pi.is_worn = 0 THEN i.weight_grams * pi.count ELSE 0 END), 0) as pack_weight
 		FROM packs p
 		LEFT JOIN pack_items pi ON p.id = pi.pack_id
-		LEFT JOIN items i ON pi.item_id = i.id
-		WHERE p.user_id = ?
+		LEFT JOIN items i ON pi.item_id = i.id AND i.deleted_at IS NULL
+		WHERE p.user_id = ? AND COALESCE(p.is_template, FALSE) = FALSE
 		GROUP BY p.id, p.name
-		HAVING COUNT(pi.item_id) > 0
+		HAVING COUNT(i.id) > 0
 		ORDER BY pack_weight ASC
 		LIMIT 1
 	`
@@ -101,10 +109,10 @@ func GetUserStats(db *sql.DB, userID int) (*UserStats, error) {
 			COALESCE(SUM(CASE WHEN pi.is_worn = 0 THEN i.weight_grams * pi.count ELSE 0 END), 0) as pack_weight
 		FROM packs p
 		LEFT JOIN pack_items pi ON p.id = pi.pack_id
-		LEFT JOIN items i ON pi.item_id = i.id
-		WHERE p.user_id = ?
+		LEFT JOIN items i ON pi.item_id = i.id AND i.deleted_at IS NULL
+		WHERE p.user_id = ? AND COALESCE(p.is_template, FALSE) = FALSE
 		GROUP BY p.id, p.name
-		HAVING COUNT(pi.item_id) > 0
+		HAVING COUNT(i.id) > 0
 		ORDER BY pack_weight DESC
 		LIMIT 1
 	`
@@ -130,17 +138,18 @@ func GetRecentPacks(db *sql.DB, userID int, limit int) ([]RecentPack, error) {
 			p.name,
 			p.is_public,
 			COALESCE(p.is_locked, FALSE),
+			COALESCE(p.is_favorite, FALSE),
 			COALESCE(p.short_id, ''),
 			p.updated_at,
-			COALESCE(SUM(pi.count), 0) as item_count,
+			COALESCE(SUM(CASE WHEN i.id IS NOT NULL THEN pi.count ELSE 0 END), 0) as item_count,
 			COALESCE(SUM(CASE WHEN pi.is_worn = 0 THEN i.weight_grams * pi.count ELSE 0 END), 0) as pack_weight,
 			COALESCE(SUM(CASE WHEN pi.is_worn = 1 THEN i.weight_grams * pi.count ELSE 0 END), 0) as worn_weight
 		FROM packs p
 		LEFT JOIN pack_items pi ON p.id = pi.pack_id
-		LEFT JOIN items i ON pi.item_id = i.id
-		WHERE p.user_id = ?
-		GROUP BY p.id, p.name, p.is_public, p.is_locked, p.short_id, p.updated_at
-		ORDER BY p.updated_at DESC
+		LEFT JOIN items i ON pi.item_id = i.id AND i.deleted_at IS NULL
+		WHERE p.user_id = ? AND COALESCE(p.is_template, FALSE) = FALSE
+		GROUP BY p.id, p.name, p.is_public, p.is_locked, p.is_favorite, p.short_id, p.updated_at
+		ORDER BY COALESCE(p.is_favorite, FALSE) DESC, p.updated_at DESC
 		LIMIT ?
 	`
 
@@ -158,6 +167,7 @@ func GetRecentPacks(db *sql.DB, userID int, limit int) ([]RecentPack, error) {
 			&pack.Name,
 			&pack.IsPublic,
 			&pack.IsLocked,
+			&pack.IsFavorite,
 			&pack.ShortID,
 			&pack.UpdatedAt,
 			&pack.ItemCount,
@@ -176,4 +186,113 @@ func GetRecentPacks(db *sql.DB, userID int, limit int) ([]RecentPack, error) {
 	}
 	
 	return recentPacks, nil
-}
\ No newline at end of file
+}
+
+type CategoryStats struct {
+	CategoryID  int     `json:"category_id"`
+	Name        string  `json:"name"`
+	ItemCount   int     `json:"item_count"`
+	TotalWeight int     `json:"total_weight"`
+	TotalPrice  float64 `json:"total_price"`
+}
+
+type InventoryStats struct {
+	Categories  []CategoryStats `json:"categories"`
+	TotalItems  int             `json:"total_items"`
+	TotalWeight int             `json:"total_weight"`
+	TotalPrice  float64         `json:"total_price"`
+}
+
+// GetInventoryStats sums item count, weight and price per category across the user's
+// whole inventory. Categories without items are left out.
+func GetInventoryStats(db *sql.DB, userID int) (*InventoryStats, error) {
+	query := `
+		SELECT
+			c.id,
+			c.name,
+			COUNT(i.id),
+			COALESCE(SUM(i.weight_grams), 0),
+			COALESCE(SUM(i.price), 0)
+		FROM categories c
+		JOIN items i ON i.category_id = c.id AND i.deleted_at IS NULL
+		WHERE c.user_id = ?
+		GROUP BY c.id, c.name
+		ORDER BY c.sort_order, c.name
+	`
+
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inventory stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &InventoryStats{Categories: []CategoryStats{}}
+	for rows.Next() {
+		var category CategoryStats
+		err := rows.Scan(
+			&category.CategoryID,
+			&category.Name,
+			&category.ItemCount,
+			&category.TotalWeight,
+			&category.TotalPrice,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category stats: %w", err)
+		}
+		stats.Categories = append(stats.Categories, category)
+		stats.TotalItems += category.ItemCount
+		stats.TotalWeight += category.TotalWeight
+		stats.TotalPrice += category.TotalPrice
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating inventory stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// InventorySummary holds the totals of a user's inventory, with prices in their currency
+type InventorySummary struct {
+	ItemCount           int     `json:"item_count"`
+	TotalWeight         int     `json:"total_weight"`
+	TotalPrice          float64 `json:"total_price"`
+	TotalPriceFormatted string  `json:"total_price_formatted"`
+	ItemsToVerify       int     `json:"items_to_verify"`
+	Currency            string  `json:"currency"`
+}
+
+// GetInventorySummary totals the user's items with a single aggregate query, which is much
+// cheaper than loading them with GetItems. Prices carry no currency of their own, they are
+// all in the one set on the account.
+func GetInventorySummary(db *sql.DB, userID int) (*InventorySummary, error) {
+	query := `
+		SELECT
+			COALESCE(u.currency, '$'),
+			COUNT(i.id),
+			COALESCE(SUM(i.weight_grams), 0),
+			COALESCE(SUM(i.price), 0),
+			COALESCE(SUM(CASE WHEN i.weight_to_verify THEN 1 ELSE 0 END), 0)
+		FROM users u
+		LEFT JOIN items i ON i.user_id = u.id AND i.deleted_at IS NULL
+		WHERE u.id = ?
+		GROUP BY u.id
+	`
+
+	summary := &InventorySummary{}
+	err := db.QueryRow(query, userID).Scan(
+		&summary.Currency,
+		&summary.ItemCount,
+		&summary.TotalWeight,
+		&summary.TotalPrice,
+		&summary.ItemsToVerify,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get inventory summary: %w", err)
+	}
+	summary.TotalPriceFormatted = models.FormatCurrency(summary.TotalPrice, summary.Currency)
+
+	return summary, nil
+}
@@ -0,0 +1,311 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"carryless/internal/models"
+	"carryless/internal/totp"
+)
+
+// loginChallengeLifetime bounds how long the second login step stays open after the password check
+const loginChallengeLifetime = 5 * time.Minute
+
+// maxLoginChallengeAttempts is how many wrong codes a login challenge accepts before the
+// password has to be entered again
+const maxLoginChallengeAttempts = 5
+
+// BeginTOTPEnrollment stores a new, not yet enabled, encrypted secret for the user, replacing any
+// unfinished enrollment. It fails if two-factor authentication is already enabled.
+func BeginTOTPEnrollment(db *sql.DB, userID int, encryptedSecret string) error {
+	result, err := db.Exec(`
+		INSERT INTO user_totp (user_id, secret, enabled, confirmed_at, last_used_step, created_at)
+		VALUES (?, ?, FALSE, NULL, 0, CURRENT_TIMESTAMP)
+		ON CONFLICT(user_id) DO UPDATE SET
+			secret = excluded.secret,
+			confirmed_at = NULL,
+			last_used_step = 0,
+			created_at = CURRENT_TIMESTAMP
+		WHERE user_totp.enabled = FALSE
+	`, userID, encryptedSecret)
+	if err != nil {
+		return fmt.Errorf("failed to store two-factor secret: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("two-factor authentication already enabled")
+	}
+
+	return nil
+}
+
+// GetUserTOTP returns the user's enrollment, enabled or not
+func GetUserTOTP(db *sql.DB, userID int) (*models.UserTOTP, error) {
+	var t models.UserTOTP
+	var confirmedAt sql.NullTime
+	err := db.QueryRow(`
+		SELECT user_id, secret, enabled, confirmed_at, last_used_step, created_at
+		FROM user_totp WHERE user_id = ?
+	`, userID).Scan(&t.UserID, &t.Secret, &t.Enabled, &confirmedAt, &t.LastUsedStep, &t.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("two-factor authentication not found")
+		}
+		return nil, fmt.Errorf("failed to get two-factor authentication: %w", err)
+	}
+
+	if confirmedAt.Valid {
+		t.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &t, nil
+}
+
+// IsTOTPEnabled reports whether logging in as the user requires a second factor
+func IsTOTPEnabled(db *sql.DB, userID int) (bool, error) {
+	var enabled bool
+	err := db.QueryRow("SELECT enabled FROM user_totp WHERE user_id = ?", userID).Scan(&enabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check two-factor authentication: %w", err)
+	}
+	return enabled, nil
+}
+
+// EnableTOTP turns on a pending enrollment once the user entered a valid code for time step
+// step, and replaces the user's recovery codes.
+func EnableTOTP(db *sql.DB, userID int, step int64, recoveryCodes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE user_totp SET enabled = TRUE, confirmed_at = CURRENT_TIMESTAMP, last_used_step = ?
+		WHERE user_id = ? AND enabled = FALSE
+	`, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("two-factor enrollment not found")
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableTOTP removes the user's enrollment, recovery codes and pending login challenges
+func DisableTOTP(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		"DELETE FROM user_totp WHERE user_id = ?",
+		"DELETE FROM user_totp_recovery_codes WHERE user_id = ?",
+		"DELETE FROM login_challenges WHERE user_id = ?",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records that the code for time step step was used, so the same code can't be
+// replayed while it is still valid. Steps at or before the last used one are rejected.
+func UseTOTPStep(db *sql.DB, userID int, step int64) error {
+	result, err := db.Exec(`
+		UPDATE user_totp SET last_used_step = ?
+		WHERE user_id = ? AND enabled = TRUE AND last_used_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record two-factor code use: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("code already used")
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user with two-factor authentication enabled
+func RegenerateRecoveryCodes(db *sql.DB, userID int, recoveryCodes []string) error {
+	enabled, err := IsTOTPEnabled(db, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return fmt.Errorf("two-factor authentication not found")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes one of the user's unused recovery codes
+func UseRecoveryCode(db *sql.DB, userID int, code string) error {
+	result, err := db.Exec(`
+		UPDATE user_totp_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, userID, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("invalid recovery code")
+	}
+
+	return nil
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes the user has left
+func CountUnusedRecoveryCodes(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM user_totp_recovery_codes WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, recoveryCodes []string) error {
+	if _, err := tx.Exec("DELETE FROM user_totp_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete old recovery codes: %w", err)
+	}
+
+	for _, code := range recoveryCodes {
+		_, err := tx.Exec(`
+			INSERT INTO user_totp_recovery_codes (user_id, code_hash) VALUES (?, ?)
+		`, userID, hashRecoveryCode(code))
+		if err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// hashRecoveryCode hashes a recovery code so codes are never stored in clear. Codes are
+// random, so a fast unsalted hash is enough.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(totp.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateLoginChallenge opens the second login step for a user whose password was verified
+func CreateLoginChallenge(db *sql.DB, userID int, rememberMe bool) (*models.LoginChallenge, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate login challenge token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(loginChallengeLifetime)
+	_, err = db.Exec(`
+		INSERT INTO login_challenges (token, user_id, remember_me, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, token, userID, rememberMe, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create login challenge: %w", err)
+	}
+
+	return &models.LoginChallenge{
+		Token:      token,
+		UserID:     userID,
+		RememberMe: rememberMe,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// GetLoginChallenge returns a login challenge that hasn't expired nor run out of attempts
+func GetLoginChallenge(db *sql.DB, token string) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	err := db.QueryRow(`
+		SELECT token, user_id, remember_me, attempts, expires_at, created_at
+		FROM login_challenges
+		WHERE token = ? AND expires_at > ? AND attempts < ?
+	`, token, time.Now(), maxLoginChallengeAttempts).Scan(
+		&challenge.Token, &challenge.UserID, &challenge.RememberMe, &challenge.Attempts,
+		&challenge.ExpiresAt, &challenge.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("login challenge not found or expired")
+		}
+		return nil, fmt.Errorf("failed to get login challenge: %w", err)
+	}
+
+	return &challenge, nil
+}
+
+// RecordLoginChallengeFailure counts a wrong code against the challenge
+func RecordLoginChallengeFailure(db *sql.DB, token string) error {
+	_, err := db.Exec("UPDATE login_challenges SET attempts = attempts + 1 WHERE token = ?", token)
+	if err != nil {
+		return fmt.Errorf("failed to record login challenge failure: %w", err)
+	}
+	return nil
+}
+
+// DeleteLoginChallenge closes a login challenge once the login completed
+func DeleteLoginChallenge(db *sql.DB, token string) error {
+	_, err := db.Exec("DELETE FROM login_challenges WHERE token = ?", token)
+	if err != nil {
+		return fmt.Errorf("failed to delete login challenge: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpiredLoginChallenges removes login challenges that can no longer be completed and
+// returns how many were removed
+func CleanupExpiredLoginChallenges(db *sql.DB) (int64, error) {
+	result, err := db.Exec("DELETE FROM login_challenges WHERE expires_at < ?", time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired login challenges: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -3,17 +3,41 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"carryless/internal/gpx"
 	"carryless/internal/logger"
 	"carryless/internal/models"
 
 	"github.com/google/uuid"
 )
 
-// Helper function to generate short ID for trips (reusing logic from packs)
+// generateTripShortID returns a short ID no other trip uses
 func generateTripShortID(db *sql.DB) (string, error) {
-	return generateShortID(db) // Reuse existing function
+	return generateShortIDWithConfig(db, shortIDTableTrips, DefaultShortIDConfig)
+}
+
+// Trip dates outside this range are almost certainly typos
+const (
+	minTripYear = 1900
+	maxTripYear = 2100
+)
+
+// ValidateTripDates checks that trip dates are within a sane range and that the
+// end date is not before the start date
+func ValidateTripDates(startDate, endDate *time.Time) error {
+	for _, date := range []*time.Time{startDate, endDate} {
+		if date != nil && (date.Year() < minTripYear || date.Year() > maxTripYear) {
+			return fmt.Errorf("dates must be between %d and %d", minTripYear, maxTripYear)
+		}
+	}
+
+	if startDate != nil && endDate != nil && endDate.Before(*startDate) {
+		return fmt.Errorf("end date cannot be before start date")
+	}
+
+	return nil
 }
 
 // Helper function to update trip timestamp
@@ -23,8 +47,35 @@ func updateTripTimestamp(db *sql.DB, tripID string) error {
 	return err
 }
 
+// gpxStatsColumns holds the nullable GPX stats columns scanned from the trips table
+type gpxStatsColumns struct {
+	distance, ascent, descent      sql.NullFloat64
+	minLat, minLon, maxLat, maxLon sql.NullFloat64
+}
+
+// apply copies the valid GPX stats columns onto the trip
+func (g gpxStatsColumns) apply(trip *models.Trip) {
+	set := func(dst **float64, src sql.NullFloat64) {
+		if src.Valid {
+			value := src.Float64
+			*dst = &value
+		}
+	}
+	set(&trip.GPXDistance, g.distance)
+	set(&trip.GPXAscent, g.ascent)
+	set(&trip.GPXDescent, g.descent)
+	set(&trip.GPXMinLat, g.minLat)
+	set(&trip.GPXMinLon, g.minLon)
+	set(&trip.GPXMaxLat, g.maxLat)
+	set(&trip.GPXMaxLon, g.maxLon)
+}
+
 // CreateTrip creates a new trip
 func CreateTrip(db *sql.DB, userID int, name string, description, location *string, startDate, endDate *time.Time, isPublic bool) (*models.Trip, error) {
+	if err := ValidateTripDates(startDate, endDate); err != nil {
+		return nil, err
+	}
+
 	tripID := uuid.New().String()
 
 	var shortID sql.NullString
@@ -64,8 +115,28 @@ func CreateTrip(db *sql.DB, userID int, name string, description, location *stri
 	return trip, nil
 }
 
-// GetTrips returns all trips for a user
+// GetTrips returns all trips for a user, archived ones last
 func GetTrips(db *sql.DB, userID int) ([]models.Trip, error) {
+	return getTrips(db, userID, "")
+}
+
+// GetTripsByArchived returns the user's active trips, or only the archived ones
+func GetTripsByArchived(db *sql.DB, userID int, archived bool) ([]models.Trip, error) {
+	return getTrips(db, userID, "AND is_archived = ?", archived)
+}
+
+// CountArchivedTrips returns how many of the user's trips are archived
+func CountArchivedTrips(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM trips WHERE user_id = ? AND is_archived = TRUE`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count archived trips: %w", err)
+	}
+	return count, nil
+}
+
+// getTrips lists the user's trips, restricted by an optional extra condition with its arguments
+func getTrips(db *sql.DB, userID int, condition string, args ...interface{}) ([]models.Trip, error) {
 	query := `
 		SELECT
 			id, user_id, name,
@@ -75,13 +146,15 @@ func GetTrips(db *sql.DB, userID int) ([]models.Trip, error) {
 			COALESCE(notes, ''),
 			is_public, is_archived,
 			COALESCE(short_id, ''),
-			created_at, updated_at
+			created_at, updated_at,
+			(SELECT COUNT(*) FROM trip_checklist_items WHERE trip_id = trips.id AND is_checked),
+			(SELECT COUNT(*) FROM trip_checklist_items WHERE trip_id = trips.id)
 		FROM trips
-		WHERE user_id = ?
+		WHERE user_id = ? ` + condition + `
 		ORDER BY is_archived ASC, created_at DESC
 	`
 
-	rows, err := db.Query(query, userID)
+	rows, err := db.Query(query, append([]interface{}{userID}, args...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query trips: %w", err)
 	}
@@ -101,6 +174,7 @@ func GetTrips(db *sql.DB, userID int) ([]models.Trip, error) {
 			&trip.IsPublic, &trip.IsArchived,
 			&shortID,
 			&trip.CreatedAt, &trip.UpdatedAt,
+			&trip.Progress.Checked, &trip.Progress.Total,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan trip: %w", err)
@@ -141,7 +215,8 @@ func GetTrip(db *sql.DB, tripID string) (*models.Trip, error) {
 			COALESCE(location, ''),
 			start_date, end_date,
 			COALESCE(notes, ''),
-			COALESCE(gpx_data, ''),
+			gpx_distance_m, gpx_ascent_m, gpx_descent_m,
+			gpx_min_lat, gpx_min_lon, gpx_max_lat, gpx_max_lon,
 			is_public, is_archived,
 			COALESCE(short_id, ''),
 			created_at, updated_at
@@ -150,14 +225,17 @@ func GetTrip(db *sql.DB, tripID string) (*models.Trip, error) {
 	`
 
 	var trip models.Trip
-	var description, location, notes, gpxData, shortID string
+	var description, location, notes, shortID string
 	var startDate, endDate sql.NullTime
+	var gpxStats gpxStatsColumns
 
 	err := db.QueryRow(query, tripID).Scan(
 		&trip.ID, &trip.UserID, &trip.Name,
 		&description, &location,
 		&startDate, &endDate,
-		&notes, &gpxData,
+		&notes,
+		&gpxStats.distance, &gpxStats.ascent, &gpxStats.descent,
+		&gpxStats.minLat, &gpxStats.minLon, &gpxStats.maxLat, &gpxStats.maxLon,
 		&trip.IsPublic, &trip.IsArchived,
 		&shortID,
 		&trip.CreatedAt, &trip.UpdatedAt,
@@ -185,12 +263,10 @@ func GetTrip(db *sql.DB, tripID string) (*models.Trip, error) {
 	if notes != "" {
 		trip.Notes = &notes
 	}
-	if gpxData != "" {
-		trip.GPXData = &gpxData
-	}
 	if shortID != "" {
 		trip.ShortID = shortID
 	}
+	gpxStats.apply(&trip)
 
 	return &trip, nil
 }
@@ -204,7 +280,8 @@ func GetTripByShortID(db *sql.DB, shortID string) (*models.Trip, error) {
 			COALESCE(location, ''),
 			start_date, end_date,
 			COALESCE(notes, ''),
-			COALESCE(gpx_data, ''),
+			gpx_distance_m, gpx_ascent_m, gpx_descent_m,
+			gpx_min_lat, gpx_min_lon, gpx_max_lat, gpx_max_lon,
 			is_public, is_archived,
 			COALESCE(short_id, ''),
 			created_at, updated_at
@@ -213,14 +290,17 @@ func GetTripByShortID(db *sql.DB, shortID string) (*models.Trip, error) {
 	`
 
 	var trip models.Trip
-	var description, location, notes, gpxData, shortIDVal string
+	var description, location, notes, shortIDVal string
 	var startDate, endDate sql.NullTime
+	var gpxStats gpxStatsColumns
 
 	err := db.QueryRow(query, shortID).Scan(
 		&trip.ID, &trip.UserID, &trip.Name,
 		&description, &location,
 		&startDate, &endDate,
-		&notes, &gpxData,
+		&notes,
+		&gpxStats.distance, &gpxStats.ascent, &gpxStats.descent,
+		&gpxStats.minLat, &gpxStats.minLon, &gpxStats.maxLat, &gpxStats.maxLon,
 		&trip.IsPublic, &trip.IsArchived,
 		&shortIDVal,
 		&trip.CreatedAt, &trip.UpdatedAt,
@@ -248,17 +328,15 @@ func GetTripByShortID(db *sql.DB, shortID string) (*models.Trip, error) {
 	if notes != "" {
 		trip.Notes = &notes
 	}
-	if gpxData != "" {
-		trip.GPXData = &gpxData
-	}
 	if shortIDVal != "" {
 		trip.ShortID = shortIDVal
 	}
+	gpxStats.apply(&trip)
 
 	return &trip, nil
 }
 
-// GetTripWithDetails returns a trip with all related data (packs, checklist, transport steps)
+// GetTripWithDetails returns a trip with all related data (packs, checklist, transport steps, GPX tracks)
 func GetTripWithDetails(db *sql.DB, tripID string) (*models.Trip, error) {
 	trip, err := GetTrip(db, tripID)
 	if err != nil {
@@ -281,6 +359,14 @@ func GetTripWithDetails(db *sql.DB, tripID string) (*models.Trip, error) {
 		trip.ChecklistItems = checklistItems
 	}
 
+	// Load checklist progress
+	progress, err := GetTripChecklistProgress(db, tripID)
+	if err != nil {
+		logger.Error("Failed to load checklist progress", "trip_id", tripID, "error", err)
+	} else {
+		trip.Progress = *progress
+	}
+
 	// Load transport steps
 	transportSteps, err := GetTransportSteps(db, tripID)
 	if err != nil {
@@ -289,11 +375,23 @@ func GetTripWithDetails(db *sql.DB, tripID string) (*models.Trip, error) {
 		trip.TransportSteps = transportSteps
 	}
 
+	// Load GPX tracks
+	gpxFiles, err := GetTripGPXFiles(db, tripID)
+	if err != nil {
+		logger.Error("Failed to load GPX files", "trip_id", tripID, "error", err)
+	} else {
+		trip.GPXFiles = gpxFiles
+	}
+
 	return trip, nil
 }
 
 // UpdateTrip updates a trip's fields
 func UpdateTrip(db *sql.DB, userID int, tripID string, name string, description, location *string, startDate, endDate *time.Time, isPublic bool) error {
+	if err := ValidateTripDates(startDate, endDate); err != nil {
+		return err
+	}
+
 	// First check ownership
 	var ownerID int
 	err := db.QueryRow("SELECT user_id FROM trips WHERE id = ?", tripID).Scan(&ownerID)
@@ -348,6 +446,44 @@ func UpdateTrip(db *sql.DB, userID int, tripID string, name string, description,
 	return nil
 }
 
+// PublishTrip makes a trip public, generating its short ID when it doesn't have one yet.
+// Returns the trip's short ID.
+func PublishTrip(db *sql.DB, userID int, tripID string) (string, error) {
+	var ownerID int
+	var shortID sql.NullString
+	err := db.QueryRow("SELECT user_id, short_id FROM trips WHERE id = ?", tripID).Scan(&ownerID, &shortID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("trip not found")
+		}
+		return "", fmt.Errorf("failed to check trip ownership: %w", err)
+	}
+
+	if ownerID != userID {
+		return "", fmt.Errorf("unauthorized")
+	}
+
+	if !shortID.Valid || shortID.String == "" {
+		shortIDValue, err := generateTripShortID(db)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate short ID: %w", err)
+		}
+		shortID = sql.NullString{String: shortIDValue, Valid: true}
+	}
+
+	query := `
+		UPDATE trips
+		SET is_public = TRUE, short_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?
+	`
+
+	if _, err := db.Exec(query, shortID, tripID, userID); err != nil {
+		return "", fmt.Errorf("failed to publish trip: %w", err)
+	}
+
+	return shortID.String, nil
+}
+
 // UpdateTripNotes updates the notes field of a trip
 func UpdateTripNotes(db *sql.DB, userID int, tripID string, notes *string) error {
 	query := `
@@ -419,6 +555,132 @@ func ArchiveTrip(db *sql.DB, userID int, tripID string, archive bool) error {
 	return nil
 }
 
+// DuplicateTrip copies a trip with its checklist, transport steps, packs and GPX tracks.
+// The copy is private and has no short ID until it is made public.
+func DuplicateTrip(db *sql.DB, userID int, originalTripID string) (*models.Trip, error) {
+	logger.Debug("Starting trip duplication",
+		"user_id", userID,
+		"original_trip_id", originalTripID)
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error("Failed to begin transaction", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Check the trip exists and belongs to the user
+	var ownerID int
+	var originalName string
+	err = tx.QueryRow("SELECT user_id, name FROM trips WHERE id = ?", originalTripID).Scan(&ownerID, &originalName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("trip not found")
+		}
+		logger.Error("Failed to get original trip",
+			"trip_id", originalTripID,
+			"error", err)
+		return nil, fmt.Errorf("failed to get original trip: %w", err)
+	}
+
+	if ownerID != userID {
+		logger.Warn("Unauthorized trip duplication attempt",
+			"user_id", userID,
+			"trip_owner_id", ownerID)
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	// Copy the trip row with "Copy" appended to the name
+	newTripID := uuid.New().String()
+	newTripName := originalName + " Copy"
+	copyTripQuery := `
+		INSERT INTO trips (id, user_id, name, description, location, start_date, end_date, notes,
+		                   gpx_distance_m, gpx_ascent_m, gpx_descent_m,
+		                   gpx_min_lat, gpx_min_lon, gpx_max_lat, gpx_max_lon,
+		                   is_public, is_archived, short_id)
+		SELECT ?, user_id, ?, description, location, start_date, end_date, notes,
+		       gpx_distance_m, gpx_ascent_m, gpx_descent_m,
+		       gpx_min_lat, gpx_min_lon, gpx_max_lat, gpx_max_lon,
+		       FALSE, FALSE, NULL
+		FROM trips WHERE id = ?
+	`
+	if _, err := tx.Exec(copyTripQuery, newTripID, newTripName, originalTripID); err != nil {
+		logger.Error("Failed to create duplicate trip", "error", err)
+		return nil, fmt.Errorf("failed to create duplicate trip: %w", err)
+	}
+	logger.Info("Created new trip", "trip_id", newTripID)
+
+	// Copy checklist items, unchecked so the list can be reused
+	copyChecklistQuery := `
+		INSERT INTO trip_checklist_items (trip_id, content, is_checked, sort_order)
+		SELECT ?, content, FALSE, sort_order
+		FROM trip_checklist_items WHERE trip_id = ?
+		ORDER BY sort_order, id
+	`
+	result, err := tx.Exec(copyChecklistQuery, newTripID, originalTripID)
+	if err != nil {
+		logger.Error("Failed to copy checklist items", "error", err)
+		return nil, fmt.Errorf("failed to copy checklist items: %w", err)
+	}
+	checklistCount, _ := result.RowsAffected()
+	logger.Debug("Copied checklist items", "count", checklistCount)
+
+	// Copy transport steps
+	copyTransportQuery := `
+		INSERT INTO trip_transport_steps (trip_id, journey_type, step_order, departure_place, departure_datetime,
+		                                  arrival_place, arrival_datetime, transport_type, transport_number, notes, cost, currency)
+		SELECT ?, journey_type, step_order, departure_place, departure_datetime,
+		       arrival_place, arrival_datetime, transport_type, transport_number, notes, cost, currency
+		FROM trip_transport_steps WHERE trip_id = ?
+		ORDER BY journey_type, step_order
+	`
+	result, err = tx.Exec(copyTransportQuery, newTripID, originalTripID)
+	if err != nil {
+		logger.Error("Failed to copy transport steps", "error", err)
+		return nil, fmt.Errorf("failed to copy transport steps: %w", err)
+	}
+	transportCount, _ := result.RowsAffected()
+	logger.Debug("Copied transport steps", "count", transportCount)
+
+	// Copy pack associations
+	copyPacksQuery := `
+		INSERT INTO trip_packs (trip_id, pack_id)
+		SELECT ?, pack_id FROM trip_packs WHERE trip_id = ?
+	`
+	result, err = tx.Exec(copyPacksQuery, newTripID, originalTripID)
+	if err != nil {
+		logger.Error("Failed to copy trip packs", "error", err)
+		return nil, fmt.Errorf("failed to copy trip packs: %w", err)
+	}
+	packCount, _ := result.RowsAffected()
+	logger.Debug("Copied trip packs", "count", packCount)
+
+	// Copy GPX tracks
+	copyGPXQuery := `
+		INSERT INTO trip_gpx_files (trip_id, name, gpx_data, created_at)
+		SELECT ?, name, gpx_data, created_at
+		FROM trip_gpx_files WHERE trip_id = ?
+		ORDER BY created_at, id
+	`
+	result, err = tx.Exec(copyGPXQuery, newTripID, originalTripID)
+	if err != nil {
+		logger.Error("Failed to copy GPX files", "error", err)
+		return nil, fmt.Errorf("failed to copy GPX files: %w", err)
+	}
+	gpxCount, _ := result.RowsAffected()
+	logger.Debug("Copied GPX files", "count", gpxCount)
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit transaction", "error", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	logger.Info("Trip duplication completed successfully",
+		"new_trip_id", newTripID,
+		"trip_name", newTripName)
+
+	return GetTrip(db, newTripID)
+}
+
 // Pack Association Functions
 
 // AddPackToTrip associates a pack with a trip
@@ -573,6 +835,20 @@ func GetChecklistItems(db *sql.DB, tripID string) ([]models.TripChecklistItem, e
 	return items, nil
 }
 
+// GetTripChecklistProgress counts the checked and total items of a trip's checklist
+func GetTripChecklistProgress(db *sql.DB, tripID string) (*models.ChecklistProgress, error) {
+	progress := &models.ChecklistProgress{}
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN is_checked THEN 1 ELSE 0 END), 0), COUNT(*)
+		FROM trip_checklist_items
+		WHERE trip_id = ?
+	`, tripID).Scan(&progress.Checked, &progress.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checklist progress: %w", err)
+	}
+	return progress, nil
+}
+
 // AddChecklistItem adds a new checklist item to a trip
 func AddChecklistItem(db *sql.DB, tripID string, content string, userID int) (*models.TripChecklistItem, error) {
 	// Verify trip ownership
@@ -624,6 +900,89 @@ func AddChecklistItem(db *sql.DB, tripID string, content string, userID int) (*m
 	return item, nil
 }
 
+// GenerateChecklistFromPacks adds one checklist item per distinct item name found in the
+// trip's packs. Names already on the checklist, checked or not, are skipped, so running it
+// again only picks up items added to the packs since. Returns the number of items added.
+func GenerateChecklistFromPacks(db *sql.DB, tripID string, userID int) (int, error) {
+	// Verify trip ownership
+	var tripOwnerID int
+	err := db.QueryRow("SELECT user_id FROM trips WHERE id = ?", tripID).Scan(&tripOwnerID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check trip ownership: %w", err)
+	}
+
+	if tripOwnerID != userID {
+		return 0, fmt.Errorf("unauthorized")
+	}
+
+	existingItems, err := GetChecklistItems(db, tripID)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]bool)
+	maxSortOrder := -1
+	for _, item := range existingItems {
+		seen[strings.ToLower(strings.TrimSpace(item.Content))] = true
+		if item.SortOrder > maxSortOrder {
+			maxSortOrder = item.SortOrder
+		}
+	}
+
+	packs, err := GetTripPacks(db, tripID)
+	if err != nil {
+		return 0, err
+	}
+
+	var names []string
+	for _, pack := range packs {
+		packWithItems, err := GetPackWithItems(db, pack.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get pack %s: %w", pack.ID, err)
+		}
+
+		for _, packItem := range packWithItems.Items {
+			name := strings.TrimSpace(packItem.Item.Name)
+			key := strings.ToLower(name)
+			if name == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO trip_checklist_items (trip_id, content, is_checked, sort_order)
+		VALUES (?, ?, FALSE, ?)
+	`
+
+	for i, name := range names {
+		if _, err := tx.Exec(query, tripID, name, maxSortOrder+1+i); err != nil {
+			return 0, fmt.Errorf("failed to add checklist item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Update trip timestamp
+	updateTripTimestamp(db, tripID)
+
+	return len(names), nil
+}
+
 // UpdateChecklistItem updates a checklist item's content and checked status
 func UpdateChecklistItem(db *sql.DB, itemID int, content string, isChecked bool, userID int) error {
 	// Verify ownership via trip
@@ -797,7 +1156,7 @@ func ReorderChecklistItems(db *sql.DB, tripID string, itemIDs []int, userID int)
 func GetTransportSteps(db *sql.DB, tripID string) ([]models.TripTransportStep, error) {
 	query := `
 		SELECT id, trip_id, journey_type, step_order, departure_place,
-		       departure_datetime, arrival_place, arrival_datetime, transport_type, transport_number, notes, created_at
+		       departure_datetime, arrival_place, arrival_datetime, transport_type, transport_number, notes, cost, currency, created_at
 		FROM trip_transport_steps
 		WHERE trip_id = ?
 		ORDER BY journey_type, step_order ASC
@@ -813,12 +1172,13 @@ func GetTransportSteps(db *sql.DB, tripID string) ([]models.TripTransportStep, e
 	for rows.Next() {
 		var step models.TripTransportStep
 		var departureDatetime, arrivalDatetime sql.NullTime
-		var arrivalPlace, transportType, transportNumber, notes sql.NullString
+		var arrivalPlace, transportType, transportNumber, notes, currency sql.NullString
+		var cost sql.NullFloat64
 
 		err := rows.Scan(
 			&step.ID, &step.TripID, &step.JourneyType, &step.StepOrder,
 			&step.DeparturePlace, &departureDatetime, &arrivalPlace, &arrivalDatetime, &transportType, &transportNumber, &notes,
-			&step.CreatedAt,
+			&cost, &currency, &step.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan transport step: %w", err)
@@ -843,6 +1203,12 @@ func GetTransportSteps(db *sql.DB, tripID string) ([]models.TripTransportStep, e
 		if notes.Valid {
 			step.Notes = &notes.String
 		}
+		if cost.Valid {
+			step.Cost = &cost.Float64
+		}
+		if currency.Valid {
+			step.Currency = &currency.String
+		}
 
 		steps = append(steps, step)
 	}
@@ -851,7 +1217,7 @@ func GetTransportSteps(db *sql.DB, tripID string) ([]models.TripTransportStep, e
 }
 
 // AddTransportStep adds a new transport step to a trip
-func AddTransportStep(db *sql.DB, tripID string, journeyType string, departurePlace string, departureDatetime *time.Time, arrivalPlace *string, arrivalDatetime *time.Time, transportType, transportNumber, notes *string, userID int) (*models.TripTransportStep, error) {
+func AddTransportStep(db *sql.DB, tripID string, journeyType string, departurePlace string, departureDatetime *time.Time, arrivalPlace *string, arrivalDatetime *time.Time, transportType, transportNumber, notes *string, cost *float64, currency *string, userID int) (*models.TripTransportStep, error) {
 	// Verify trip ownership
 	var tripOwnerID int
 	err := db.QueryRow("SELECT user_id FROM trips WHERE id = ?", tripID).Scan(&tripOwnerID)
@@ -876,11 +1242,11 @@ func AddTransportStep(db *sql.DB, tripID string, journeyType string, departurePl
 	}
 
 	query := `
-		INSERT INTO trip_transport_steps (trip_id, journey_type, step_order, departure_place, departure_datetime, arrival_place, arrival_datetime, transport_type, transport_number, notes)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO trip_transport_steps (trip_id, journey_type, step_order, departure_place, departure_datetime, arrival_place, arrival_datetime, transport_type, transport_number, notes, cost, currency)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := db.Exec(query, tripID, journeyType, maxStepOrder+1, departurePlace, departureDatetime, arrivalPlace, arrivalDatetime, transportType, transportNumber, notes)
+	result, err := db.Exec(query, tripID, journeyType, maxStepOrder+1, departurePlace, departureDatetime, arrivalPlace, arrivalDatetime, transportType, transportNumber, notes, cost, currency)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add transport step: %w", err)
 	}
@@ -905,6 +1271,8 @@ func AddTransportStep(db *sql.DB, tripID string, journeyType string, departurePl
 		TransportType:     transportType,
 		TransportNumber:   transportNumber,
 		Notes:             notes,
+		Cost:              cost,
+		Currency:          currency,
 		CreatedAt:         time.Now(),
 	}
 
@@ -912,7 +1280,7 @@ func AddTransportStep(db *sql.DB, tripID string, journeyType string, departurePl
 }
 
 // UpdateTransportStep updates a transport step
-func UpdateTransportStep(db *sql.DB, stepID int, departurePlace string, departureDatetime *time.Time, arrivalPlace *string, arrivalDatetime *time.Time, transportType, transportNumber, notes *string, userID int) error {
+func UpdateTransportStep(db *sql.DB, stepID int, departurePlace string, departureDatetime *time.Time, arrivalPlace *string, arrivalDatetime *time.Time, transportType, transportNumber, notes *string, cost *float64, currency *string, userID int) error {
 	// Verify ownership via trip
 	var tripOwnerID int
 	var tripID string
@@ -932,11 +1300,12 @@ func UpdateTransportStep(db *sql.DB, stepID int, departurePlace string, departur
 
 	query := `
 		UPDATE trip_transport_steps
-		SET departure_place = ?, departure_datetime = ?, arrival_place = ?, arrival_datetime = ?, transport_type = ?, transport_number = ?, notes = ?
+		SET departure_place = ?, departure_datetime = ?, arrival_place = ?, arrival_datetime = ?, transport_type = ?, transport_number = ?, notes = ?,
+		    cost = ?, currency = ?
 		WHERE id = ?
 	`
 
-	result, err := db.Exec(query, departurePlace, departureDatetime, arrivalPlace, arrivalDatetime, transportType, transportNumber, notes, stepID)
+	result, err := db.Exec(query, departurePlace, departureDatetime, arrivalPlace, arrivalDatetime, transportType, transportNumber, notes, cost, currency, stepID)
 	if err != nil {
 		return fmt.Errorf("failed to update transport step: %w", err)
 	}
@@ -956,6 +1325,49 @@ func UpdateTransportStep(db *sql.DB, stepID int, departurePlace string, departur
 	return nil
 }
 
+// TripCostTotal is the transport spend of a trip in one currency
+type TripCostTotal struct {
+	Currency string  `json:"currency"`
+	Total    float64 `json:"total"`
+	Steps    int     `json:"steps"`
+}
+
+// GetTripCostSummary totals the cost of a trip's transport steps per currency. Steps
+// recorded without a currency count in the trip owner's currency, and steps without
+// a cost are left out.
+func GetTripCostSummary(db *sql.DB, tripID string) ([]TripCostTotal, error) {
+	query := `
+		SELECT COALESCE(tts.currency, u.currency, '$') AS step_currency, SUM(tts.cost), COUNT(*)
+		FROM trip_transport_steps tts
+		INNER JOIN trips t ON tts.trip_id = t.id
+		INNER JOIN users u ON t.user_id = u.id
+		WHERE tts.trip_id = ? AND tts.cost IS NOT NULL
+		GROUP BY step_currency
+		ORDER BY SUM(tts.cost) DESC
+	`
+
+	rows, err := db.Query(query, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trip costs: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []TripCostTotal
+	for rows.Next() {
+		var total TripCostTotal
+		if err := rows.Scan(&total.Currency, &total.Total, &total.Steps); err != nil {
+			return nil, fmt.Errorf("failed to scan trip cost: %w", err)
+		}
+		totals = append(totals, total)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating trip costs: %w", err)
+	}
+
+	return totals, nil
+}
+
 // DeleteTransportStep deletes a transport step
 func DeleteTransportStep(db *sql.DB, stepID int, userID int) error {
 	// Verify ownership and get trip_id
@@ -1039,42 +1451,127 @@ func ReorderTransportSteps(db *sql.DB, tripID, journeyType string, stepIDs []int
 
 // GPX Functions
 
-// UpdateTripGPX updates the GPX data for a trip
-func UpdateTripGPX(db *sql.DB, userID int, tripID string, gpxData string) error {
+// maxTripGPXFiles caps how many GPX tracks a single trip can hold
+const maxTripGPXFiles = 20
+
+// GetTripGPXFiles returns the GPX tracks of a trip in upload order
+func GetTripGPXFiles(db *sql.DB, tripID string) ([]models.TripGPXFile, error) {
 	query := `
-		UPDATE trips
-		SET gpx_data = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND user_id = ?
+		SELECT id, trip_id, name, gpx_data, created_at
+		FROM trip_gpx_files
+		WHERE trip_id = ?
+		ORDER BY created_at ASC, id ASC
 	`
 
-	result, err := db.Exec(query, gpxData, tripID, userID)
+	rows, err := db.Query(query, tripID)
 	if err != nil {
-		return fmt.Errorf("failed to update GPX data: %w", err)
+		return nil, fmt.Errorf("failed to query GPX files: %w", err)
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
+	var files []models.TripGPXFile
+	for rows.Next() {
+		var file models.TripGPXFile
+		if err := rows.Scan(&file.ID, &file.TripID, &file.Name, &file.GPXData, &file.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan GPX file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// GetTripGPXFile returns one GPX track of a trip
+func GetTripGPXFile(db *sql.DB, tripID string, fileID int) (*models.TripGPXFile, error) {
+	var file models.TripGPXFile
+	err := db.QueryRow(`
+		SELECT id, trip_id, name, gpx_data, created_at
+		FROM trip_gpx_files
+		WHERE id = ? AND trip_id = ?
+	`, fileID, tripID).Scan(&file.ID, &file.TripID, &file.Name, &file.GPXData, &file.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("GPX file not found")
+		}
+		return nil, fmt.Errorf("failed to get GPX file: %w", err)
 	}
 
+	return &file, nil
+}
+
+// AddTripGPXFile stores a new GPX track for a trip and refreshes the trip's combined GPX stats
+func AddTripGPXFile(db *sql.DB, userID int, tripID string, name string, gpxData string) (*models.TripGPXFile, error) {
+	// Verify trip ownership
+	var tripOwnerID int
+	err := db.QueryRow("SELECT user_id FROM trips WHERE id = ?", tripID).Scan(&tripOwnerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("trip not found")
+		}
+		return nil, fmt.Errorf("failed to check trip ownership: %w", err)
+	}
+
+	if tripOwnerID != userID {
+		return nil, fmt.Errorf("unauthorized")
+	}
+
+	// The limit is checked by the insert itself, so concurrent uploads can't both get past it
+	result, err := db.Exec(`
+		INSERT INTO trip_gpx_files (trip_id, name, gpx_data)
+		SELECT ?, ?, ?
+		WHERE (SELECT COUNT(*) FROM trip_gpx_files WHERE trip_id = ?) < ?
+	`, tripID, name, gpxData, tripID, maxTripGPXFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add GPX file: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("trip not found or unauthorized")
+		return nil, fmt.Errorf("too many GPX files (max %d)", maxTripGPXFiles)
 	}
 
-	return nil
+	fileID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if err := updateTripGPXStats(db, tripID); err != nil {
+		return nil, err
+	}
+
+	file := &models.TripGPXFile{
+		ID:        int(fileID),
+		TripID:    tripID,
+		Name:      name,
+		GPXData:   gpxData,
+		CreatedAt: time.Now(),
+	}
+
+	return file, nil
 }
 
-// DeleteTripGPX removes the GPX data from a trip
-func DeleteTripGPX(db *sql.DB, userID int, tripID string) error {
-	query := `
-		UPDATE trips
-		SET gpx_data = NULL, updated_at = CURRENT_TIMESTAMP
-		WHERE id = ? AND user_id = ?
-	`
+// DeleteTripGPXFile removes one GPX track from a trip and refreshes the trip's combined GPX stats
+func DeleteTripGPXFile(db *sql.DB, userID int, tripID string, fileID int) error {
+	// Verify trip ownership
+	var tripOwnerID int
+	err := db.QueryRow("SELECT user_id FROM trips WHERE id = ?", tripID).Scan(&tripOwnerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("trip not found")
+		}
+		return fmt.Errorf("failed to check trip ownership: %w", err)
+	}
 
-	result, err := db.Exec(query, tripID, userID)
+	if tripOwnerID != userID {
+		return fmt.Errorf("unauthorized")
+	}
+
+	result, err := db.Exec(`DELETE FROM trip_gpx_files WHERE id = ? AND trip_id = ?`, fileID, tripID)
 	if err != nil {
-		return fmt.Errorf("failed to delete GPX data: %w", err)
+		return fmt.Errorf("failed to delete GPX file: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
@@ -1083,7 +1580,53 @@ func DeleteTripGPX(db *sql.DB, userID int, tripID string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("trip not found or unauthorized")
+		return fmt.Errorf("GPX file not found")
+	}
+
+	return updateTripGPXStats(db, tripID)
+}
+
+// updateTripGPXStats recomputes the trip's GPX stats from all of its tracks.
+// Tracks that can't be parsed are left out, the stats are cleared when none remain.
+func updateTripGPXStats(db *sql.DB, tripID string) error {
+	files, err := GetTripGPXFiles(db, tripID)
+	if err != nil {
+		return err
+	}
+
+	var tracks []gpx.GPXStats
+	for _, file := range files {
+		stats, err := gpx.ParseStats(file.GPXData)
+		if err != nil {
+			logger.Warn("Skipping unparseable GPX file in trip stats", "trip_id", tripID, "gpx_file_id", file.ID, "error", err)
+			continue
+		}
+		tracks = append(tracks, stats)
+	}
+
+	var distance, ascent, descent, minLat, minLon, maxLat, maxLon sql.NullFloat64
+	if len(tracks) > 0 {
+		merged := gpx.MergeStats(tracks...)
+		distance = sql.NullFloat64{Float64: merged.DistanceMeters, Valid: true}
+		ascent = sql.NullFloat64{Float64: merged.AscentMeters, Valid: true}
+		descent = sql.NullFloat64{Float64: merged.DescentMeters, Valid: true}
+		minLat = sql.NullFloat64{Float64: merged.MinLat, Valid: true}
+		minLon = sql.NullFloat64{Float64: merged.MinLon, Valid: true}
+		maxLat = sql.NullFloat64{Float64: merged.MaxLat, Valid: true}
+		maxLon = sql.NullFloat64{Float64: merged.MaxLon, Valid: true}
+	}
+
+	query := `
+		UPDATE trips
+		SET gpx_distance_m = ?, gpx_ascent_m = ?, gpx_descent_m = ?,
+		    gpx_min_lat = ?, gpx_min_lon = ?, gpx_max_lat = ?, gpx_max_lon = ?,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+
+	_, err = db.Exec(query, distance, ascent, descent, minLat, minLon, maxLat, maxLon, tripID)
+	if err != nil {
+		return fmt.Errorf("failed to update GPX stats: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"carryless/internal/models"
+)
+
+// ExportUserData gathers the user's profile and everything they own into a single document.
+// Packs include their items and labels, trips include checklists, transport steps and GPX tracks.
+func ExportUserData(db *sql.DB, userID int) (*models.UserDataExport, error) {
+	user, err := GetUserByID(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserDataExport{
+		ExportedAt: time.Now(),
+		Profile: models.UserDataProfile{
+			Username:   user.Username,
+			Email:      user.Email,
+			Currency:   user.Currency,
+			WeightUnit: user.WeightUnit,
+			CreatedAt:  user.CreatedAt,
+		},
+		Categories:   []models.Category{},
+		Items:        []models.Item{},
+		DeletedItems: []models.Item{},
+		PackLabels:   []models.UserPackLabel{},
+		Packs:        []models.Pack{},
+		Trips:        []models.Trip{},
+	}
+
+	categories, err := GetCategories(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	export.Categories = append(export.Categories, categories...)
+
+	items, err := GetItems(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	export.Items = append(export.Items, items...)
+
+	deletedItems, err := GetDeletedItems(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	export.DeletedItems = append(export.DeletedItems, deletedItems...)
+
+	packLabels, err := GetUserPackLabels(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	export.PackLabels = append(export.PackLabels, packLabels...)
+
+	packs, err := GetPacks(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := GetPackTemplates(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, pack := range append(packs, templates...) {
+		fullPack, err := GetPackWithItems(db, pack.ID)
+		if err != nil {
+			return nil, err
+		}
+		export.Packs = append(export.Packs, *fullPack)
+	}
+
+	trips, err := GetTrips(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, trip := range trips {
+		fullTrip, err := GetTripWithDetails(db, trip.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Packs are exported in full above, trips only reference the user's own
+		var tripPacks []models.Pack
+		for _, pack := range fullTrip.Packs {
+			if pack.UserID == userID {
+				tripPacks = append(tripPacks, pack)
+			}
+		}
+		fullTrip.Packs = tripPacks
+
+		export.Trips = append(export.Trips, *fullTrip)
+	}
+
+	return export, nil
+}
@@ -3,6 +3,7 @@ package email
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"time"
 
 	"carryless/internal/config"
@@ -12,61 +13,117 @@ import (
 	"github.com/mailgun/mailgun-go/v5"
 )
 
+// Supported values for EMAIL_PROVIDER
+const (
+	ProviderMailgun = "mailgun"
+	ProviderSMTP    = "smtp"
+)
+
+// sendTimeout bounds how long delivering a single email may take
+const sendTimeout = 10 * time.Second
+
 type Service struct {
+	provider    string
 	client      mailgun.Mailgun
 	domain      string
+	smtp        *smtpTransport
 	senderEmail string
 	senderName  string
 	enabled     bool
 }
 
 func NewService(cfg *config.Config) *Service {
-	enabled := cfg.MailgunDomain != "" && cfg.MailgunAPIKey != ""
-	
-	var client mailgun.Mailgun
-	if enabled {
-		client = mailgun.NewMailgun(cfg.MailgunAPIKey)
-		// Set EU API base for European users
-		if cfg.MailgunRegion == "EU" {
-			client.SetAPIBase(mailgun.APIBaseEU)
+	mailgunConfigured := cfg.MailgunDomain != "" && cfg.MailgunAPIKey != ""
+	smtpConfigured := cfg.SMTPHost != ""
+
+	// Without an explicit provider, Mailgun is preferred and SMTP is the fallback
+	provider := cfg.EmailProvider
+	if provider == "" {
+		provider = ProviderMailgun
+		if !mailgunConfigured && smtpConfigured {
+			provider = ProviderSMTP
 		}
 	}
-	
-	return &Service{
-		client:      client,
-		domain:      cfg.MailgunDomain,
-		senderEmail: cfg.MailgunSenderEmail,
-		senderName:  cfg.MailgunSenderName,
-		enabled:     enabled,
+
+	service := &Service{provider: provider}
+
+	switch provider {
+	case ProviderSMTP:
+		service.enabled = smtpConfigured
+		service.smtp = &smtpTransport{
+			host:     cfg.SMTPHost,
+			port:     cfg.SMTPPort,
+			username: cfg.SMTPUsername,
+			password: cfg.SMTPPassword,
+		}
+		service.senderEmail = cfg.SMTPSenderEmail
+		service.senderName = cfg.SMTPSenderName
+	case ProviderMailgun:
+		service.enabled = mailgunConfigured
+		if mailgunConfigured {
+			service.client = mailgun.NewMailgun(cfg.MailgunAPIKey)
+			// Set EU API base for European users
+			if cfg.MailgunRegion == "EU" {
+				service.client.SetAPIBase(mailgun.APIBaseEU)
+			}
+		}
+		service.domain = cfg.MailgunDomain
+		service.senderEmail = cfg.MailgunSenderEmail
+		service.senderName = cfg.MailgunSenderName
+	default:
+		logger.Warn("Unknown email provider, email is disabled", "provider", provider)
 	}
+
+	return service
 }
 
 func (s *Service) IsEnabled() bool {
 	return s.enabled
 }
 
-func (s *Service) SendWelcomeEmail(user *models.User, activationToken string) error {
-	if !s.enabled {
-		return fmt.Errorf("email service is not configured")
-	}
+// Provider returns the name of the transport used to deliver emails
+func (s *Service) Provider() string {
+	return s.provider
+}
 
-	subject := fmt.Sprintf("Welcome to Carryless, %s! Please activate your account", user.Username)
-	htmlBody := s.generateWelcomeHTML(user, activationToken)
-	textBody := s.generateWelcomeText(user, activationToken)
+// send delivers a message through the configured provider and returns the provider's
+// message ID when it has one
+func (s *Service) send(to, subject, textBody, htmlBody string) (string, error) {
+	from := fmt.Sprintf("%s <%s>", s.senderName, s.senderEmail)
+
+	if s.provider == ProviderSMTP {
+		return "", s.smtp.send(s.senderEmail, from, to, subject, textBody, htmlBody)
+	}
 
 	message := mailgun.NewMessage(
 		s.domain,
-		fmt.Sprintf("%s <%s>", s.senderName, s.senderEmail),
+		from,
 		subject,
 		textBody,
-		user.Email,
+		to,
 	)
 	message.SetHTML(htmlBody)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
 	defer cancel()
 
 	resp, err := s.client.Send(ctx, message)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (s *Service) SendWelcomeEmail(user *models.User, activationToken string) error {
+	if !s.enabled {
+		return fmt.Errorf("email service is not configured")
+	}
+
+	subject := fmt.Sprintf("Welcome to Carryless, %s! Please activate your account", user.Username)
+	htmlBody := s.generateWelcomeHTML(user, activationToken)
+	textBody := s.generateWelcomeText(user, activationToken)
+
+	messageID, err := s.sendWithRetry(user.Email, subject, textBody, htmlBody)
 	if err != nil {
 		return fmt.Errorf("failed to send welcome email to %s: %w", user.Email, err)
 	}
@@ -74,32 +131,43 @@ func (s *Service) SendWelcomeEmail(user *models.User, activationToken string) er
 	logger.Info("Welcome email sent",
 		"email", user.Email,
 		"user_id", user.ID,
-		"message_id", resp)
+		"message_id", messageID)
 	return nil
 }
 
-func (s *Service) SendAdminNotificationEmail(admin *models.User, newUser *models.User) error {
+// unsubscribeURL is the link turning off a category of optional emails for the user
+func unsubscribeURL(prefs *models.EmailPreferences, category string) string {
+	return fmt.Sprintf("https://carryless.org/unsubscribe/%s?category=%s", prefs.UnsubscribeToken, url.QueryEscape(category))
+}
+
+// optedOut reports whether the user turned off a category of optional emails, in which case
+// nothing must be sent to them
+func optedOut(user *models.User, prefs *models.EmailPreferences, category string) bool {
+	if prefs.Allows(category) {
+		return false
+	}
+	logger.Debug("Skipping email the user opted out of",
+		"user_id", user.ID,
+		"category", category)
+	return true
+}
+
+// SendAdminNotificationEmail tells an admin about a new registration, unless they opted out
+// of admin notifications
+func (s *Service) SendAdminNotificationEmail(admin *models.User, newUser *models.User, prefs *models.EmailPreferences) error {
 	if !s.enabled {
 		return fmt.Errorf("email service is not configured")
 	}
+	if optedOut(admin, prefs, models.EmailCategoryAdminNotifications) {
+		return nil
+	}
 
 	subject := fmt.Sprintf("New User Registered - %s", newUser.Username)
-	htmlBody := s.generateAdminNotificationHTML(admin, newUser)
-	textBody := s.generateAdminNotificationText(admin, newUser)
-
-	message := mailgun.NewMessage(
-		s.domain,
-		fmt.Sprintf("%s <%s>", s.senderName, s.senderEmail),
-		subject,
-		textBody,
-		admin.Email,
-	)
-	message.SetHTML(htmlBody)
+	unsubscribe := unsubscribeURL(prefs, models.EmailCategoryAdminNotifications)
+	htmlBody := s.generateAdminNotificationHTML(admin, newUser, unsubscribe)
+	textBody := s.generateAdminNotificationText(admin, newUser, unsubscribe)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	resp, err := s.client.Send(ctx, message)
+	messageID, err := s.sendWithRetry(admin.Email, subject, textBody, htmlBody)
 	if err != nil {
 		return fmt.Errorf("failed to send admin notification email to %s: %w", admin.Email, err)
 	}
@@ -109,6 +177,53 @@ func (s *Service) SendAdminNotificationEmail(admin *models.User, newUser *models
 		"admin_id", admin.ID,
 		"new_user_email", newUser.Email,
 		"new_user_id", newUser.ID,
-		"message_id", resp)
+		"message_id", messageID)
+	return nil
+}
+
+// SendEmailChangeConfirmation asks the owner of newEmail to confirm it should replace the
+// user's current address. The change is only applied once the link is followed.
+func (s *Service) SendEmailChangeConfirmation(user *models.User, newEmail, token string) error {
+	if !s.enabled {
+		return fmt.Errorf("email service is not configured")
+	}
+
+	subject := "Confirm your new Carryless email address"
+	htmlBody := s.generateEmailChangeHTML(user, newEmail, token)
+	textBody := s.generateEmailChangeText(user, newEmail, token)
+
+	messageID, err := s.sendWithRetry(newEmail, subject, textBody, htmlBody)
+	if err != nil {
+		return fmt.Errorf("failed to send email change confirmation to %s: %w", newEmail, err)
+	}
+
+	logger.Info("Email change confirmation sent",
+		"email", newEmail,
+		"user_id", user.ID,
+		"message_id", messageID)
+	return nil
+}
+
+// SendTripShareEmail sends the public link of a trip to someone the owner wants to share it with.
+// The trip must already be public with a short ID.
+func (s *Service) SendTripShareEmail(sender *models.User, trip *models.Trip, recipientEmail string) error {
+	if !s.enabled {
+		return fmt.Errorf("email service is not configured")
+	}
+
+	subject := fmt.Sprintf("%s shared a trip with you: %s", sender.Username, trip.Name)
+	htmlBody := s.generateTripShareHTML(sender, trip)
+	textBody := s.generateTripShareText(sender, trip)
+
+	messageID, err := s.sendWithRetry(recipientEmail, subject, textBody, htmlBody)
+	if err != nil {
+		return fmt.Errorf("failed to send trip share email to %s: %w", recipientEmail, err)
+	}
+
+	logger.Info("Trip share email sent",
+		"email", recipientEmail,
+		"user_id", sender.ID,
+		"trip_id", trip.ID,
+		"message_id", messageID)
 	return nil
-}
\ No newline at end of file
+}
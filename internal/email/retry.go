@@ -0,0 +1,75 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"time"
+
+	"carryless/internal/logger"
+
+	"github.com/mailgun/mailgun-go/v5"
+)
+
+// sendRetries is how many times a failed send is retried before giving up
+const sendRetries = 3
+
+// retryBaseDelay is the wait before the first retry, doubled for each following one
+const retryBaseDelay = time.Second
+
+// sendWithRetry delivers a message, retrying transient failures with exponential backoff.
+// It blocks until the message is sent or abandoned, so handlers should call it from a goroutine.
+func (s *Service) sendWithRetry(to, subject, textBody, htmlBody string) (string, error) {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		messageID, err := s.send(to, subject, textBody, htmlBody)
+		if err == nil {
+			return messageID, nil
+		}
+
+		if attempt > sendRetries || !isTransientSendError(err) {
+			logger.Warn("Email send failed",
+				"email", to,
+				"provider", s.provider,
+				"attempt", attempt,
+				"error", err)
+			return "", err
+		}
+
+		logger.Warn("Email send failed, retrying",
+			"email", to,
+			"provider", s.provider,
+			"attempt", attempt,
+			"retry_in", delay,
+			"error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isTransientSendError reports whether a failed send may succeed if tried again:
+// network errors and timeouts, Mailgun 5xx responses and SMTP 4xx replies.
+func isTransientSendError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var mailgunErr *mailgun.UnexpectedResponseError
+	if errors.As(err, &mailgunErr) {
+		return mailgunErr.Actual >= 500
+	}
+
+	// SMTP uses 4xx for temporary failures, 5xx replies are permanent
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+
+	return false
+}
@@ -2,6 +2,9 @@ package email
 
 import (
 	"fmt"
+	"html"
+	"strings"
+
 	"carryless/internal/models"
 )
 
@@ -129,7 +132,7 @@ The Carryless Team
 This email was sent to %s. If you have any questions, feel free to reach out to us.`, user.Username, activationToken, user.Email)
 }
 
-func (s *Service) generateAdminNotificationHTML(admin *models.User, newUser *models.User) string {
+func (s *Service) generateAdminNotificationHTML(admin *models.User, newUser *models.User, unsubscribeURL string) string {
 	return fmt.Sprintf(`
 <!DOCTYPE html>
 <html>
@@ -222,13 +225,14 @@ func (s *Service) generateAdminNotificationHTML(admin *models.User, newUser *mod
         
         <div class="footer">
             <p>Carryless Admin Notification System</p>
+            <p><a href="%s">Stop receiving new user notifications</a></p>
         </div>
     </div>
 </body>
-</html>`, admin.Username, newUser.Username, newUser.Email, newUser.CreatedAt.Format("January 2, 2006 at 3:04 PM"))
+</html>`, admin.Username, newUser.Username, newUser.Email, newUser.CreatedAt.Format("January 2, 2006 at 3:04 PM"), unsubscribeURL)
 }
 
-func (s *Service) generateAdminNotificationText(admin *models.User, newUser *models.User) string {
+func (s *Service) generateAdminNotificationText(admin *models.User, newUser *models.User, unsubscribeURL string) string {
 	return fmt.Sprintf(`New User Registration
 
 Hello %s,
@@ -241,5 +245,258 @@ User Details:
 - Registration Time: %s
 
 ---
-Carryless Admin Notification System`, admin.Username, newUser.Username, newUser.Email, newUser.CreatedAt.Format("January 2, 2006 at 3:04 PM"))
-}
\ No newline at end of file
+Carryless Admin Notification System
+Stop receiving new user notifications: %s`, admin.Username, newUser.Username, newUser.Email, newUser.CreatedAt.Format("January 2, 2006 at 3:04 PM"), unsubscribeURL)
+}
+
+func (s *Service) generateEmailChangeHTML(user *models.User, newEmail, token string) string {
+	return fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Confirm your new email address</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+            background-color: #f8f9fa;
+        }
+        .container {
+            background-color: white;
+            padding: 40px;
+            border-radius: 12px;
+            box-shadow: 0 2px 10px rgba(0, 0, 0, 0.1);
+        }
+        .header {
+            text-align: center;
+            margin-bottom: 30px;
+        }
+        .logo {
+            font-size: 28px;
+            font-weight: bold;
+            color: #2d5e3e;
+            margin-bottom: 10px;
+        }
+        .content {
+            font-size: 16px;
+            margin-bottom: 30px;
+        }
+        .cta-button {
+            display: inline-block;
+            background-color: #2d5e3e;
+            color: white;
+            padding: 12px 24px;
+            text-decoration: none;
+            border-radius: 6px;
+            font-weight: 500;
+        }
+        .footer {
+            margin-top: 40px;
+            padding-top: 20px;
+            border-top: 1px solid #e9ecef;
+            font-size: 14px;
+            color: #6c757d;
+            text-align: center;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <div class="logo">Carryless</div>
+        </div>
+
+        <div class="content">
+            <p>Hi %s,</p>
+
+            <p>We received a request to change the email address of your Carryless account to <strong>%s</strong>.</p>
+
+            <p>Please confirm this is your address by clicking the link below:</p>
+
+            <p style="text-align: center; margin: 30px 0;">
+                <a href="https://carryless.org/account/email/confirm/%s" class="cta-button">Confirm Email Address</a>
+            </p>
+
+            <p style="font-size: 14px; color: #6c757d;">This link will expire in 24 hours. If you didn't request this change, you can ignore this email and your account will keep its current address.</p>
+        </div>
+
+        <div class="footer">
+            <p>Happy trails!</p>
+            <p>The Carryless Team</p>
+        </div>
+    </div>
+</body>
+</html>`, user.Username, newEmail, token)
+}
+
+func (s *Service) generateEmailChangeText(user *models.User, newEmail, token string) string {
+	return fmt.Sprintf(`Hi %s,
+
+We received a request to change the email address of your Carryless account to %s.
+
+Please confirm this is your address by visiting:
+https://carryless.org/account/email/confirm/%s
+
+This link will expire in 24 hours. If you didn't request this change, you can ignore this email and your account will keep its current address.
+
+Happy trails!
+The Carryless Team`, user.Username, newEmail, token)
+}
+
+// tripDates describes the dates of a trip for emails, empty when it has none
+func tripDates(trip *models.Trip) string {
+	const layout = "January 2, 2006"
+	switch {
+	case trip.StartDate != nil && trip.EndDate != nil:
+		return trip.StartDate.Format(layout) + " - " + trip.EndDate.Format(layout)
+	case trip.StartDate != nil:
+		return "From " + trip.StartDate.Format(layout)
+	case trip.EndDate != nil:
+		return "Until " + trip.EndDate.Format(layout)
+	}
+	return ""
+}
+
+// tripShareDetails lists the trip's dates and location, skipping the ones it doesn't have
+func tripShareDetails(trip *models.Trip) [][2]string {
+	var details [][2]string
+	if dates := tripDates(trip); dates != "" {
+		details = append(details, [2]string{"Dates", dates})
+	}
+	if trip.Location != nil && *trip.Location != "" {
+		details = append(details, [2]string{"Location", *trip.Location})
+	}
+	return details
+}
+
+func (s *Service) generateTripShareHTML(sender *models.User, trip *models.Trip) string {
+	// Trip fields are user input, escape them before they end up in the markup
+	var details strings.Builder
+	if shareDetails := tripShareDetails(trip); len(shareDetails) > 0 {
+		details.WriteString("<ul>")
+		for _, detail := range shareDetails {
+			fmt.Fprintf(&details, "<li><strong>%s:</strong> %s</li>", detail[0], html.EscapeString(detail[1]))
+		}
+		details.WriteString("</ul>")
+	}
+
+	return fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>A trip was shared with you</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+            background-color: #f8f9fa;
+        }
+        .container {
+            background-color: white;
+            padding: 40px;
+            border-radius: 12px;
+            box-shadow: 0 2px 10px rgba(0, 0, 0, 0.1);
+        }
+        .header {
+            text-align: center;
+            margin-bottom: 30px;
+        }
+        .logo {
+            font-size: 28px;
+            font-weight: bold;
+            color: #2d5e3e;
+            margin-bottom: 10px;
+        }
+        .content {
+            font-size: 16px;
+            margin-bottom: 30px;
+        }
+        .trip-details {
+            background-color: #f8f9fa;
+            padding: 20px;
+            border-radius: 8px;
+            margin: 20px 0;
+        }
+        .trip-details ul {
+            margin: 10px 0 0 0;
+            padding-left: 20px;
+        }
+        .cta-button {
+            display: inline-block;
+            background-color: #2d5e3e;
+            color: white;
+            padding: 12px 24px;
+            text-decoration: none;
+            border-radius: 6px;
+            font-weight: 500;
+        }
+        .footer {
+            margin-top: 40px;
+            padding-top: 20px;
+            border-top: 1px solid #e9ecef;
+            font-size: 14px;
+            color: #6c757d;
+            text-align: center;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <div class="logo">Carryless</div>
+        </div>
+
+        <div class="content">
+            <p>Hi,</p>
+
+            <p><strong>%s</strong> shared a trip with you on Carryless.</p>
+
+            <div class="trip-details">
+                <strong>%s</strong>
+                %s
+            </div>
+
+            <p style="text-align: center; margin: 30px 0;">
+                <a href="https://carryless.org/t/%s" class="cta-button">View Trip</a>
+            </p>
+        </div>
+
+        <div class="footer">
+            <p>Happy trails!</p>
+            <p>The Carryless Team</p>
+        </div>
+    </div>
+</body>
+</html>`, html.EscapeString(sender.Username), html.EscapeString(trip.Name), details.String(), trip.ShortID)
+}
+
+func (s *Service) generateTripShareText(sender *models.User, trip *models.Trip) string {
+	var details strings.Builder
+	for _, detail := range tripShareDetails(trip) {
+		fmt.Fprintf(&details, "- %s: %s\n", detail[0], detail[1])
+	}
+
+	return fmt.Sprintf(`Hi,
+
+%s shared a trip with you on Carryless.
+
+%s
+%s
+View the trip at:
+https://carryless.org/t/%s
+
+Happy trails!
+The Carryless Team`, sender.Username, trip.Name, details.String(), trip.ShortID)
+}
@@ -0,0 +1,149 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// earthRadiusMeters is the mean Earth radius used for haversine distances
+const earthRadiusMeters = 6371000.0
+
+// GPXStats holds the values derived from a GPX track
+type GPXStats struct {
+	DistanceMeters float64
+	AscentMeters   float64
+	DescentMeters  float64
+	MinLat         float64
+	MinLon         float64
+	MaxLat         float64
+	MaxLon         float64
+	PointCount     int
+}
+
+type gpxFile struct {
+	XMLName xml.Name   `xml:"gpx"`
+	Tracks  []gpxTrack `xml:"trk"`
+	Routes  []gpxRoute `xml:"rte"`
+}
+
+type gpxTrack struct {
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxRoute struct {
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxPoint struct {
+	Lat float64  `xml:"lat,attr"`
+	Lon float64  `xml:"lon,attr"`
+	Ele *float64 `xml:"ele"`
+}
+
+// ParseStats parses GPX data and computes distance, ascent, descent and bounding box.
+// Track segments are used when present, otherwise routes. Distance is not counted
+// across segment boundaries.
+func ParseStats(data string) (GPXStats, error) {
+	var stats GPXStats
+
+	var file gpxFile
+	if err := xml.NewDecoder(strings.NewReader(data)).Decode(&file); err != nil {
+		return stats, fmt.Errorf("invalid GPX data: %w", err)
+	}
+
+	var segments [][]gpxPoint
+	for _, track := range file.Tracks {
+		for _, segment := range track.Segments {
+			segments = append(segments, segment.Points)
+		}
+	}
+	if len(segments) == 0 {
+		for _, route := range file.Routes {
+			segments = append(segments, route.Points)
+		}
+	}
+
+	for _, points := range segments {
+		for i, point := range points {
+			if point.Lat < -90 || point.Lat > 90 || point.Lon < -180 || point.Lon > 180 {
+				return stats, fmt.Errorf("invalid GPX data: coordinates out of range")
+			}
+
+			if stats.PointCount == 0 {
+				stats.MinLat, stats.MaxLat = point.Lat, point.Lat
+				stats.MinLon, stats.MaxLon = point.Lon, point.Lon
+			} else {
+				stats.MinLat = math.Min(stats.MinLat, point.Lat)
+				stats.MaxLat = math.Max(stats.MaxLat, point.Lat)
+				stats.MinLon = math.Min(stats.MinLon, point.Lon)
+				stats.MaxLon = math.Max(stats.MaxLon, point.Lon)
+			}
+			stats.PointCount++
+
+			if i == 0 {
+				continue
+			}
+
+			previous := points[i-1]
+			stats.DistanceMeters += haversine(previous.Lat, previous.Lon, point.Lat, point.Lon)
+
+			if previous.Ele != nil && point.Ele != nil {
+				delta := *point.Ele - *previous.Ele
+				if delta > 0 {
+					stats.AscentMeters += delta
+				} else {
+					stats.DescentMeters -= delta
+				}
+			}
+		}
+	}
+
+	if stats.PointCount == 0 {
+		return stats, fmt.Errorf("invalid GPX data: no track points found")
+	}
+
+	return stats, nil
+}
+
+// haversine returns the great-circle distance in meters between two coordinates
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	deltaPhi := (lat2 - lat1) * math.Pi / 180
+	deltaLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(deltaPhi/2)*math.Sin(deltaPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(deltaLambda/2)*math.Sin(deltaLambda/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusMeters * c
+}
+
+// MergeStats combines the stats of several tracks. Distance, ascent and descent add up
+// and the bounding box covers all of the tracks.
+func MergeStats(tracks ...GPXStats) GPXStats {
+	var merged GPXStats
+	for i, stats := range tracks {
+		merged.DistanceMeters += stats.DistanceMeters
+		merged.AscentMeters += stats.AscentMeters
+		merged.DescentMeters += stats.DescentMeters
+		merged.PointCount += stats.PointCount
+
+		if i == 0 {
+			merged.MinLat, merged.MinLon = stats.MinLat, stats.MinLon
+			merged.MaxLat, merged.MaxLon = stats.MaxLat, stats.MaxLon
+			continue
+		}
+		merged.MinLat = math.Min(merged.MinLat, stats.MinLat)
+		merged.MinLon = math.Min(merged.MinLon, stats.MinLon)
+		merged.MaxLat = math.Max(merged.MaxLat, stats.MaxLat)
+		merged.MaxLon = math.Max(merged.MaxLon, stats.MaxLon)
+	}
+	return merged
+}
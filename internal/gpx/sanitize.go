@@ -0,0 +1,115 @@
+package gpx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxPoints is the number of points a sanitized GPX file may hold, counting track,
+// route and waypoints. A week of GPS recording at one point every 5 seconds stays below it.
+const DefaultMaxPoints = 150000
+
+// ErrTooManyPoints is returned by Sanitize when a file holds more points than allowed
+var ErrTooManyPoints = errors.New("too many points")
+
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+// The clean* types list the only GPX elements kept by Sanitize. Anything else, such as
+// extensions, links or foreign elements, is dropped.
+type cleanFile struct {
+	XMLName   xml.Name     `xml:"gpx"`
+	Xmlns     string       `xml:"xmlns,attr"`
+	Version   string       `xml:"version,attr"`
+	Creator   string       `xml:"creator,attr"`
+	Metadata  *cleanMeta   `xml:"metadata,omitempty"`
+	Waypoints []cleanPoint `xml:"wpt"`
+	Routes    []cleanRoute `xml:"rte"`
+	Tracks    []cleanTrack `xml:"trk"`
+}
+
+type cleanMeta struct {
+	Name string `xml:"name,omitempty"`
+	Desc string `xml:"desc,omitempty"`
+	Time string `xml:"time,omitempty"`
+}
+
+type cleanTrack struct {
+	Name     string         `xml:"name,omitempty"`
+	Desc     string         `xml:"desc,omitempty"`
+	Type     string         `xml:"type,omitempty"`
+	Segments []cleanSegment `xml:"trkseg"`
+}
+
+type cleanSegment struct {
+	Points []cleanPoint `xml:"trkpt"`
+}
+
+type cleanRoute struct {
+	Name   string       `xml:"name,omitempty"`
+	Desc   string       `xml:"desc,omitempty"`
+	Points []cleanPoint `xml:"rtept"`
+}
+
+type cleanPoint struct {
+	Lat  coordinate `xml:"lat,attr"`
+	Lon  coordinate `xml:"lon,attr"`
+	Ele  *float64   `xml:"ele,omitempty"`
+	Time string     `xml:"time,omitempty"`
+	Name string     `xml:"name,omitempty"`
+	Desc string     `xml:"desc,omitempty"`
+}
+
+// coordinate is written as a plain decimal, as GPX doesn't allow exponents
+type coordinate float64
+
+func (c coordinate) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	return xml.Attr{Name: name, Value: strconv.FormatFloat(float64(c), 'f', -1, 64)}, nil
+}
+
+// Sanitize parses GPX data and writes it back with only the elements the app uses:
+// metadata, waypoints, routes and tracks with their names and points. Malformed files,
+// files without track points and files with more than maxPoints points are rejected.
+func Sanitize(data string, maxPoints int) (string, error) {
+	var file cleanFile
+	if err := xml.NewDecoder(strings.NewReader(data)).Decode(&file); err != nil {
+		return "", fmt.Errorf("invalid GPX data: %w", err)
+	}
+
+	points := len(file.Waypoints)
+	for _, route := range file.Routes {
+		points += len(route.Points)
+	}
+	for _, track := range file.Tracks {
+		for _, segment := range track.Segments {
+			points += len(segment.Points)
+		}
+	}
+	if points > maxPoints {
+		return "", fmt.Errorf("GPX file has %d points, at most %d are allowed: %w", points, maxPoints, ErrTooManyPoints)
+	}
+
+	// Files in other GPX versions are written back as GPX 1.1
+	file.XMLName = xml.Name{Local: "gpx"}
+	file.Xmlns = gpxNamespace
+	file.Version = "1.1"
+	file.Creator = "Carryless"
+	if file.Metadata != nil && *file.Metadata == (cleanMeta{}) {
+		file.Metadata = nil
+	}
+
+	out, err := xml.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to write GPX data: %w", err)
+	}
+	sanitized := xml.Header + string(out)
+
+	// The stats parser checks the coordinates and that there is something to draw
+	if _, err := ParseStats(sanitized); err != nil {
+		return "", err
+	}
+
+	return sanitized, nil
+}
@@ -0,0 +1,75 @@
+package gpx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeStripsForeignElements(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<gpx version="1.0" creator="watch" xmlns="http://www.topografix.com/GPX/1/0" xmlns:x="http://example.com/x">
+  <metadata><name>Ridge loop</name><link href="javascript:alert(1)"/></metadata>
+  <script>alert(1)</script>
+  <trk>
+    <name>Day 1 &lt;b&gt;</name>
+    <extensions><x:hr>140</x:hr></extensions>
+    <trkseg>
+      <trkpt lat="45.0" lon="6.0"><ele>1000</ele><time>2024-07-01T08:00:00Z</time><x:junk>1</x:junk></trkpt>
+      <trkpt lat="45.00001" lon="6.001"><ele>1010</ele></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+	sanitized, err := Sanitize(data, DefaultMaxPoints)
+	if err != nil {
+		t.Fatal("Failed to sanitize:", err)
+	}
+
+	for _, unwanted := range []string{"script", "alert", "extensions", "junk", "x:", "GPX/1/0", "<b>"} {
+		if strings.Contains(sanitized, unwanted) {
+			t.Errorf("Expected %q to be stripped from:\n%s", unwanted, sanitized)
+		}
+	}
+	for _, wanted := range []string{`xmlns="http://www.topografix.com/GPX/1/1"`, "<name>Ridge loop</name>", "Day 1 &lt;b&gt;", `lat="45.00001"`, "<ele>1010</ele>", "<time>2024-07-01T08:00:00Z</time>"} {
+		if !strings.Contains(sanitized, wanted) {
+			t.Errorf("Expected %q to be kept in:\n%s", wanted, sanitized)
+		}
+	}
+
+	stats, err := ParseStats(sanitized)
+	if err != nil {
+		t.Fatal("Failed to parse sanitized GPX:", err)
+	}
+	if stats.PointCount != 2 || stats.AscentMeters != 10 {
+		t.Errorf("Expected 2 points and 10m of ascent, got %+v", stats)
+	}
+}
+
+func TestSanitizeRejectsInvalidFiles(t *testing.T) {
+	var points strings.Builder
+	for i := 0; i < 11; i++ {
+		fmt.Fprintf(&points, `<trkpt lat="45" lon="%d"/>`, i)
+	}
+	tooMany := `<gpx><trk><trkseg>` + points.String() + `</trkseg></trk></gpx>`
+	if _, err := Sanitize(tooMany, 10); !errors.Is(err, ErrTooManyPoints) {
+		t.Errorf("Expected 11 points to be too many, got %v", err)
+	}
+	if _, err := Sanitize(tooMany, 11); err != nil {
+		t.Errorf("Expected 11 points to be accepted, got %v", err)
+	}
+
+	invalid := map[string]string{
+		"not xml":      "hello",
+		"not gpx":      `<kml><trk><trkseg><trkpt lat="1" lon="1"/></trkseg></trk></kml>`,
+		"unclosed":     `<gpx><trk><trkseg><trkpt lat="1" lon="1">`,
+		"no points":    `<gpx><trk><name>empty</name></trk></gpx>`,
+		"out of range": `<gpx><trk><trkseg><trkpt lat="91" lon="1"/></trkseg></trk></gpx>`,
+	}
+	for name, data := range invalid {
+		if _, err := Sanitize(data, DefaultMaxPoints); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
@@ -2,10 +2,16 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"carryless/internal/config"
 	"carryless/internal/database"
+	"carryless/internal/email"
+	"carryless/internal/logger"
+	"carryless/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,13 +31,186 @@ func handleAccountPage(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "account.html", gin.H{
-		"Title":     "Account - Carryless",
-		"User":      user,
-		"CSRFToken": csrfToken.Token,
+	sessions, err := database.GetUserSessions(db, userID)
+	if err != nil {
+		logger.Error("Failed to get user sessions", "user_id", userID, "error", err)
+	}
+
+	var currentSessionID string
+	if sessionCookie, err := c.Cookie("session_id"); err == nil {
+		currentSessionID = database.SessionDisplayID(sessionCookie)
+	}
+
+	totpEnabled, err := database.IsTOTPEnabled(db, userID)
+	if err != nil {
+		logger.Error("Failed to check two-factor authentication", "user_id", userID, "error", err)
+	}
+
+	var recoveryCodesLeft int
+	if totpEnabled {
+		recoveryCodesLeft, err = database.CountUnusedRecoveryCodes(db, userID)
+		if err != nil {
+			logger.Error("Failed to count recovery codes", "user_id", userID, "error", err)
+		}
+	}
+
+	apiTokens, err := database.GetAPITokens(db, userID)
+	if err != nil {
+		logger.Error("Failed to get API tokens", "user_id", userID, "error", err)
+	}
+
+	emailPrefs, err := database.GetEmailPreferences(db, userID)
+	if err != nil {
+		logger.Error("Failed to get email preferences", "user_id", userID, "error", err)
+	}
+
+	data := gin.H{
+		"Title":             "Account - Carryless",
+		"User":              user,
+		"CSRFToken":         csrfToken.Token,
+		"Sessions":          sessions,
+		"CurrentSessionID":  currentSessionID,
+		"TOTPAvailable":     c.MustGet("config").(*config.Config).TOTPEnabled(),
+		"TOTPEnabled":       totpEnabled,
+		"RecoveryCodesLeft": recoveryCodesLeft,
+		"APITokens":         apiTokens,
+		"EmailPrefs":        emailPrefs,
+	}
+
+	switch c.Query("success") {
+	case "session_revoked":
+		data["Success"] = "Session revoked successfully"
+	case "email_change_sent":
+		data["Success"] = "Check your new email address and click the confirmation link to complete the change"
+	case "2fa_disabled":
+		data["Success"] = "Two-factor authentication disabled"
+	case "api_token_revoked":
+		data["Success"] = "API token revoked"
+	case "email_prefs_updated":
+		data["Success"] = "Email preferences updated"
+	}
+	switch c.Query("error") {
+	case "invalid_email":
+		data["Error"] = "Please enter a valid email address"
+	case "email_unchanged":
+		data["Error"] = "This is already your email address"
+	case "email_in_use":
+		data["Error"] = "This email address is already used by another account"
+	case "email_unavailable":
+		data["Error"] = "Email changes are not available because email delivery is not configured"
+	case "email_change_failed":
+		data["Error"] = "Failed to start the email change"
+	case "session_not_found":
+		data["Error"] = "Session not found"
+	case "session_revoke_failed":
+		data["Error"] = "Failed to revoke session"
+	case "2fa_password":
+		data["Error"] = "Current password is incorrect"
+	case "2fa_invalid_code":
+		data["Error"] = "Invalid authentication or recovery code"
+	case "2fa_already_enabled":
+		data["Error"] = "Two-factor authentication is already enabled"
+	case "2fa_unavailable":
+		data["Error"] = "Two-factor authentication is not available on this instance"
+	case "2fa_failed":
+		data["Error"] = "Failed to update two-factor authentication"
+	case "api_token_name":
+		data["Error"] = "Please give the token a name of at most 100 characters"
+	case "api_token_limit":
+		data["Error"] = "You have too many API tokens, revoke one before creating another"
+	case "api_token_not_found":
+		data["Error"] = "API token not found"
+	case "api_token_failed":
+		data["Error"] = "Failed to update API tokens"
+	case "email_prefs_failed":
+		data["Error"] = "Failed to update email preferences"
+	}
+
+	c.HTML(http.StatusOK, "account.html", data)
+}
+
+// handleDeleteSession revokes one of the user's sessions, e.g. one left open on a shared computer
+func handleDeleteSession(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	sessionID := c.Param("id")
+
+	err := database.DeleteUserSession(db, userID, sessionID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.Redirect(http.StatusFound, "/account?error=session_not_found")
+			return
+		}
+		logger.Error("Failed to revoke session", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=session_revoke_failed")
+		return
+	}
+
+	// Revoking the current session logs the user out
+	if sessionCookie, err := c.Cookie("session_id"); err == nil && database.SessionDisplayID(sessionCookie) == sessionID {
+		c.SetCookie("session_id", "", -1, "/", "", true, true)
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/account?success=session_revoked")
+}
+
+// handleCreateAPIToken issues an API token and shows it once: only its hash is kept
+func handleCreateAPIToken(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" || len(name) > 100 {
+		c.Redirect(http.StatusFound, "/account?error=api_token_name")
+		return
+	}
+
+	token, apiToken, err := database.CreateAPIToken(db, userID, name)
+	if err != nil {
+		if strings.Contains(err.Error(), "too many") {
+			c.Redirect(http.StatusFound, "/account?error=api_token_limit")
+			return
+		}
+		logger.Error("Failed to create API token", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=api_token_failed")
+		return
+	}
+
+	c.HTML(http.StatusOK, "api_token_created.html", gin.H{
+		"Title":    "API Token - Carryless",
+		"User":     c.MustGet("user"),
+		"Success":  "API token created",
+		"Token":    token,
+		"APIToken": apiToken,
 	})
 }
 
+// handleDeleteAPIToken revokes one of the user's API tokens
+func handleDeleteAPIToken(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/account?error=api_token_not_found")
+		return
+	}
+
+	if err := database.DeleteAPIToken(db, userID, tokenID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.Redirect(http.StatusFound, "/account?error=api_token_not_found")
+			return
+		}
+		logger.Error("Failed to revoke API token", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=api_token_failed")
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/account?success=api_token_revoked")
+}
+
 func handleChangePassword(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -160,17 +339,6 @@ func handleChangeCurrency(c *gin.Context) {
 	currency := strings.TrimSpace(c.PostForm("currency"))
 
 	// Validate currency
-	validCurrencies := map[string]bool{
-		"$": true,  // USD
-		"€": true,  // EUR
-		"¥": true,  // JPY
-		"£": true,  // GBP
-		"₹": true,  // INR
-		"₩": true,  // KRW
-		"¢": true,  // cents
-		"R": true,  // ZAR
-	}
-
 	if !validCurrencies[currency] {
 		c.HTML(http.StatusBadRequest, "account.html", gin.H{
 			"Title": "Account - Carryless",
@@ -196,4 +364,276 @@ func handleChangeCurrency(c *gin.Context) {
 		"User":    user,
 		"Success": "Currency updated successfully",
 	})
-}
\ No newline at end of file
+}
+
+// validCurrencies lists the currency symbols a user can pick, also accepted on transport step costs.
+var validCurrencies = map[string]bool{
+	"$": true, // USD
+	"€": true, // EUR
+	"¥": true, // JPY
+	"£": true, // GBP
+	"₹": true, // INR
+	"₩": true, // KRW
+	"¢": true, // cents
+	"R": true, // ZAR
+}
+
+// validWeightUnits lists the weight units a user can pick for display. Weights are always stored in grams.
+var validWeightUnits = map[string]bool{
+	"g":  true,
+	"oz": true,
+}
+
+// weightUnitFor returns the display weight unit of the user in the context, defaulting to grams.
+func weightUnitFor(user interface{}) string {
+	if u, ok := user.(*models.User); ok && validWeightUnits[u.WeightUnit] {
+		return u.WeightUnit
+	}
+	return "g"
+}
+
+// setWeightUnitCookie keeps the client-side unit selector in sync with the stored preference.
+func setWeightUnitCookie(c *gin.Context, weightUnit string) {
+	c.SetCookie("weightUnit", weightUnit, 365*24*60*60, "/", "", false, false)
+}
+
+func handleChangeWeightUnit(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user")
+
+	weightUnit := strings.TrimSpace(c.PostForm("weight_unit"))
+
+	if !validWeightUnits[weightUnit] {
+		c.HTML(http.StatusBadRequest, "account.html", gin.H{
+			"Title": "Account - Carryless",
+			"User":  user,
+			"Error": "Invalid weight unit selected",
+		})
+		return
+	}
+
+	err := database.UpdateUserWeightUnit(db, userID, weightUnit)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "account.html", gin.H{
+			"Title": "Account - Carryless",
+			"User":  user,
+			"Error": "Failed to update weight unit",
+		})
+		return
+	}
+
+	setWeightUnitCookie(c, weightUnit)
+
+	// Refresh user data
+	updatedUser, _ := database.GetUserByID(db, userID)
+
+	c.HTML(http.StatusOK, "account.html", gin.H{
+		"Title":   "Account - Carryless",
+		"User":    updatedUser,
+		"Success": "Weight unit updated successfully",
+	})
+}
+
+// handleExportAccountData downloads everything stored for the user as a JSON file
+func handleExportAccountData(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	export, err := database.ExportUserData(db, userID)
+	if err != nil {
+		logger.Error("Failed to export account data", "user_id", userID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to export account data")
+		return
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to generate JSON")
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.Header("Content-Disposition", "attachment; filename=carryless-account-data.json")
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+// handleRequestEmailChange sends a confirmation link to the new address. The account keeps its
+// current email until the link is followed.
+func handleRequestEmailChange(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+	emailService := c.MustGet("email_service").(*email.Service)
+
+	newEmail := strings.TrimSpace(c.PostForm("email"))
+	if !emailRegex.MatchString(newEmail) {
+		c.Redirect(http.StatusFound, "/account?error=invalid_email")
+		return
+	}
+
+	if !emailService.IsEnabled() {
+		c.Redirect(http.StatusFound, "/account?error=email_unavailable")
+		return
+	}
+
+	token, err := database.RequestEmailChange(db, userID, newEmail)
+	if err != nil {
+		switch err.Error() {
+		case "email unchanged":
+			c.Redirect(http.StatusFound, "/account?error=email_unchanged")
+		case "email already in use":
+			c.Redirect(http.StatusFound, "/account?error=email_in_use")
+		default:
+			logger.Error("Failed to request email change", "user_id", userID, "error", err)
+			c.Redirect(http.StatusFound, "/account?error=email_change_failed")
+		}
+		return
+	}
+
+	go func() {
+		if err := emailService.SendEmailChangeConfirmation(user, newEmail, token.Token); err != nil {
+			logger.Error("Failed to send email change confirmation", "user_id", userID, "error", err)
+		}
+	}()
+
+	c.Redirect(http.StatusFound, "/account?success=email_change_sent")
+}
+
+// handleConfirmEmailChange applies a pending email change from the link sent to the new address
+func handleConfirmEmailChange(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	token := c.Param("token")
+
+	user, err := database.ConfirmEmailChange(db, token)
+	if err != nil {
+		message := "This confirmation link is invalid or has expired. Please request the email change again from your account settings."
+		if err.Error() == "email already in use" {
+			message = "This email address is now used by another account, so it could not be applied."
+		} else if !strings.Contains(err.Error(), "not found") {
+			logger.Error("Failed to confirm email change", "error", err)
+			message = "There was an error changing your email address. Please try again or contact support."
+		}
+		c.HTML(http.StatusBadRequest, "activation_result.html", gin.H{
+			"Title":   "Email Change Failed - Carryless",
+			"Success": false,
+			"Message": message,
+		})
+		return
+	}
+
+	logger.Info("User email changed", "user_id", user.ID)
+
+	c.HTML(http.StatusOK, "activation_result.html", gin.H{
+		"Title":   "Email Changed - Carryless",
+		"Success": true,
+		"Message": "Your email address has been changed. Use it the next time you log in.",
+	})
+}
+
+// emailCategoryLabels names the categories of optional emails on the unsubscribe page.
+// The empty category stands for all of them.
+var emailCategoryLabels = map[string]string{
+	"":                                     "all optional emails",
+	models.EmailCategoryAdminNotifications: "new user notifications",
+	models.EmailCategoryProduct:            "news about Carryless",
+	models.EmailCategoryTripReminders:      "trip reminders",
+}
+
+// handleUpdateEmailPreferences sets which optional emails the user receives
+func handleUpdateEmailPreferences(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	prefs, err := database.GetEmailPreferences(db, userID)
+	if err != nil {
+		logger.Error("Failed to get email preferences", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=email_prefs_failed")
+		return
+	}
+
+	// Only admins get new user notifications, and only they see the setting
+	adminNotifications := prefs.AdminNotifications
+	if user.IsAdmin {
+		adminNotifications = c.PostForm("admin_notifications") == "true"
+	}
+
+	err = database.UpdateEmailPreferences(db, userID,
+		adminNotifications,
+		c.PostForm("product_emails") == "true",
+		c.PostForm("trip_reminders") == "true")
+	if err != nil {
+		logger.Error("Failed to update email preferences", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=email_prefs_failed")
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/account?success=email_prefs_updated")
+}
+
+// handleUnsubscribePage asks to confirm turning off the emails of an unsubscribe link
+func handleUnsubscribePage(c *gin.Context) {
+	user, _ := c.Get("user")
+	category := c.Query("category")
+
+	label, ok := emailCategoryLabels[category]
+	if !ok {
+		c.HTML(http.StatusBadRequest, "unsubscribe.html", gin.H{
+			"Title":   "Unsubscribe - Carryless",
+			"User":    user,
+			"Success": false,
+			"Message": "This unsubscribe link is invalid.",
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "unsubscribe.html", gin.H{
+		"Title":         "Unsubscribe - Carryless",
+		"User":          user,
+		"Token":         c.Param("token"),
+		"Category":      category,
+		"CategoryLabel": label,
+	})
+}
+
+// handleUnsubscribe turns off the emails of an unsubscribe link. The token in the link
+// identifies the user, no login is needed.
+func handleUnsubscribe(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user, _ := c.Get("user")
+	category := c.PostForm("category")
+
+	label, ok := emailCategoryLabels[category]
+	if !ok {
+		c.HTML(http.StatusBadRequest, "unsubscribe.html", gin.H{
+			"Title":   "Unsubscribe - Carryless",
+			"User":    user,
+			"Success": false,
+			"Message": "This unsubscribe link is invalid.",
+		})
+		return
+	}
+
+	if err := database.UnsubscribeByToken(db, c.Param("token"), category); err != nil {
+		message := "This unsubscribe link is invalid. You can change your email preferences from your account settings."
+		if !strings.Contains(err.Error(), "invalid") {
+			logger.Error("Failed to unsubscribe", "category", category, "error", err)
+			message = "There was an error updating your email preferences. Please try again or change them from your account settings."
+		}
+		c.HTML(http.StatusBadRequest, "unsubscribe.html", gin.H{
+			"Title":   "Unsubscribe - Carryless",
+			"User":    user,
+			"Success": false,
+			"Message": message,
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "unsubscribe.html", gin.H{
+		"Title":   "Unsubscribed - Carryless",
+		"User":    user,
+		"Success": true,
+		"Message": "You will no longer receive " + label + ".",
+	})
+}
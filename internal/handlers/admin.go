@@ -2,16 +2,23 @@ package handlers
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"carryless/internal/database"
 	"carryless/internal/email"
+	"carryless/internal/logger"
 	"carryless/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
+// adminUsersPageSize is the number of users listed per page in the admin panel
+const adminUsersPageSize = 50
+
 func handleAdminPanel(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 	user := c.MustGet("user").(*models.User)
@@ -23,8 +30,28 @@ func handleAdminPanel(c *gin.Context) {
 		return
 	}
 	
-	// Get all users with pack counts
-	users, err := database.GetAllUsersWithStats(db)
+	// Get the requested page of users matching the search
+	query := strings.TrimSpace(c.Query("q"))
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	matchingUsers, err := database.CountUsers(db, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users"})
+		return
+	}
+
+	totalPages := (matchingUsers + adminUsersPageSize - 1) / adminUsersPageSize
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+
+	users, err := database.SearchUsersWithStats(db, query, adminUsersPageSize, (page-1)*adminUsersPageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users"})
 		return
@@ -37,6 +64,17 @@ func handleAdminPanel(c *gin.Context) {
 		return
 	}
 	
+	inviteMode, err := database.IsInviteModeEnabled(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invite mode"})
+		return
+	}
+
+	invites, err := database.GetPendingInviteTokens(db)
+	if err != nil {
+		logger.Warn("Failed to load pending invites", "error", err)
+	}
+
 	// Generate CSRF token
 	csrfToken, err := database.CreateCSRFToken(db, user.ID)
 	if err != nil {
@@ -49,7 +87,15 @@ func handleAdminPanel(c *gin.Context) {
 		"User":                user,
 		"Stats":               stats,
 		"Users":               users,
+		"Query":               query,
+		"MatchingUsers":       matchingUsers,
+		"Page":                page,
+		"TotalPages":          totalPages,
+		"HasPrev":             page > 1,
+		"HasNext":             page < totalPages,
 		"RegistrationEnabled": registrationEnabled,
+		"InviteMode":          inviteMode,
+		"Invites":             invites,
 		"CSRFToken":           csrfToken.Token,
 	})
 }
@@ -77,6 +123,15 @@ func handleToggleUserAdmin(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle admin status"})
 		return
 	}
+
+	detail := ""
+	if target, err := database.GetUserByID(db, userID); err == nil {
+		detail = target.Username + ": revoked admin"
+		if target.IsAdmin {
+			detail = target.Username + ": granted admin"
+		}
+	}
+	recordAdminAction(db, user.ID, database.AuditActionToggleAdmin, strconv.Itoa(userID), detail)
 	
 	c.JSON(http.StatusOK, gin.H{"message": "User admin status toggled successfully"})
 }
@@ -99,29 +154,266 @@ func handleBanUser(c *gin.Context) {
 		return
 	}
 	
+	// Read the username before it is deleted along with the account
+	detail := auditUserDetail(db, userID, "account deleted")
+
 	err = database.BanUser(db, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban user"})
 		return
 	}
+
+	recordAdminAction(db, user.ID, database.AuditActionBanUser, strconv.Itoa(userID), detail)
 	
 	c.JSON(http.StatusOK, gin.H{"message": "User banned successfully"})
 }
 
+func handleSuspendUser(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Prevent admin from suspending themselves
+	if userID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot suspend yourself"})
+		return
+	}
+
+	err = database.SuspendUser(db, userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend user"})
+		}
+		return
+	}
+
+	recordAdminAction(db, user.ID, database.AuditActionSuspendUser, strconv.Itoa(userID), auditUserDetail(db, userID, "suspended"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "User suspended successfully"})
+}
+
+func handleUnsuspendUser(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	err = database.UnsuspendUser(db, userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsuspend user"})
+		}
+		return
+	}
+
+	recordAdminAction(db, user.ID, database.AuditActionUnsuspendUser, strconv.Itoa(userID), auditUserDetail(db, userID, "unsuspended"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unsuspended successfully"})
+}
+
+func handleAdminDeletePack(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+	packID := c.Param("id")
+
+	// Keep the pack name for the audit log before it is gone
+	detail := ""
+	if pack, err := database.GetPack(db, packID); err == nil {
+		detail = fmt.Sprintf("%q owned by user %d", pack.Name, pack.UserID)
+	}
+
+	err := database.AdminDeletePack(db, packID)
+	if err != nil {
+		if err.Error() == "pack not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+		} else {
+			logger.Error("Failed to delete pack as admin", "admin_user_id", user.ID, "pack_id", packID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pack"})
+		}
+		return
+	}
+
+	logger.Info("Pack deleted by admin", "admin_user_id", user.ID, "pack_id", packID)
+	recordAdminAction(db, user.ID, database.AuditActionDeletePack, packID, detail)
+	c.JSON(http.StatusOK, gin.H{"message": "Pack deleted successfully"})
+}
+
 func handleToggleRegistration(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
 	
 	err := database.ToggleRegistration(db)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle registration"})
 		return
 	}
+
+	detail := "registration disabled"
+	if enabled, err := database.IsRegistrationEnabled(db); err == nil && enabled {
+		detail = "registration enabled"
+	}
+	recordAdminAction(db, user.ID, database.AuditActionToggleRegistration, "", detail)
 	
 	c.JSON(http.StatusOK, gin.H{"message": "Registration setting toggled successfully"})
 }
 
+// handleAdminCreateUser creates an account for someone, whether or not public registration is
+// open. The account gets a temporary password, shown once to the admin, and the activation
+// email is sent to its owner.
+func handleAdminCreateUser(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	admin := c.MustGet("user").(*models.User)
+	emailService := c.MustGet("email_service").(*email.Service)
+
+	var req struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	username := strings.TrimSpace(req.Username)
+	userEmail := strings.TrimSpace(req.Email)
+	if len(username) < 3 || len(username) > 30 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must be between 3 and 30 characters"})
+		return
+	}
+	if !emailRegex.MatchString(userEmail) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please enter a valid email address"})
+		return
+	}
+
+	password, err := database.GenerateTemporaryPassword()
+	if err != nil {
+		logger.Error("Failed to generate temporary password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	newUser, err := database.CreateUser(db, username, userEmail, password)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A user with this username or email already exists"})
+			return
+		}
+		logger.Error("Failed to create user", "admin_id", admin.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	activationToken, err := database.CreateActivationToken(db, newUser.ID)
+	if err != nil {
+		logger.Error("Failed to create activation token", "user_id", newUser.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User created, but the activation token could not be generated. Resend the activation email from the users list."})
+		return
+	}
+
+	recordAdminAction(db, admin.ID, database.AuditActionCreateUser, strconv.Itoa(newUser.ID), newUser.Username)
+
+	message := "User created. An activation email is being sent."
+	emailSent := emailService.IsEnabled()
+	if emailSent {
+		go func() {
+			if err := emailService.SendWelcomeEmail(newUser, activationToken.Token); err != nil {
+				logger.Warn("Failed to send activation email to created user", "user_id", newUser.ID, "error", err)
+			}
+		}()
+	} else {
+		message = "User created. Email is not configured, activate the account from the users list."
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":            message,
+		"user_id":            newUser.ID,
+		"username":           newUser.Username,
+		"temporary_password": password,
+		"email_sent":         emailSent,
+	})
+}
+
+// handleToggleInviteMode switches between open registration and registration by invite only
+func handleToggleInviteMode(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	if err := database.ToggleInviteMode(db); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to toggle invite mode"})
+		return
+	}
+
+	detail := "invite mode disabled"
+	if enabled, err := database.IsInviteModeEnabled(db); err == nil && enabled {
+		detail = "invite mode enabled"
+	}
+	recordAdminAction(db, user.ID, database.AuditActionToggleInviteMode, "", detail)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite mode toggled successfully"})
+}
+
+// inviteURL is the registration link of an invite, relative to the site
+func inviteURL(token string) string {
+	return "/register?invite=" + url.QueryEscape(token)
+}
+
+// handleCreateInvite issues a single-use registration link
+func handleCreateInvite(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	invite, err := database.CreateInviteToken(db, user.ID)
+	if err != nil {
+		logger.Error("Failed to create invite", "admin_id", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	recordAdminAction(db, user.ID, database.AuditActionCreateInvite, "", "expires "+invite.ExpiresAt.Format("2006-01-02"))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "Invite link created",
+		"token":      invite.Token,
+		"url":        inviteURL(invite.Token),
+		"expires_at": invite.ExpiresAt,
+	})
+}
+
+// handleRevokeInvite deletes an invite link nobody used yet
+func handleRevokeInvite(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	if err := database.RevokeInviteToken(db, c.Param("token")); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+
+	recordAdminAction(db, user.ID, database.AuditActionRevokeInvite, "", "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
 func handleToggleUserActivation(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
 	
 	// Get user ID from URL parameter
 	userIDStr := c.Param("id")
@@ -140,6 +432,15 @@ func handleToggleUserActivation(c *gin.Context) {
 		}
 		return
 	}
+
+	detail := ""
+	if target, err := database.GetUserByID(db, userID); err == nil {
+		detail = target.Username + ": deactivated"
+		if target.IsActivated {
+			detail = target.Username + ": activated"
+		}
+	}
+	recordAdminAction(db, user.ID, database.AuditActionToggleActivation, strconv.Itoa(userID), detail)
 	
 	c.JSON(http.StatusOK, gin.H{"message": "User activation status toggled successfully"})
 }
@@ -186,12 +487,72 @@ func handleResendActivationEmail(c *gin.Context) {
 		return
 	}
 
-	// Send the activation email
-	err = emailService.SendWelcomeEmail(targetUser, activationToken.Token)
+	// Send the activation email in the background, it is retried on transient failures
+	go func() {
+		if err := emailService.SendWelcomeEmail(targetUser, activationToken.Token); err != nil {
+			logger.Warn("Failed to resend activation email", "user_id", targetUser.ID, "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Activation email is being resent"})
+}
+
+// auditLogPageSize is the number of events listed per page of the audit log
+const auditLogPageSize = 50
+
+func handleAdminAuditLog(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	total, err := database.CountAuditEvents(db)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send activation email"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit log"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Activation email resent successfully"})
-}
\ No newline at end of file
+	totalPages := (total + auditLogPageSize - 1) / auditLogPageSize
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+
+	events, err := database.GetAuditEvents(db, auditLogPageSize, (page-1)*auditLogPageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit log"})
+		return
+	}
+
+	c.HTML(http.StatusOK, "admin_audit.html", gin.H{
+		"Title":      "Audit Log - Carryless",
+		"User":       user,
+		"Events":     events,
+		"Page":       page,
+		"TotalPages": totalPages,
+		"HasPrev":    page > 1,
+		"HasNext":    page < totalPages,
+	})
+}
+
+// recordAdminAction adds an event to the audit log. The action has already happened,
+// so a failure is logged rather than reported to the admin.
+func recordAdminAction(db *sql.DB, actorID int, action, targetID, detail string) {
+	if err := database.RecordAuditEvent(db, actorID, action, targetID, detail); err != nil {
+		logger.Error("Failed to record audit event", "admin_user_id", actorID, "action", action, "error", err)
+	}
+}
+
+// auditUserDetail prefixes an audit detail with the target's username when it can be found
+func auditUserDetail(db *sql.DB, userID int, detail string) string {
+	target, err := database.GetUserByID(db, userID)
+	if err != nil {
+		return detail
+	}
+	return target.Username + ": " + detail
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"carryless/internal/database"
+	"carryless/internal/logger"
+	"carryless/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleAPIListPacks returns the user's packs, followed by the packs shared with them
+func handleAPIListPacks(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	packs, err := database.GetPacksIncludingShared(db, userID)
+	if err != nil {
+		logger.Error("Failed to list packs for API", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load packs"})
+		return
+	}
+	if packs == nil {
+		packs = []models.Pack{}
+	}
+
+	c.JSON(http.StatusOK, packs)
+}
+
+// handleAPIGetPack returns a pack with its items and labels
+func handleAPIGetPack(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	pack, err := database.GetPackWithItems(db, c.Param("id"))
+	if err != nil {
+		apiPackError(c, err, "load pack")
+		return
+	}
+	if !userCanEditPack(db, pack, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pack)
+}
+
+// handleAPIAddPackItem adds one of an inventory item to a pack, from a JSON body like
+// {"item_id": 12}. Linked items come along unless include_linked is false.
+func handleAPIAddPackItem(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	var req struct {
+		ItemID        int   `json:"item_id"`
+		IncludeLinked *bool `json:"include_linked"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ItemID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	includeLinked := true
+	if req.IncludeLinked != nil {
+		includeLinked = *req.IncludeLinked
+	}
+
+	if err := database.AddItemToPack(db, packID, req.ItemID, userID, includeLinked); err != nil {
+		apiPackError(c, err, "add item to pack")
+		return
+	}
+
+	apiPackResponse(c, db, packID)
+}
+
+// handleAPIRemovePackItem removes one of an item from a pack, and the item itself once
+// none are left
+func handleAPIRemovePackItem(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	itemID, err := strconv.Atoi(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	if err := database.RemoveItemFromPack(db, packID, itemID, userID); err != nil {
+		apiPackError(c, err, "remove item from pack")
+		return
+	}
+
+	apiPackResponse(c, db, packID)
+}
+
+// handleAPISetPackItemWorn sets how many of an item in a pack are worn, from a JSON body like
+// {"worn_count": 2}. {"is_worn": true} is still accepted for items packed once.
+func handleAPISetPackItemWorn(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	itemID, err := strconv.Atoi(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var req struct {
+		WornCount *int  `json:"worn_count"`
+		IsWorn    *bool `json:"is_worn"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.WornCount == nil && req.IsWorn == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if req.WornCount != nil {
+		err = database.SetPackItemWornCount(db, packID, itemID, userID, *req.WornCount)
+	} else {
+		err = database.TogglePackItemWorn(db, packID, itemID, userID, *req.IsWorn)
+	}
+	if err != nil {
+		apiPackError(c, err, "update worn status")
+		return
+	}
+
+	apiPackResponse(c, db, packID)
+}
+
+// apiPackResponse answers a successful change with the pack as it is now
+func apiPackResponse(c *gin.Context, db *sql.DB, packID string) {
+	pack, err := database.GetPackWithItems(db, packID)
+	if err != nil {
+		apiPackError(c, err, "load pack")
+		return
+	}
+	c.JSON(http.StatusOK, pack)
+}
+
+// apiPackError maps database errors to JSON error responses
+func apiPackError(c *gin.Context, err error, action string) {
+	switch {
+	case strings.Contains(err.Error(), "unauthorized"):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pack or item not found"})
+	case strings.Contains(err.Error(), "invalid worn count"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Worn count must be between 0 and the item count"})
+	default:
+		logger.Error("Failed to "+action, "user_id", c.MustGet("user_id"), "pack_id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
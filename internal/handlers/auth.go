@@ -17,24 +17,58 @@ import (
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 
+// registrationClosedReason tells why someone can't register, or returns an empty string when
+// they can. A valid invite lets them in even while registration is closed; without one,
+// registration must be open and not restricted to invites.
+func registrationClosedReason(db *sql.DB, invite string) (string, error) {
+	if invite != "" {
+		if err := database.ValidateInviteToken(db, invite); err != nil {
+			if strings.Contains(err.Error(), "invalid invite") {
+				return "This invite link is invalid, has expired or was already used.", nil
+			}
+			return "", err
+		}
+		return "", nil
+	}
+
+	registrationEnabled, err := database.IsRegistrationEnabled(db)
+	if err != nil {
+		return "", err
+	}
+	if !registrationEnabled {
+		return "Registration has been disabled by an administrator.", nil
+	}
+
+	inviteMode, err := database.IsInviteModeEnabled(db)
+	if err != nil {
+		return "", err
+	}
+	if inviteMode {
+		return "Registration is by invitation only. Ask a site administrator for an invite link.", nil
+	}
+
+	return "", nil
+}
+
 func handleRegister(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
-	
-	// Check if registration is enabled
-	registrationEnabled, err := database.IsRegistrationEnabled(db)
+	invite := c.PostForm("invite")
+
+	closedReason, err := registrationClosedReason(db, invite)
 	if err != nil {
+		logger.Error("Failed to check registration status", "error", err)
 		c.HTML(http.StatusInternalServerError, "register.html", gin.H{
 			"Title": "Register - Carryless",
 			"Error": "Unable to check registration status",
 		})
 		return
 	}
-	
-	if !registrationEnabled {
+
+	if closedReason != "" {
 		c.HTML(http.StatusForbidden, "register.html", gin.H{
 			"Title":               "Register - Carryless",
 			"RegistrationEnabled": false,
-			"Error":               "Registration has been disabled by an administrator",
+			"ClosedReason":        closedReason,
 		})
 		return
 	}
@@ -68,13 +102,28 @@ func handleRegister(c *gin.Context) {
 			"Errors":              errors,
 			"Username":            username,
 			"Email":               email,
+			"Invite":              invite,
 			"RegistrationEnabled": true,
 		})
 		return
 	}
 
-	user, err := database.CreateUser(db, username, email, password)
+	var user *models.User
+	if invite != "" {
+		user, err = database.CreateUserWithInvite(db, username, email, password, invite)
+	} else {
+		user, err = database.CreateUser(db, username, email, password)
+	}
 	if err != nil {
+		if strings.Contains(err.Error(), "invalid invite") {
+			// Someone else registered with the invite in the meantime
+			c.HTML(http.StatusForbidden, "register.html", gin.H{
+				"Title":               "Register - Carryless",
+				"RegistrationEnabled": false,
+				"ClosedReason":        "This invite link is invalid, has expired or was already used.",
+			})
+			return
+		}
 		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
 			errors["general"] = "An account with those credentials already exists"
 		} else {
@@ -86,6 +135,7 @@ func handleRegister(c *gin.Context) {
 			"Errors":              errors,
 			"Username":            "",
 			"Email":               "",
+			"Invite":              invite,
 			"RegistrationEnabled": true,
 		})
 		return
@@ -108,12 +158,15 @@ func handleRegister(c *gin.Context) {
 
 	emailSvc, _ := c.Get("email_service")
 	if service, ok := emailSvc.(*emailService.Service); ok && service.IsEnabled() {
-		if err := service.SendWelcomeEmail(user, activationToken.Token); err != nil {
-			logger.Warn("Failed to send welcome email",
-				"email", user.Email,
-				"user_id", user.ID,
-				"error", err)
-		}
+		// Sending retries on transient failures, don't hold the response for it
+		go func() {
+			if err := service.SendWelcomeEmail(user, activationToken.Token); err != nil {
+				logger.Warn("Failed to send welcome email",
+					"email", user.Email,
+					"user_id", user.ID,
+					"error", err)
+			}
+		}()
 		
 		// Send notification to all admins about the new user registration
 		admins, err := database.GetAllAdmins(db)
@@ -126,8 +179,14 @@ func handleRegister(c *gin.Context) {
 					"admin_email", admin.Email,
 					"admin_id", admin.ID,
 					"new_user_id", user.ID)
+				prefs, err := database.GetEmailPreferences(db, admin.ID)
+				if err != nil {
+					// Without the preferences the admin may have opted out, don't send
+					logger.Error("Failed to get admin email preferences", "admin_id", admin.ID, "error", err)
+					continue
+				}
 				go func(adminUser models.User) {
-					if err := service.SendAdminNotificationEmail(&adminUser, user); err != nil {
+					if err := service.SendAdminNotificationEmail(&adminUser, user, prefs); err != nil {
 						logger.Warn("Failed to send admin notification email",
 							"admin_email", adminUser.Email,
 							"admin_id", adminUser.ID,
@@ -186,9 +245,55 @@ func handleLogin(c *gin.Context) {
 		return
 	}
 
-	cfg := c.MustGet("config").(*config.Config)
-	session, err := database.CreateSession(db, user.ID, cfg.SessionDuration)
+	if user.IsSuspended {
+		errors["general"] = "This account has been suspended. Please contact support."
+		c.HTML(http.StatusForbidden, "login.html", gin.H{
+			"Title":  "Login - Carryless",
+			"Errors": errors,
+			"Email":  email,
+		})
+		return
+	}
+
+	rememberMe := c.PostForm("remember_me") == "true"
+
+	totpEnabled, err := database.IsTOTPEnabled(db, user.ID)
 	if err != nil {
+		logger.Error("Failed to check two-factor authentication", "user_id", user.ID, "error", err)
+		c.HTML(http.StatusInternalServerError, "login.html", gin.H{
+			"Title":  "Login - Carryless",
+			"Errors": map[string]string{"general": "Failed to log in. Please try again."},
+			"Email":  email,
+		})
+		return
+	}
+
+	// The password was right, the session is only created once the second factor is checked
+	if totpEnabled {
+		challenge, err := database.CreateLoginChallenge(db, user.ID, rememberMe)
+		if err != nil {
+			logger.Error("Failed to create login challenge", "user_id", user.ID, "error", err)
+			c.HTML(http.StatusInternalServerError, "login.html", gin.H{
+				"Title":  "Login - Carryless",
+				"Errors": map[string]string{"general": "Failed to log in. Please try again."},
+				"Email":  email,
+			})
+			return
+		}
+
+		c.HTML(http.StatusOK, "login_totp.html", gin.H{
+			"Title":     "Two-Factor Authentication - Carryless",
+			"Challenge": challenge.Token,
+		})
+		return
+	}
+
+	startSession(c, db, user, rememberMe)
+}
+
+// startSession logs the user in and sends them to their dashboard
+func startSession(c *gin.Context, db *sql.DB, user *models.User, rememberMe bool) {
+	if err := setSessionCookie(c, db, user, rememberMe); err != nil {
 		c.HTML(http.StatusInternalServerError, "login.html", gin.H{
 			"Title":  "Login - Carryless",
 			"Errors": map[string]string{"general": "Failed to create session. Please try again."},
@@ -196,11 +301,28 @@ func handleLogin(c *gin.Context) {
 		return
 	}
 
+	c.Redirect(http.StatusFound, "/dashboard")
+}
+
+// setSessionCookie creates a session for the user and hands it to the browser
+func setSessionCookie(c *gin.Context, db *sql.DB, user *models.User, rememberMe bool) error {
+	cfg := c.MustGet("config").(*config.Config)
+	sessionDuration := cfg.SessionDuration
+	if rememberMe {
+		sessionDuration = cfg.RememberMeDuration
+	}
+
+	session, err := database.CreateSession(db, user.ID, sessionDuration)
+	if err != nil {
+		return err
+	}
+
 	c.SetSameSite(http.SameSiteStrictMode)
 	// Set cookie expiry to match session duration
-	cookieMaxAge := int(cfg.SessionDuration.Seconds())
+	cookieMaxAge := int(sessionDuration.Seconds())
 	c.SetCookie("session_id", session.ID, cookieMaxAge, "/", "", true, true)
-	c.Redirect(http.StatusFound, "/dashboard")
+	setWeightUnitCookie(c, weightUnitFor(user))
+	return nil
 }
 
 func handleLogout(c *gin.Context) {
@@ -247,10 +369,14 @@ func handleActivate(c *gin.Context) {
 		logger.Warn("Failed to validate activation token",
 			"token", token,
 			"error", err)
+		message := "This activation link is invalid. Please check the link in your email or request a new one from the login page."
+		if err.Error() == "activation token expired" {
+			message = "This activation link has expired. Please request a new one from the login page."
+		}
 		c.HTML(http.StatusBadRequest, "activation_result.html", gin.H{
 			"Title":   "Activation Failed - Carryless",
 			"Success": false,
-			"Message": "This activation link is invalid or has expired. Please register again or contact support.",
+			"Message": message,
 		})
 		return
 	}
@@ -291,4 +417,47 @@ func handleActivate(c *gin.Context) {
 		"Message": "Congratulations! Your account has been successfully activated. You can now log in and start using all features of Carryless.",
 		"ShowLoginButton": true,
 	})
-}
\ No newline at end of file
+}
+
+// resendActivationMessage is shown whatever the outcome so the form can't be used to find registered emails
+const resendActivationMessage = "If an account with this email is waiting for activation, a new activation link is on its way."
+
+// handleResendActivation sends a fresh activation link to an account that hasn't been activated yet
+func handleResendActivation(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	email := strings.TrimSpace(c.PostForm("email"))
+
+	if !emailRegex.MatchString(email) {
+		c.HTML(http.StatusBadRequest, "login.html", gin.H{
+			"Title":  "Login - Carryless",
+			"Email":  email,
+			"Errors": map[string]string{"email": "Please enter a valid email address"},
+		})
+		return
+	}
+
+	user, err := database.GetUserByEmail(db, email)
+	if err == nil && !user.IsActivated && !user.IsSuspended {
+		emailSvc, _ := c.Get("email_service")
+		if service, ok := emailSvc.(*emailService.Service); ok && service.IsEnabled() {
+			token, err := database.CreateActivationToken(db, user.ID)
+			if err != nil {
+				logger.Error("Failed to create activation token", "user_id", user.ID, "error", err)
+			} else {
+				go func() {
+					if err := service.SendWelcomeEmail(user, token.Token); err != nil {
+						logger.Warn("Failed to resend activation email", "user_id", user.ID, "error", err)
+					}
+				}()
+			}
+		}
+	} else if err != nil && err.Error() != "user not found" {
+		logger.Error("Failed to look up user for activation resend", "error", err)
+	}
+
+	c.HTML(http.StatusOK, "login.html", gin.H{
+		"Title":   "Login - Carryless",
+		"Email":   email,
+		"Success": resendActivationMessage,
+	})
+}
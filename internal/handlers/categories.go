@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"carryless/internal/database"
+	"carryless/internal/logger"
 
 	"github.com/gin-gonic/gin"
 )
@@ -153,6 +154,34 @@ func handleUpdateCategory(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/categories")
 }
 
+// handleReorderCategories saves the order the user dragged their categories into
+func handleReorderCategories(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	var req struct {
+		CategoryIDs []int `json:"category_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	err := database.ReorderCategories(db, userID, req.CategoryIDs)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+			return
+		}
+		logger.Error("Failed to reorder categories", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
 func handleNewCategoryPage(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -8,28 +8,41 @@ import (
 	"carryless/internal/database"
 	"carryless/internal/email"
 	"carryless/internal/logger"
+	"carryless/internal/metrics"
 	"carryless/internal/middleware"
+	"carryless/internal/oauth"
+	"carryless/internal/weather"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *config.Config) {
+func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, weatherService *weather.Service, oauthService *oauth.Service, cfg *config.Config) {
 	r.Use(middleware.LogRequests())
 	r.Use(middleware.SecurityHeaders(cfg))
 	r.Use(middleware.AddDBContext(db))
 	r.Use(addEmailServiceContext(emailService))
+	r.Use(addWeatherServiceContext(weatherService))
+	r.Use(addOAuthServiceContext(oauthService))
 	r.Use(addConfigContext(cfg))
 	r.Use(middleware.TrimSpaces())
 
 	r.GET("/", middleware.AuthOptional(db, cfg), handleHome)
 	r.GET("/terms", middleware.AuthOptional(db, cfg), handleTermsPage)
 	r.GET("/privacy", middleware.AuthOptional(db, cfg), handlePrivacyPage)
+	r.GET("/metrics", metrics.Handler(cfg.MetricsToken))
 	r.GET("/register", handleRegisterPage)
 	r.POST("/register", middleware.AuthRateLimit(cfg), handleRegister)
 	r.GET("/login", handleLoginPage)
 	r.POST("/login", middleware.AuthRateLimit(cfg), handleLogin)
+	r.POST("/login/2fa", middleware.AuthRateLimit(cfg), handleLoginTOTP)
+	r.GET("/auth/oauth/:provider", middleware.AuthRateLimit(cfg), handleOAuthLogin)
+	r.GET("/auth/oauth/:provider/callback", middleware.AuthRateLimit(cfg), handleOAuthCallback)
 	r.POST("/logout", middleware.AuthRequired(db, cfg), handleLogout)
 	r.GET("/activate/:token", middleware.ActivationRateLimit(cfg), middleware.AddDBContext(db), handleActivate)
+	r.POST("/resend-activation", middleware.ActivationRateLimit(cfg), handleResendActivation)
+	r.GET("/account/email/confirm/:token", middleware.ActivationRateLimit(cfg), handleConfirmEmailChange)
+	r.GET("/unsubscribe/:token", middleware.ActivationRateLimit(cfg), middleware.AuthOptional(db, cfg), handleUnsubscribePage)
+	r.POST("/unsubscribe/:token", middleware.ActivationRateLimit(cfg), middleware.AuthOptional(db, cfg), handleUnsubscribe)
 
 	protected := r.Group("/")
 	protected.Use(middleware.AuthRequired(db, cfg))
@@ -37,9 +50,20 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 	{
 		protected.GET("/dashboard", handleDashboard)
 		protected.GET("/account", handleAccountPage)
+		protected.GET("/account/export", handleExportAccountData)
 		protected.POST("/account/password", handleChangePassword)
 		protected.POST("/account/currency", handleChangeCurrency)
+		protected.POST("/account/weight-unit", handleChangeWeightUnit)
 		protected.POST("/account/username", handleChangeUsername)
+		protected.POST("/account/email", handleRequestEmailChange)
+		protected.POST("/account/email-preferences", handleUpdateEmailPreferences)
+		protected.POST("/account/sessions/:id/delete", handleDeleteSession)
+		protected.POST("/account/api-tokens", handleCreateAPIToken)
+		protected.POST("/account/api-tokens/:id/delete", handleDeleteAPIToken)
+		protected.POST("/account/2fa/setup", middleware.AuthRateLimit(cfg), handleSetupTOTP)
+		protected.POST("/account/2fa/enable", middleware.AuthRateLimit(cfg), handleEnableTOTP)
+		protected.POST("/account/2fa/disable", middleware.AuthRateLimit(cfg), handleDisableTOTP)
+		protected.POST("/account/2fa/recovery-codes", middleware.AuthRateLimit(cfg), handleRegenerateRecoveryCodes)
 		protected.GET("/api/csrf-token", handleCSRFToken)
 	}
 
@@ -49,18 +73,29 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 	activated.Use(middleware.RequireActivation())
 	activated.Use(middleware.CSRF(cfg))
 	{
+		activated.GET("/search", handleSearch)
 		activated.GET("/inventory", handleInventory)
+		activated.GET("/inventory/stats", handleInventoryStats)
+		activated.GET("/inventory/summary", handleInventorySummary)
 		activated.GET("/inventory/export", handleExportInventory)
 		activated.POST("/inventory/import", handleImportInventory)
+		activated.POST("/inventory/import/json", handleImportInventoryJSON)
+		activated.GET("/inventory/trash", handleInventoryTrash)
+		activated.GET("/inventory/to-verify", handleItemsToVerify)
+		activated.POST("/inventory/to-verify", handleMarkItemsVerified)
 		activated.GET("/inventory/items/new", handleNewItemPage)
 		activated.POST("/inventory/items", handleCreateItem)
 		activated.GET("/inventory/items/:id/edit", handleEditItemPage)
 		activated.POST("/inventory/items/:id", handleUpdateItem)
 		activated.GET("/inventory/items/:id/packs", handleCheckItemPacks)
 		activated.POST("/inventory/items/:id/delete", handleDeleteItem)
+		activated.POST("/inventory/items/:id/restore", handleRestoreItem)
+		activated.POST("/inventory/items/:id/image", handleUploadItemImage)
+		activated.DELETE("/inventory/items/:id/image", handleDeleteItemImage)
 		activated.POST("/inventory/items/:id/duplicate", handleDuplicateItem)
 		activated.POST("/inventory/items/bulk-edit", handleBulkEditItems)
 		activated.POST("/inventory/items/bulk-delete", handleBulkDeleteItems)
+		activated.POST("/inventory/items/bulk-category", handleBulkUpdateItemCategory)
 		activated.PATCH("/api/items/:id", handlePatchItem)
 
 		// Item links API
@@ -71,6 +106,7 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 		activated.GET("/categories", handleCategories)
 		activated.GET("/categories/new", handleNewCategoryPage)
 		activated.POST("/categories", handleCreateCategory)
+		activated.POST("/categories/reorder", handleReorderCategories)
 		activated.GET("/categories/:id/edit", handleEditCategoryPage)
 		activated.POST("/categories/:id", handleUpdateCategory)
 		activated.GET("/categories/:id/items", handleCheckCategoryItems)
@@ -78,17 +114,37 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 
 		activated.GET("/packs", handlePacks)
 		activated.GET("/packs/new", handleNewPackPage)
+		activated.GET("/packs/compare", handleComparePacks)
+		activated.GET("/packs/export-all", handleExportAllPacks)
 		activated.POST("/packs", handleCreatePack)
+		activated.POST("/packs/from-template", handleCreatePackFromTemplate)
+		activated.POST("/packs/import", handleImportPack)
 		activated.GET("/packs/:id", handlePackDetail)
+		activated.GET("/packs/:id/breakdown.json", handlePackBreakdown)
 		activated.GET("/packs/:id/edit", handleEditPackPage)
 		activated.POST("/packs/:id", handleUpdatePack)
 		activated.POST("/packs/:id/delete", handleDeletePack)
+		activated.POST("/packs/:id/rotate-link", handleRotatePackLink)
 		activated.POST("/packs/:id/duplicate", handleDuplicatePack)
+		activated.POST("/p/:id/copy", handleCopyPublicPack)
+		activated.GET("/packs/:id/export.pdf", handleExportPackPDF)
+		activated.GET("/packs/:id/export.json", handleExportPackJSON)
+		activated.GET("/packs/:id/export.csv", handleExportPackCSV)
+		activated.POST("/packs/:id/import.csv", handleImportPackCSV)
 		activated.POST("/packs/:id/items", handleAddItemToPack)
+		activated.POST("/packs/:id/items/reorder", handleReorderPackItems)
+		activated.POST("/packs/:id/items/bulk", handleAddItemsToPack)
 		activated.DELETE("/packs/:id/items/:item_id", handleRemoveItemFromPack)
+		activated.PUT("/packs/:id/items/:item_id/count", handleSetPackItemCount)
 		activated.PUT("/packs/:id/items/:item_id/worn", handleToggleWorn)
 		activated.PUT("/packs/:id/items/:item_id/worn-count", handleUpdateWornCount)
+		activated.PUT("/packs/:id/items/:item_id/consumable", handleToggleConsumable)
+		activated.PUT("/packs/:id/items/:item_id/note", handleUpdatePackItemNote)
 		activated.POST("/packs/:id/lock", handleTogglePackLock)
+		activated.POST("/packs/:id/template", handleSetPackTemplate)
+		activated.POST("/packs/:id/favorite", handleTogglePackFavorite)
+		activated.POST("/packs/:id/collaborators", handleAddPackCollaborator)
+		activated.POST("/packs/:id/collaborators/:user_id/delete", handleRemovePackCollaborator)
 
 		activated.POST("/packs/:id/labels", handleCreatePackLabel)
 		activated.POST("/packs/:id/labels/:label_id", handleUpdatePackLabel)
@@ -97,6 +153,7 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 		activated.DELETE("/packs/:id/items/:item_id/labels/:label_id", handleRemoveLabelFromItem)
 
 		// User pack labels (pack-level labels shared across user's packs)
+		activated.GET("/pack-labels", handleGetUserPackLabels)
 		activated.POST("/pack-labels", handleCreateUserPackLabel)
 		activated.POST("/pack-labels/:label_id", handleUpdateUserPackLabel)
 		activated.DELETE("/pack-labels/:label_id", handleDeleteUserPackLabel)
@@ -114,6 +171,7 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 		activated.POST("/trips/:id", handleUpdateTrip)
 		activated.POST("/trips/:id/delete", handleDeleteTrip)
 		activated.POST("/trips/:id/archive", handleArchiveTrip)
+		activated.POST("/trips/:id/duplicate", handleDuplicateTrip)
 
 		// Pack associations
 		activated.POST("/trips/:id/packs", handleAddPackToTrip)
@@ -125,6 +183,7 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 		activated.DELETE("/trips/:id/checklist/:item_id", handleDeleteChecklistItem)
 		activated.POST("/trips/:id/checklist/:item_id/toggle", handleToggleChecklistItem)
 		activated.POST("/trips/:id/checklist/reorder", handleReorderChecklist)
+		activated.POST("/trips/:id/checklist/generate", handleGenerateChecklist)
 
 		// Transport timeline API
 		activated.POST("/trips/:id/transport", handleAddTransportStep)
@@ -134,8 +193,12 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 
 		// GPX upload
 		activated.POST("/trips/:id/gpx", handleUploadGPX)
-		activated.DELETE("/trips/:id/gpx", handleDeleteGPX)
+		activated.DELETE("/trips/:id/gpx/:file_id", handleDeleteGPX)
+		activated.GET("/trips/:id/gpx/:file_id/download", handleDownloadGPX)
 		activated.GET("/trips/:id/gpx/download", handleDownloadGPX)
+		activated.GET("/trips/:id/export.ics", handleExportTripICS)
+		activated.POST("/trips/:id/share", middleware.ShareRateLimit(cfg), handleShareTrip)
+		activated.GET("/trips/:id/weather", handleTripWeather)
 	}
 
 	// Autosave routes that need new CSRF tokens returned after each request
@@ -148,27 +211,53 @@ func SetupRoutes(r *gin.Engine, db *sql.DB, emailService *email.Service, cfg *co
 		autosave.POST("/trips/:id/notes", handleUpdateTripNotes)
 	}
 
+	// JSON API for clients other than the web pages
+	api := r.Group("/api/v1")
+	api.Use(middleware.APIAuth(db, cfg))
+	api.Use(middleware.CSRF(cfg))
+	{
+		api.GET("/packs", handleAPIListPacks)
+		api.GET("/packs/:id", handleAPIGetPack)
+		api.POST("/packs/:id/items", handleAPIAddPackItem)
+		api.DELETE("/packs/:id/items/:item_id", handleAPIRemovePackItem)
+		api.PUT("/packs/:id/items/:item_id/worn", handleAPISetPackItemWorn)
+	}
+
 	// Admin routes
 	admin := r.Group("/admin")
 	admin.Use(middleware.AdminRequired(db, cfg))
 	admin.Use(middleware.CSRF(cfg))
 	{
 		admin.GET("/", handleAdminPanel)
+		admin.GET("/audit", handleAdminAuditLog)
+		admin.POST("/users", handleAdminCreateUser)
 		admin.POST("/users/:id/toggle-admin", handleToggleUserAdmin)
 		admin.POST("/users/:id/toggle-activation", handleToggleUserActivation)
 		admin.POST("/users/:id/resend-activation", handleResendActivationEmail)
+		admin.POST("/users/:id/suspend", handleSuspendUser)
+		admin.POST("/users/:id/unsuspend", handleUnsuspendUser)
 		admin.POST("/users/:id/ban", handleBanUser)
+		admin.POST("/packs/:id/delete", handleAdminDeletePack)
 		admin.POST("/toggle-registration", handleToggleRegistration)
+		admin.POST("/toggle-invite-mode", handleToggleInviteMode)
+		admin.POST("/invites", handleCreateInvite)
+		admin.POST("/invites/:token/delete", handleRevokeInvite)
 	}
 
+	r.GET("/explore", middleware.AuthOptional(db, cfg), handleExplorePacks)
 	r.GET("/p/:id", middleware.AuthOptional(db, cfg), handlePublicPackByShortID)
 	r.GET("/p/:id/checklist", middleware.AuthOptional(db, cfg), handlePackChecklistByShortID)
+	r.GET("/p/:id/breakdown.json", handlePublicPackBreakdown)
+	r.GET("/p/:id/export.pdf", middleware.AuthOptional(db, cfg), handlePublicExportPackPDF)
+	r.POST("/p/:id/like", middleware.AuthOptional(db, cfg), middleware.CSRF(cfg), handleLikePack)
 	r.GET("/p/packs/:id", middleware.AuthOptional(db, cfg), handlePublicPack)
 	r.GET("/packs/:id/checklist", middleware.AuthOptional(db, cfg), handlePackChecklist)
 
 	// Public trip route
 	r.GET("/t/:id", middleware.AuthOptional(db, cfg), handlePublicTripByShortID)
+	r.GET("/t/:id/gpx/:file_id/download", middleware.AuthOptional(db, cfg), handlePublicDownloadGPX)
 	r.GET("/t/:id/gpx/download", middleware.AuthOptional(db, cfg), handlePublicDownloadGPX)
+	r.GET("/t/:id/export.ics", middleware.AuthOptional(db, cfg), handlePublicExportTripICS)
 
 	r.NoRoute(handle404)
 }
@@ -197,26 +286,30 @@ func handleHome(c *gin.Context) {
 
 func handleRegisterPage(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
-	
-	// Check if registration is enabled
-	registrationEnabled, err := database.IsRegistrationEnabled(db)
+	invite := c.Query("invite")
+
+	closedReason, err := registrationClosedReason(db, invite)
 	if err != nil {
+		logger.Error("Failed to check registration status", "error", err)
 		c.HTML(http.StatusInternalServerError, "register.html", gin.H{
 			"Title": "Register - Carryless",
 			"Error": "Unable to check registration status",
 		})
 		return
 	}
-	
+
 	c.HTML(http.StatusOK, "register.html", gin.H{
 		"Title":               "Register - Carryless",
-		"RegistrationEnabled": registrationEnabled,
+		"RegistrationEnabled": closedReason == "",
+		"ClosedReason":        closedReason,
+		"Invite":              invite,
 	})
 }
 
 func handleLoginPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "login.html", gin.H{
-		"Title": "Login - Carryless",
+		"Title":          "Login - Carryless",
+		"OAuthProviders": c.MustGet("oauth_service").(*oauth.Service).Providers(),
 	})
 }
 
@@ -243,6 +336,20 @@ func addEmailServiceContext(emailService *email.Service) gin.HandlerFunc {
 	}
 }
 
+func addWeatherServiceContext(weatherService *weather.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("weather_service", weatherService)
+		c.Next()
+	}
+}
+
+func addOAuthServiceContext(oauthService *oauth.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("oauth_service", oauthService)
+		c.Next()
+	}
+}
+
 func addConfigContext(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Set("config", cfg)
@@ -321,4 +428,4 @@ func handleDashboard(c *gin.Context) {
 		"RecentPacks": recentPacks,
 	})
 	logger.Debug("Dashboard template rendered successfully", "user_id", userID)
-}
\ No newline at end of file
+}
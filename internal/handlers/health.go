@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"carryless/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds how long /readyz waits for the database
+const readinessTimeout = 2 * time.Second
+
+// SetupHealthRoutes registers the liveness and readiness probes used by container
+// orchestrators and load balancers. Register them before the rate limiting and IP
+// blocking middleware so probes are never throttled.
+func SetupHealthRoutes(r *gin.Engine, db *sql.DB) {
+	r.GET("/healthz", handleHealthz)
+	r.HEAD("/healthz", handleHealthz)
+	r.GET("/readyz", handleReadyz(db))
+	r.HEAD("/readyz", handleReadyz(db))
+}
+
+// handleHealthz reports that the process is up
+func handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyz reports whether the app can serve requests, which needs the database
+func handleReadyz(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		if err := db.PingContext(ctx); err != nil {
+			logger.Warn("Readiness check failed", "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
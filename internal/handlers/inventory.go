@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -13,23 +14,18 @@ import (
 	"strings"
 	"time"
 
+	"carryless/internal/config"
 	"carryless/internal/database"
+	"carryless/internal/logger"
 	"carryless/internal/models"
+	"carryless/internal/uploads"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Valid capacity units for items
-var validCapacityUnits = map[string]bool{
-	"mL":    true,
-	"L":     true,
-	"fl-oz": true,
-	"mAh":   true,
-}
-
 // isValidCapacityUnit checks if the given unit is valid
 func isValidCapacityUnit(unit string) bool {
-	return validCapacityUnits[unit]
+	return models.IsValidCapacityUnit(unit)
 }
 
 // isValidURL checks if the given string is a valid http/https URL
@@ -49,9 +45,25 @@ func handleInventory(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 	user := c.MustGet("user")
 
-	// Load all items - filtering is done client-side via JavaScript
-	items, err := database.GetItems(db, userID)
-	
+	// Search and category filters are applied server-side; the remaining filters run client-side via JavaScript
+	query := strings.TrimSpace(c.Query("q"))
+	var categoryID *int
+	categoryFilter := 0
+	if categoryIDStr := c.Query("category"); categoryIDStr != "" {
+		if id, err := strconv.Atoi(categoryIDStr); err == nil {
+			categoryID = &id
+			categoryFilter = id
+		}
+	}
+
+	var items []models.Item
+	var err error
+	if query == "" && categoryID == nil {
+		items, err = database.GetItems(db, userID)
+	} else {
+		items, err = database.SearchItems(db, userID, query, categoryID)
+	}
+
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "inventory.html", gin.H{
 			"Title": "Inventory - Carryless",
@@ -99,6 +111,9 @@ func handleInventory(c *gin.Context) {
 		"Categories":     categories,
 		"CSRFToken":      csrfToken.Token,
 		"ItemLinksCount": itemLinksCount,
+		"Query":          query,
+		"CategoryFilter": categoryFilter,
+		"MaxCSVSize":     config.FormatSize(c.MustGet("config").(*config.Config).MaxCSVSize),
 	})
 }
 
@@ -245,6 +260,48 @@ func handleCreateItem(c *gin.Context) {
 		return
 	}
 
+	// Warn about likely duplicates once, the user can still create the item by resubmitting
+	if c.PostForm("confirm_duplicate") != "true" {
+		similarItems, err := database.FindSimilarItems(db, userID, name)
+		if err != nil {
+			logger.Warn("Failed to check for similar items", "user_id", userID, "error", err)
+		} else if len(similarItems) > 0 {
+			csrfToken, err := database.CreateCSRFToken(db, userID)
+			if err != nil {
+				c.HTML(http.StatusInternalServerError, "new_item.html", gin.H{
+					"Title":      "New Item - Carryless",
+					"User":       user,
+					"Categories": categories,
+					"Error":      "Failed to generate security token",
+				})
+				return
+			}
+
+			c.HTML(http.StatusOK, "new_item.html", gin.H{
+				"Title":        "New Item - Carryless",
+				"User":         user,
+				"Categories":   categories,
+				"CSRFToken":    csrfToken.Token,
+				"SimilarItems": similarItems,
+				"Form": gin.H{
+					"name":             name,
+					"note":             note,
+					"category_name":    categoryName,
+					"weight_grams":     weightStr,
+					"price":            priceStr,
+					"weight_to_verify": weightToVerify,
+					"brand":            brand,
+					"model":            model,
+					"purchase_date":    purchaseDateStr,
+					"capacity":         capacityStr,
+					"capacity_unit":    capacityUnit,
+					"link":             link,
+				},
+			})
+			return
+		}
+	}
+
 	// Get or create the category
 	category, err := database.GetOrCreateCategory(db, userID, categoryName)
 	if err != nil {
@@ -357,13 +414,16 @@ func handleEditItemPage(c *gin.Context) {
 		return
 	}
 
+	maxImageSize := c.MustGet("config").(*config.Config).MaxImageSize
 	c.HTML(http.StatusOK, "edit_item.html", gin.H{
-		"Title":      "Edit Item - Carryless",
-		"User":       user,
-		"Item":       item,
-		"Items":      items,
-		"Categories": categories,
-		"CSRFToken":  csrfToken.Token,
+		"Title":             "Edit Item - Carryless",
+		"User":              user,
+		"Item":              item,
+		"Items":             items,
+		"Categories":        categories,
+		"CSRFToken":         csrfToken.Token,
+		"MaxImageSize":      maxImageSize,
+		"MaxImageSizeLabel": config.FormatSize(maxImageSize),
 	})
 }
 
@@ -589,13 +649,11 @@ func handleDeleteItem(c *gin.Context) {
 		return
 	}
 
-	// Regular delete - check for packs first
+	// Regular delete - move to the trash, keeping pack memberships for a restore
 	err = database.DeleteItem(db, userID, itemID)
 	if err != nil {
 		fmt.Printf("[DEBUG] Delete item failed - ID: %d, error: %v\n", itemID, err)
-		if strings.Contains(err.Error(), "cannot delete item used in") {
-			c.Redirect(http.StatusFound, "/inventory?error=item_in_use")
-		} else if strings.Contains(err.Error(), "item not found") {
+		if strings.Contains(err.Error(), "item not found") {
 			c.Redirect(http.StatusFound, "/inventory?error=item_not_found")
 		} else {
 			c.Redirect(http.StatusFound, "/inventory?error=delete_failed")
@@ -607,6 +665,249 @@ func handleDeleteItem(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/inventory?success=deleted")
 }
 
+func handleInventoryTrash(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user")
+
+	items, err := database.GetDeletedItems(db, userID)
+	if err != nil {
+		logger.Error("Failed to load deleted items", "user_id", userID, "error", err)
+		c.HTML(http.StatusInternalServerError, "inventory_trash.html", gin.H{
+			"Title": "Trash - Carryless",
+			"User":  user,
+			"Error": "Failed to load trash",
+		})
+		return
+	}
+
+	csrfToken, err := database.CreateCSRFToken(db, userID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "inventory_trash.html", gin.H{
+			"Title": "Trash - Carryless",
+			"User":  user,
+			"Error": "Failed to generate security token",
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "inventory_trash.html", gin.H{
+		"Title":      "Trash - Carryless",
+		"User":       user,
+		"Items":      items,
+		"CSRFToken":  csrfToken.Token,
+		"WeightUnit": weightUnitFor(user),
+	})
+}
+
+// handleItemsToVerify lists the items whose weight still has to be checked on a scale
+func handleItemsToVerify(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user")
+
+	items, err := database.GetItemsToVerify(db, userID)
+	if err != nil {
+		logger.Error("Failed to load items to verify", "user_id", userID, "error", err)
+		c.HTML(http.StatusInternalServerError, "inventory_to_verify.html", gin.H{
+			"Title": "Weights to Verify - Carryless",
+			"User":  user,
+			"Error": "Failed to load items",
+		})
+		return
+	}
+
+	csrfToken, err := database.CreateCSRFToken(db, userID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "inventory_to_verify.html", gin.H{
+			"Title": "Weights to Verify - Carryless",
+			"User":  user,
+			"Error": "Failed to generate security token",
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "inventory_to_verify.html", gin.H{
+		"Title":     "Weights to Verify - Carryless",
+		"User":      user,
+		"Items":     items,
+		"CSRFToken": csrfToken.Token,
+	})
+}
+
+// handleMarkItemsVerified clears the flag of the selected items, saving the weight entered
+// for each one as its measured weight
+func handleMarkItemsVerified(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	itemIDs, err := parseItemIDs(strings.Join(c.PostFormArray("item_ids"), ","))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/inventory/to-verify?error=invalid_item_ids")
+		return
+	}
+	if len(itemIDs) == 0 {
+		c.Redirect(http.StatusFound, "/inventory/to-verify?error=no_items_selected")
+		return
+	}
+
+	weights := make(map[int]int)
+	for _, itemID := range itemIDs {
+		weightStr := strings.TrimSpace(c.PostForm(fmt.Sprintf("weight_%d", itemID)))
+		if weightStr == "" {
+			continue
+		}
+		weight, err := strconv.Atoi(weightStr)
+		if err != nil || weight < 0 {
+			c.Redirect(http.StatusFound, "/inventory/to-verify?error=invalid_weight")
+			return
+		}
+		weights[itemID] = weight
+	}
+
+	if err := database.MarkItemsVerified(db, userID, itemIDs, weights); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.Redirect(http.StatusFound, "/inventory/to-verify?error=item_not_found")
+			return
+		}
+		logger.Error("Failed to mark items verified", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/inventory/to-verify?error=verify_failed")
+		return
+	}
+
+	logger.Info("Items marked verified", "user_id", userID, "count", len(itemIDs))
+	c.Redirect(http.StatusFound, fmt.Sprintf("/inventory/to-verify?success=verified&count=%d", len(itemIDs)))
+}
+
+func handleRestoreItem(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/inventory/trash?error=invalid_id")
+		return
+	}
+
+	err = database.RestoreItem(db, userID, itemID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.Redirect(http.StatusFound, "/inventory/trash?error=item_not_found")
+		} else {
+			logger.Error("Failed to restore item", "user_id", userID, "item_id", itemID, "error", err)
+			c.Redirect(http.StatusFound, "/inventory/trash?error=restore_failed")
+		}
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory/trash?success=restored")
+}
+
+func handleUploadItemImage(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		return
+	}
+
+	maxSize := c.MustGet("config").(*config.Config).MaxImageSize
+	if file.Size > maxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File too large (max %s)", config.FormatSize(maxSize))})
+		return
+	}
+
+	fileContent, err := file.Open()
+	if err != nil {
+		logger.Error("Failed to open image", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer fileContent.Close()
+
+	data, err := io.ReadAll(io.LimitReader(fileContent, maxSize+1))
+	if err != nil {
+		logger.Error("Failed to read image content", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	// Make sure the item exists before writing anything to disk
+	if _, err := database.GetItem(db, userID, itemID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	imagePath, err := uploads.SaveItemImage(userID, data, maxSize)
+	if err != nil {
+		if strings.Contains(err.Error(), "too large") || strings.Contains(err.Error(), "unsupported") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Only JPEG and PNG images up to %s are allowed", config.FormatSize(maxSize))})
+			return
+		}
+		logger.Error("Failed to save item image", "user_id", userID, "item_id", itemID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+
+	previous, err := database.SetItemImage(db, userID, itemID, imagePath)
+	if err != nil {
+		uploads.Remove(imagePath)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+			return
+		}
+		logger.Error("Failed to set item image", "user_id", userID, "item_id", itemID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+
+	if previous != "" {
+		if err := uploads.Remove(previous); err != nil {
+			logger.Warn("Failed to remove previous item image", "item_id", itemID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "image_path": imagePath})
+}
+
+func handleDeleteItemImage(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	itemID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	previous, err := database.SetItemImage(db, userID, itemID, "")
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+			return
+		}
+		logger.Error("Failed to clear item image", "user_id", userID, "item_id", itemID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+
+	if previous != "" {
+		if err := uploads.Remove(previous); err != nil {
+			logger.Warn("Failed to remove item image", "item_id", itemID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
 func handleDuplicateItem(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -621,7 +922,7 @@ func handleDuplicateItem(c *gin.Context) {
 	// Duplicate the item
 	_, err = database.DuplicateItem(db, userID, itemID)
 	if err != nil {
-		fmt.Printf("[DEBUG] Duplicate item failed - ID: %d, error: %v\n", itemID, err)
+		logger.Error("Failed to duplicate item", "user_id", userID, "item_id", itemID, "error", err)
 		if strings.Contains(err.Error(), "not found") {
 			c.Redirect(http.StatusFound, "/inventory?error=item_not_found")
 		} else {
@@ -633,6 +934,7 @@ func handleDuplicateItem(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/inventory?success=duplicated")
 }
 
+// handleCheckItemPacks lists the packs an item is in, to show what editing or deleting it affects
 func handleCheckItemPacks(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -644,14 +946,50 @@ func handleCheckItemPacks(c *gin.Context) {
 		return
 	}
 
-	// Get packs using this item
-	packNames, err := database.GetPacksUsingItem(db, userID, itemID)
+	packs, err := database.GetPacksContainingItem(db, userID, itemID)
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+			return
+		}
+		logger.Error("Failed to check packs containing item", "user_id", userID, "item_id", itemID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check packs"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"packs": packNames})
+	c.JSON(http.StatusOK, gin.H{"packs": packs})
+}
+
+// handleInventoryStats returns weight and cost totals per category for the whole inventory
+func handleInventoryStats(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	stats, err := database.GetInventoryStats(db, userID)
+	if err != nil {
+		logger.Error("Failed to load inventory stats", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load inventory stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats, "currency": user.Currency})
+}
+
+// handleInventorySummary answers with the totals of the user's inventory, for widgets that
+// don't need the items themselves
+func handleInventorySummary(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	summary, err := database.GetInventorySummary(db, userID)
+	if err != nil {
+		logger.Error("Failed to load inventory summary", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load inventory summary"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
 }
 
 func handleExportInventory(c *gin.Context) {
@@ -664,6 +1002,11 @@ func handleExportInventory(c *gin.Context) {
 		return
 	}
 
+	if c.Query("format") == "json" {
+		exportInventoryJSON(c, items)
+		return
+	}
+
 	// Create CSV content
 	var buf bytes.Buffer
 	writer := csv.NewWriter(&buf)
@@ -741,6 +1084,24 @@ func handleExportInventory(c *gin.Context) {
 	c.Data(http.StatusOK, "text/csv", buf.Bytes())
 }
 
+// exportInventoryJSON writes the full item list, including fields the CSV drops, as a
+// re-importable JSON document.
+func exportInventoryJSON(c *gin.Context, items []models.Item) {
+	if items == nil {
+		items = []models.Item{}
+	}
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Failed to generate JSON")
+		return
+	}
+
+	c.Header("Content-Type", "application/json")
+	c.Header("Content-Disposition", "attachment; filename=inventory.json")
+	c.Data(http.StatusOK, "application/json", data)
+}
+
 func handleImportInventory(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -754,7 +1115,11 @@ func handleImportInventory(c *gin.Context) {
 	defer file.Close()
 
 	// Security validations
-	if err := validateCSVFile(file, header); err != nil {
+	if err := validateCSVFile(file, header, c.MustGet("config").(*config.Config).MaxCSVSize); err != nil {
+		if strings.Contains(err.Error(), "too large") {
+			c.Redirect(http.StatusFound, "/inventory?error=file_too_large")
+			return
+		}
 		c.Redirect(http.StatusFound, "/inventory?error=invalid_file")
 		return
 	}
@@ -769,39 +1134,133 @@ func handleImportInventory(c *gin.Context) {
 		return
 	}
 
-	// Begin transaction for atomic operation
-	tx, err := db.Begin()
+	// Existing items are only wiped when replace mode is explicitly chosen
+	replace := c.PostForm("mode") == "replace"
+
+	if _, _, err := database.ImportItems(db, userID, items, replace); err != nil {
+		c.Redirect(http.StatusFound, "/inventory?error=import_error")
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/inventory?success=imported")
+}
+
+func handleImportInventoryJSON(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	file, header, err := c.Request.FormFile("jsonFile")
 	if err != nil {
-		c.Redirect(http.StatusFound, "/inventory?error=database_error")
+		c.Redirect(http.StatusFound, "/inventory?error=no_file")
 		return
 	}
-	defer tx.Rollback()
+	defer file.Close()
 
-	// Delete all existing items
-	if err := database.DeleteAllItems(db, userID); err != nil {
-		c.Redirect(http.StatusFound, "/inventory?error=delete_error")
+	if header.Size > 10*1024*1024 || !strings.HasSuffix(strings.ToLower(header.Filename), ".json") {
+		c.Redirect(http.StatusFound, "/inventory?error=invalid_file")
 		return
 	}
 
-	// Insert new items
-	for _, item := range items {
-		if _, err := database.CreateItem(db, userID, item); err != nil {
-			c.Redirect(http.StatusFound, "/inventory?error=import_error")
-			return
-		}
+	items, err := parseJSONFile(file, db, userID)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/inventory?error=parse_error")
+		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.Redirect(http.StatusFound, "/inventory?error=commit_error")
+	// Existing items are only wiped when replace mode is explicitly chosen
+	replace := c.PostForm("mode") == "replace"
+
+	if _, _, err := database.ImportItems(db, userID, items, replace); err != nil {
+		c.Redirect(http.StatusFound, "/inventory?error=import_error")
 		return
 	}
 
 	c.Redirect(http.StatusFound, "/inventory?success=imported")
 }
 
-func validateCSVFile(file multipart.File, header *multipart.FileHeader) error {
-	// Check file size (max 10MB)
-	if header.Size > 10*1024*1024 {
+// parseJSONFile parses an inventory produced by the JSON export. Items are matched to
+// existing ones by name and category the same way parseCSVFile does.
+func parseJSONFile(file multipart.File, db *sql.DB, userID int) ([]models.Item, error) {
+	var decoded []models.Item
+	if err := json.NewDecoder(io.LimitReader(file, 10*1024*1024)).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("JSON parse error: %v", err)
+	}
+
+	if len(decoded) > 10000 {
+		return nil, fmt.Errorf("too many items (max 10000)")
+	}
+
+	items := make([]models.Item, 0, len(decoded))
+	for i, src := range decoded {
+		position := i + 1
+
+		name := strings.TrimSpace(src.Name)
+		categoryName := ""
+		if src.Category != nil {
+			categoryName = strings.TrimSpace(src.Category.Name)
+		}
+		note := strings.TrimSpace(src.Note)
+
+		if name == "" || categoryName == "" {
+			return nil, fmt.Errorf("empty required field at item %d", position)
+		}
+		if len(name) > 255 || len(categoryName) > 100 || len(note) > 1000 {
+			return nil, fmt.Errorf("field too long at item %d", position)
+		}
+		if src.WeightGrams < 0 || src.WeightGrams > 100000 {
+			return nil, fmt.Errorf("invalid weight at item %d", position)
+		}
+		if src.Price < 0 || src.Price > 100000 {
+			return nil, fmt.Errorf("invalid price at item %d", position)
+		}
+		if src.Brand != nil && len(*src.Brand) > 100 {
+			return nil, fmt.Errorf("brand too long at item %d", position)
+		}
+		if src.Model != nil && len(*src.Model) > 100 {
+			return nil, fmt.Errorf("model too long at item %d", position)
+		}
+		if src.Capacity != nil && *src.Capacity < 0 {
+			return nil, fmt.Errorf("invalid capacity at item %d", position)
+		}
+		if src.CapacityUnit != nil && !isValidCapacityUnit(*src.CapacityUnit) {
+			return nil, fmt.Errorf("invalid capacity unit at item %d", position)
+		}
+		if src.Link != nil && (len(*src.Link) > 500 || !isValidURL(*src.Link)) {
+			return nil, fmt.Errorf("invalid link at item %d", position)
+		}
+
+		category, err := database.GetOrCreateCategory(db, userID, categoryName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get/create category at item %d", position)
+		}
+
+		existingID, err := database.FindItemIDByNameAndCategory(db, userID, name, category.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up item at item %d", position)
+		}
+
+		items = append(items, models.Item{
+			ID:             existingID,
+			Name:           name,
+			CategoryID:     category.ID,
+			WeightGrams:    src.WeightGrams,
+			WeightToVerify: src.WeightToVerify,
+			Price:          src.Price,
+			Note:           note,
+			Brand:          src.Brand,
+			Model:          src.Model,
+			PurchaseDate:   src.PurchaseDate,
+			Capacity:       src.Capacity,
+			CapacityUnit:   src.CapacityUnit,
+			Link:           src.Link,
+		})
+	}
+
+	return items, nil
+}
+
+func validateCSVFile(file multipart.File, header *multipart.FileHeader, maxSize int64) error {
+	if header.Size > maxSize {
 		return fmt.Errorf("file too large")
 	}
 
@@ -813,10 +1272,12 @@ func validateCSVFile(file multipart.File, header *multipart.FileHeader) error {
 
 	// Read first 512 bytes for MIME type detection
 	buffer := make([]byte, 512)
-	_, err := file.Read(buffer)
+	n, err := file.Read(buffer)
 	if err != nil {
 		return fmt.Errorf("cannot read file")
 	}
+	// Small files don't fill the buffer, and the zero padding would look like binary data
+	buffer = buffer[:n]
 
 	// Check MIME type (should be text/plain or text/csv)
 	contentType := http.DetectContentType(buffer)
@@ -833,27 +1294,41 @@ func validateCSVFile(file multipart.File, header *multipart.FileHeader) error {
 	return nil
 }
 
+// parseCSVFile parses an inventory CSV. Rows matching an existing item by name and
+// category are returned with that item's ID set; new rows have an ID of 0.
 func parseCSVFile(file multipart.File, db *sql.DB, userID int) ([]models.Item, error) {
 	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // Allow variable number of fields for backward compatibility
+	reader.FieldsPerRecord = -1 // The header decides the format, see below
 
 	var items []models.Item
-	lineNumber := 0
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return items, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSV parse error at line 1: %v", err)
+	}
+
+	// Validate field count (5 = old format, 10 = legacy format with brand, 11 = format with model, 12 = new format with WeightToVerify)
+	if len(header) != 5 && len(header) != 10 && len(header) != 11 && len(header) != 12 {
+		return nil, fmt.Errorf("invalid number of columns in header (expected 5, 10, 11, or 12, got %d)", len(header))
+	}
+
+	// Every row must then have as many fields as the header
+	reader.FieldsPerRecord = len(header)
+	lineNumber := 1
 
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
-			return nil, fmt.Errorf("CSV parse error at line %d: %v", lineNumber, err)
-		}
 
 		lineNumber++
 
-		// Skip header row
-		if lineNumber == 1 {
-			continue
+		if err != nil {
+			return nil, fmt.Errorf("CSV parse error at line %d: %v", lineNumber, err)
 		}
 
 		// Limit total rows to prevent DoS
@@ -861,11 +1336,6 @@ func parseCSVFile(file multipart.File, db *sql.DB, userID int) ([]models.Item, e
 			return nil, fmt.Errorf("too many rows (max 10000)")
 		}
 
-		// Validate field count (5 = old format, 10 = legacy format with brand, 11 = format with model, 12 = new format with WeightToVerify)
-		if len(record) != 5 && len(record) != 10 && len(record) != 11 && len(record) != 12 {
-			return nil, fmt.Errorf("invalid number of fields at line %d (expected 5, 10, 11, or 12, got %d)", lineNumber, len(record))
-		}
-
 		name := strings.TrimSpace(record[0])
 		categoryName := strings.TrimSpace(record[1])
 		weightStr := strings.TrimSpace(record[2])
@@ -914,7 +1384,14 @@ func parseCSVFile(file multipart.File, db *sql.DB, userID int) ([]models.Item, e
 			return nil, fmt.Errorf("failed to get/create category at line %d", lineNumber)
 		}
 
+		// Match an existing item by name and category so merge imports can update it
+		existingID, err := database.FindItemIDByNameAndCategory(db, userID, name, category.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up item at line %d", lineNumber)
+		}
+
 		item := models.Item{
+			ID:             existingID,
 			Name:           name,
 			CategoryID:     category.ID,
 			WeightGrams:    weight,
@@ -1206,10 +1683,56 @@ func handleBulkEditItems(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/inventory?success=bulk_updated")
 }
 
+// bulkItemsRequest is the JSON body accepted by the bulk inventory endpoints
+type bulkItemsRequest struct {
+	ItemIDs    []int `json:"item_ids"`
+	CategoryID int   `json:"category_id"`
+}
+
+// uniqueItemIDs drops duplicate IDs so ownership checks compare against the right count
+func uniqueItemIDs(itemIDs []int) []int {
+	seen := make(map[int]bool, len(itemIDs))
+	unique := make([]int, 0, len(itemIDs))
+	for _, id := range itemIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// handleBulkDeleteItems accepts either the inventory bulk edit form (comma-separated item_ids,
+// answered with a redirect) or a JSON body {"item_ids": [...]} answered with JSON
 func handleBulkDeleteItems(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
 
+	if c.ContentType() == "application/json" {
+		var req bulkItemsRequest
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.ItemIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "item_ids must be a non-empty array"})
+			return
+		}
+
+		deleted, skipped, err := database.BulkDeleteItems(db, userID, uniqueItemIDs(req.ItemIDs))
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			} else {
+				logger.Error("Failed to bulk delete items", "user_id", userID, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete items"})
+			}
+			return
+		}
+
+		if skipped == nil {
+			skipped = []int{}
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "deleted": deleted, "skipped": skipped})
+		return
+	}
+
 	// Parse item IDs (comma-separated)
 	itemIDsStr := c.PostForm("item_ids")
 	if itemIDsStr == "" {
@@ -1224,17 +1747,52 @@ func handleBulkDeleteItems(c *gin.Context) {
 	}
 
 	// Call database function
-	deleted, err := database.BulkDeleteItems(db, userID, itemIDs)
+	deleted, skipped, err := database.BulkDeleteItems(db, userID, uniqueItemIDs(itemIDs))
 	if err != nil {
 		fmt.Printf("[DEBUG] Bulk delete failed: %v\n", err)
 		c.Redirect(http.StatusFound, "/inventory?error=bulk_delete_failed")
 		return
 	}
 
-	fmt.Printf("[DEBUG] Bulk deleted %d items\n", deleted)
+	logger.Info("Bulk deleted items", "user_id", userID, "deleted", deleted, "skipped", len(skipped))
+	if len(skipped) > 0 {
+		c.Redirect(http.StatusFound, fmt.Sprintf("/inventory?success=bulk_deleted&skipped=%d", len(skipped)))
+		return
+	}
 	c.Redirect(http.StatusFound, "/inventory?success=bulk_deleted")
 }
 
+// handleBulkUpdateItemCategory moves items to another category from a JSON body
+// {"item_ids": [...], "category_id": N}
+func handleBulkUpdateItemCategory(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	var req bulkItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.ItemIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "item_ids must be a non-empty array"})
+		return
+	}
+	if req.CategoryID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category_id is required"})
+		return
+	}
+
+	itemIDs := uniqueItemIDs(req.ItemIDs)
+	err := database.BulkUpdateItemCategory(db, userID, itemIDs, req.CategoryID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			logger.Error("Failed to bulk update item category", "user_id", userID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update items"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "updated": len(itemIDs)})
+}
+
 // ItemPatchRequest represents the JSON body for PATCH /api/items/:id
 type ItemPatchRequest struct {
 	Name           *string  `json:"name"`
@@ -1516,7 +2074,10 @@ func handleCreateItemLink(c *gin.Context) {
 
 	err = database.CreateItemLink(db, userID, parentItemID, req.LinkedItemID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if strings.Contains(err.Error(), "does not belong") {
+			// Don't reveal that another user's item exists
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		} else if strings.Contains(err.Error(), "circular") || strings.Contains(err.Error(), "itself") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -1559,7 +2120,9 @@ func handleDeleteItemLink(c *gin.Context) {
 
 	err = database.DeleteItemLink(db, userID, parentItemID, linkedItemID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if strings.Contains(err.Error(), "does not belong") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		} else if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete link"})
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"carryless/internal/database"
+	"carryless/internal/logger"
+	"carryless/internal/models"
+	"carryless/internal/oauth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oauthStateCookie holds the state sent to the provider until it comes back to the callback
+const oauthStateCookie = "oauth_state"
+
+// oauthStateLifetime is how long the user has to log in at the provider
+const oauthStateLifetime = 10 * time.Minute
+
+// handleOAuthLogin sends the user to the provider's login page
+func handleOAuthLogin(c *gin.Context) {
+	oauthService := c.MustGet("oauth_service").(*oauth.Service)
+
+	provider, ok := oauthService.Provider(c.Param("provider"))
+	if !ok {
+		handle404(c)
+		return
+	}
+
+	stateBytes := make([]byte, 32)
+	if _, err := rand.Read(stateBytes); err != nil {
+		logger.Error("Failed to generate OAuth state", "error", err)
+		renderOAuthLoginError(c, http.StatusInternalServerError, "Failed to start login. Please try again.")
+		return
+	}
+	state := hex.EncodeToString(stateBytes)
+
+	// Lax so the cookie comes back with the provider's redirect to the callback
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, int(oauthStateLifetime.Seconds()), "/auth/oauth", "", true, true)
+	c.Redirect(http.StatusFound, oauthService.AuthCodeURL(provider, state))
+}
+
+// handleOAuthCallback logs in the user the provider authenticated, linking or creating the
+// account on first login
+func handleOAuthCallback(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	oauthService := c.MustGet("oauth_service").(*oauth.Service)
+
+	provider, ok := oauthService.Provider(c.Param("provider"))
+	if !ok {
+		handle404(c)
+		return
+	}
+
+	state, err := c.Cookie(oauthStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, "", -1, "/auth/oauth", "", true, true)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		renderOAuthLoginError(c, http.StatusBadRequest, "Your login has expired. Please try again.")
+		return
+	}
+
+	if c.Query("error") != "" || c.Query("code") == "" {
+		renderOAuthLoginError(c, http.StatusBadRequest, fmt.Sprintf("Login with %s was cancelled.", provider.DisplayName))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	identity, err := oauthService.Exchange(ctx, provider, c.Query("code"))
+	if err != nil {
+		logger.Error("Failed to complete OAuth login", "provider", provider.Name, "error", err)
+		renderOAuthLoginError(c, http.StatusBadGateway, fmt.Sprintf("Login with %s failed. Please try again.", provider.DisplayName))
+		return
+	}
+
+	user, message, err := resolveOAuthUser(db, provider, identity)
+	if err != nil {
+		logger.Error("Failed to resolve OAuth user", "provider", provider.Name, "error", err)
+		renderOAuthLoginError(c, http.StatusInternalServerError, "Failed to log in. Please try again.")
+		return
+	}
+	if message != "" {
+		renderOAuthLoginError(c, http.StatusForbidden, message)
+		return
+	}
+
+	if user.IsSuspended {
+		renderOAuthLoginError(c, http.StatusForbidden, "This account has been suspended. Please contact support.")
+		return
+	}
+
+	// The provider replaces the password, not the second factor
+	totpEnabled, err := database.IsTOTPEnabled(db, user.ID)
+	if err != nil {
+		logger.Error("Failed to check two-factor authentication", "user_id", user.ID, "error", err)
+		renderOAuthLoginError(c, http.StatusInternalServerError, "Failed to log in. Please try again.")
+		return
+	}
+	if totpEnabled {
+		challenge, err := database.CreateLoginChallenge(db, user.ID, false)
+		if err != nil {
+			logger.Error("Failed to create login challenge", "user_id", user.ID, "error", err)
+			renderOAuthLoginError(c, http.StatusInternalServerError, "Failed to log in. Please try again.")
+			return
+		}
+
+		c.HTML(http.StatusOK, "login_totp.html", gin.H{
+			"Title":     "Two-Factor Authentication - Carryless",
+			"Challenge": challenge.Token,
+		})
+		return
+	}
+
+	if err := setSessionCookie(c, db, user, false); err != nil {
+		logger.Error("Failed to create session", "user_id", user.ID, "error", err)
+		renderOAuthLoginError(c, http.StatusInternalServerError, "Failed to create session. Please try again.")
+		return
+	}
+
+	// The callback is reached from the provider's site, and browsers don't send the strict
+	// session cookie along a redirect chain started elsewhere. Navigating from our own page
+	// makes the dashboard request same-site.
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(
+		`<!DOCTYPE html><meta http-equiv="refresh" content="0; url=/dashboard"><a href="/dashboard">Continue to your dashboard</a>`))
+}
+
+// resolveOAuthUser finds the user for the identity. An activated account with the same
+// verified email is linked, and a new account is created if registration is enabled. When
+// the user can't log in, a message explaining why is returned instead.
+func resolveOAuthUser(db *sql.DB, provider *oauth.Provider, identity *oauth.Identity) (*models.User, string, error) {
+	user, err := database.GetUserByOAuthIdentity(db, identity.Provider, identity.Subject)
+	if err == nil {
+		return user, "", nil
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		return nil, "", err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, fmt.Sprintf("Your %s account has no verified email address.", provider.DisplayName), nil
+	}
+
+	existing, err := database.GetUserByEmail(db, identity.Email)
+	if err == nil {
+		// Linking an account nobody proved to own would let whoever registered it first
+		// take over the provider login
+		if !existing.IsActivated {
+			return nil, "An account with this email address exists but isn't activated yet. Activate it from the email we sent, then try again.", nil
+		}
+
+		if err := database.LinkOAuthIdentity(db, existing.ID, identity.Provider, identity.Subject); err != nil {
+			if strings.Contains(err.Error(), "already linked") {
+				return nil, "This account is already linked to another login provider. Log in with your password or that provider.", nil
+			}
+			return nil, "", err
+		}
+
+		logger.Info("OAuth identity linked", "user_id", existing.ID, "provider", identity.Provider)
+		user, err := database.GetUserByID(db, existing.ID)
+		return user, "", err
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		return nil, "", err
+	}
+
+	// Logins through a provider can't carry an invite
+	closedReason, err := registrationClosedReason(db, "")
+	if err != nil {
+		return nil, "", err
+	}
+	if closedReason != "" {
+		return nil, closedReason, nil
+	}
+
+	user, err = database.CreateOAuthUser(db, oauthUsername(identity), identity.Email, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, "", err
+	}
+
+	logger.Info("User registered with OAuth", "user_id", user.ID, "provider", identity.Provider)
+	return user, "", nil
+}
+
+// oauthUsername picks a display name for a new user from the provider profile, falling back
+// to the email's local part
+func oauthUsername(identity *oauth.Identity) string {
+	username := strings.TrimSpace(identity.Name)
+	if utf8.RuneCountInString(username) < 3 {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if utf8.RuneCountInString(username) < 3 {
+		username = "hiker"
+	}
+	if runes := []rune(username); len(runes) > 30 {
+		username = strings.TrimSpace(string(runes[:30]))
+	}
+	return username
+}
+
+func renderOAuthLoginError(c *gin.Context, status int, message string) {
+	c.HTML(status, "login.html", gin.H{
+		"Title":          "Login - Carryless",
+		"Errors":         map[string]string{"general": message},
+		"OAuthProviders": c.MustGet("oauth_service").(*oauth.Service).Providers(),
+	})
+}
@@ -1,13 +1,26 @@
 package handlers
 
 import (
+	"archive/zip"
+	"bytes"
 	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"carryless/internal/config"
 	"carryless/internal/database"
 	"carryless/internal/logger"
+	"carryless/internal/models"
+	"carryless/internal/pdf"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,7 +30,16 @@ func handlePacks(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 	user := c.MustGet("user")
 
-	packs, err := database.GetPacks(db, userID)
+	// Packs can be narrowed down to a single pack-level label, anything else lists them all
+	labelFilter, _ := strconv.Atoi(c.Query("label"))
+
+	var packs []models.Pack
+	var err error
+	if labelFilter > 0 {
+		packs, err = database.GetPacksByLabel(db, userID, labelFilter)
+	} else {
+		packs, err = database.GetPacksIncludingShared(db, userID)
+	}
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "packs.html", gin.H{
 			"Title": "Packs - Carryless",
@@ -27,6 +49,22 @@ func handlePacks(c *gin.Context) {
 		return
 	}
 
+	// Templates are only listed when requested, but always offered in the "from template" selector
+	templates, err := database.GetPackTemplates(db, userID)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "packs.html", gin.H{
+			"Title": "Packs - Carryless",
+			"User":  user,
+			"Error": "Failed to load pack templates",
+		})
+		return
+	}
+
+	showTemplates := c.Query("view") == "templates"
+	if showTemplates {
+		packs = templates
+	}
+
 	// Get user pack labels for the labels bar
 	userPackLabels, err := database.GetUserPackLabels(db, userID)
 	if err != nil {
@@ -52,7 +90,11 @@ func handlePacks(c *gin.Context) {
 		"Title":          "Packs - Carryless",
 		"User":           user,
 		"Packs":          packs,
+		"Templates":      templates,
+		"ShowTemplates":  showTemplates,
 		"UserPackLabels": userPackLabels,
+		"LabelFilter":    labelFilter,
+		"WeightUnit":     weightUnitFor(user),
 		"CSRFToken":      csrfToken.Token,
 	})
 }
@@ -109,10 +151,11 @@ func handleCreatePack(c *gin.Context) {
 
 	_, err := database.CreatePackWithPublic(db, userID, name, isPublic)
 	if err != nil {
+		logger.Error("Failed to create pack", "user_id", userID, "error", err)
 		c.HTML(http.StatusInternalServerError, "new_pack.html", gin.H{
 			"Title": "New Pack - Carryless",
 			"User":  user,
-			"Error": "Failed to create pack",
+			"Error": shortIDErrorMessage(err, "Failed to create pack"),
 		})
 		return
 	}
@@ -143,7 +186,7 @@ func handlePackDetail(c *gin.Context) {
 		return
 	}
 
-	if pack.UserID != userID {
+	if !userCanEditPack(db, pack, userID) {
 		c.HTML(http.StatusForbidden, "403.html", gin.H{
 			"Title": "Access Denied - Carryless",
 			"User":  user,
@@ -151,7 +194,18 @@ func handlePackDetail(c *gin.Context) {
 		return
 	}
 
-	items, err := database.GetItems(db, userID)
+	isOwner := pack.UserID == userID
+
+	var collaborators []models.PackCollaborator
+	if isOwner {
+		collaborators, err = database.GetPackCollaborators(db, packID)
+		if err != nil {
+			logger.Warn("Failed to load pack collaborators", "pack_id", packID, "error", err)
+		}
+	}
+
+	// Collaborators pack the owner's gear
+	items, err := database.GetItems(db, pack.UserID)
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "pack_detail.html", gin.H{
 			"Title": "Pack Detail - Carryless",
@@ -163,7 +217,7 @@ func handlePackDetail(c *gin.Context) {
 	}
 
 	// Get linked items count for each item to mark HasLinkedItems
-	itemLinksCount, err := database.GetItemsLinkedCount(db, userID)
+	itemLinksCount, err := database.GetItemsLinkedCount(db, pack.UserID)
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "pack_detail.html", gin.H{
 			"Title": "Pack Detail - Carryless",
@@ -181,36 +235,25 @@ func handlePackDetail(c *gin.Context) {
 		}
 	}
 
-	categoryWeights := make(map[string]int)
-	categoryWornWeights := make(map[string]int)
-	labelWeights := make(map[string]int)
-	labelColors := make(map[string]string)
+	breakdown := models.NewPackWeightBreakdown(pack.Items)
 	itemsInPack := make(map[int]bool)
-	totalWeight := 0
-	totalWornWeight := 0
-	totalItemCount := 0
-
 	for _, packItem := range pack.Items {
-		categoryName := packItem.Item.Category.Name
 		itemsInPack[packItem.Item.ID] = true
-		packWeight := packItem.Item.WeightGrams * (packItem.Count - packItem.WornCount)
-		wornWeight := packItem.Item.WeightGrams * packItem.WornCount
-		totalItemCount += packItem.Count
-		
-		if packWeight > 0 {
-			categoryWeights[categoryName] += packWeight
-			totalWeight += packWeight
-		}
-		if wornWeight > 0 {
-			categoryWornWeights[categoryName] += wornWeight
-			totalWornWeight += wornWeight
-		}
-		
-		// Calculate label weights using the actual label assignment counts
-		for _, itemLabel := range packItem.Labels {
-			labelWeights[itemLabel.PackLabel.Name] += packItem.Item.WeightGrams * itemLabel.Count
-			labelColors[itemLabel.PackLabel.Name] = itemLabel.PackLabel.Color
-		}
+	}
+
+	// Sorting by weight only changes how the items are listed, their saved order is kept
+	itemSort := c.DefaultQuery("sort", models.PackItemSortCategory)
+	var itemGroups []models.PackItemGroup
+	if itemSort == models.PackItemSortWeight {
+		itemGroups = []models.PackItemGroup{{Items: models.SortPackItemsByWeight(pack.Items)}}
+	} else {
+		itemSort = models.PackItemSortCategory
+		itemGroups = models.GroupPackItemsByCategory(pack.Items)
+	}
+
+	weightHistory, err := database.GetPackWeightHistory(db, packID)
+	if err != nil {
+		logger.Warn("Failed to load pack weight history", "pack_id", packID, "error", err)
 	}
 
 	csrfToken, err := database.CreateCSRFToken(db, userID)
@@ -230,14 +273,176 @@ func handlePackDetail(c *gin.Context) {
 		"Pack":                pack,
 		"Items":               items,
 		"ItemsInPack":         itemsInPack,
-		"CategoryWeights":     categoryWeights,
-		"CategoryWornWeights": categoryWornWeights,
-		"LabelWeights":        labelWeights,
-		"LabelColors":         labelColors,
-		"TotalWeight":         totalWeight,
-		"TotalWornWeight":     totalWornWeight,
-		"TotalItemCount":      totalItemCount,
+		"ItemGroups":          itemGroups,
+		"ItemSort":            itemSort,
+		"CategoryWeights":     breakdown.CategoryWeights(),
+		"CategoryWornWeights": breakdown.CategoryWornWeights(),
+		"CategoryOrder":       breakdown.CategoryOrder(),
+		"LabelWeights":        breakdown.LabelWeights(),
+		"LabelColors":         breakdown.LabelColors(),
+		"TotalWeight":         breakdown.Weight,
+		"TotalWornWeight":     breakdown.WornWeight,
+		"TotalItemCount":      breakdown.ItemCount,
+		"TotalVolumeLiters":   breakdown.VolumeLiters,
+		"BaseWeight":          breakdown.BaseWeight,
+		"ConsumableWeight":    breakdown.ConsumableWeight,
+		"WornWeight":          breakdown.WornWeight,
+		"WeightHistory":       weightHistory,
+		"WeightUnit":          weightUnitFor(user),
 		"CSRFToken":           csrfToken.Token,
+		"MaxCSVSize":          config.FormatSize(c.MustGet("config").(*config.Config).MaxCSVSize),
+		"IsOwner":             isOwner,
+		"Collaborators":       collaborators,
+	})
+}
+
+// packCategoryComparison holds the weight of one category in both compared packs
+type packCategoryComparison struct {
+	Category  string
+	SortOrder int
+	WeightA   int
+	WeightB   int
+	Delta     int
+}
+
+// AbsDelta returns the magnitude of the weight difference, for display
+func (p packCategoryComparison) AbsDelta() int {
+	if p.Delta < 0 {
+		return -p.Delta
+	}
+	return p.Delta
+}
+
+// packItemCountChange describes an item present in both packs with a different count
+type packItemCountChange struct {
+	Item   *models.Item
+	CountA int
+	CountB int
+}
+
+// packTotalWeight returns the weight of everything in the pack, carried and worn
+func packTotalWeight(pack *models.Pack) int {
+	total := 0
+	for _, packItem := range pack.Items {
+		total += packItem.Item.WeightGrams * packItem.Count
+	}
+	return total
+}
+
+// comparePackItems computes per-category weight deltas (B minus A) and the item-level diff between two packs
+func comparePackItems(packA, packB *models.Pack) ([]packCategoryComparison, []models.PackItem, []models.PackItem, []packItemCountChange) {
+	categoryIndex := make(map[string]int)
+	var categories []packCategoryComparison
+	addWeight := func(category *models.Category, weightA, weightB int) {
+		i, exists := categoryIndex[category.Name]
+		if !exists {
+			i = len(categories)
+			categoryIndex[category.Name] = i
+			categories = append(categories, packCategoryComparison{Category: category.Name, SortOrder: category.SortOrder})
+		}
+		categories[i].WeightA += weightA
+		categories[i].WeightB += weightB
+		categories[i].Delta = categories[i].WeightB - categories[i].WeightA
+	}
+
+	itemsA := make(map[int]models.PackItem)
+	for _, packItem := range packA.Items {
+		itemsA[packItem.ItemID] = packItem
+		addWeight(packItem.Item.Category, packItem.Item.WeightGrams*packItem.Count, 0)
+	}
+
+	itemsB := make(map[int]models.PackItem)
+	var onlyInB []models.PackItem
+	var countChanges []packItemCountChange
+	for _, packItem := range packB.Items {
+		itemsB[packItem.ItemID] = packItem
+		addWeight(packItem.Item.Category, 0, packItem.Item.WeightGrams*packItem.Count)
+
+		inA, exists := itemsA[packItem.ItemID]
+		if !exists {
+			onlyInB = append(onlyInB, packItem)
+		} else if inA.Count != packItem.Count {
+			countChanges = append(countChanges, packItemCountChange{Item: packItem.Item, CountA: inA.Count, CountB: packItem.Count})
+		}
+	}
+
+	var onlyInA []models.PackItem
+	for _, packItem := range packA.Items {
+		if _, exists := itemsB[packItem.ItemID]; !exists {
+			onlyInA = append(onlyInA, packItem)
+		}
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].SortOrder != categories[j].SortOrder {
+			return categories[i].SortOrder < categories[j].SortOrder
+		}
+		return categories[i].Category < categories[j].Category
+	})
+
+	return categories, onlyInA, onlyInB, countChanges
+}
+
+// handleComparePacks shows two of the user's packs side by side
+func handleComparePacks(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	userID := c.MustGet("user_id").(int)
+	user := c.MustGet("user")
+
+	packIDA := c.Query("a")
+	packIDB := c.Query("b")
+	if packIDA == "" || packIDB == "" {
+		c.Redirect(http.StatusFound, "/packs")
+		return
+	}
+
+	var packs []*models.Pack
+	for _, packID := range []string{packIDA, packIDB} {
+		pack, err := database.GetPackWithItems(db, packID)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				c.HTML(http.StatusNotFound, "404.html", gin.H{
+					"Title": "Pack Not Found - Carryless",
+					"User":  user,
+				})
+				return
+			}
+			logger.Error("Failed to load pack for comparison", "user_id", userID, "pack_id", packID, "error", err)
+			c.HTML(http.StatusInternalServerError, "pack_compare.html", gin.H{
+				"Title": "Compare Packs - Carryless",
+				"User":  user,
+				"Error": "Failed to load packs",
+			})
+			return
+		}
+
+		if !userCanEditPack(db, pack, userID) {
+			c.HTML(http.StatusForbidden, "403.html", gin.H{
+				"Title": "Access Denied - Carryless",
+				"User":  user,
+			})
+			return
+		}
+		packs = append(packs, pack)
+	}
+
+	packA, packB := packs[0], packs[1]
+	categories, onlyInA, onlyInB, countChanges := comparePackItems(packA, packB)
+	totalA := packTotalWeight(packA)
+	totalB := packTotalWeight(packB)
+	total := packCategoryComparison{WeightA: totalA, WeightB: totalB, Delta: totalB - totalA}
+
+	c.HTML(http.StatusOK, "pack_compare.html", gin.H{
+		"Title":        "Compare Packs - Carryless",
+		"User":         user,
+		"PackA":        packA,
+		"PackB":        packB,
+		"Total":        total,
+		"Categories":   categories,
+		"OnlyInA":      onlyInA,
+		"OnlyInB":      onlyInB,
+		"CountChanges": countChanges,
+		"WeightUnit":   weightUnitFor(user),
 	})
 }
 
@@ -272,36 +477,16 @@ func handlePublicPack(c *gin.Context) {
 		return
 	}
 
-	categoryWeights := make(map[string]int)
-	categoryWornWeights := make(map[string]int)
-	labelWeights := make(map[string]int)
-	labelColors := make(map[string]string)
-	totalWeight := 0
-	totalWornWeight := 0
-	totalItemCount := 0
-
-	for _, packItem := range pack.Items {
-		categoryName := packItem.Item.Category.Name
-		packWeight := packItem.Item.WeightGrams * (packItem.Count - packItem.WornCount)
-		wornWeight := packItem.Item.WeightGrams * packItem.WornCount
-		totalItemCount += packItem.Count
-		
-		if packWeight > 0 {
-			categoryWeights[categoryName] += packWeight
-			totalWeight += packWeight
-		}
-		if wornWeight > 0 {
-			categoryWornWeights[categoryName] += wornWeight
-			totalWornWeight += wornWeight
-		}
-		
-		// Calculate label weights using the actual label assignment counts
-		for _, itemLabel := range packItem.Labels {
-			labelWeights[itemLabel.PackLabel.Name] += packItem.Item.WeightGrams * itemLabel.Count
-			labelColors[itemLabel.PackLabel.Name] = itemLabel.PackLabel.Color
-		}
+	if pack.PublicLinkExpired() {
+		c.HTML(http.StatusNotFound, "404.html", gin.H{
+			"Title": "Pack Not Found - Carryless",
+			"User":  user,
+		})
+		return
 	}
 
+	breakdown := models.NewPackWeightBreakdown(pack.Items)
+
 	var csrfToken string
 	if userID, hasUserID := c.Get("user_id"); hasUserID {
 		if token, err := database.CreateCSRFToken(db, userID.(int)); err == nil {
@@ -313,13 +498,18 @@ func handlePublicPack(c *gin.Context) {
 		"Title":               pack.Name + " - Carryless",
 		"User":                user,
 		"Pack":                pack,
-		"CategoryWeights":     categoryWeights,
-		"CategoryWornWeights": categoryWornWeights,
-		"LabelWeights":        labelWeights,
-		"LabelColors":         labelColors,
-		"TotalWeight":         totalWeight,
-		"TotalWornWeight":     totalWornWeight,
-		"TotalItemCount":      totalItemCount,
+		"CategoryWeights":     breakdown.CategoryWeights(),
+		"CategoryWornWeights": breakdown.CategoryWornWeights(),
+		"CategoryOrder":       breakdown.CategoryOrder(),
+		"LabelWeights":        breakdown.LabelWeights(),
+		"LabelColors":         breakdown.LabelColors(),
+		"TotalWeight":         breakdown.Weight,
+		"TotalWornWeight":     breakdown.WornWeight,
+		"TotalItemCount":      breakdown.ItemCount,
+		"BaseWeight":          breakdown.BaseWeight,
+		"ConsumableWeight":    breakdown.ConsumableWeight,
+		"WornWeight":          breakdown.WornWeight,
+		"WeightUnit":          weightUnitFor(user),
 		"CSRFToken":           csrfToken,
 	})
 }
@@ -366,58 +556,142 @@ func handlePublicPackByShortID(c *gin.Context) {
 		return
 	}
 
-	categoryWeights := make(map[string]int)
-	categoryWornWeights := make(map[string]int)
-	labelWeights := make(map[string]int)
-	labelColors := make(map[string]string)
-	totalWeight := 0
-	totalWornWeight := 0
-	totalItemCount := 0
+	breakdown := models.NewPackWeightBreakdown(packWithItems.Items)
 
-	for _, packItem := range packWithItems.Items {
-		categoryName := packItem.Item.Category.Name
-		packWeight := packItem.Item.WeightGrams * (packItem.Count - packItem.WornCount)
-		wornWeight := packItem.Item.WeightGrams * packItem.WornCount
-		totalItemCount += packItem.Count
-		
-		if packWeight > 0 {
-			categoryWeights[categoryName] += packWeight
-			totalWeight += packWeight
-		}
-		if wornWeight > 0 {
-			categoryWornWeights[categoryName] += wornWeight
-			totalWornWeight += wornWeight
-		}
-		
-		// Calculate label weights using the actual label assignment counts
-		for _, itemLabel := range packItem.Labels {
-			labelWeights[itemLabel.PackLabel.Name] += packItem.Item.WeightGrams * itemLabel.Count
-			labelColors[itemLabel.PackLabel.Name] = itemLabel.PackLabel.Color
-		}
+	likeCount, err := database.GetPackLikeCount(db, pack.ID)
+	if err != nil {
+		logger.Warn("Failed to count pack likes", "pack_id", pack.ID, "error", err)
 	}
 
 	var csrfToken string
+	liked := false
 	if userID, hasUserID := c.Get("user_id"); hasUserID {
 		if token, err := database.CreateCSRFToken(db, userID.(int)); err == nil {
 			csrfToken = token.Token
 		}
+		if liked, err = database.HasUserLikedPack(db, pack.ID, userID.(int)); err != nil {
+			logger.Warn("Failed to check pack like", "pack_id", pack.ID, "user_id", userID, "error", err)
+		}
+	}
+
+	var errorMessage string
+	if c.Query("error") == "copy_failed" {
+		errorMessage = "Failed to copy the pack to your account"
 	}
 
 	c.HTML(http.StatusOK, "public_pack.html", gin.H{
 		"Title":               packWithItems.Name + " - Carryless",
 		"User":                user,
 		"Pack":                packWithItems,
-		"CategoryWeights":     categoryWeights,
-		"CategoryWornWeights": categoryWornWeights,
-		"LabelWeights":        labelWeights,
-		"LabelColors":         labelColors,
-		"TotalWeight":         totalWeight,
-		"TotalWornWeight":     totalWornWeight,
-		"TotalItemCount":      totalItemCount,
+		"Error":               errorMessage,
+		"CategoryWeights":     breakdown.CategoryWeights(),
+		"CategoryWornWeights": breakdown.CategoryWornWeights(),
+		"CategoryOrder":       breakdown.CategoryOrder(),
+		"LabelWeights":        breakdown.LabelWeights(),
+		"LabelColors":         breakdown.LabelColors(),
+		"TotalWeight":         breakdown.Weight,
+		"TotalWornWeight":     breakdown.WornWeight,
+		"TotalItemCount":      breakdown.ItemCount,
+		"BaseWeight":          breakdown.BaseWeight,
+		"ConsumableWeight":    breakdown.ConsumableWeight,
+		"WornWeight":          breakdown.WornWeight,
+		"WeightUnit":          weightUnitFor(user),
 		"CSRFToken":           csrfToken,
+		"LikeCount":           likeCount,
+		"Liked":               liked,
 	})
 }
 
+// handlePackBreakdown answers with the weight breakdown of a pack the user can edit, for charts
+func handlePackBreakdown(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	pack, err := database.GetPack(db, packID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		logger.Error("Failed to load pack", "pack_id", packID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load pack"})
+		return
+	}
+	if !userCanEditPack(db, pack, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	packBreakdownResponse(c, db, pack.ID)
+}
+
+// handlePublicPackBreakdown answers with the weight breakdown of a public pack
+func handlePublicPackBreakdown(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	shortID := c.Param("id")
+
+	pack, err := database.GetPackByShortID(db, shortID)
+	if err != nil || !pack.IsPublic {
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			logger.Error("Failed to load public pack", "short_id", shortID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load pack"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+		return
+	}
+
+	packBreakdownResponse(c, db, pack.ID)
+}
+
+func packBreakdownResponse(c *gin.Context, db *sql.DB, packID string) {
+	breakdown, err := database.GetPackWeightBreakdown(db, packID)
+	if err != nil {
+		logger.Error("Failed to compute pack breakdown", "pack_id", packID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load pack breakdown"})
+		return
+	}
+	c.JSON(http.StatusOK, breakdown)
+}
+
+// handleLikePack likes or unlikes a public pack for the logged in user
+func handleLikePack(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	shortID := c.Param("id")
+
+	userID, hasUserID := c.Get("user_id")
+	if !hasUserID {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	pack, err := database.GetPackByShortID(db, shortID)
+	if err != nil || !pack.IsPublic {
+		user, _ := c.Get("user")
+		c.HTML(http.StatusNotFound, "404.html", gin.H{
+			"Title": "Pack Not Found - Carryless",
+			"User":  user,
+		})
+		return
+	}
+
+	likedStr := c.PostForm("liked")
+	if likedStr == "false" || likedStr == "0" {
+		err = database.UnlikePack(db, pack.ID, userID.(int))
+	} else {
+		err = database.LikePack(db, pack.ID, userID.(int))
+	}
+	if err != nil {
+		logger.Error("Failed to update pack like",
+			"user_id", userID,
+			"pack_id", pack.ID,
+			"error", err)
+	}
+
+	c.Redirect(http.StatusFound, "/p/"+shortID)
+}
+
 func handleEditPackPage(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -453,12 +727,21 @@ func handleEditPackPage(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "edit_pack.html", gin.H{
+	data := gin.H{
 		"Title":     "Edit Pack - Carryless",
 		"User":      user,
 		"Pack":      pack,
 		"CSRFToken": csrfToken.Token,
-	})
+	}
+	switch c.Query("success") {
+	case "link_rotated":
+		data["Success"] = "The pack has a new public link, the previous one no longer works"
+	}
+	if c.Query("error") == "rotate_failed" {
+		data["Error"] = "Failed to create a new public link"
+	}
+
+	c.HTML(http.StatusOK, "edit_pack.html", data)
 }
 
 func handleUpdatePack(c *gin.Context) {
@@ -493,14 +776,39 @@ func handleUpdatePack(c *gin.Context) {
 	}
 
 	isPublic := isPublicStr == "true" || isPublicStr == "1"
+	isAnonymous := c.PostForm("is_anonymous") == "true"
+
+	// The public link stops working on the chosen day (UTC). Forms without the field, like the
+	// edit modal of the pack page, keep the current expiry, an empty value clears it.
+	var publicExpiresAt *time.Time
+	expiresStr, expirySent := c.GetPostForm("public_expires_at")
+	if !expirySent {
+		if pack, err := database.GetPack(db, packID); err == nil {
+			publicExpiresAt = pack.PublicExpiresAt
+		}
+	} else if expiresStr != "" {
+		t, err := time.Parse("2006-01-02", expiresStr)
+		if err != nil {
+			pack, _ := database.GetPack(db, packID)
+			c.HTML(http.StatusBadRequest, "edit_pack.html", gin.H{
+				"Title": "Edit Pack - Carryless",
+				"User":  user,
+				"Pack":  pack,
+				"Error": "Invalid link expiry date",
+			})
+			return
+		}
+		publicExpiresAt = &t
+	}
 
-	err := database.UpdatePack(db, userID, packID, name, isPublic)
+	err := database.UpdatePack(db, userID, packID, name, isPublic, isAnonymous, publicExpiresAt)
 	if err != nil {
 		var errorMsg string
 		if strings.Contains(err.Error(), "not found") {
 			errorMsg = "Pack not found"
 		} else {
-			errorMsg = "Failed to update pack"
+			logger.Error("Failed to update pack", "user_id", userID, "pack_id", packID, "error", err)
+			errorMsg = shortIDErrorMessage(err, "Failed to update pack")
 		}
 		
 		pack, _ := database.GetPack(db, packID)
@@ -516,6 +824,25 @@ func handleUpdatePack(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/packs")
 }
 
+// handleRotatePackLink gives a pack a new public link, so whoever has the previous one loses access
+func handleRotatePackLink(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	if _, err := database.RotatePackShortID(db, userID, packID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.Redirect(http.StatusFound, "/packs?error=pack_not_found")
+			return
+		}
+		logger.Error("Failed to rotate pack link", "user_id", userID, "pack_id", packID, "error", err)
+		c.Redirect(http.StatusFound, "/packs/"+packID+"/edit?error=rotate_failed")
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/packs/"+packID+"/edit?success=link_rotated")
+}
+
 func handleDeletePack(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -575,7 +902,17 @@ func handleAddItemToPack(c *gin.Context) {
 		return
 	}
 
-	err = database.AddItemToPack(db, packID, itemID, userID)
+	// Linked items come along unless the client opts out with include_linked=false
+	includeLinked := true
+	if value := c.PostForm("include_linked"); value != "" {
+		includeLinked, err = strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_linked value"})
+			return
+		}
+	}
+
+	err = database.AddItemToPack(db, packID, itemID, userID, includeLinked)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Pack or item not found"})
@@ -596,6 +933,63 @@ func handleAddItemToPack(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Item added to pack successfully"})
 }
 
+// maxBulkPackItems bounds how many items can be added to a pack in one request
+const maxBulkPackItems = 500
+
+// handleAddItemsToPack adds several inventory items to a pack at once, from a JSON body
+// like {"item_ids": [1, 2, 3]}
+func handleAddItemsToPack(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	var req struct {
+		ItemIDs []int `json:"item_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if len(req.ItemIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No items given"})
+		return
+	}
+	if len(req.ItemIDs) > maxBulkPackItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d items can be added at once", maxBulkPackItems)})
+		return
+	}
+
+	added, alreadyPresent, err := database.AddItemsToPack(db, packID, req.ItemIDs, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack or item not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+			return
+		}
+		logger.Error("Failed to add items to pack", "user_id", userID, "pack_id", packID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add items to pack"})
+		return
+	}
+
+	// Keep empty lists as [] rather than null for clients
+	if added == nil {
+		added = []int{}
+	}
+	if alreadyPresent == nil {
+		alreadyPresent = []int{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"added":           added,
+		"already_present": alreadyPresent,
+	})
+}
+
 func handleRemoveItemFromPack(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -625,7 +1019,7 @@ func handleRemoveItemFromPack(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Item removed from pack successfully"})
 }
 
-func handleToggleWorn(c *gin.Context) {
+func handleSetPackItemCount(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
 	packID := c.Param("id")
@@ -637,10 +1031,21 @@ func handleToggleWorn(c *gin.Context) {
 		return
 	}
 
-	isWornStr := c.PostForm("is_worn")
-	isWorn := isWornStr == "true" || isWornStr == "1"
+	var req struct {
+		Count *int `json:"count"`
+	}
 
-	err = database.TogglePackItemWorn(db, packID, itemID, userID, isWorn)
+	if err := c.ShouldBindJSON(&req); err != nil || req.Count == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if *req.Count < 0 || *req.Count > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Count must be between 0 and 1000"})
+		return
+	}
+
+	err = database.SetPackItemCount(db, packID, itemID, userID, *req.Count)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Pack or item not found"})
@@ -650,14 +1055,17 @@ func handleToggleWorn(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update worn status"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update item count"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Worn status updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Item count updated successfully"})
 }
 
-func handleUpdateWornCount(c *gin.Context) {
+// maxPackItemNoteLength bounds the per-pack note on an item
+const maxPackItemNoteLength = 500
+
+func handleUpdatePackItemNote(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
 	packID := c.Param("id")
@@ -669,14 +1077,22 @@ func handleUpdateWornCount(c *gin.Context) {
 		return
 	}
 
-	wornCountStr := c.PostForm("worn_count")
-	wornCount, err := strconv.Atoi(wornCountStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid worn count"})
+	var req struct {
+		Note *string `json:"note"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.Note == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	note := strings.TrimSpace(*req.Note)
+	if len(note) > maxPackItemNoteLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Note must be less than 500 characters"})
 		return
 	}
 
-	err = database.UpdatePackItemWornCount(db, packID, itemID, userID, wornCount)
+	err = database.UpdatePackItemNote(db, packID, itemID, userID, note)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Pack or item not found"})
@@ -686,29 +1102,173 @@ func handleUpdateWornCount(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update worn count"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Worn count updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Note updated successfully", "note": note})
 }
 
-func handleDuplicatePack(c *gin.Context) {
+// handleToggleWorn marks an item packed once as worn or not.
+//
+// Deprecated: the pack page uses handleUpdateWornCount for every item. This stays for
+// clients still posting is_worn.
+func handleToggleWorn(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
 	packID := c.Param("id")
 
-	logger.Debug("Duplicate pack request",
-		"user_id", userID,
-		"pack_id", packID,
-		"ip", c.ClientIP())
-
-	newPack, err := database.DuplicatePack(db, userID, packID)
+	itemIDStr := c.Param("item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
 	if err != nil {
-		logger.Error("Duplicate pack failed",
-			"user_id", userID,
-			"pack_id", packID,
-			"error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	isWornStr := c.PostForm("is_worn")
+	isWorn := isWornStr == "true" || isWornStr == "1"
+
+	err = database.TogglePackItemWorn(db, packID, itemID, userID, isWorn)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid worn count") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This item is packed more than once, set how many are worn instead"})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack or item not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update worn status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Worn status updated successfully"})
+}
+
+func handleToggleConsumable(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	itemIDStr := c.Param("item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	isConsumableStr := c.PostForm("is_consumable")
+	isConsumable := isConsumableStr == "true" || isConsumableStr == "1"
+
+	err = database.TogglePackItemConsumable(db, packID, itemID, userID, isConsumable)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack or item not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update consumable status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Consumable status updated successfully"})
+}
+
+func handleReorderPackItems(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	var req struct {
+		PackItemIDs []int `json:"pack_item_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	err := database.ReorderPackItems(db, packID, req.PackItemIDs, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+			return
+		}
+		logger.Error("Failed to reorder pack items", "user_id", userID, "pack_id", packID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder pack items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func handleUpdateWornCount(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	itemIDStr := c.Param("item_id")
+	itemID, err := strconv.Atoi(itemIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	wornCountStr := c.PostForm("worn_count")
+	wornCount, err := strconv.Atoi(wornCountStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid worn count"})
+		return
+	}
+
+	err = database.SetPackItemWornCount(db, packID, itemID, userID, wornCount)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid worn count") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Worn count must be between 0 and the item count"})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack or item not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update worn count"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Worn count updated successfully"})
+}
+
+func handleDuplicatePack(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	logger.Debug("Duplicate pack request",
+		"user_id", userID,
+		"pack_id", packID,
+		"ip", c.ClientIP())
+
+	newPack, err := database.DuplicatePack(db, userID, packID)
+	if err != nil {
+		logger.Error("Duplicate pack failed",
+			"user_id", userID,
+			"pack_id", packID,
+			"error", err)
 		if strings.Contains(err.Error(), "not found") {
 			logger.Debug("Pack not found - redirecting to packs page")
 			c.Redirect(http.StatusFound, "/packs")
@@ -737,6 +1297,50 @@ func handleDuplicatePack(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/packs")
 }
 
+// handleCopyPublicPack copies a public pack into the viewer's account. Form posts are
+// redirected to the new pack, JSON requests get its URL back.
+func handleCopyPublicPack(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	shortID := c.Param("id")
+	wantsJSON := c.ContentType() == "application/json"
+
+	pack, err := database.GetPackByShortID(db, shortID)
+	if err != nil || !pack.IsPublic {
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			logger.Error("Failed to load pack to copy", "user_id", userID, "short_id", shortID, "error", err)
+		}
+		if wantsJSON {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
+			return
+		}
+		c.HTML(http.StatusNotFound, "404.html", gin.H{
+			"Title": "Pack Not Found - Carryless",
+			"User":  c.MustGet("user"),
+		})
+		return
+	}
+
+	newPack, err := database.ClonePackToUser(db, pack.ID, userID)
+	if err != nil {
+		logger.Error("Failed to copy public pack", "user_id", userID, "pack_id", pack.ID, "error", err)
+		if wantsJSON {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy pack"})
+			return
+		}
+		c.Redirect(http.StatusFound, "/p/"+shortID+"?error=copy_failed")
+		return
+	}
+
+	logger.Info("Public pack copied", "user_id", userID, "pack_id", pack.ID, "new_pack_id", newPack.ID)
+	url := "/packs/" + newPack.ID
+	if wantsJSON {
+		c.JSON(http.StatusCreated, gin.H{"id": newPack.ID, "url": url})
+		return
+	}
+	c.Redirect(http.StatusFound, url+"?success=pack_copied")
+}
+
 func handleCreatePackLabel(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -948,13 +1552,20 @@ func handlePackChecklist(c *gin.Context) {
 			return
 		}
 		
-		if pack.UserID != userID.(int) {
+		if !userCanEditPack(db, pack, userID.(int)) {
 			c.HTML(http.StatusForbidden, "403.html", gin.H{
 				"Title": "Access Denied - Carryless",
 				"User":  user,
 			})
 			return
 		}
+	} else if pack.PublicLinkExpired() && !(hasUserID && userCanEditPack(db, pack, userID.(int))) {
+		// An expired public link only leaves the pack to the people who can edit it
+		c.HTML(http.StatusNotFound, "404.html", gin.H{
+			"Title": "Pack Not Found - Carryless",
+			"User":  user,
+		})
+		return
 	}
 
 	totalItems := 0
@@ -1040,102 +1651,764 @@ func handlePackChecklistByShortID(c *gin.Context) {
 	})
 }
 
-func handleTogglePackLock(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
-	db := c.MustGet("db").(*sql.DB)
-	packID := c.Param("id")
-
-	isLockedStr := c.PostForm("is_locked")
-	isLocked := isLockedStr == "true" || isLockedStr == "1"
+// packPDFFilename turns a pack name into a safe download filename
+func packPDFFilename(name string) string {
+	return downloadFilename(name, ".pdf")
+}
 
-	err := database.TogglePackLock(db, userID, packID, isLocked)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "unauthorized") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found or unauthorized"})
-			return
+// downloadFilename turns a pack or trip name into a safe download filename with the given extension
+func downloadFilename(name, ext string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('_')
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update archive status"})
+	}
+	if b.Len() == 0 {
+		return "pack" + ext
+	}
+	return b.String() + ext
+}
+
+// writePackPDF renders the pack as a PDF attachment
+func writePackPDF(c *gin.Context, pack *models.Pack, weightUnit string) {
+	var buf bytes.Buffer
+	if err := pdf.WritePack(&buf, pack, weightUnit); err != nil {
+		logger.Error("Failed to generate pack PDF", "pack_id", pack.ID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to generate PDF")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Archive status updated successfully"})
+	c.Header("Content-Disposition", "attachment; filename=\""+packPDFFilename(pack.Name)+"\"")
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
 }
 
-// User Pack Labels handlers (pack-level labels shared across user's packs)
-
-func handleCreateUserPackLabel(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+// handleExportPackPDF downloads one of the user's packs as a printable packing list
+func handleExportPackPDF(c *gin.Context) {
+	packID := c.Param("id")
 	db := c.MustGet("db").(*sql.DB)
+	userID := c.MustGet("user_id").(int)
+	user := c.MustGet("user")
 
-	name := c.PostForm("name")
-	color := c.PostForm("color")
-	if color == "" {
-		color = "#6b7280" // Default gray color
-	}
-
-	if strings.TrimSpace(name) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Label name is required"})
-		return
-	}
-
-	label, err := database.CreateUserPackLabel(db, userID, strings.TrimSpace(name), color)
+	pack, err := database.GetPackWithItems(db, packID)
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Label name already exists"})
+		if strings.Contains(err.Error(), "not found") {
+			c.HTML(http.StatusNotFound, "404.html", gin.H{
+				"Title": "Pack Not Found - Carryless",
+				"User":  user,
+			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create label"})
+		logger.Error("Failed to load pack for PDF export", "user_id", userID, "pack_id", packID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load pack")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Label created successfully", "label": label})
+	if !userCanEditPack(db, pack, userID) {
+		c.HTML(http.StatusForbidden, "403.html", gin.H{
+			"Title": "Access Denied - Carryless",
+			"User":  user,
+		})
+		return
+	}
+
+	writePackPDF(c, pack, weightUnitFor(user))
 }
 
-func handleUpdateUserPackLabel(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
+// handlePublicExportPackPDF downloads a public pack as a PDF through its short ID
+func handlePublicExportPackPDF(c *gin.Context) {
+	shortID := c.Param("id")
 	db := c.MustGet("db").(*sql.DB)
 
-	labelID, err := strconv.Atoi(c.Param("label_id"))
+	user, _ := c.Get("user")
+
+	pack, err := database.GetPackByShortID(db, shortID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		if strings.Contains(err.Error(), "not found") {
+			c.HTML(http.StatusNotFound, "404.html", gin.H{
+				"Title": "Pack Not Found - Carryless",
+				"User":  user,
+			})
+			return
+		}
+		c.String(http.StatusInternalServerError, "Failed to load pack")
 		return
 	}
 
-	name := c.PostForm("name")
-	color := c.PostForm("color")
-	if color == "" {
-		color = "#6b7280" // Default gray color
-	}
-
-	if strings.TrimSpace(name) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Label name is required"})
+	if !pack.IsPublic {
+		c.HTML(http.StatusForbidden, "403.html", gin.H{
+			"Title": "Access Denied - Carryless",
+			"User":  user,
+		})
 		return
 	}
 
-	err = database.UpdateUserPackLabel(db, labelID, strings.TrimSpace(name), color, userID)
+	packWithItems, err := database.GetPackWithItems(db, pack.ID)
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Label name already exists"})
-			return
-		}
-		if strings.Contains(err.Error(), "unauthorized") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			return
-		}
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update label"})
+		logger.Error("Failed to load pack for PDF export", "pack_id", pack.ID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load pack items")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Label updated successfully"})
+	writePackPDF(c, packWithItems, weightUnitFor(user))
 }
 
-func handleDeleteUserPackLabel(c *gin.Context) {
-	userID := c.MustGet("user_id").(int)
-	db := c.MustGet("db").(*sql.DB)
+// Limits applied to pack JSON imports
+const (
+	maxPackImportSize   = 1024 * 1024
+	maxPackImportItems  = 1000
+	maxPackImportLabels = 100
+)
+
+// handleExportPackJSON downloads one of the user's packs as a self-contained JSON file
+func handleExportPackJSON(c *gin.Context) {
+	packID := c.Param("id")
+	db := c.MustGet("db").(*sql.DB)
+	userID := c.MustGet("user_id").(int)
+	user := c.MustGet("user")
+
+	pack, err := database.GetPack(db, packID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.HTML(http.StatusNotFound, "404.html", gin.H{
+				"Title": "Pack Not Found - Carryless",
+				"User":  user,
+			})
+			return
+		}
+		logger.Error("Failed to load pack for JSON export", "user_id", userID, "pack_id", packID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load pack")
+		return
+	}
+
+	if !userCanEditPack(db, pack, userID) {
+		c.HTML(http.StatusForbidden, "403.html", gin.H{
+			"Title": "Access Denied - Carryless",
+			"User":  user,
+		})
+		return
+	}
+
+	export, err := database.ExportPack(db, packID)
+	if err != nil {
+		logger.Error("Failed to export pack", "user_id", userID, "pack_id", packID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to export pack")
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\""+downloadFilename(pack.Name, ".json")+"\"")
+	c.JSON(http.StatusOK, export)
+}
+
+// handleExportAllPacks downloads a ZIP backup of the user's packs and templates, one JSON export
+// per pack, along with the inventory. Each file can be imported back on its own.
+func handleExportAllPacks(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	packs, err := database.GetPacks(db, userID)
+	if err != nil {
+		logger.Error("Failed to load packs for backup", "user_id", userID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load packs")
+		return
+	}
+	templates, err := database.GetPackTemplates(db, userID)
+	if err != nil {
+		logger.Error("Failed to load pack templates for backup", "user_id", userID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load packs")
+		return
+	}
+	packs = append(packs, templates...)
+
+	// Everything is loaded before writing, so a failure can still be answered with an error
+	exports := make(map[string]*models.PackExport, len(packs))
+	names := make([]string, 0, len(packs))
+	used := make(map[string]bool)
+	for _, pack := range packs {
+		export, err := database.ExportPack(db, pack.ID)
+		if err != nil {
+			logger.Error("Failed to export pack for backup", "user_id", userID, "pack_id", pack.ID, "error", err)
+			c.String(http.StatusInternalServerError, "Failed to export packs")
+			return
+		}
+		// Packs may share a name, keep one file for each
+		base := strings.TrimSuffix(downloadFilename(pack.Name, ".json"), ".json")
+		name := "packs/" + base + ".json"
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("packs/%s_%d.json", base, i)
+		}
+		used[name] = true
+		exports[name] = export
+		names = append(names, name)
+	}
+
+	items, err := database.GetItems(db, userID)
+	if err != nil {
+		logger.Error("Failed to load inventory for backup", "user_id", userID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load inventory")
+		return
+	}
+	if items == nil {
+		items = []models.Item{}
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename=carryless-backup.zip")
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	now := time.Now()
+	writeJSON := func(name string, v interface{}) error {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	if err := writeJSON("inventory.json", items); err != nil {
+		logger.Error("Failed to write backup archive", "user_id", userID, "error", err)
+		return
+	}
+	for _, name := range names {
+		if err := writeJSON(name, exports[name]); err != nil {
+			logger.Error("Failed to write backup archive", "user_id", userID, "error", err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		logger.Error("Failed to write backup archive", "user_id", userID, "error", err)
+	}
+}
+
+// handleImportPack recreates a pack from a file produced by the JSON export
+func handleImportPack(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	file, header, err := c.Request.FormFile("packFile")
+	if err != nil {
+		c.Redirect(http.StatusFound, "/packs?error=no_file")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxPackImportSize || !strings.HasSuffix(strings.ToLower(header.Filename), ".json") {
+		c.Redirect(http.StatusFound, "/packs?error=invalid_file")
+		return
+	}
+
+	var data models.PackExport
+	if err := json.NewDecoder(io.LimitReader(file, maxPackImportSize)).Decode(&data); err != nil {
+		c.Redirect(http.StatusFound, "/packs?error=parse_error")
+		return
+	}
+
+	if err := validatePackExport(&data); err != nil {
+		logger.Warn("Rejected pack import", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/packs?error=parse_error")
+		return
+	}
+
+	newPack, err := database.ImportPack(db, userID, &data)
+	if err != nil {
+		logger.Error("Failed to import pack", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/packs?error=import_failed")
+		return
+	}
+
+	logger.Info("Pack imported", "user_id", userID, "pack_id", newPack.ID, "items", len(data.Items))
+	c.Redirect(http.StatusFound, "/packs/"+newPack.ID)
+}
+
+// handleExportPackCSV downloads the items of one of the user's packs as CSV
+func handleExportPackCSV(c *gin.Context) {
+	packID := c.Param("id")
+	db := c.MustGet("db").(*sql.DB)
+	userID := c.MustGet("user_id").(int)
+	user := c.MustGet("user")
+
+	pack, err := database.GetPackWithItems(db, packID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.HTML(http.StatusNotFound, "404.html", gin.H{
+				"Title": "Pack Not Found - Carryless",
+				"User":  user,
+			})
+			return
+		}
+		logger.Error("Failed to load pack for CSV export", "user_id", userID, "pack_id", packID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load pack")
+		return
+	}
+
+	if !userCanEditPack(db, pack, userID) {
+		c.HTML(http.StatusForbidden, "403.html", gin.H{
+			"Title": "Access Denied - Carryless",
+			"User":  user,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	if err := writer.Write([]string{"Category", "Item", "Count", "Worn Count", "Weight (grams)", "Price"}); err != nil {
+		c.String(http.StatusInternalServerError, "Failed to generate CSV")
+		return
+	}
+
+	for _, packItem := range pack.Items {
+		record := []string{
+			packItem.Item.Category.Name,
+			packItem.Item.Name,
+			strconv.Itoa(packItem.Count),
+			strconv.Itoa(packItem.WornCount),
+			strconv.Itoa(packItem.Item.WeightGrams),
+			fmt.Sprintf("%.2f", packItem.Item.Price),
+		}
+		if err := writer.Write(record); err != nil {
+			c.String(http.StatusInternalServerError, "Failed to generate CSV")
+			return
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		c.String(http.StatusInternalServerError, "Failed to generate CSV")
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\""+downloadFilename(pack.Name, ".csv")+"\"")
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// handleImportPackCSV adds the items listed in a CSV produced by the pack CSV export
+// to the pack, setting the counts of items it already contains
+func handleImportPackCSV(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+	redirectURL := "/packs/" + packID
+
+	file, header, err := c.Request.FormFile("csvFile")
+	if err != nil {
+		c.Redirect(http.StatusFound, redirectURL+"?error=no_file")
+		return
+	}
+	defer file.Close()
+
+	if err := validateCSVFile(file, header, c.MustGet("config").(*config.Config).MaxCSVSize); err != nil {
+		if strings.Contains(err.Error(), "too large") {
+			c.Redirect(http.StatusFound, redirectURL+"?error=file_too_large")
+			return
+		}
+		c.Redirect(http.StatusFound, redirectURL+"?error=invalid_file")
+		return
+	}
+
+	// Reset file position after validation
+	file.Seek(0, 0)
+
+	items, err := parsePackCSVFile(file)
+	if err != nil {
+		logger.Warn("Rejected pack CSV import", "user_id", userID, "pack_id", packID, "error", err)
+		c.Redirect(http.StatusFound, redirectURL+"?error=parse_error")
+		return
+	}
+
+	imported, err := database.ImportPackItems(db, packID, userID, items)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "unauthorized") {
+			c.Redirect(http.StatusFound, "/packs")
+			return
+		}
+		logger.Error("Failed to import pack CSV", "user_id", userID, "pack_id", packID, "error", err)
+		c.Redirect(http.StatusFound, redirectURL+"?error=import_failed")
+		return
+	}
+
+	logger.Info("Pack items imported", "user_id", userID, "pack_id", packID, "items", imported)
+	c.Redirect(http.StatusFound, redirectURL+"?success=imported")
+}
+
+// parsePackCSVFile parses a pack CSV with the columns written by handleExportPackCSV
+func parsePackCSVFile(file multipart.File) ([]models.PackExportItem, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 6
+
+	var items []models.PackExportItem
+
+	// Skip the header row
+	if _, err := reader.Read(); err != nil {
+		if err == io.EOF {
+			return items, nil
+		}
+		return nil, fmt.Errorf("CSV parse error at line 1: %v", err)
+	}
+
+	lineNumber := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		lineNumber++
+
+		if err != nil {
+			return nil, fmt.Errorf("CSV parse error at line %d: %v", lineNumber, err)
+		}
+
+		if len(items) >= maxPackImportItems {
+			return nil, fmt.Errorf("too many rows (max %d)", maxPackImportItems)
+		}
+
+		categoryName := strings.TrimSpace(record[0])
+		name := strings.TrimSpace(record[1])
+		if name == "" || categoryName == "" {
+			return nil, fmt.Errorf("empty required field at line %d", lineNumber)
+		}
+		if len(name) > 255 || len(categoryName) > 100 {
+			return nil, fmt.Errorf("field too long at line %d", lineNumber)
+		}
+
+		count, err := strconv.Atoi(strings.TrimSpace(record[2]))
+		if err != nil || count < 1 || count > 1000 {
+			return nil, fmt.Errorf("invalid count at line %d", lineNumber)
+		}
+
+		wornCount, err := strconv.Atoi(strings.TrimSpace(record[3]))
+		if err != nil || wornCount < 0 || wornCount > count {
+			return nil, fmt.Errorf("invalid worn count at line %d", lineNumber)
+		}
+
+		weight, err := strconv.Atoi(strings.TrimSpace(record[4]))
+		if err != nil || weight < 0 || weight > 100000 {
+			return nil, fmt.Errorf("invalid weight at line %d", lineNumber)
+		}
+
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[5]), 64)
+		if err != nil || price < 0 || price > 100000 {
+			return nil, fmt.Errorf("invalid price at line %d", lineNumber)
+		}
+
+		items = append(items, models.PackExportItem{
+			Name:        name,
+			Category:    categoryName,
+			WeightGrams: weight,
+			Price:       price,
+			Count:       count,
+			WornCount:   wornCount,
+		})
+	}
+
+	return items, nil
+}
+
+// validatePackExport checks an uploaded pack export against the limits the rest of the
+// app enforces, trimming names so they match how items and labels are stored
+func validatePackExport(data *models.PackExport) error {
+	if data.Version != models.PackExportVersion {
+		return fmt.Errorf("unsupported version %d", data.Version)
+	}
+
+	data.Name = strings.TrimSpace(data.Name)
+	if data.Name == "" || len(data.Name) > 200 {
+		return fmt.Errorf("invalid pack name")
+	}
+	if len(data.Note) > 500 {
+		return fmt.Errorf("pack note too long")
+	}
+	if len(data.Items) > maxPackImportItems {
+		return fmt.Errorf("too many items (max %d)", maxPackImportItems)
+	}
+	if len(data.Labels) > maxPackImportLabels {
+		return fmt.Errorf("too many labels (max %d)", maxPackImportLabels)
+	}
+
+	labels := make(map[string]bool)
+	for i := range data.Labels {
+		label := &data.Labels[i]
+		label.Name = strings.TrimSpace(label.Name)
+		if label.Name == "" || len(label.Name) > 100 {
+			return fmt.Errorf("invalid label name at label %d", i+1)
+		}
+		if labels[label.Name] {
+			return fmt.Errorf("duplicate label %q", label.Name)
+		}
+		labels[label.Name] = true
+
+		if label.Color == "" {
+			label.Color = "#6b7280" // Default gray color
+		}
+		if !isHexColor(label.Color) {
+			return fmt.Errorf("invalid color at label %d", i+1)
+		}
+	}
+
+	items := make(map[string]bool)
+	for i := range data.Items {
+		item := &data.Items[i]
+		position := i + 1
+
+		item.Name = strings.TrimSpace(item.Name)
+		item.Category = strings.TrimSpace(item.Category)
+		item.Note = strings.TrimSpace(item.Note)
+
+		if item.Name == "" || item.Category == "" {
+			return fmt.Errorf("empty required field at item %d", position)
+		}
+		if len(item.Name) > 255 || len(item.Category) > 100 || len(item.Note) > 1000 {
+			return fmt.Errorf("field too long at item %d", position)
+		}
+		if item.WeightGrams < 0 || item.WeightGrams > 100000 {
+			return fmt.Errorf("invalid weight at item %d", position)
+		}
+		if item.Price < 0 || item.Price > 100000 {
+			return fmt.Errorf("invalid price at item %d", position)
+		}
+		if item.Brand != nil && len(*item.Brand) > 100 {
+			return fmt.Errorf("brand too long at item %d", position)
+		}
+		if item.Model != nil && len(*item.Model) > 100 {
+			return fmt.Errorf("model too long at item %d", position)
+		}
+		if item.Count < 1 || item.Count > 1000 {
+			return fmt.Errorf("invalid count at item %d", position)
+		}
+		if item.WornCount < 0 || item.WornCount > item.Count {
+			return fmt.Errorf("invalid worn count at item %d", position)
+		}
+
+		key := strings.ToLower(item.Category) + "\x00" + strings.ToLower(item.Name)
+		if items[key] {
+			return fmt.Errorf("duplicate item at item %d", position)
+		}
+		items[key] = true
+
+		assigned := make(map[string]bool)
+		for _, itemLabel := range item.Labels {
+			if !labels[itemLabel.Label] || assigned[itemLabel.Label] {
+				return fmt.Errorf("invalid label reference at item %d", position)
+			}
+			if itemLabel.Count < 1 || itemLabel.Count > item.Count {
+				return fmt.Errorf("invalid label count at item %d", position)
+			}
+			assigned[itemLabel.Label] = true
+		}
+	}
+
+	return nil
+}
+
+// isHexColor reports whether color is a #rrggbb color
+func isHexColor(color string) bool {
+	if len(color) != 7 || color[0] != '#' {
+		return false
+	}
+	for _, r := range color[1:] {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func handleSetPackTemplate(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	isTemplate := c.PostForm("is_template") == "true"
+
+	err := database.SetPackTemplate(db, userID, packID, isTemplate)
+	if err != nil {
+		logger.Error("Failed to update pack template status",
+			"user_id", userID,
+			"pack_id", packID,
+			"error", err)
+		c.Redirect(http.StatusFound, "/packs")
+		return
+	}
+
+	if isTemplate {
+		c.Redirect(http.StatusFound, "/packs?view=templates")
+		return
+	}
+	c.Redirect(http.StatusFound, "/packs")
+}
+
+func handleCreatePackFromTemplate(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	templateID := c.PostForm("template_id")
+	name := strings.TrimSpace(c.PostForm("name"))
+	if templateID == "" || len(name) > 200 {
+		c.Redirect(http.StatusFound, "/packs")
+		return
+	}
+
+	newPack, err := database.CreatePackFromTemplate(db, userID, templateID, name)
+	if err != nil {
+		if strings.Contains(err.Error(), "unauthorized") {
+			logger.Warn("Unauthorized template access attempt",
+				"user_id", userID,
+				"template_id", templateID)
+		} else {
+			logger.Error("Failed to create pack from template",
+				"user_id", userID,
+				"template_id", templateID,
+				"error", err)
+		}
+		c.Redirect(http.StatusFound, "/packs")
+		return
+	}
+
+	logger.Info("Pack created from template",
+		"user_id", userID,
+		"template_id", templateID,
+		"new_pack_id", newPack.ID)
+	c.Redirect(http.StatusFound, "/packs/"+newPack.ID)
+}
+
+func handleTogglePackFavorite(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	favoriteStr := c.PostForm("is_favorite")
+	favorite := favoriteStr == "true" || favoriteStr == "1"
+
+	err := database.TogglePackFavorite(db, userID, packID, favorite)
+	if err != nil {
+		logger.Error("Failed to update pack favorite status",
+			"user_id", userID,
+			"pack_id", packID,
+			"error", err)
+	}
+
+	c.Redirect(http.StatusFound, "/packs")
+}
+
+func handleTogglePackLock(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+
+	isLockedStr := c.PostForm("is_locked")
+	isLocked := isLockedStr == "true" || isLockedStr == "1"
+
+	err := database.TogglePackLock(db, userID, packID, isLocked)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found or unauthorized"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update archive status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Archive status updated successfully"})
+}
+
+// User Pack Labels handlers (pack-level labels shared across user's packs)
+
+func handleGetUserPackLabels(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	labels, err := database.GetUserPackLabels(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load labels"})
+		return
+	}
+	if labels == nil {
+		labels = []models.UserPackLabel{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"labels": labels})
+}
+
+func handleCreateUserPackLabel(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	name := c.PostForm("name")
+	color := c.PostForm("color")
+	if color == "" {
+		color = "#6b7280" // Default gray color
+	}
+
+	if strings.TrimSpace(name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Label name is required"})
+		return
+	}
+
+	label, err := database.CreateUserPackLabel(db, userID, strings.TrimSpace(name), color)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Label name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create label"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Label created successfully", "label": label})
+}
+
+func handleUpdateUserPackLabel(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	labelID, err := strconv.Atoi(c.Param("label_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label ID"})
+		return
+	}
+
+	name := c.PostForm("name")
+	color := c.PostForm("color")
+	if color == "" {
+		color = "#6b7280" // Default gray color
+	}
+
+	if strings.TrimSpace(name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Label name is required"})
+		return
+	}
+
+	err = database.UpdateUserPackLabel(db, labelID, strings.TrimSpace(name), color, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Label name already exists"})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update label"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Label updated successfully"})
+}
+
+func handleDeleteUserPackLabel(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
 
 	labelID, err := strconv.Atoi(c.Param("label_id"))
 	if err != nil {
@@ -1218,4 +2491,149 @@ func handleRemovePackLevelLabel(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Label removed successfully"})
-}
\ No newline at end of file
+}
+
+// explorePageSize is the number of public packs listed per page on the explore page
+const explorePageSize = 24
+
+// handleExplorePacks lists the packs shared publicly by all users
+func handleExplorePacks(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	user, _ := c.Get("user")
+
+	sort := c.DefaultQuery("sort", database.PublicPackSortNewest)
+	if sort != database.PublicPackSortLightest && sort != database.PublicPackSortLiked {
+		sort = database.PublicPackSortNewest
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	total, err := database.CountPublicPacks(db)
+	if err != nil {
+		logger.Error("Failed to count public packs", "error", err)
+		c.HTML(http.StatusInternalServerError, "explore.html", gin.H{
+			"Title": "Explore Packs - Carryless",
+			"User":  user,
+			"Error": "Failed to load packs",
+		})
+		return
+	}
+
+	totalPages := (total + explorePageSize - 1) / explorePageSize
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+
+	packs, err := database.GetPublicPacks(db, explorePageSize, (page-1)*explorePageSize, sort)
+	if err != nil {
+		logger.Error("Failed to get public packs", "sort", sort, "page", page, "error", err)
+		c.HTML(http.StatusInternalServerError, "explore.html", gin.H{
+			"Title": "Explore Packs - Carryless",
+			"User":  user,
+			"Error": "Failed to load packs",
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "explore.html", gin.H{
+		"Title":      "Explore Packs - Carryless",
+		"User":       user,
+		"Packs":      packs,
+		"Sort":       sort,
+		"Page":       page,
+		"TotalPages": totalPages,
+		"HasPrev":    page > 1,
+		"HasNext":    page < totalPages,
+		"WeightUnit": weightUnitFor(user),
+	})
+}
+
+// handleAddPackCollaborator shares the pack with the user registered with the submitted email
+func handleAddPackCollaborator(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+	redirectURL := "/packs/" + packID
+
+	email := strings.TrimSpace(c.PostForm("email"))
+	role := c.DefaultPostForm("role", database.PackRoleEditor)
+
+	collaborator, err := database.AddPackCollaborator(db, userID, packID, email, role)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "unauthorized"), strings.Contains(err.Error(), "pack not found"):
+			c.Redirect(http.StatusFound, "/packs")
+		case strings.Contains(err.Error(), "user not found"):
+			c.Redirect(http.StatusFound, redirectURL+"?error=collaborator_not_found")
+		case strings.Contains(err.Error(), "its owner"):
+			c.Redirect(http.StatusFound, redirectURL+"?error=collaborator_self")
+		default:
+			logger.Error("Failed to add pack collaborator", "user_id", userID, "pack_id", packID, "error", err)
+			c.Redirect(http.StatusFound, redirectURL+"?error=collaborator_failed")
+		}
+		return
+	}
+
+	logger.Info("Pack shared", "user_id", userID, "pack_id", packID, "collaborator_id", collaborator.UserID, "role", collaborator.Role)
+	c.Redirect(http.StatusFound, redirectURL+"?success=collaborator_added")
+}
+
+// handleRemovePackCollaborator stops sharing the pack with a collaborator. Collaborators use it
+// to leave a pack, and are sent back to their pack list since they can no longer open it.
+func handleRemovePackCollaborator(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	packID := c.Param("id")
+	redirectURL := "/packs/" + packID
+
+	collaboratorID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, redirectURL+"?error=collaborator_failed")
+		return
+	}
+
+	if err := database.RemovePackCollaborator(db, userID, packID, collaboratorID); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "unauthorized"), strings.Contains(err.Error(), "pack not found"):
+			c.Redirect(http.StatusFound, "/packs")
+		default:
+			if !strings.Contains(err.Error(), "not found") {
+				logger.Error("Failed to remove pack collaborator", "user_id", userID, "pack_id", packID, "error", err)
+			}
+			c.Redirect(http.StatusFound, redirectURL+"?error=collaborator_failed")
+		}
+		return
+	}
+
+	logger.Info("Pack collaborator removed", "user_id", userID, "pack_id", packID, "collaborator_id", collaboratorID)
+	if collaboratorID == userID {
+		c.Redirect(http.StatusFound, "/packs")
+		return
+	}
+	c.Redirect(http.StatusFound, redirectURL+"?success=collaborator_removed")
+}
+
+// shortIDErrorMessage tells the user to retry when no free public link could be generated,
+// and falls back to message for any other error
+func shortIDErrorMessage(err error, message string) string {
+	if errors.Is(err, database.ErrShortIDExhausted) {
+		return "Could not generate a public link. Please try again."
+	}
+	return message
+}
+
+// userCanEditPack reports whether the user owns the pack or was invited to edit it
+func userCanEditPack(db *sql.DB, pack *models.Pack, userID int) bool {
+	canEdit, err := database.CanEditPack(db, pack, userID)
+	if err != nil {
+		logger.Error("Failed to check pack access", "pack_id", pack.ID, "user_id", userID, "error", err)
+		return false
+	}
+	return canEdit
+}
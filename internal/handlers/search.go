@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"carryless/internal/database"
+	"carryless/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchQueryLength bounds the search query, longer ones are cut
+const maxSearchQueryLength = 100
+
+// handleSearch looks for a query in the user's items, packs and trips. It renders the search page,
+// or answers with JSON when the client asks for it.
+func handleSearch(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user")
+
+	query := c.Query("q")
+	if runes := []rune(query); len(runes) > maxSearchQueryLength {
+		query = string(runes[:maxSearchQueryLength])
+	}
+	wantsJSON := c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+
+	results, err := database.SearchAll(db, userID, query)
+	if err != nil {
+		logger.Error("Failed to search", "user_id", userID, "error", err)
+		if wantsJSON {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search"})
+			return
+		}
+		c.HTML(http.StatusInternalServerError, "search.html", gin.H{
+			"Title": "Search - Carryless",
+			"User":  user,
+			"Query": query,
+			"Error": "Failed to search",
+		})
+		return
+	}
+
+	if wantsJSON {
+		c.JSON(http.StatusOK, results)
+		return
+	}
+
+	c.HTML(http.StatusOK, "search.html", gin.H{
+		"Title":   "Search - Carryless",
+		"User":    user,
+		"Query":   results.Query,
+		"Results": results,
+		"Limit":   database.SearchResultLimit,
+	})
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"carryless/internal/config"
+	"carryless/internal/database"
+	"carryless/internal/logger"
+	"carryless/internal/models"
+	"carryless/internal/totp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recoveryCodeCount is how many recovery codes are issued each time codes are generated
+const recoveryCodeCount = 10
+
+// totpIssuer is the account name prefix shown in authenticator apps
+const totpIssuer = "Carryless"
+
+// handleSetupTOTP starts an enrollment once the user confirmed their password, and shows the
+// secret to add to an authenticator app
+func handleSetupTOTP(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	cfg := c.MustGet("config").(*config.Config)
+
+	if !cfg.TOTPEnabled() {
+		c.Redirect(http.StatusFound, "/account?error=2fa_unavailable")
+		return
+	}
+
+	if err := database.VerifyPassword(db, userID, c.PostForm("current_password")); err != nil {
+		c.Redirect(http.StatusFound, "/account?error=2fa_password")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logger.Error("Failed to generate two-factor secret", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	encrypted, err := totp.Encrypt(cfg.TOTPEncryptionKey, secret)
+	if err != nil {
+		logger.Error("Failed to encrypt two-factor secret", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	if err := database.BeginTOTPEnrollment(db, userID, encrypted); err != nil {
+		if strings.Contains(err.Error(), "already enabled") {
+			c.Redirect(http.StatusFound, "/account?error=2fa_already_enabled")
+			return
+		}
+		logger.Error("Failed to start two-factor enrollment", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	renderTOTPSetup(c, http.StatusOK, secret, "")
+}
+
+// handleEnableTOTP enables the pending enrollment once the user entered a valid code, and
+// shows the recovery codes
+func handleEnableTOTP(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	cfg := c.MustGet("config").(*config.Config)
+
+	if !cfg.TOTPEnabled() {
+		c.Redirect(http.StatusFound, "/account?error=2fa_unavailable")
+		return
+	}
+
+	enrollment, err := database.GetUserTOTP(db, userID)
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			logger.Error("Failed to get two-factor enrollment", "user_id", userID, "error", err)
+		}
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+	if enrollment.Enabled {
+		c.Redirect(http.StatusFound, "/account?error=2fa_already_enabled")
+		return
+	}
+
+	secret, err := totp.Decrypt(cfg.TOTPEncryptionKey, enrollment.Secret)
+	if err != nil {
+		logger.Error("Failed to decrypt two-factor secret", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	step, ok := totp.Validate(secret, c.PostForm("code"), time.Now())
+	if !ok {
+		renderTOTPSetup(c, http.StatusBadRequest, secret, "Invalid code. Check that your device's clock is correct and try again.")
+		return
+	}
+
+	recoveryCodes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		logger.Error("Failed to generate recovery codes", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	if err := database.EnableTOTP(db, userID, step, recoveryCodes); err != nil {
+		logger.Error("Failed to enable two-factor authentication", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	logger.Info("Two-factor authentication enabled", "user_id", userID)
+
+	c.HTML(http.StatusOK, "totp_recovery_codes.html", gin.H{
+		"Title":         "Recovery Codes - Carryless",
+		"User":          c.MustGet("user"),
+		"Success":       "Two-factor authentication is now enabled",
+		"RecoveryCodes": recoveryCodes,
+	})
+}
+
+// handleRegenerateRecoveryCodes replaces the user's recovery codes after a password check
+func handleRegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+
+	if err := database.VerifyPassword(db, userID, c.PostForm("current_password")); err != nil {
+		c.Redirect(http.StatusFound, "/account?error=2fa_password")
+		return
+	}
+
+	recoveryCodes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		logger.Error("Failed to generate recovery codes", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	if err := database.RegenerateRecoveryCodes(db, userID, recoveryCodes); err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			logger.Error("Failed to regenerate recovery codes", "user_id", userID, "error", err)
+		}
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	c.HTML(http.StatusOK, "totp_recovery_codes.html", gin.H{
+		"Title":         "Recovery Codes - Carryless",
+		"User":          c.MustGet("user"),
+		"Success":       "New recovery codes generated, the previous ones no longer work",
+		"RecoveryCodes": recoveryCodes,
+	})
+}
+
+// handleDisableTOTP turns off two-factor authentication. Both the password and a current code
+// are required, so a stolen session alone can't remove the second factor.
+func handleDisableTOTP(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	cfg := c.MustGet("config").(*config.Config)
+
+	if err := database.VerifyPassword(db, userID, c.PostForm("current_password")); err != nil {
+		c.Redirect(http.StatusFound, "/account?error=2fa_password")
+		return
+	}
+
+	if err := verifySecondFactor(db, cfg, userID, c.PostForm("code")); err != nil {
+		c.Redirect(http.StatusFound, "/account?error=2fa_invalid_code")
+		return
+	}
+
+	if err := database.DisableTOTP(db, userID); err != nil {
+		logger.Error("Failed to disable two-factor authentication", "user_id", userID, "error", err)
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	logger.Info("Two-factor authentication disabled", "user_id", userID)
+	c.Redirect(http.StatusFound, "/account?success=2fa_disabled")
+}
+
+// handleLoginTOTP completes a login started by handleLogin for a user with two-factor
+// authentication enabled
+func handleLoginTOTP(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	cfg := c.MustGet("config").(*config.Config)
+	token := c.PostForm("challenge")
+
+	challenge, err := database.GetLoginChallenge(db, token)
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			logger.Error("Failed to get login challenge", "error", err)
+		}
+		c.HTML(http.StatusBadRequest, "login.html", gin.H{
+			"Title":  "Login - Carryless",
+			"Errors": map[string]string{"general": "Your login has expired. Please log in again."},
+		})
+		return
+	}
+
+	if err := verifySecondFactor(db, cfg, challenge.UserID, c.PostForm("code")); err != nil {
+		if err := database.RecordLoginChallengeFailure(db, token); err != nil {
+			logger.Error("Failed to record login challenge failure", "user_id", challenge.UserID, "error", err)
+		}
+		logger.Warn("Failed two-factor login attempt", "user_id", challenge.UserID, "ip", c.ClientIP())
+		c.HTML(http.StatusBadRequest, "login_totp.html", gin.H{
+			"Title":     "Two-Factor Authentication - Carryless",
+			"Challenge": token,
+			"Error":     "Invalid code",
+		})
+		return
+	}
+
+	if err := database.DeleteLoginChallenge(db, token); err != nil {
+		logger.Error("Failed to delete login challenge", "user_id", challenge.UserID, "error", err)
+	}
+
+	user, err := database.GetUserByID(db, challenge.UserID)
+	if err != nil {
+		logger.Error("Failed to get user for login challenge", "user_id", challenge.UserID, "error", err)
+		c.HTML(http.StatusInternalServerError, "login.html", gin.H{
+			"Title":  "Login - Carryless",
+			"Errors": map[string]string{"general": "Failed to log in. Please try again."},
+		})
+		return
+	}
+
+	if user.IsSuspended {
+		c.HTML(http.StatusForbidden, "login.html", gin.H{
+			"Title":  "Login - Carryless",
+			"Errors": map[string]string{"general": "This account has been suspended. Please contact support."},
+		})
+		return
+	}
+
+	startSession(c, db, user, challenge.RememberMe)
+}
+
+// verifySecondFactor accepts either a code from the user's authenticator app or one of their
+// recovery codes, which is consumed
+func verifySecondFactor(db *sql.DB, cfg *config.Config, userID int, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return fmt.Errorf("code is required")
+	}
+
+	if !isTOTPCode(code) {
+		return database.UseRecoveryCode(db, userID, code)
+	}
+
+	if !cfg.TOTPEnabled() {
+		// Recovery codes are hashed rather than encrypted and keep working without the key
+		logger.Error("Two-factor code submitted but TOTP_ENCRYPTION_KEY is not configured", "user_id", userID)
+		return fmt.Errorf("two-factor authentication unavailable")
+	}
+
+	enrollment, err := database.GetUserTOTP(db, userID)
+	if err != nil {
+		return err
+	}
+	if !enrollment.Enabled {
+		return fmt.Errorf("two-factor authentication not found")
+	}
+
+	secret, err := totp.Decrypt(cfg.TOTPEncryptionKey, enrollment.Secret)
+	if err != nil {
+		logger.Error("Failed to decrypt two-factor secret", "user_id", userID, "error", err)
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return fmt.Errorf("invalid code")
+	}
+
+	return database.UseTOTPStep(db, userID, step)
+}
+
+// isTOTPCode tells authenticator codes, made of digits only, apart from recovery codes
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func renderTOTPSetup(c *gin.Context, status int, secret, errorMessage string) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+
+	csrfToken, err := database.CreateCSRFToken(db, userID)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/account?error=2fa_failed")
+		return
+	}
+
+	// otpauth: links would otherwise be rewritten as unsafe by html/template
+	otpauthURL := template.URL(totp.URL(totpIssuer, user.Email, secret))
+
+	c.HTML(status, "totp_setup.html", gin.H{
+		"Title":      "Set Up Two-Factor Authentication - Carryless",
+		"User":       user,
+		"CSRFToken":  csrfToken.Token,
+		"Secret":     groupSecret(secret),
+		"OTPAuthURL": otpauthURL,
+		"Error":      errorMessage,
+	})
+}
+
+// groupSecret splits the secret in blocks of four characters so it's easier to type
+func groupSecret(secret string) string {
+	var groups []string
+	for len(secret) > 4 {
+		groups = append(groups, secret[:4])
+		secret = secret[4:]
+	}
+	return strings.Join(append(groups, secret), " ")
+}
@@ -1,15 +1,24 @@
 package handlers
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"carryless/internal/config"
 	"carryless/internal/database"
+	"carryless/internal/email"
+	"carryless/internal/gpx"
+	"carryless/internal/ical"
 	"carryless/internal/logger"
+	"carryless/internal/models"
+	"carryless/internal/weather"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,7 +29,10 @@ func handleTrips(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 	user := c.MustGet("user")
 
-	trips, err := database.GetTrips(db, userID)
+	// Archived trips are only listed when asked for
+	showArchived := c.Query("archived") == "1"
+
+	trips, err := database.GetTripsByArchived(db, userID, showArchived)
 	if err != nil {
 		logger.Error("Failed to get trips", "user_id", userID, "error", err)
 		c.HTML(http.StatusInternalServerError, "trips.html", gin.H{
@@ -42,11 +54,18 @@ func handleTrips(c *gin.Context) {
 		return
 	}
 
+	archivedCount, err := database.CountArchivedTrips(db, userID)
+	if err != nil {
+		logger.Warn("Failed to count archived trips", "user_id", userID, "error", err)
+	}
+
 	c.HTML(http.StatusOK, "trips.html", gin.H{
-		"Title":     "Trips - Carryless",
-		"User":      user,
-		"Trips":     trips,
-		"CSRFToken": csrfToken.Token,
+		"Title":         "Trips - Carryless",
+		"User":          user,
+		"Trips":         trips,
+		"ShowArchived":  showArchived,
+		"ArchivedCount": archivedCount,
+		"CSRFToken":     csrfToken.Token,
 	})
 }
 
@@ -129,13 +148,26 @@ func handleCreateTrip(c *gin.Context) {
 	isPublicStr := c.PostForm("is_public")
 	isPublic := isPublicStr == "true"
 
+	if err := database.ValidateTripDates(startDate, endDate); err != nil {
+		data := gin.H{
+			"Title": "New Trip - Carryless",
+			"User":  c.MustGet("user"),
+			"Error": "Invalid dates: " + err.Error(),
+		}
+		if csrfToken, tokenErr := database.CreateCSRFToken(db, userID); tokenErr == nil {
+			data["CSRFToken"] = csrfToken.Token
+		}
+		c.HTML(http.StatusBadRequest, "new_trip.html", data)
+		return
+	}
+
 	trip, err := database.CreateTrip(db, userID, name, description, location, startDate, endDate, isPublic)
 	if err != nil {
 		logger.Error("Failed to create trip", "user_id", userID, "error", err)
 		c.HTML(http.StatusInternalServerError, "new_trip.html", gin.H{
 			"Title": "New Trip - Carryless",
 			"User":  c.MustGet("user"),
-			"Error": "Failed to create trip",
+			"Error": shortIDErrorMessage(err, "Failed to create trip"),
 		})
 		return
 	}
@@ -169,6 +201,8 @@ func handleTripDetail(c *gin.Context) {
 		return
 	}
 
+	selectedGPXFile := selectedTripGPXFile(c, trip)
+
 	// Get user's packs for the pack selector
 	allPacks, err := database.GetPacks(db, userID)
 	if err != nil {
@@ -179,23 +213,49 @@ func handleTripDetail(c *gin.Context) {
 	if err != nil {
 		logger.Error("Failed to create CSRF token", "user_id", userID, "error", err)
 		c.HTML(http.StatusInternalServerError, "trip_detail.html", gin.H{
-			"Title": "Trip - Carryless",
-			"User":  user,
-			"Trip":  trip,
-			"Error": "Failed to generate security token",
+			"Title":           "Trip - Carryless",
+			"User":            user,
+			"Trip":            trip,
+			"SelectedGPXFile": selectedGPXFile,
+			"Error":           "Failed to generate security token",
 		})
 		return
 	}
 
+	transportCosts, err := database.GetTripCostSummary(db, tripID)
+	if err != nil {
+		logger.Error("Failed to get trip cost summary", "user_id", userID, "trip_id", tripID, "error", err)
+	}
+
 	c.HTML(http.StatusOK, "trip_detail.html", gin.H{
-		"Title":     trip.Name + " - Carryless",
-		"User":      user,
-		"Trip":      trip,
-		"AllPacks":  allPacks,
-		"CSRFToken": csrfToken.Token,
+		"Title":           trip.Name + " - Carryless",
+		"User":            user,
+		"Trip":            trip,
+		"AllPacks":        allPacks,
+		"TransportCosts":  transportCosts,
+		"SelectedGPXFile": selectedGPXFile,
+		"CSRFToken":       csrfToken.Token,
 	})
 }
 
+// selectedTripGPXFile returns the GPX track picked with the track query parameter,
+// falling back to the trip's first track. It returns nil when the trip has no tracks.
+func selectedTripGPXFile(c *gin.Context, trip *models.Trip) *models.TripGPXFile {
+	if len(trip.GPXFiles) == 0 {
+		return nil
+	}
+
+	if fileID, err := strconv.Atoi(c.Query("track")); err == nil {
+		for i := range trip.GPXFiles {
+			if trip.GPXFiles[i].ID == fileID {
+				return &trip.GPXFiles[i]
+			}
+		}
+	}
+
+	return &trip.GPXFiles[0]
+}
+
 // handleEditTripPage displays the edit trip form
 func handleEditTripPage(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
@@ -299,13 +359,44 @@ func handleUpdateTrip(c *gin.Context) {
 	isPublicStr := c.PostForm("is_public")
 	isPublic := isPublicStr == "true"
 
+	if err := database.ValidateTripDates(startDate, endDate); err != nil {
+		trip, getErr := database.GetTrip(db, tripID)
+		if getErr != nil || trip.UserID != userID {
+			c.HTML(http.StatusNotFound, "404.html", gin.H{
+				"Title": "Trip Not Found - Carryless",
+				"User":  user,
+			})
+			return
+		}
+
+		// Keep the submitted values so the user can correct them
+		trip.Name = name
+		trip.Description = description
+		trip.Location = location
+		trip.StartDate = startDate
+		trip.EndDate = endDate
+		trip.IsPublic = isPublic
+
+		data := gin.H{
+			"Title": "Edit Trip - Carryless",
+			"User":  user,
+			"Trip":  trip,
+			"Error": "Invalid dates: " + err.Error(),
+		}
+		if csrfToken, tokenErr := database.CreateCSRFToken(db, userID); tokenErr == nil {
+			data["CSRFToken"] = csrfToken.Token
+		}
+		c.HTML(http.StatusBadRequest, "edit_trip.html", data)
+		return
+	}
+
 	err := database.UpdateTrip(db, userID, tripID, name, description, location, startDate, endDate, isPublic)
 	if err != nil {
 		logger.Error("Failed to update trip", "user_id", userID, "trip_id", tripID, "error", err)
 		c.HTML(http.StatusInternalServerError, "edit_trip.html", gin.H{
 			"Title": "Edit Trip - Carryless",
 			"User":  user,
-			"Error": "Failed to update trip",
+			"Error": shortIDErrorMessage(err, "Failed to update trip"),
 		})
 		return
 	}
@@ -345,9 +436,38 @@ func handleArchiveTrip(c *gin.Context) {
 		return
 	}
 
+	// Trips are unarchived from the archived list, stay there
+	if !isArchived {
+		c.Redirect(http.StatusFound, "/trips?archived=1")
+		return
+	}
 	c.Redirect(http.StatusFound, "/trips")
 }
 
+// handleDuplicateTrip creates a copy of a trip and redirects to it
+func handleDuplicateTrip(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	tripID := c.Param("id")
+
+	newTrip, err := database.DuplicateTrip(db, userID, tripID)
+	if err != nil {
+		if strings.Contains(err.Error(), "unauthorized") {
+			logger.Warn("Unauthorized trip duplication attempt", "user_id", userID, "trip_id", tripID)
+		} else {
+			logger.Error("Failed to duplicate trip", "user_id", userID, "trip_id", tripID, "error", err)
+		}
+		c.Redirect(http.StatusFound, "/trips")
+		return
+	}
+
+	logger.Info("Trip duplication successful",
+		"user_id", userID,
+		"original_trip_id", tripID,
+		"new_trip_id", newTrip.ID)
+	c.Redirect(http.StatusFound, "/trips/"+newTrip.ID)
+}
+
 // Pack Association Handlers
 
 // handleAddPackToTrip adds a pack to a trip
@@ -422,6 +542,26 @@ func handleAddChecklistItem(c *gin.Context) {
 	c.JSON(http.StatusOK, item)
 }
 
+// handleGenerateChecklist fills the trip checklist with the items of its packs
+func handleGenerateChecklist(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	tripID := c.Param("id")
+
+	added, err := database.GenerateChecklistFromPacks(db, tripID, userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		logger.Error("Failed to generate checklist", "user_id", userID, "trip_id", tripID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate checklist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "added": added})
+}
+
 // handleUpdateChecklistItem updates a checklist item
 func handleUpdateChecklistItem(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
@@ -532,14 +672,16 @@ func handleAddTransportStep(c *gin.Context) {
 	tripID := c.Param("id")
 
 	var req struct {
-		JourneyType       string  `json:"journey_type"`
-		DeparturePlace    string  `json:"departure_place"`
-		DepartureDatetime *string `json:"departure_datetime"`
-		ArrivalPlace      *string `json:"arrival_place"`
-		ArrivalDatetime   *string `json:"arrival_datetime"`
-		TransportType     *string `json:"transport_type"`
-		TransportNumber   *string `json:"transport_number"`
-		Notes             *string `json:"notes"`
+		JourneyType       string   `json:"journey_type"`
+		DeparturePlace    string   `json:"departure_place"`
+		DepartureDatetime *string  `json:"departure_datetime"`
+		ArrivalPlace      *string  `json:"arrival_place"`
+		ArrivalDatetime   *string  `json:"arrival_datetime"`
+		TransportType     *string  `json:"transport_type"`
+		TransportNumber   *string  `json:"transport_number"`
+		Notes             *string  `json:"notes"`
+		Cost              *float64 `json:"cost"`
+		Currency          *string  `json:"currency"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -584,7 +726,13 @@ func handleAddTransportStep(c *gin.Context) {
 		}
 	}
 
-	step, err := database.AddTransportStep(db, tripID, req.JourneyType, departurePlace, departureDatetime, arrivalPlace, arrivalDatetime, req.TransportType, req.TransportNumber, req.Notes, userID)
+	cost, currency, err := parseTransportCost(req.Cost, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	step, err := database.AddTransportStep(db, tripID, req.JourneyType, departurePlace, departureDatetime, arrivalPlace, arrivalDatetime, req.TransportType, req.TransportNumber, req.Notes, cost, currency, userID)
 	if err != nil {
 		logger.Error("Failed to add transport step", "user_id", userID, "trip_id", tripID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add transport step"})
@@ -594,6 +742,29 @@ func handleAddTransportStep(c *gin.Context) {
 	c.JSON(http.StatusOK, step)
 }
 
+// parseTransportCost validates the optional cost of a transport step. The currency is only
+// kept alongside a cost, and an empty currency means the owner's default currency.
+func parseTransportCost(cost *float64, currency *string) (*float64, *string, error) {
+	if cost == nil {
+		return nil, nil, nil
+	}
+	if *cost < 0 || *cost > 1000000 {
+		return nil, nil, fmt.Errorf("Cost must be between 0 and 1000000")
+	}
+
+	if currency == nil {
+		return cost, nil, nil
+	}
+	trimmed := strings.TrimSpace(*currency)
+	if trimmed == "" {
+		return cost, nil, nil
+	}
+	if !validCurrencies[trimmed] {
+		return nil, nil, fmt.Errorf("Invalid currency")
+	}
+	return cost, &trimmed, nil
+}
+
 // handleUpdateTransportStep updates a transport step
 func handleUpdateTransportStep(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
@@ -605,13 +776,15 @@ func handleUpdateTransportStep(c *gin.Context) {
 	}
 
 	var req struct {
-		DeparturePlace    string  `json:"departure_place"`
-		DepartureDatetime *string `json:"departure_datetime"`
-		ArrivalPlace      *string `json:"arrival_place"`
-		ArrivalDatetime   *string `json:"arrival_datetime"`
-		TransportType     *string `json:"transport_type"`
-		TransportNumber   *string `json:"transport_number"`
-		Notes             *string `json:"notes"`
+		DeparturePlace    string   `json:"departure_place"`
+		DepartureDatetime *string  `json:"departure_datetime"`
+		ArrivalPlace      *string  `json:"arrival_place"`
+		ArrivalDatetime   *string  `json:"arrival_datetime"`
+		TransportType     *string  `json:"transport_type"`
+		TransportNumber   *string  `json:"transport_number"`
+		Notes             *string  `json:"notes"`
+		Cost              *float64 `json:"cost"`
+		Currency          *string  `json:"currency"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -651,7 +824,13 @@ func handleUpdateTransportStep(c *gin.Context) {
 		}
 	}
 
-	err = database.UpdateTransportStep(db, stepID, departurePlace, departureDatetime, arrivalPlace, arrivalDatetime, req.TransportType, req.TransportNumber, req.Notes, userID)
+	cost, currency, err := parseTransportCost(req.Cost, req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.UpdateTransportStep(db, stepID, departurePlace, departureDatetime, arrivalPlace, arrivalDatetime, req.TransportType, req.TransportNumber, req.Notes, cost, currency, userID)
 	if err != nil {
 		logger.Error("Failed to update transport step", "user_id", userID, "step_id", stepID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transport step"})
@@ -709,7 +888,10 @@ func handleReorderTransportSteps(c *gin.Context) {
 
 // GPX Handlers
 
-// handleUploadGPX uploads a GPX file to a trip
+// maxGPXFileNameLength caps the stored display name of an uploaded GPX track
+const maxGPXFileNameLength = 100
+
+// handleUploadGPX adds a GPX track to a trip, keeping the tracks already uploaded
 func handleUploadGPX(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
@@ -722,9 +904,9 @@ func handleUploadGPX(c *gin.Context) {
 		return
 	}
 
-	// Check file size (5MB limit)
-	if file.Size > 5*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 5MB)"})
+	maxSize := c.MustGet("config").(*config.Config).MaxGPXSize
+	if file.Size > maxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File too large (max %s)", config.FormatSize(maxSize))})
 		return
 	}
 
@@ -750,26 +932,73 @@ func handleUploadGPX(c *gin.Context) {
 		return
 	}
 
-	// Store GPX data
-	err = database.UpdateTripGPX(db, userID, tripID, string(gpxData))
+	// Only the parts of the file the app uses are stored, so malformed files and anything
+	// else embedded in them never reach the database or the download endpoints
+	sanitized, err := gpx.Sanitize(string(gpxData), gpx.DefaultMaxPoints)
 	if err != nil {
-		logger.Error("Failed to update trip GPX", "user_id", userID, "trip_id", tripID, "error", err)
+		logger.Warn("Rejected invalid GPX file", "user_id", userID, "trip_id", tripID, "error", err)
+		if errors.Is(err, gpx.ErrTooManyPoints) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("GPX file has too many points (max %d)", gpx.DefaultMaxPoints)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid GPX file"})
+		return
+	}
+
+	// The track is named after the uploaded file, without its extension
+	name := strings.TrimSpace(file.Filename[:len(file.Filename)-len(".gpx")])
+	if runes := []rune(name); len(runes) > maxGPXFileNameLength {
+		name = string(runes[:maxGPXFileNameLength])
+	}
+	if name == "" {
+		name = "Track"
+	}
+
+	gpxFile, err := database.AddTripGPXFile(db, userID, tripID, name, sanitized)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if strings.Contains(err.Error(), "too many") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This trip already has the maximum number of GPX files"})
+			return
+		}
+		logger.Error("Failed to add trip GPX file", "user_id", userID, "trip_id", tripID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save GPX data"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true})
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": gpxFile.ID, "name": gpxFile.Name})
 }
 
-// handleDeleteGPX deletes GPX data from a trip
+// handleDeleteGPX deletes one GPX track from a trip
 func handleDeleteGPX(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
 	tripID := c.Param("id")
 
-	err := database.DeleteTripGPX(db, userID, tripID)
+	fileID, err := strconv.Atoi(c.Param("file_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid GPX file ID"})
+		return
+	}
+
+	err = database.DeleteTripGPXFile(db, userID, tripID, fileID)
 	if err != nil {
-		logger.Error("Failed to delete trip GPX", "user_id", userID, "trip_id", tripID, "error", err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "GPX file not found"})
+			return
+		}
+		if strings.Contains(err.Error(), "unauthorized") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+			return
+		}
+		logger.Error("Failed to delete trip GPX file", "user_id", userID, "trip_id", tripID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete GPX data"})
 		return
 	}
@@ -777,13 +1006,12 @@ func handleDeleteGPX(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
-// handleDownloadGPX downloads the GPX file for a trip
+// handleDownloadGPX downloads a GPX track of a trip
 func handleDownloadGPX(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
 	db := c.MustGet("db").(*sql.DB)
 	tripID := c.Param("id")
 
-	// Get trip with GPX data
 	trip, err := database.GetTrip(db, tripID)
 	if err != nil {
 		logger.Error("Failed to get trip", "user_id", userID, "trip_id", tripID, "error", err)
@@ -797,28 +1025,10 @@ func handleDownloadGPX(c *gin.Context) {
 		return
 	}
 
-	// Check if GPX data exists
-	if trip.GPXData == nil || *trip.GPXData == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No GPX data available"})
-		return
-	}
-
-	// Generate filename from trip name
-	filename := trip.Name
-	if filename == "" {
-		filename = "trip"
-	}
-	// Sanitize filename
-	filename = strings.ReplaceAll(filename, " ", "_")
-	filename = strings.ToLower(filename) + ".gpx"
-
-	// Set headers for file download
-	c.Header("Content-Type", "application/gpx+xml")
-	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
-	c.Data(http.StatusOK, "application/gpx+xml", []byte(*trip.GPXData))
+	writeTripGPXFile(c, db, trip)
 }
 
-// handlePublicDownloadGPX downloads the GPX file for a public trip
+// handlePublicDownloadGPX downloads a GPX track of a public trip
 func handlePublicDownloadGPX(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 	shortID := c.Param("id")
@@ -837,25 +1047,125 @@ func handlePublicDownloadGPX(c *gin.Context) {
 		return
 	}
 
-	// Check if GPX data exists
-	if trip.GPXData == nil || *trip.GPXData == "" {
+	writeTripGPXFile(c, db, trip)
+}
+
+// writeTripGPXFile sends the GPX track named by the file_id route parameter as an attachment.
+// Download links from before trips had several tracks carry no file ID and get the first track.
+func writeTripGPXFile(c *gin.Context, db *sql.DB, trip *models.Trip) {
+	var gpxFile *models.TripGPXFile
+	if fileIDStr := c.Param("file_id"); fileIDStr != "" {
+		fileID, err := strconv.Atoi(fileIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid GPX file ID"})
+			return
+		}
+		gpxFile, err = database.GetTripGPXFile(db, trip.ID, fileID)
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			logger.Error("Failed to get trip GPX file", "trip_id", trip.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load GPX data"})
+			return
+		}
+	} else {
+		files, err := database.GetTripGPXFiles(db, trip.ID)
+		if err != nil {
+			logger.Error("Failed to get trip GPX files", "trip_id", trip.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load GPX data"})
+			return
+		}
+		if len(files) > 0 {
+			gpxFile = &files[0]
+		}
+	}
+
+	if gpxFile == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No GPX data available"})
 		return
 	}
 
-	// Generate filename from trip name
-	filename := trip.Name
-	if filename == "" {
-		filename = "trip"
-	}
-	// Sanitize filename
-	filename = strings.ReplaceAll(filename, " ", "_")
-	filename = strings.ToLower(filename) + ".gpx"
+	filename := downloadFilename(trip.Name+" "+gpxFile.Name, ".gpx")
 
 	// Set headers for file download
 	c.Header("Content-Type", "application/gpx+xml")
 	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
-	c.Data(http.StatusOK, "application/gpx+xml", []byte(*trip.GPXData))
+	c.Data(http.StatusOK, "application/gpx+xml", []byte(gpxFile.GPXData))
+}
+
+// writeTripICS renders the trip as an iCalendar attachment
+func writeTripICS(c *gin.Context, trip *models.Trip) {
+	var buf bytes.Buffer
+	if err := ical.WriteTrip(&buf, trip, time.Now()); err != nil {
+		logger.Error("Failed to generate trip calendar", "trip_id", trip.ID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to generate calendar")
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\""+downloadFilename(trip.Name, ".ics")+"\"")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// handleExportTripICS downloads one of the user's trips as a calendar file
+func handleExportTripICS(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user")
+	tripID := c.Param("id")
+
+	trip, err := database.GetTripWithDetails(db, tripID)
+	if err != nil {
+		logger.Error("Failed to get trip", "user_id", userID, "trip_id", tripID, "error", err)
+		c.HTML(http.StatusNotFound, "404.html", gin.H{
+			"Title": "Trip Not Found - Carryless",
+			"User":  user,
+		})
+		return
+	}
+
+	// Check ownership
+	if trip.UserID != userID {
+		c.HTML(http.StatusForbidden, "403.html", gin.H{
+			"Title": "Access Denied - Carryless",
+			"User":  user,
+		})
+		return
+	}
+
+	writeTripICS(c, trip)
+}
+
+// handlePublicExportTripICS downloads a public trip as a calendar file through its short ID
+func handlePublicExportTripICS(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	shortID := c.Param("id")
+	user, _ := c.Get("user")
+
+	trip, err := database.GetTripByShortID(db, shortID)
+	if err != nil {
+		logger.Error("Failed to get public trip", "short_id", shortID, "error", err)
+		c.HTML(http.StatusNotFound, "404.html", gin.H{
+			"Title": "Trip Not Found - Carryless",
+			"User":  user,
+		})
+		return
+	}
+
+	// Check if trip is public
+	if !trip.IsPublic {
+		c.HTML(http.StatusForbidden, "403.html", gin.H{
+			"Title": "Access Denied - Carryless",
+			"User":  user,
+		})
+		return
+	}
+
+	tripWithDetails, err := database.GetTripWithDetails(db, trip.ID)
+	if err != nil {
+		logger.Error("Failed to get trip details", "trip_id", trip.ID, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to load trip")
+		return
+	}
+
+	writeTripICS(c, tripWithDetails)
 }
 
 // handlePublicTripByShortID displays a public trip by its short ID
@@ -881,13 +1191,89 @@ func handlePublicTripByShortID(c *gin.Context) {
 		tripWithDetails = trip
 	}
 
+	// Logged-in viewers get a token for actions on the trip
+	var csrfToken string
+	if userID, hasUserID := c.Get("user_id"); hasUserID {
+		if token, err := database.CreateCSRFToken(db, userID.(int)); err == nil {
+			csrfToken = token.Token
+		}
+	}
+
 	c.HTML(http.StatusOK, "public_trip.html", gin.H{
-		"Title": tripWithDetails.Name + " - Carryless",
-		"User":  user,
-		"Trip":  tripWithDetails,
+		"Title":           tripWithDetails.Name + " - Carryless",
+		"User":            user,
+		"Trip":            tripWithDetails,
+		"SelectedGPXFile": selectedTripGPXFile(c, tripWithDetails),
+		"CSRFToken":       csrfToken,
 	})
 }
 
+// handleShareTrip emails the public link of a trip to a travel companion. Private trips are
+// only published when the request confirms it with make_public.
+func handleShareTrip(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	user := c.MustGet("user").(*models.User)
+	emailService := c.MustGet("email_service").(*email.Service)
+	tripID := c.Param("id")
+
+	var req struct {
+		Email      string `json:"email"`
+		MakePublic bool   `json:"make_public"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	recipient := strings.TrimSpace(req.Email)
+	if !emailRegex.MatchString(recipient) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+
+	if !emailService.IsEnabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not available on this server"})
+		return
+	}
+
+	trip, err := database.GetTrip(db, tripID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return
+	}
+
+	if trip.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if !trip.IsPublic || trip.ShortID == "" {
+		if !req.MakePublic {
+			c.JSON(http.StatusConflict, gin.H{"error": "The trip must be public to be shared", "requires_public": true})
+			return
+		}
+
+		shortID, err := database.PublishTrip(db, userID, tripID)
+		if err != nil {
+			logger.Error("Failed to publish trip for sharing", "user_id", userID, "trip_id", tripID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to make the trip public"})
+			return
+		}
+		trip.IsPublic = true
+		trip.ShortID = shortID
+	}
+
+	go func() {
+		if err := emailService.SendTripShareEmail(user, trip, recipient); err != nil {
+			logger.Error("Failed to send trip share email", "user_id", userID, "trip_id", tripID, "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "url": "/t/" + trip.ShortID})
+}
+
 // handleUpdateTripNotes updates the notes field of a trip (JSON endpoint)
 func handleUpdateTripNotes(c *gin.Context) {
 	userID := c.MustGet("user_id").(int)
@@ -925,3 +1311,44 @@ func handleUpdateTripNotes(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// handleTripWeather returns the daily forecast for the trip location over the trip dates.
+// Provider failures are logged and answered with an empty forecast so the trip page keeps working.
+func handleTripWeather(c *gin.Context) {
+	userID := c.MustGet("user_id").(int)
+	db := c.MustGet("db").(*sql.DB)
+	weatherService := c.MustGet("weather_service").(*weather.Service)
+	tripID := c.Param("id")
+
+	trip, err := database.GetTrip(db, tripID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return
+	}
+
+	if trip.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	empty := &weather.Forecast{Days: []weather.Day{}}
+	if trip.Location == nil || trip.StartDate == nil {
+		c.JSON(http.StatusOK, empty)
+		return
+	}
+	empty.Location = *trip.Location
+
+	end := *trip.StartDate
+	if trip.EndDate != nil && trip.EndDate.After(end) {
+		end = *trip.EndDate
+	}
+
+	forecast, err := weatherService.Forecast(*trip.Location, *trip.StartDate, end)
+	if err != nil {
+		logger.Warn("Failed to get weather forecast", "user_id", userID, "trip_id", tripID, "error", err)
+		c.JSON(http.StatusOK, empty)
+		return
+	}
+
+	c.JSON(http.StatusOK, forecast)
+}
@@ -0,0 +1,160 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"carryless/internal/models"
+)
+
+// maxLineOctets is the longest content line allowed by RFC 5545 before it must be folded
+const maxLineOctets = 75
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+)
+
+// WriteTrip renders a trip loaded with GetTripWithDetails as an iCalendar file.
+// The trip span becomes an all-day event and every transport step with a departure
+// time becomes a timed event. stamp is used as the DTSTAMP of every event.
+func WriteTrip(w io.Writer, trip *models.Trip, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	dtstamp := stamp.UTC().Format(dateTimeFormat)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:-//Carryless//Trips//EN")
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "X-WR-CALNAME:"+escapeText(trip.Name))
+
+	if trip.StartDate != nil {
+		end := trip.StartDate
+		if trip.EndDate != nil && !trip.EndDate.Before(*trip.StartDate) {
+			end = trip.EndDate
+		}
+
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:trip-"+trip.ID+"@carryless")
+		writeLine(bw, "DTSTAMP:"+dtstamp)
+		writeLine(bw, "DTSTART;VALUE=DATE:"+trip.StartDate.Format(dateFormat))
+		// DTEND is exclusive for all-day events
+		writeLine(bw, "DTEND;VALUE=DATE:"+end.AddDate(0, 0, 1).Format(dateFormat))
+		writeLine(bw, "SUMMARY:"+escapeText(trip.Name))
+		if trip.Location != nil && *trip.Location != "" {
+			writeLine(bw, "LOCATION:"+escapeText(*trip.Location))
+		}
+		if trip.Description != nil && *trip.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escapeText(*trip.Description))
+		}
+		writeLine(bw, "END:VEVENT")
+	}
+
+	for _, step := range trip.TransportSteps {
+		if step.DepartureDatetime == nil {
+			continue
+		}
+
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, fmt.Sprintf("UID:transport-%d@carryless", step.ID))
+		writeLine(bw, "DTSTAMP:"+dtstamp)
+		writeLine(bw, "DTSTART:"+step.DepartureDatetime.UTC().Format(dateTimeFormat))
+		if step.ArrivalDatetime != nil && step.ArrivalDatetime.After(*step.DepartureDatetime) {
+			writeLine(bw, "DTEND:"+step.ArrivalDatetime.UTC().Format(dateTimeFormat))
+		}
+		writeLine(bw, "SUMMARY:"+escapeText(transportSummary(step)))
+		writeLine(bw, "LOCATION:"+escapeText(step.DeparturePlace))
+		writeLine(bw, "DESCRIPTION:"+escapeText(transportDescription(trip, step)))
+		writeLine(bw, "END:VEVENT")
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// transportTypeNames maps the transport types offered on the trip page to display names
+var transportTypeNames = map[string]string{
+	"train": "Train",
+	"plane": "Plane",
+	"bus":   "Bus",
+}
+
+// transportSummary describes a step as "Train IC 123: Paris → Lyon"
+func transportSummary(step models.TripTransportStep) string {
+	var prefix []string
+	if step.TransportType != nil {
+		if name, ok := transportTypeNames[*step.TransportType]; ok {
+			prefix = append(prefix, name)
+		}
+	}
+	if step.TransportNumber != nil && *step.TransportNumber != "" {
+		prefix = append(prefix, *step.TransportNumber)
+	}
+
+	var b strings.Builder
+	if len(prefix) > 0 {
+		b.WriteString(strings.Join(prefix, " ") + ": ")
+	}
+	b.WriteString(step.DeparturePlace)
+	if step.ArrivalPlace != nil && *step.ArrivalPlace != "" {
+		b.WriteString(" → " + *step.ArrivalPlace)
+	}
+	return b.String()
+}
+
+// transportDescription lists the places and local times of a step along with its notes
+func transportDescription(trip *models.Trip, step models.TripTransportStep) string {
+	journey := "Outbound"
+	if step.JourneyType == "return" {
+		journey = "Return"
+	}
+
+	lines := []string{fmt.Sprintf("%s journey of %s", journey, trip.Name)}
+	lines = append(lines, "Departure: "+step.DeparturePlace+" at "+step.DepartureDatetime.Format("Jan 2, 2006 15:04"))
+	if step.ArrivalPlace != nil && *step.ArrivalPlace != "" {
+		arrival := "Arrival: " + *step.ArrivalPlace
+		if step.ArrivalDatetime != nil {
+			arrival += " at " + step.ArrivalDatetime.Format("Jan 2, 2006 15:04")
+		}
+		lines = append(lines, arrival)
+	}
+	if step.Notes != nil && *step.Notes != "" {
+		lines = append(lines, *step.Notes)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeText escapes a TEXT property value as described in RFC 5545 section 3.3.11
+func escapeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeLine writes a content line terminated by CRLF, folding it every 75 octets
+// without splitting a UTF-8 sequence. Continuation lines start with a single space.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the length of continuation lines
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,115 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"carryless/internal/models"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Tour du Mont Blanc", "Tour du Mont Blanc"},
+		{"Chamonix, France", `Chamonix\, France`},
+		{"Day 1; Day 2", `Day 1\; Day 2`},
+		{"First line\nSecond line", `First line\nSecond line`},
+		{"Windows\r\nline", `Windows\nline`},
+		{`C:\gear`, `C:\\gear`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeText(tt.in); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, expected %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// foldedLines writes line with writeLine and returns its physical lines without CRLF
+func foldedLines(t *testing.T, line string) []string {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeLine(w, line)
+	if err := w.Flush(); err != nil {
+		t.Fatal("Failed to flush:", err)
+	}
+
+	out := buf.String()
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Fatalf("Expected the line to end with CRLF, got %q", out)
+	}
+	return strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+}
+
+func TestWriteLineFolding(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Weekend"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"ascii", "DESCRIPTION:" + strings.Repeat("abcdefghij", 20)},
+		{"multibyte", "DESCRIPTION:" + strings.Repeat("Pâturage → Col ", 12)},
+	}
+
+	for _, tt := range tests {
+		lines := foldedLines(t, tt.line)
+
+		var unfolded strings.Builder
+		for i, l := range lines {
+			if len(l) > maxLineOctets {
+				t.Errorf("%s: line %d is %d octets, expected at most %d", tt.name, i+1, len(l), maxLineOctets)
+			}
+			if !utf8.ValidString(l) {
+				t.Errorf("%s: line %d splits a UTF-8 sequence: %q", tt.name, i+1, l)
+			}
+			if i > 0 {
+				if !strings.HasPrefix(l, " ") {
+					t.Errorf("%s: continuation line %d doesn't start with a space: %q", tt.name, i+1, l)
+				}
+				l = l[1:]
+			}
+			unfolded.WriteString(l)
+		}
+		if unfolded.String() != tt.line {
+			t.Errorf("%s: unfolding gave %q, expected %q", tt.name, unfolded.String(), tt.line)
+		}
+	}
+
+	if lines := foldedLines(t, "SUMMARY:"+strings.Repeat("a", 67)); len(lines) != 1 {
+		t.Errorf("Expected a 75 octet line not to be folded, got %d lines", len(lines))
+	}
+	if lines := foldedLines(t, "SUMMARY:"+strings.Repeat("a", 68)); len(lines) != 2 {
+		t.Errorf("Expected a 76 octet line to be folded once, got %d lines", len(lines))
+	}
+}
+
+func TestWriteTripEscapesFields(t *testing.T) {
+	start := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 7, 3, 0, 0, 0, 0, time.UTC)
+	location := "Chamonix, France"
+	trip := &models.Trip{ID: "trip-1", Name: "TMB; stage 1", StartDate: &start, EndDate: &end, Location: &location}
+
+	var buf bytes.Buffer
+	if err := WriteTrip(&buf, trip, start); err != nil {
+		t.Fatal("Failed to write trip:", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"SUMMARY:TMB\\; stage 1\r\n",
+		"LOCATION:Chamonix\\, France\r\n",
+		"DTSTART;VALUE=DATE:20260701\r\n",
+		"DTEND;VALUE=DATE:20260704\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in calendar, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package metrics collects request and abuse-protection counters and serves them
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limiters reported by RecordRateLimitRejection
+const (
+	LimiterGlobal     = "global"
+	LimiterAuth       = "auth"
+	LimiterActivation = "activation"
+	LimiterShare      = "share"
+)
+
+// Events reported by RecordBlockEvent
+const (
+	BlockEventIPBlocked       = "ip_blocked"
+	BlockEventRequestRejected = "request_rejected"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the handler latency histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	requestsTotal = newCounterVec(
+		"carryless_http_requests_total",
+		"HTTP requests by response status code.",
+		"code",
+	)
+	rateLimitRejections = newCounterVec(
+		"carryless_rate_limit_rejections_total",
+		"Requests rejected by a rate limiter.",
+		"limiter",
+	)
+	blockEvents = newCounterVec(
+		"carryless_404_block_events_total",
+		"IPs blocked for excessive 404s and requests rejected while blocked.",
+		"event",
+	)
+	requestDuration = newHistogramVec(
+		"carryless_http_request_duration_seconds",
+		"Time spent handling HTTP requests by route.",
+		"route",
+		latencyBuckets,
+	)
+)
+
+// RecordRateLimitRejection counts a request refused by the given limiter
+func RecordRateLimitRejection(limiter string) {
+	rateLimitRejections.inc(limiter)
+}
+
+// RecordBlockEvent counts an IP being blocked or a request from a blocked IP being refused
+func RecordBlockEvent(event string) {
+	blockEvents.inc(event)
+}
+
+// Middleware records the status code and latency of every request. It should be
+// registered before the other middlewares so rejected requests are counted too.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Unmatched paths are grouped together to keep the number of series bounded
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		requestsTotal.inc(strconv.Itoa(c.Writer.Status()))
+		requestDuration.observe(route, time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the collected metrics. When token is set, scrapers must send it
+// as a bearer token.
+func Handler(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" {
+			expected := "Bearer " + token
+			if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(expected)) != 1 {
+				c.String(http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+		}
+
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		requestsTotal.write(c.Writer)
+		rateLimitRejections.write(c.Writer)
+		blockEvents.write(c.Writer)
+		requestDuration.write(c.Writer)
+	}
+}
+
+type counterVec struct {
+	name   string
+	help   string
+	label  string
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: make(map[string]uint64)}
+}
+
+func (v *counterVec) inc(labelValue string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[labelValue]++
+}
+
+func (v *counterVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	for _, labelValue := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", v.name, v.label, escapeLabel(labelValue), v.values[labelValue])
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	return &histogramVec{name: name, help: help, label: label, buckets: buckets, values: make(map[string]*histogram)}
+}
+
+func (v *histogramVec) observe(labelValue string, value float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	h, exists := v.values[labelValue]
+	if !exists {
+		h = &histogram{counts: make([]uint64, len(v.buckets))}
+		v.values[labelValue] = h
+	}
+
+	for i, bound := range v.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (v *histogramVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", v.name, v.help, v.name)
+	for _, labelValue := range sortedKeys(v.values) {
+		h := v.values[labelValue]
+		label := fmt.Sprintf("%s=\"%s\"", v.label, escapeLabel(labelValue))
+		for i, bound := range v.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", v.name, label, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", v.name, label, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", v.name, label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", v.name, label, h.count)
+	}
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
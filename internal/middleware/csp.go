@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// cspNonceKey is the template data key holding the nonce of the request
+const cspNonceKey = "CSPNonce"
+
+// generateCSPNonce returns a random value allowing the inline scripts of one response
+func generateCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// Hex only uses characters valid in a CSP nonce and left as is by html/template
+	return hex.EncodeToString(b), nil
+}
+
+// nonceResponseWriter carries the CSP nonce of the request to the HTML renderer,
+// which only gets the response writer
+type nonceResponseWriter struct {
+	gin.ResponseWriter
+	nonce string
+}
+
+func (w *nonceResponseWriter) CSPNonce() string {
+	return w.nonce
+}
+
+// NonceHTMLRender wraps an HTML renderer so templates rendered with gin.H data can
+// tag their inline scripts with nonce="{{.CSPNonce}}"
+func NonceHTMLRender(htmlRender render.HTMLRender) render.HTMLRender {
+	return nonceHTMLRender{htmlRender}
+}
+
+type nonceHTMLRender struct {
+	render.HTMLRender
+}
+
+func (r nonceHTMLRender) Instance(name string, data interface{}) render.Render {
+	return nonceRender{htmlRender: r.HTMLRender, name: name, data: data}
+}
+
+type nonceRender struct {
+	htmlRender render.HTMLRender
+	name       string
+	data       interface{}
+}
+
+func (r nonceRender) Render(w http.ResponseWriter) error {
+	if h, ok := r.data.(gin.H); ok {
+		if nw, ok := w.(interface{ CSPNonce() string }); ok {
+			h[cspNonceKey] = nw.CSPNonce()
+		}
+	}
+	return r.htmlRender.Instance(r.name, r.data).Render(w)
+}
+
+func (r nonceRender) WriteContentType(w http.ResponseWriter) {
+	r.htmlRender.Instance(r.name, r.data).WriteContentType(w)
+}
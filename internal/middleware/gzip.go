@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipMinSize is the smallest response worth compressing, below it the gzip overhead isn't worth it
+const gzipMinSize = 1024
+
+// compressibleTypes are the content type prefixes that benefit from compression.
+// Images other than SVG, PDFs and archives are already compressed and are sent as is.
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/gpx+xml",
+	"application/x-gpx+xml",
+	"image/svg+xml",
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// Gzip compresses responses for clients that accept it when the content type is
+// compressible and the body is larger than gzipMinSize.
+func Gzip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead ||
+			c.GetHeader("Range") != "" ||
+			!strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+			c.Next()
+			return
+		}
+
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer writer.finish()
+
+		c.Next()
+	}
+}
+
+// gzipResponseWriter buffers the start of the body until it knows whether the response
+// is large enough to compress, then either streams through gzip or passes the body through.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	buf     bytes.Buffer
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf.Write(data)
+		if w.buf.Len() < gzipMinSize {
+			return len(data), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide picks compression based on what has been buffered so far and writes the buffer out
+func (w *gzipResponseWriter) decide() error {
+	w.decided = true
+
+	header := w.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+	}
+
+	if w.buf.Len() >= gzipMinSize && w.shouldCompress() {
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+
+		w.gz = gzipWriterPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *gzipResponseWriter) shouldCompress() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	switch w.Status() {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// finish writes out a body too small to have been decided on and closes the gzip stream
+func (w *gzipResponseWriter) finish() {
+	if !w.decided {
+		if w.buf.Len() == 0 {
+			return
+		}
+		w.decide()
+	}
+
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriterPool.Put(w.gz)
+		w.gz = nil
+	}
+}
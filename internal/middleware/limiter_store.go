@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// LimiterStore keeps the state behind rate limiting and 404 blocking. The in-memory
+// store is the default, the Redis store shares state between replicas and restarts.
+type LimiterStore interface {
+	// Allow takes a token from the bucket identified by key, refilled at limit up to burst tokens
+	Allow(ctx context.Context, key string, limit rate.Limit, burst int) (bool, error)
+	// Record404 counts a 404 for the IP and returns how many it caused within window
+	Record404(ctx context.Context, ip string, window time.Duration) (int, error)
+	// Block blocks the IP for duration and resets its 404 count
+	Block(ctx context.Context, ip string, duration time.Duration) error
+	// IsBlocked reports whether the IP is currently blocked
+	IsBlocked(ctx context.Context, ip string) (bool, error)
+}
+
+// limiterStore is used by RateLimit, AuthRateLimit, ActivationRateLimit, IPBlocker and Track404AndBlock
+var limiterStore LimiterStore = NewMemoryLimiterStore()
+
+// UseLimiterStore replaces the store used by the rate limiting and blocking middlewares.
+// It must be called before the server starts handling requests.
+func UseLimiterStore(store LimiterStore) {
+	limiterStore = store
+}
+
+// limiterIdleTimeout is how long an IP's state is kept in memory after its last request
+const limiterIdleTimeout = 30 * time.Minute
+
+type rateLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+type clientTracker struct {
+	errors404    []time.Time
+	blockedUntil time.Time
+	lastSeen     time.Time
+}
+
+type memoryLimiterStore struct {
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+	trackers map[string]*clientTracker
+}
+
+// NewMemoryLimiterStore keeps limiter state in the process. It is lost on restart and
+// not shared between instances.
+func NewMemoryLimiterStore() LimiterStore {
+	return &memoryLimiterStore{
+		limiters: make(map[string]*rateLimiter),
+		trackers: make(map[string]*clientTracker),
+	}
+}
+
+func (s *memoryLimiterStore) Allow(ctx context.Context, key string, limit rate.Limit, burst int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	limiter, exists := s.limiters[key]
+	if !exists {
+		limiter = &rateLimiter{limiter: rate.NewLimiter(limit, burst)}
+		s.limiters[key] = limiter
+	}
+	limiter.lastSeen = now
+
+	s.cleanup(now)
+	return limiter.limiter.Allow(), nil
+}
+
+func (s *memoryLimiterStore) Record404(ctx context.Context, ip string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	tracker := s.tracker(ip, now)
+	tracker.errors404 = append(tracker.errors404, now)
+
+	// Drop 404s that fell out of the window
+	cutoff := now.Add(-window)
+	validErrors := make([]time.Time, 0, len(tracker.errors404))
+	for _, errorTime := range tracker.errors404 {
+		if errorTime.After(cutoff) {
+			validErrors = append(validErrors, errorTime)
+		}
+	}
+	tracker.errors404 = validErrors
+
+	s.cleanup(now)
+	return len(tracker.errors404), nil
+}
+
+func (s *memoryLimiterStore) Block(ctx context.Context, ip string, duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	tracker := s.tracker(ip, now)
+	tracker.blockedUntil = now.Add(duration)
+	tracker.errors404 = make([]time.Time, 0)
+	return nil
+}
+
+func (s *memoryLimiterStore) IsBlocked(ctx context.Context, ip string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracker, exists := s.trackers[ip]
+	return exists && time.Now().Before(tracker.blockedUntil), nil
+}
+
+func (s *memoryLimiterStore) tracker(ip string, now time.Time) *clientTracker {
+	tracker, exists := s.trackers[ip]
+	if !exists {
+		tracker = &clientTracker{errors404: make([]time.Time, 0)}
+		s.trackers[ip] = tracker
+	}
+	tracker.lastSeen = now
+	return tracker
+}
+
+// cleanup forgets idle IPs so the maps don't grow forever. Callers hold s.mu.
+func (s *memoryLimiterStore) cleanup(now time.Time) {
+	for key, limiter := range s.limiters {
+		if now.Sub(limiter.lastSeen) > limiterIdleTimeout {
+			delete(s.limiters, key)
+		}
+	}
+	for ip, tracker := range s.trackers {
+		if now.Sub(tracker.lastSeen) > limiterIdleTimeout && now.After(tracker.blockedUntil) {
+			delete(s.trackers, ip)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// redisKeyPrefix namespaces the limiter keys in a Redis instance that may be shared
+const redisKeyPrefix = "carryless:ratelimit:"
+
+// tokenBucketScript refills the bucket for the time elapsed since the last request,
+// then takes a token if one is available. It runs atomically on the Redis server.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], ttl)
+return allowed
+`)
+
+type redisLimiterStore struct {
+	client *redis.Client
+}
+
+// NewRedisLimiterStore shares limiter state between instances through the Redis server at url,
+// e.g. redis://localhost:6379/0
+func NewRedisLimiterStore(url string) (LimiterStore, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &redisLimiterStore{client: client}, nil
+}
+
+func (s *redisLimiterStore) Allow(ctx context.Context, key string, limit rate.Limit, burst int) (bool, error) {
+	// Keep the bucket until it would be full again
+	refill := time.Duration(float64(burst) / float64(limit) * float64(time.Second))
+	ttl := refill + time.Second
+	if math.IsInf(float64(limit), 0) || ttl <= 0 {
+		return true, nil
+	}
+
+	allowed, err := tokenBucketScript.Run(ctx, s.client,
+		[]string{redisKeyPrefix + "bucket:" + key},
+		float64(limit), burst, time.Now().UnixMilli(), ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	return allowed == 1, nil
+}
+
+func (s *redisLimiterStore) Record404(ctx context.Context, ip string, window time.Duration) (int, error) {
+	key := redisKeyPrefix + "404:" + ip
+	now := time.Now()
+
+	var count *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.UnixNano()), Member: strconv.FormatInt(now.UnixNano(), 10)})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-window).UnixNano(), 10))
+		count = pipe.ZCard(ctx, key)
+		pipe.PExpire(ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to record 404: %w", err)
+	}
+	return int(count.Val()), nil
+}
+
+func (s *redisLimiterStore) Block(ctx context.Context, ip string, duration time.Duration) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, redisKeyPrefix+"blocked:"+ip, 1, duration)
+		pipe.Del(ctx, redisKeyPrefix+"404:"+ip)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to block IP: %w", err)
+	}
+	return nil
+}
+
+func (s *redisLimiterStore) IsBlocked(ctx context.Context, ip string) (bool, error) {
+	exists, err := s.client.Exists(ctx, redisKeyPrefix+"blocked:"+ip).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check IP block: %w", err)
+	}
+	return exists > 0, nil
+}
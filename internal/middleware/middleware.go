@@ -7,71 +7,64 @@ import (
 	"log"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"carryless/internal/config"
 	"carryless/internal/database"
+	"carryless/internal/metrics"
 	"carryless/internal/models"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
 
-type rateLimiter struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
+// allowRequest checks the client's bucket for the given limiter. Store errors let the
+// request through so a Redis outage doesn't take the site down.
+func allowRequest(c *gin.Context, limiter string, limit rate.Limit, burst int) bool {
+	return allowRequestKey(c, limiter, limiter+":"+c.ClientIP(), limit, burst)
 }
 
-type clientTracker struct {
-	errors404    []time.Time
-	blockedUntil time.Time
-	lastSeen     time.Time
+// allowRouteRequest is allowRequest with a bucket per route, so the routes sharing a
+// limiter don't use up each other's requests
+func allowRouteRequest(c *gin.Context, limiter string, limit rate.Limit, burst int) bool {
+	return allowRequestKey(c, limiter, limiter+":"+c.FullPath()+":"+c.ClientIP(), limit, burst)
 }
 
-var (
-	clients      = make(map[string]*rateLimiter)
-	mu           sync.Mutex
-	trackers     = make(map[string]*clientTracker)
-	trackersMu   sync.Mutex
-)
+func allowRequestKey(c *gin.Context, limiter, key string, limit rate.Limit, burst int) bool {
+	allowed, err := limiterStore.Allow(c.Request.Context(), key, limit, burst)
+	if err != nil {
+		log.Printf("Rate limiter %s unavailable: %v", limiter, err)
+		return true
+	}
+	return allowed
+}
+
+// exemptFromRateLimit reports whether a request skips the global limiter: CORS preflights
+// and static assets, which a single page load can request by the dozen
+func exemptFromRateLimit(c *gin.Context) bool {
+	return c.Request.Method == http.MethodOptions || strings.HasPrefix(c.Request.URL.Path, "/static/")
+}
 
 func RateLimit(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Skip rate limiting in development mode
-		if cfg.IsDevelopment() {
+		if cfg.IsDevelopment() || exemptFromRateLimit(c) {
 			c.Next()
 			return
 		}
 
-		ip := c.ClientIP()
-
-		mu.Lock()
-		defer mu.Unlock()
-
-		if limiter, exists := clients[ip]; exists {
-			limiter.lastSeen = time.Now()
-			if !limiter.limiter.Allow() {
-				c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
-				c.Abort()
-				return
-			}
-		} else {
-			clients[ip] = &rateLimiter{
-				limiter:  rate.NewLimiter(rate.Every(time.Second/20), 20),
-				lastSeen: time.Now(),
-			}
+		if !allowRequest(c, metrics.LimiterGlobal, rate.Limit(cfg.RateLimitRPS), cfg.RateLimitRPS) {
+			metrics.RecordRateLimitRejection(metrics.LimiterGlobal)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			c.Abort()
+			return
 		}
 
-		cleanupOldClients()
 		c.Next()
 	}
 }
 
 func AuthRateLimit(cfg *config.Config) gin.HandlerFunc {
-	authClients := make(map[string]*rateLimiter)
-	var authMu sync.Mutex
-
 	return func(c *gin.Context) {
 		// Skip rate limiting in development mode
 		if cfg.IsDevelopment() {
@@ -79,30 +72,11 @@ func AuthRateLimit(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		ip := c.ClientIP()
-
-		authMu.Lock()
-		defer authMu.Unlock()
-
-		if limiter, exists := authClients[ip]; exists {
-			limiter.lastSeen = time.Now()
-			if !limiter.limiter.Allow() {
-				c.JSON(http.StatusTooManyRequests, gin.H{"error": "Authentication rate limit exceeded"})
-				c.Abort()
-				return
-			}
-		} else {
-			authClients[ip] = &rateLimiter{
-				limiter:  rate.NewLimiter(rate.Every(time.Minute), 5),
-				lastSeen: time.Now(),
-			}
-		}
-
-		// Cleanup old auth clients
-		for ip, client := range authClients {
-			if time.Since(client.lastSeen) > 30*time.Minute {
-				delete(authClients, ip)
-			}
+		if !allowRouteRequest(c, metrics.LimiterAuth, rate.Every(time.Minute/time.Duration(cfg.AuthRateLimitPerMinute)), cfg.AuthRateLimitPerMinute) {
+			metrics.RecordRateLimitRejection(metrics.LimiterAuth)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Authentication rate limit exceeded"})
+			c.Abort()
+			return
 		}
 
 		c.Next()
@@ -110,9 +84,6 @@ func AuthRateLimit(cfg *config.Config) gin.HandlerFunc {
 }
 
 func ActivationRateLimit(cfg *config.Config) gin.HandlerFunc {
-	activationClients := make(map[string]*rateLimiter)
-	var activationMu sync.Mutex
-
 	return func(c *gin.Context) {
 		// Skip rate limiting in development mode
 		if cfg.IsDevelopment() {
@@ -120,34 +91,35 @@ func ActivationRateLimit(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		ip := c.ClientIP()
+		if !allowRouteRequest(c, metrics.LimiterActivation, rate.Every(time.Minute*5), 3) {
+			metrics.RecordRateLimitRejection(metrics.LimiterActivation)
+			c.HTML(http.StatusTooManyRequests, "activation_result.html", gin.H{
+				"Title":   "Too Many Requests - Carryless",
+				"Success": false,
+				"Message": "Too many activation attempts. Please wait before trying again.",
+			})
+			c.Abort()
+			return
+		}
 
-		activationMu.Lock()
-		defer activationMu.Unlock()
+		c.Next()
+	}
+}
 
-		if limiter, exists := activationClients[ip]; exists {
-			limiter.lastSeen = time.Now()
-			if !limiter.limiter.Allow() {
-				c.HTML(http.StatusTooManyRequests, "activation_result.html", gin.H{
-					"Title":   "Too Many Requests - Carryless",
-					"Success": false,
-					"Message": "Too many activation attempts. Please wait before trying again.",
-				})
-				c.Abort()
-				return
-			}
-		} else {
-			activationClients[ip] = &rateLimiter{
-				limiter:  rate.NewLimiter(rate.Every(time.Minute*5), 3),
-				lastSeen: time.Now(),
-			}
+// ShareRateLimit keeps the email sharing endpoints from being used to send spam
+func ShareRateLimit(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Skip rate limiting in development mode
+		if cfg.IsDevelopment() {
+			c.Next()
+			return
 		}
 
-		// Cleanup old activation clients
-		for ip, client := range activationClients {
-			if time.Since(client.lastSeen) > 30*time.Minute {
-				delete(activationClients, ip)
-			}
+		if !allowRouteRequest(c, metrics.LimiterShare, rate.Every(time.Hour/10), 5) {
+			metrics.RecordRateLimitRejection(metrics.LimiterShare)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many share emails sent. Please wait before trying again."})
+			c.Abort()
+			return
 		}
 
 		c.Next()
@@ -162,13 +134,13 @@ func IPBlocker(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		ip := c.ClientIP()
-
-		trackersMu.Lock()
-		tracker, exists := trackers[ip]
-		trackersMu.Unlock()
+		blocked, err := limiterStore.IsBlocked(c.Request.Context(), c.ClientIP())
+		if err != nil {
+			log.Printf("IP block check unavailable: %v", err)
+		}
 
-		if exists && time.Now().Before(tracker.blockedUntil) {
+		if blocked {
+			metrics.RecordBlockEvent(metrics.BlockEventRequestRejected)
 			c.HTML(http.StatusForbidden, "blocked.html", gin.H{
 				"Title":   "Access Blocked - Carryless",
 				"Message": "Your IP has been temporarily blocked due to excessive invalid requests. Please try again later.",
@@ -192,56 +164,43 @@ func Track404AndBlock(cfg *config.Config) gin.HandlerFunc {
 
 		if c.Writer.Status() == http.StatusNotFound {
 			ip := c.ClientIP()
-			now := time.Now()
-
-			trackersMu.Lock()
-			defer trackersMu.Unlock()
-
-			tracker, exists := trackers[ip]
-			if !exists {
-				tracker = &clientTracker{
-					errors404: make([]time.Time, 0),
-					lastSeen:  now,
-				}
-				trackers[ip] = tracker
-			}
+			ctx := c.Request.Context()
 
-			tracker.lastSeen = now
-			tracker.errors404 = append(tracker.errors404, now)
-
-			// Remove 404 errors older than 5 minutes
-			cutoff := now.Add(-5 * time.Minute)
-			validErrors := make([]time.Time, 0)
-			for _, errorTime := range tracker.errors404 {
-				if errorTime.After(cutoff) {
-					validErrors = append(validErrors, errorTime)
-				}
+			// Count 404 errors from the last 5 minutes
+			count, err := limiterStore.Record404(ctx, ip, 5*time.Minute)
+			if err != nil {
+				log.Printf("Failed to record 404: %v", err)
+				return
 			}
-			tracker.errors404 = validErrors
 
 			// Check if we should block this IP
-			if len(tracker.errors404) >= 10 {
-				tracker.blockedUntil = now.Add(15 * time.Minute)
-				tracker.errors404 = make([]time.Time, 0) // Reset counter
-				log.Printf("Blocked IP %s for 15 minutes due to %d 404 errors in 5 minutes", ip, len(validErrors))
-			}
-
-			// Cleanup old trackers
-			for trackerIP, trackerData := range trackers {
-				if time.Since(trackerData.lastSeen) > 30*time.Minute && time.Now().After(trackerData.blockedUntil) {
-					delete(trackers, trackerIP)
+			if count >= cfg.Block404Threshold {
+				if err := limiterStore.Block(ctx, ip, cfg.BlockDuration); err != nil {
+					log.Printf("Failed to block IP %s: %v", ip, err)
+					return
 				}
+				metrics.RecordBlockEvent(metrics.BlockEventIPBlocked)
+				log.Printf("Blocked IP %s for %v due to %d 404 errors in 5 minutes", ip, cfg.BlockDuration, count)
 			}
 		}
 	}
 }
 
-func cleanupOldClients() {
-	for ip, client := range clients {
-		if time.Since(client.lastSeen) > 10*time.Minute {
-			delete(clients, ip)
+// TrustProxies restricts which peers may set the client IP through X-Forwarded-For or
+// X-Real-IP. trustedProxies is a comma-separated list of IPs or CIDRs; when it is empty
+// forwarded headers are ignored and ClientIP is always the address of the TCP peer.
+func TrustProxies(r *gin.Engine, trustedProxies string) error {
+	var proxies []string
+	for _, proxy := range strings.Split(trustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
 		}
 	}
+
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return nil
 }
 
 func CORS(allowedOrigins string) gin.HandlerFunc {
@@ -290,6 +249,12 @@ func CSRF(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		// Browsers never attach API tokens on their own, so requests using one can't be forged
+		if _, ok := c.Get("api_token_id"); ok {
+			c.Next()
+			return
+		}
+
 		token := c.GetHeader("X-CSRF-Token")
 		if token == "" {
 			token = c.PostForm("csrf_token")
@@ -400,7 +365,7 @@ func AuthRequired(db *sql.DB, cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		user, err := database.ValidateSession(db, sessionCookie, cfg.SessionDuration)
+		user, renewedFor, err := database.ValidateSession(db, sessionCookie, cfg.SessionDuration, cfg.SessionExtensionThreshold)
 		if err != nil {
 			c.SetSameSite(http.SameSiteStrictMode)
 			c.SetCookie("session_id", "", -1, "/", "", true, true)
@@ -408,6 +373,11 @@ func AuthRequired(db *sql.DB, cfg *config.Config) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if user.IsSuspended {
+			abortSuspended(c)
+			return
+		}
+		refreshSessionCookie(c, sessionCookie, renewedFor)
 
 		c.Set("user", user)
 		c.Set("user_id", user.ID)
@@ -420,8 +390,9 @@ func AuthOptional(db *sql.DB, cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		sessionCookie, err := c.Cookie("session_id")
 		if err == nil {
-			user, err := database.ValidateSession(db, sessionCookie, cfg.SessionDuration)
-			if err == nil {
+			user, renewedFor, err := database.ValidateSession(db, sessionCookie, cfg.SessionDuration, cfg.SessionExtensionThreshold)
+			if err == nil && !user.IsSuspended {
+				refreshSessionCookie(c, sessionCookie, renewedFor)
 				c.Set("user", user)
 				c.Set("user_id", user.ID)
 			}
@@ -431,8 +402,99 @@ func AuthOptional(db *sql.DB, cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// APIAuth authenticates requests to the JSON API. It works like AuthRequired followed by
+// RequireActivation, but answers with JSON errors instead of redirects and HTML pages.
+// Scripts can send an API token as "Authorization: Bearer <token>" instead of the session
+// cookie.
+func APIAuth(db *sql.DB, cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user *models.User
+		if bearer, ok := bearerToken(c); ok {
+			tokenUser, apiToken, err := database.ValidateAPIToken(db, bearer)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
+				return
+			}
+			user = tokenUser
+			c.Set("api_token_id", apiToken.ID)
+		} else {
+			sessionCookie, err := c.Cookie("session_id")
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+				return
+			}
+
+			sessionUser, renewedFor, err := database.ValidateSession(db, sessionCookie, cfg.SessionDuration, cfg.SessionExtensionThreshold)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+				return
+			}
+			user = sessionUser
+			if !user.IsSuspended && user.IsActivated {
+				refreshSessionCookie(c, sessionCookie, renewedFor)
+			}
+		}
+
+		if user.IsSuspended {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+			return
+		}
+		if !user.IsActivated {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account not activated"})
+			return
+		}
+
+		c.Set("user", user)
+		c.Set("user_id", user.ID)
+		c.Set("db", db)
+		c.Next()
+	}
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header
+func bearerToken(c *gin.Context) (string, bool) {
+	const prefix = "Bearer "
+	header := c.GetHeader("Authorization")
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+// abortSuspended logs a suspended user out and explains why they can't continue
+func abortSuspended(c *gin.Context) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie("session_id", "", -1, "/", "", true, true)
+	c.HTML(http.StatusForbidden, "blocked.html", gin.H{
+		"Title":   "Account Suspended - Carryless",
+		"Message": "Your account has been suspended by an administrator.",
+	})
+	c.Abort()
+}
+
+// refreshSessionCookie extends the session cookie after the session itself was extended,
+// otherwise the browser would drop it at the end of the original window.
+func refreshSessionCookie(c *gin.Context, sessionID string, renewedFor time.Duration) {
+	if renewedFor <= 0 {
+		return
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie("session_id", sessionID, int(renewedFor.Seconds()), "/", "", true, true)
+}
+
+// SecurityHeaders sets the security headers, and gives each request a fresh nonce that
+// the Content-Security-Policy requires on inline scripts
 func SecurityHeaders(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		nonce, err := generateCSPNonce()
+		if err != nil {
+			log.Printf("Failed to generate CSP nonce: %v", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Writer = &nonceResponseWriter{ResponseWriter: c.Writer, nonce: nonce}
+
 		// Skip security headers in development mode to allow browser automation tools
 		if cfg.IsDevelopment() {
 			c.Next()
@@ -443,7 +505,8 @@ func SecurityHeaders(cfg *config.Config) gin.HandlerFunc {
 		c.Header("X-Frame-Options", "DENY")
 		c.Header("X-XSS-Protection", "1; mode=block")
 		c.Header("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
-		c.Header("Content-Security-Policy", "default-src 'self'; script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://unpkg.com; style-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com https://unpkg.com; img-src 'self' data: https://*.tile.openstreetmap.org https://tile.opentopomap.org https://*.tile.opentopomap.org https://*.tile.top-o-map.de https://cdnjs.cloudflare.com; font-src 'self' https://cdnjs.cloudflare.com")
+		// Inline event handlers (onclick=...) can't carry a nonce, script-src-attr keeps them working
+		c.Header("Content-Security-Policy", "default-src 'self'; script-src 'self' 'nonce-"+nonce+"' https://cdn.jsdelivr.net https://unpkg.com; script-src-attr 'unsafe-inline'; style-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com https://unpkg.com; img-src 'self' data: https://*.tile.openstreetmap.org https://tile.opentopomap.org https://*.tile.opentopomap.org https://*.tile.top-o-map.de https://cdnjs.cloudflare.com; font-src 'self' https://cdnjs.cloudflare.com")
 		c.Next()
 	}
 }
@@ -516,7 +579,7 @@ func AdminRequired(db *sql.DB, cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		user, err := database.ValidateSession(db, sessionCookie, cfg.SessionDuration)
+		user, renewedFor, err := database.ValidateSession(db, sessionCookie, cfg.SessionDuration, cfg.SessionExtensionThreshold)
 		if err != nil {
 			c.SetSameSite(http.SameSiteStrictMode)
 			c.SetCookie("session_id", "", -1, "/", "", true, true)
@@ -524,6 +587,11 @@ func AdminRequired(db *sql.DB, cfg *config.Config) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if user.IsSuspended {
+			abortSuspended(c)
+			return
+		}
+		refreshSessionCookie(c, sessionCookie, renewedFor)
 
 		if !user.IsAdmin {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"carryless/internal/config"
+	"carryless/internal/database"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func clientIPFor(t *testing.T, trustedProxies, remoteAddr, forwardedFor string) string {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := TrustProxies(r, trustedProxies); err != nil {
+		t.Fatal("Failed to configure trusted proxies:", err)
+	}
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w.Body.String()
+}
+
+func TestForwardedForHonoredFromTrustedProxy(t *testing.T) {
+	ip := clientIPFor(t, "127.0.0.1, 10.0.0.0/8", "10.1.2.3:54321", "203.0.113.7")
+	if ip != "203.0.113.7" {
+		t.Errorf("Expected forwarded client IP 203.0.113.7, got %s", ip)
+	}
+}
+
+func TestForwardedForIgnoredFromUntrustedSource(t *testing.T) {
+	ip := clientIPFor(t, "10.0.0.0/8", "198.51.100.9:54321", "203.0.113.7")
+	if ip != "198.51.100.9" {
+		t.Errorf("Expected peer IP 198.51.100.9 from untrusted source, got %s", ip)
+	}
+
+	// Without any trusted proxy, forwarded headers are never honored
+	ip = clientIPFor(t, "", "10.1.2.3:54321", "203.0.113.7")
+	if ip != "10.1.2.3" {
+		t.Errorf("Expected peer IP 10.1.2.3 with no trusted proxies, got %s", ip)
+	}
+}
+
+func TestTrustProxiesRejectsInvalidEntries(t *testing.T) {
+	if err := TrustProxies(gin.New(), "10.0.0.0/8,not-an-ip"); err == nil {
+		t.Error("Expected invalid trusted proxy to be rejected")
+	}
+}
+
+func TestAPIAuthAcceptsBearerToken(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal("Failed to open test database:", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatal("Failed to run migrations:", err)
+	}
+
+	user, err := database.CreateUser(db, "testuser", "test@example.com", "password123")
+	if err != nil {
+		t.Fatal("Failed to create user:", err)
+	}
+	if _, err := db.Exec("UPDATE users SET is_activated = TRUE WHERE id = ?", user.ID); err != nil {
+		t.Fatal("Failed to activate user:", err)
+	}
+	token, _, err := database.CreateAPIToken(db, user.ID, "Script")
+	if err != nil {
+		t.Fatal("Failed to create API token:", err)
+	}
+
+	// Tokens replace CSRF protection, so run the real CSRF check
+	cfg := &config.Config{Environment: "production"}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(APIAuth(db, cfg), CSRF(cfg))
+	r.POST("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.MustGet("user_id")})
+	})
+
+	request := func(authorization string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request("Bearer " + token); code != http.StatusOK {
+		t.Errorf("Expected 200 with a valid token, got %d", code)
+	}
+	if code := request("bearer " + token); code != http.StatusOK {
+		t.Errorf("Expected the Bearer scheme to be case-insensitive, got %d", code)
+	}
+	if code := request("Bearer " + token + "x"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with an invalid token, got %d", code)
+	}
+	if code := request(""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials, got %d", code)
+	}
+
+	if _, err := db.Exec("UPDATE users SET is_suspended = TRUE WHERE id = ?", user.ID); err != nil {
+		t.Fatal("Failed to suspend user:", err)
+	}
+	if code := request("Bearer " + token); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a suspended user, got %d", code)
+	}
+}
+
+func TestRateLimitSkipsStaticAndPreflight(t *testing.T) {
+	cfg := &config.Config{Environment: "production", RateLimitRPS: 2}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimit(cfg))
+	r.GET("/static/*filepath", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.OPTIONS("/api/items", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	r.POST("/api/items", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	request := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.10:40000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 10; i++ {
+		if code := request(http.MethodGet, "/static/css/style.css"); code != http.StatusOK {
+			t.Fatalf("Expected static request %d to pass, got %d", i+1, code)
+		}
+		if code := request(http.MethodOptions, "/api/items"); code != http.StatusNoContent {
+			t.Fatalf("Expected preflight %d to pass, got %d", i+1, code)
+		}
+	}
+
+	// The burst above left the bucket untouched, and other requests stay limited
+	limited := false
+	for i := 0; i < 5; i++ {
+		code := request(http.MethodPost, "/api/items")
+		if i < cfg.RateLimitRPS && code != http.StatusCreated {
+			t.Fatalf("Expected POST %d within the burst to pass, got %d", i+1, code)
+		}
+		if code == http.StatusTooManyRequests {
+			limited = true
+		}
+	}
+	if !limited {
+		t.Error("Expected POST requests beyond the limit to be rejected")
+	}
+}
+
+func TestAuthRateLimitKeepsABucketPerRoute(t *testing.T) {
+	cfg := &config.Config{Environment: "production", AuthRateLimitPerMinute: 2}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/login", AuthRateLimit(cfg), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.POST("/register", AuthRateLimit(cfg), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = "192.0.2.20:40000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < cfg.AuthRateLimitPerMinute; i++ {
+		if code := request("/login"); code != http.StatusOK {
+			t.Fatalf("Expected login %d within the burst to pass, got %d", i+1, code)
+		}
+	}
+	if code := request("/login"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected login beyond the burst to be rejected, got %d", code)
+	}
+
+	// Exhausting the login bucket leaves registration alone
+	if code := request("/register"); code != http.StatusOK {
+		t.Errorf("Expected register to keep its own bucket, got %d", code)
+	}
+}
+
+func TestSecurityHeadersNonceMatchesTemplate(t *testing.T) {
+	cfg := &config.Config{Environment: "production"}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("page.html").Parse(`<script nonce="{{.CSPNonce}}"></script>`)))
+	r.HTMLRender = NonceHTMLRender(r.HTMLRender)
+	r.Use(SecurityHeaders(cfg))
+	r.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "page.html", gin.H{})
+	})
+
+	nonceOf := func() string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		csp := w.Header().Get("Content-Security-Policy")
+		if strings.Contains(csp, "'unsafe-inline' https://cdn.jsdelivr.net") {
+			t.Errorf("Expected inline scripts to need a nonce, got %s", csp)
+		}
+		start := strings.Index(csp, "'nonce-")
+		if start == -1 {
+			t.Fatalf("Expected a nonce in the CSP, got %s", csp)
+		}
+		nonce := csp[start+len("'nonce-"):]
+		nonce = nonce[:strings.Index(nonce, "'")]
+
+		if body := w.Body.String(); body != `<script nonce="`+nonce+`"></script>` {
+			t.Errorf("Expected the script tagged with nonce %s, got %s", nonce, body)
+		}
+		return nonce
+	}
+
+	if first, second := nonceOf(), nonceOf(); first == second {
+		t.Error("Expected a new nonce on each request")
+	}
+}
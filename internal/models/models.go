@@ -1,7 +1,12 @@
 package models
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type User struct {
@@ -10,16 +15,50 @@ type User struct {
 	Email        string    `json:"email" db:"email"`
 	PasswordHash string    `json:"-" db:"password_hash"`
 	Currency     string    `json:"currency" db:"currency"`
+	WeightUnit   string    `json:"weight_unit" db:"weight_unit"`
 	IsAdmin      bool      `json:"is_admin" db:"is_admin"`
 	IsActivated  bool      `json:"is_activated" db:"is_activated"`
+	IsSuspended  bool      `json:"is_suspended" db:"is_suspended"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// Optional kinds of email a user can opt out of. Transactional emails such as account
+// activation are always sent.
+const (
+	EmailCategoryAdminNotifications = "admin_notifications"
+	EmailCategoryProduct            = "product_emails"
+	EmailCategoryTripReminders      = "trip_reminders"
+)
+
+// EmailPreferences holds which optional emails a user receives, and the token of the
+// unsubscribe links put in those emails
+type EmailPreferences struct {
+	UserID             int    `json:"user_id" db:"user_id"`
+	AdminNotifications bool   `json:"admin_notifications" db:"admin_notifications"`
+	ProductEmails      bool   `json:"product_emails" db:"product_emails"`
+	TripReminders      bool   `json:"trip_reminders" db:"trip_reminders"`
+	UnsubscribeToken   string `json:"-" db:"unsubscribe_token"`
+}
+
+// Allows reports whether the user wants to receive emails of the given category
+func (p *EmailPreferences) Allows(category string) bool {
+	switch category {
+	case EmailCategoryAdminNotifications:
+		return p.AdminNotifications
+	case EmailCategoryProduct:
+		return p.ProductEmails
+	case EmailCategoryTripReminders:
+		return p.TripReminders
+	}
+	return false
+}
+
 type Category struct {
 	ID        int       `json:"id" db:"id"`
 	UserID    int       `json:"user_id" db:"user_id"`
 	Name      string    `json:"name" db:"name"`
+	SortOrder int       `json:"sort_order" db:"sort_order"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -39,13 +78,41 @@ type Item struct {
 	Capacity       *float64   `json:"capacity,omitempty" db:"capacity"`
 	CapacityUnit   *string    `json:"capacity_unit,omitempty" db:"capacity_unit"`
 	Link           *string    `json:"link,omitempty" db:"link"`
+	ImagePath      *string    `json:"image_path,omitempty" db:"image_path"`
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	Category       *Category  `json:"category,omitempty"`
 	LinkedItems    []ItemLink `json:"linked_items,omitempty"`
 	HasLinkedItems bool       `json:"has_linked_items"`
 }
 
+// capacityUnitLiters converts volume capacity units to liters. mAh is battery capacity
+// rather than volume, so it isn't counted in pack volumes.
+var capacityUnitLiters = map[string]float64{
+	"mL":    0.001,
+	"L":     1,
+	"fl-oz": 0.0295735,
+}
+
+// IsValidCapacityUnit checks if the given unit can be used for an item capacity
+func IsValidCapacityUnit(unit string) bool {
+	_, isVolume := capacityUnitLiters[unit]
+	return isVolume || unit == "mAh"
+}
+
+// CapacityLiters returns the volume the item holds in liters, or false if it has no volume capacity
+func (i Item) CapacityLiters() (float64, bool) {
+	if i.Capacity == nil || i.CapacityUnit == nil {
+		return 0, false
+	}
+	liters, isVolume := capacityUnitLiters[*i.CapacityUnit]
+	if !isVolume {
+		return 0, false
+	}
+	return *i.Capacity * liters, true
+}
+
 type Pack struct {
 	ID              string          `json:"id" db:"id"`
 	UserID          int             `json:"user_id" db:"user_id"`
@@ -53,9 +120,16 @@ type Pack struct {
 	Note            string          `json:"note" db:"note"`
 	IsPublic        bool            `json:"is_public" db:"is_public"`
 	IsLocked        bool            `json:"is_locked" db:"is_locked"`
+	IsTemplate      bool            `json:"is_template" db:"is_template"`
+	IsFavorite      bool            `json:"is_favorite" db:"is_favorite"`
+	IsAnonymous     bool            `json:"is_anonymous" db:"is_anonymous"`
+	SharedRole      string          `json:"shared_role,omitempty"`
+	OwnerUsername   string          `json:"owner_username,omitempty"`
 	ShortID         string          `json:"short_id,omitempty" db:"short_id"`
+	PublicExpiresAt *time.Time      `json:"public_expires_at,omitempty" db:"public_expires_at"`
 	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time       `json:"updated_at" db:"updated_at"`
+	TotalWeight     int             `json:"total_weight"` // Carried weight, only set by pack lists
 	Items           []PackItem      `json:"items,omitempty"`
 	Labels          []PackLabel     `json:"labels,omitempty"`
 	PackLevelLabels []UserPackLabel `json:"pack_level_labels,omitempty"`
@@ -68,11 +142,186 @@ type PackItem struct {
 	IsWorn    bool `json:"is_worn" db:"is_worn"`
 	Count     int  `json:"count" db:"count"`
 	WornCount int  `json:"worn_count" db:"worn_count"`
+	IsConsumable bool `json:"is_consumable" db:"is_consumable"`
+	SortOrder *int `json:"sort_order,omitempty" db:"sort_order"`
+	Note      string `json:"note" db:"note"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	Item      *Item `json:"item,omitempty"`
 	Labels    []ItemLabel `json:"labels,omitempty"`
 }
 
+// WornQuantity returns how many of the item are worn, never more than are in the pack
+func (pi PackItem) WornQuantity() int {
+	if pi.WornCount > pi.Count {
+		return pi.Count
+	}
+	if pi.WornCount < 0 {
+		return 0
+	}
+	return pi.WornCount
+}
+
+// PublicLinkExpired reports whether the pack's public link is past its expiry time
+func (p *Pack) PublicLinkExpired() bool {
+	return p.PublicExpiresAt != nil && !p.PublicExpiresAt.After(time.Now())
+}
+
+// TotalWeight returns the weight of all the copies of the item in the pack, worn ones included
+func (pi PackItem) TotalWeight() int {
+	if pi.Item == nil {
+		return 0
+	}
+	return pi.Item.WeightGrams * pi.Count
+}
+
+// CarriedQuantity returns how many of the item are carried in the pack rather than worn
+func (pi PackItem) CarriedQuantity() int {
+	if pi.Count < 0 {
+		return 0
+	}
+	return pi.Count - pi.WornQuantity()
+}
+
+type PackWeightSnapshot struct {
+	ID         int       `json:"id" db:"id"`
+	PackID     string    `json:"pack_id" db:"pack_id"`
+	TotalGrams int       `json:"total_grams" db:"total_grams"`
+	BaseGrams  int       `json:"base_grams" db:"base_grams"`
+	RecordedAt time.Time `json:"recorded_at" db:"recorded_at"`
+}
+
+// FormatWeight renders a weight stored in grams in the given display unit ("g" or "oz")
+func FormatWeight(grams int, unit string) string {
+	if unit != "oz" {
+		return fmt.Sprintf("%dg", grams)
+	}
+	oz := float64(grams) * 0.035274
+	if oz >= 16 {
+		lbs := oz / 16
+		if lbs >= 10 {
+			return fmt.Sprintf("%.0f lbs", lbs)
+		}
+		return fmt.Sprintf("%.1f lbs", lbs)
+	}
+	if oz < 1 {
+		return fmt.Sprintf("%.3f oz", oz)
+	} else if oz < 10 {
+		return fmt.Sprintf("%.2f oz", oz)
+	}
+	return fmt.Sprintf("%.1f oz", oz)
+}
+
+// currencyFormat describes how amounts are written for a currency symbol
+type currencyFormat struct {
+	suffix   bool // symbol goes after the amount
+	space    bool // symbol and amount are separated by a space
+	decimals int  // digits after the decimal separator
+	comma    bool // decimal separator is a comma
+}
+
+var currencyFormats = map[string]currencyFormat{
+	"$": {decimals: 2},
+	"£": {decimals: 2},
+	"₹": {decimals: 2},
+	"R": {decimals: 2},
+	"¥": {decimals: 0},
+	"₩": {decimals: 0},
+	"€": {suffix: true, space: true, decimals: 2, comma: true},
+	"¢": {suffix: true, decimals: 0},
+}
+
+// FormatCurrency renders an amount with the currency symbol placed and the decimals written
+// the way that currency is usually displayed. Unknown symbols are prefixed, with a space when
+// they are codes such as "CHF".
+func FormatCurrency(amount float64, currency string) string {
+	format, known := currencyFormats[currency]
+	if !known {
+		format = currencyFormat{decimals: 2, space: utf8.RuneCountInString(currency) > 1}
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	number := strconv.FormatFloat(amount, 'f', format.decimals, 64)
+	if format.comma {
+		number = strings.Replace(number, ".", ",", 1)
+	}
+
+	separator := ""
+	if format.space {
+		separator = " "
+	}
+
+	if format.suffix {
+		return sign + number + separator + currency
+	}
+	return sign + currency + separator + number
+}
+
+// PackExportVersion is the current format version of PackExport documents
+const PackExportVersion = 1
+
+// PackExport is a self-contained copy of a pack used for backup and transfer between accounts.
+// Items are identified by name and category so they can be matched or recreated on import.
+type PackExport struct {
+	Version int               `json:"version"`
+	Name    string            `json:"name"`
+	Note    string            `json:"note"`
+	Labels  []PackExportLabel `json:"labels"`
+	Items   []PackExportItem  `json:"items"`
+}
+
+type PackExportLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type PackExportItem struct {
+	Name         string                `json:"name"`
+	Category     string                `json:"category"`
+	Note         string                `json:"note"`
+	WeightGrams  int                   `json:"weight_grams"`
+	Price        float64               `json:"price"`
+	Brand        *string               `json:"brand,omitempty"`
+	Model        *string               `json:"model,omitempty"`
+	Count        int                   `json:"count"`
+	WornCount    int                   `json:"worn_count"`
+	IsConsumable bool                  `json:"is_consumable"`
+	SortOrder    *int                  `json:"sort_order,omitempty"`
+	PackNote     string                `json:"pack_note,omitempty"`
+	Labels       []PackExportItemLabel `json:"labels,omitempty"`
+}
+
+// PackExportItemLabel assigns count units of an item to the pack label with the given name
+type PackExportItemLabel struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// UserDataExport bundles everything stored for one user so they can take a copy of their data.
+// Credentials and other users' data are never included.
+type UserDataExport struct {
+	ExportedAt   time.Time       `json:"exported_at"`
+	Profile      UserDataProfile `json:"profile"`
+	Categories   []Category      `json:"categories"`
+	Items        []Item          `json:"items"`
+	DeletedItems []Item          `json:"deleted_items"`
+	PackLabels   []UserPackLabel `json:"pack_labels"`
+	Packs        []Pack          `json:"packs"`
+	Trips        []Trip          `json:"trips"`
+}
+
+type UserDataProfile struct {
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	Currency   string    `json:"currency"`
+	WeightUnit string    `json:"weight_unit"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
 type Session struct {
 	ID        string    `json:"id" db:"id"`
 	UserID    int       `json:"user_id" db:"user_id"`
@@ -80,6 +329,194 @@ type Session struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// APIToken lets scripts call the JSON API as the user. Only a hash of the token is stored.
+type APIToken struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ItemPackUsage tells how many of an item a pack holds
+type ItemPackUsage struct {
+	PackID    string `json:"pack_id"`
+	PackName  string `json:"pack_name"`
+	Count     int    `json:"count"`
+	WornCount int    `json:"worn_count"`
+}
+
+// PackItemGroup holds the items of a pack that share a category
+type PackItemGroup struct {
+	Category string
+	Items    []PackItem
+}
+
+// Orders in which the items of a pack can be listed
+const (
+	PackItemSortCategory = "category"
+	PackItemSortWeight   = "weight"
+)
+
+// GroupPackItemsByCategory splits pack items by category. Pack items come sorted by category,
+// so groups keep the user's category order.
+func GroupPackItemsByCategory(items []PackItem) []PackItemGroup {
+	var groups []PackItemGroup
+	index := make(map[string]int)
+	for _, item := range items {
+		category := item.Item.Category.Name
+		i, exists := index[category]
+		if !exists {
+			i = len(groups)
+			index[category] = i
+			groups = append(groups, PackItemGroup{Category: category})
+		}
+		groups[i].Items = append(groups[i].Items, item)
+	}
+	return groups
+}
+
+// SortPackItemsByWeight returns a copy of the pack items, heaviest first. Items of equal weight
+// keep their order.
+func SortPackItemsByWeight(items []PackItem) []PackItem {
+	sorted := make([]PackItem, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TotalWeight() > sorted[j].TotalWeight()
+	})
+	return sorted
+}
+
+// CategoryWeight is the weight of a pack's items in one category
+type CategoryWeight struct {
+	Name       string `json:"name"`
+	Weight     int    `json:"weight"`
+	WornWeight int    `json:"worn_weight"`
+}
+
+// LabelWeight is the weight of a pack's items carrying one label
+type LabelWeight struct {
+	Name   string `json:"name"`
+	Color  string `json:"color"`
+	Weight int    `json:"weight"`
+}
+
+// PackWeightBreakdown splits a pack's weight by category and label. Weights are in grams;
+// Weight counts what is carried in the pack, WornWeight what is worn.
+type PackWeightBreakdown struct {
+	Weight           int              `json:"weight"`
+	WornWeight       int              `json:"worn_weight"`
+	ConsumableWeight int              `json:"consumable_weight"`
+	BaseWeight       int              `json:"base_weight"`
+	ItemCount        int              `json:"item_count"`
+	VolumeLiters     float64          `json:"volume_liters"`
+	Categories       []CategoryWeight `json:"categories"`
+	Labels           []LabelWeight    `json:"labels"`
+}
+
+// NewPackWeightBreakdown adds up the weights of a pack's items. Items come sorted by
+// category, so categories keep the user's order; labels are sorted by name.
+func NewPackWeightBreakdown(items []PackItem) PackWeightBreakdown {
+	breakdown := PackWeightBreakdown{Categories: []CategoryWeight{}, Labels: []LabelWeight{}}
+	categoryIndex := make(map[string]int)
+	labelIndex := make(map[string]int)
+
+	for _, packItem := range items {
+		// Quantities are clamped so a worn count above the item count can't go negative
+		packWeight := packItem.Item.WeightGrams * packItem.CarriedQuantity()
+		wornWeight := packItem.Item.WeightGrams * packItem.WornQuantity()
+		breakdown.ItemCount += packItem.Count
+		if liters, ok := packItem.Item.CapacityLiters(); ok {
+			breakdown.VolumeLiters += liters * float64(packItem.Count)
+		}
+
+		// Categories of weightless or fully worn items are still listed
+		categoryName := ""
+		if packItem.Item.Category != nil {
+			categoryName = packItem.Item.Category.Name
+		}
+		i, seen := categoryIndex[categoryName]
+		if !seen {
+			i = len(breakdown.Categories)
+			categoryIndex[categoryName] = i
+			breakdown.Categories = append(breakdown.Categories, CategoryWeight{Name: categoryName})
+		}
+		breakdown.Categories[i].Weight += packWeight
+		breakdown.Categories[i].WornWeight += wornWeight
+		breakdown.Weight += packWeight
+		breakdown.WornWeight += wornWeight
+		if packItem.IsConsumable {
+			breakdown.ConsumableWeight += packWeight
+		}
+
+		// Label weights use the actual label assignment counts
+		for _, itemLabel := range packItem.Labels {
+			j, seen := labelIndex[itemLabel.PackLabel.Name]
+			if !seen {
+				j = len(breakdown.Labels)
+				labelIndex[itemLabel.PackLabel.Name] = j
+				breakdown.Labels = append(breakdown.Labels, LabelWeight{Name: itemLabel.PackLabel.Name})
+			}
+			breakdown.Labels[j].Weight += packItem.Item.WeightGrams * itemLabel.Count
+			breakdown.Labels[j].Color = itemLabel.PackLabel.Color
+		}
+	}
+
+	breakdown.BaseWeight = breakdown.Weight - breakdown.ConsumableWeight
+	sort.Slice(breakdown.Labels, func(i, j int) bool {
+		return breakdown.Labels[i].Name < breakdown.Labels[j].Name
+	})
+	return breakdown
+}
+
+// CategoryOrder lists the category names in the user's order
+func (b PackWeightBreakdown) CategoryOrder() []string {
+	order := make([]string, len(b.Categories))
+	for i, category := range b.Categories {
+		order[i] = category.Name
+	}
+	return order
+}
+
+// CategoryWeights maps each category to the weight carried in the pack
+func (b PackWeightBreakdown) CategoryWeights() map[string]int {
+	weights := make(map[string]int, len(b.Categories))
+	for _, category := range b.Categories {
+		weights[category.Name] = category.Weight
+	}
+	return weights
+}
+
+// CategoryWornWeights maps the categories with worn items to their worn weight
+func (b PackWeightBreakdown) CategoryWornWeights() map[string]int {
+	weights := make(map[string]int)
+	for _, category := range b.Categories {
+		if category.WornWeight > 0 {
+			weights[category.Name] = category.WornWeight
+		}
+	}
+	return weights
+}
+
+// LabelWeights maps each label to the weight of the items carrying it
+func (b PackWeightBreakdown) LabelWeights() map[string]int {
+	weights := make(map[string]int, len(b.Labels))
+	for _, label := range b.Labels {
+		weights[label.Name] = label.Weight
+	}
+	return weights
+}
+
+// LabelColors maps each label to its color
+func (b PackWeightBreakdown) LabelColors() map[string]string {
+	colors := make(map[string]string, len(b.Labels))
+	for _, label := range b.Labels {
+		colors[label.Name] = label.Color
+	}
+	return colors
+}
+
 type CSRFToken struct {
 	Token     string    `json:"token" db:"token"`
 	UserID    int       `json:"user_id" db:"user_id"`
@@ -94,6 +531,56 @@ type ActivationToken struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// InviteToken lets one person register, even while public registration is closed
+type InviteToken struct {
+	Token     string     `json:"token" db:"token"`
+	CreatedBy int        `json:"created_by" db:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	UsedBy    *int       `json:"used_by,omitempty" db:"used_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// EmailChangeToken is a pending email change, applied once NewEmail is confirmed
+type EmailChangeToken struct {
+	Token     string    `json:"token" db:"token"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	NewEmail  string    `json:"new_email" db:"new_email"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// PackCollaborator is a user the owner of a pack shared it with. Role sets what they can do.
+type PackCollaborator struct {
+	PackID    string    `json:"pack_id" db:"pack_id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Username  string    `json:"username" db:"username"`
+	Email     string    `json:"email" db:"email"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// UserTOTP is a user's authenticator app enrollment. Secret is encrypted and the enrollment
+// only protects logins once Enabled, after the user proved their app generates valid codes.
+type UserTOTP struct {
+	UserID       int        `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`
+	Enabled      bool       `json:"enabled" db:"enabled"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	LastUsedStep int64      `json:"-" db:"last_used_step"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// LoginChallenge is a login that passed the password check and still waits for a second factor
+type LoginChallenge struct {
+	Token      string    `json:"token" db:"token"`
+	UserID     int       `json:"user_id" db:"user_id"`
+	RememberMe bool      `json:"remember_me" db:"remember_me"`
+	Attempts   int       `json:"attempts" db:"attempts"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
 type ItemInfo struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -126,7 +613,13 @@ type Trip struct {
 	StartDate      *time.Time           `json:"start_date,omitempty" db:"start_date"`
 	EndDate        *time.Time           `json:"end_date,omitempty" db:"end_date"`
 	Notes          *string              `json:"notes,omitempty" db:"notes"`
-	GPXData        *string              `json:"gpx_data,omitempty" db:"gpx_data"`
+	GPXDistance    *float64             `json:"gpx_distance_m,omitempty" db:"gpx_distance_m"`
+	GPXAscent      *float64             `json:"gpx_ascent_m,omitempty" db:"gpx_ascent_m"`
+	GPXDescent     *float64             `json:"gpx_descent_m,omitempty" db:"gpx_descent_m"`
+	GPXMinLat      *float64             `json:"gpx_min_lat,omitempty" db:"gpx_min_lat"`
+	GPXMinLon      *float64             `json:"gpx_min_lon,omitempty" db:"gpx_min_lon"`
+	GPXMaxLat      *float64             `json:"gpx_max_lat,omitempty" db:"gpx_max_lat"`
+	GPXMaxLon      *float64             `json:"gpx_max_lon,omitempty" db:"gpx_max_lon"`
 	IsPublic       bool                 `json:"is_public" db:"is_public"`
 	IsArchived     bool                 `json:"is_archived" db:"is_archived"`
 	ShortID        string               `json:"short_id,omitempty" db:"short_id"`
@@ -135,6 +628,46 @@ type Trip struct {
 	Packs          []Pack               `json:"packs,omitempty"`
 	ChecklistItems []TripChecklistItem  `json:"checklist_items,omitempty"`
 	TransportSteps []TripTransportStep  `json:"transport_steps,omitempty"`
+	GPXFiles       []TripGPXFile        `json:"gpx_files,omitempty"`
+	Progress       ChecklistProgress    `json:"checklist_progress"`
+}
+
+// HasGPXStats reports whether distance and elevation were computed from the trip's GPX tracks
+func (t *Trip) HasGPXStats() bool {
+	return t.GPXDistance != nil
+}
+
+// GPXDistanceKm returns the total distance of the GPX tracks in kilometers
+func (t *Trip) GPXDistanceKm() float64 {
+	if t.GPXDistance == nil {
+		return 0
+	}
+	return *t.GPXDistance / 1000
+}
+
+// GPXElevationGain returns the cumulative ascent of the GPX tracks in meters
+func (t *Trip) GPXElevationGain() float64 {
+	if t.GPXAscent == nil {
+		return 0
+	}
+	return *t.GPXAscent
+}
+
+// GPXElevationLoss returns the cumulative descent of the GPX tracks in meters
+func (t *Trip) GPXElevationLoss() float64 {
+	if t.GPXDescent == nil {
+		return 0
+	}
+	return *t.GPXDescent
+}
+
+// TripGPXFile is one GPX track of a trip, multi-day trips can have one per day
+type TripGPXFile struct {
+	ID        int       `json:"id" db:"id"`
+	TripID    string    `json:"trip_id" db:"trip_id"`
+	Name      string    `json:"name" db:"name"`
+	GPXData   string    `json:"gpx_data" db:"gpx_data"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
 type TripChecklistItem struct {
@@ -147,6 +680,20 @@ type TripChecklistItem struct {
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// ChecklistProgress counts how many items of a trip's checklist are checked
+type ChecklistProgress struct {
+	Checked int `json:"checked"`
+	Total   int `json:"total"`
+}
+
+// Percent returns the share of checked items, rounded down, and 0 for an empty checklist
+func (p ChecklistProgress) Percent() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return p.Checked * 100 / p.Total
+}
+
 type TripTransportStep struct {
 	ID                int        `json:"id" db:"id"`
 	TripID            string     `json:"trip_id" db:"trip_id"`
@@ -159,9 +706,19 @@ type TripTransportStep struct {
 	TransportType     *string    `json:"transport_type,omitempty" db:"transport_type"`
 	TransportNumber   *string    `json:"transport_number,omitempty" db:"transport_number"`
 	Notes             *string    `json:"notes,omitempty" db:"notes"`
+	Cost              *float64   `json:"cost,omitempty" db:"cost"`
+	Currency          *string    `json:"currency,omitempty" db:"currency"`
 	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
 }
 
+// CostAmount returns the cost of the transport step, or 0 when none was recorded
+func (t TripTransportStep) CostAmount() float64 {
+	if t.Cost == nil {
+		return 0
+	}
+	return *t.Cost
+}
+
 // Duration returns the duration of the transport step if both departure and arrival times are set
 func (t *TripTransportStep) Duration() *time.Duration {
 	if t.DepartureDatetime != nil && t.ArrivalDatetime != nil {
@@ -0,0 +1,34 @@
+package models
+
+import "testing"
+
+func TestFormatCurrency(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     string
+	}{
+		// 2 decimals, symbol first
+		{12.5, "$", "$12.50"},
+		{12.5, "£", "£12.50"},
+		{1200, "₹", "₹1200.00"},
+		{99.99, "R", "R99.99"},
+		// 0 decimals, rounded
+		{1500.4, "¥", "¥1500"},
+		{1500.6, "₩", "₩1501"},
+		{45, "¢", "45¢"},
+		// Comma decimal separator, symbol after the amount
+		{12.5, "€", "12,50 €"},
+		{0, "€", "0,00 €"},
+		{-3.25, "$", "-$3.25"},
+		// Unknown symbols and codes
+		{10, "CHF", "CHF 10.00"},
+		{10, "₺", "₺10.00"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatCurrency(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("FormatCurrency(%v, %q) = %q, expected %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"carryless/internal/config"
+)
+
+// Identity is the account a user authenticated as at a provider
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is an OAuth2 identity provider users can log in with
+type Provider struct {
+	Name        string
+	DisplayName string
+
+	clientID     string
+	clientSecret string
+	authURL      string
+	tokenURL     string
+	scopes       []string
+	identity     func(ctx context.Context, s *Service, accessToken string) (*Identity, error)
+}
+
+// Service runs the OAuth2 authorization code flow against the configured providers
+type Service struct {
+	client      *http.Client
+	redirectURL string
+	providers   map[string]*Provider
+}
+
+// NewService registers every provider with both a client ID and secret configured.
+// Without any, OAuth login is disabled.
+func NewService(cfg *config.Config) *Service {
+	s := &Service{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		redirectURL: strings.TrimRight(cfg.OAuthRedirectBaseURL, "/"),
+		providers:   make(map[string]*Provider),
+	}
+
+	if cfg.GoogleClientID != "" && cfg.GoogleClientSecret != "" {
+		s.providers["google"] = &Provider{
+			Name:         "google",
+			DisplayName:  "Google",
+			clientID:     cfg.GoogleClientID,
+			clientSecret: cfg.GoogleClientSecret,
+			authURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+			tokenURL:     "https://oauth2.googleapis.com/token",
+			scopes:       []string{"openid", "email", "profile"},
+			identity:     googleIdentity,
+		}
+	}
+
+	if cfg.GitHubClientID != "" && cfg.GitHubClientSecret != "" {
+		s.providers["github"] = &Provider{
+			Name:         "github",
+			DisplayName:  "GitHub",
+			clientID:     cfg.GitHubClientID,
+			clientSecret: cfg.GitHubClientSecret,
+			authURL:      "https://github.com/login/oauth/authorize",
+			tokenURL:     "https://github.com/login/oauth/access_token",
+			scopes:       []string{"read:user", "user:email"},
+			identity:     githubIdentity,
+		}
+	}
+
+	return s
+}
+
+// IsEnabled returns true if at least one provider is configured
+func (s *Service) IsEnabled() bool {
+	return len(s.providers) > 0
+}
+
+// Providers returns the configured providers sorted by name
+func (s *Service) Providers() []*Provider {
+	providers := make([]*Provider, 0, len(s.providers))
+	for _, p := range s.providers {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	return providers
+}
+
+// Provider returns the provider registered under name
+func (s *Service) Provider(name string) (*Provider, bool) {
+	p, ok := s.providers[name]
+	return p, ok
+}
+
+// AuthCodeURL returns the provider page the user is sent to in order to log in. state is
+// echoed back to the callback and must be checked there.
+func (s *Service) AuthCodeURL(p *Provider, state string) string {
+	params := url.Values{}
+	params.Set("client_id", p.clientID)
+	params.Set("redirect_uri", s.callbackURL(p))
+	params.Set("response_type", "code")
+	params.Set("scope", strings.Join(p.scopes, " "))
+	params.Set("state", state)
+	return p.authURL + "?" + params.Encode()
+}
+
+// Exchange trades the authorization code sent to the callback for the user's identity
+func (s *Service) Exchange(ctx context.Context, p *Provider, code string) (*Identity, error) {
+	form := url.Values{}
+	form.Set("client_id", p.clientID)
+	form.Set("client_secret", p.clientSecret)
+	form.Set("code", code)
+	form.Set("grant_type", "authorization_code")
+	form.Set("redirect_uri", s.callbackURL(p))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub answers with a form-encoded body unless JSON is asked for
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := s.do(req, &token); err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("failed to exchange code: %s", token.Error)
+	}
+
+	identity, err := p.identity(ctx, s, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	identity.Provider = p.Name
+	return identity, nil
+}
+
+func (s *Service) callbackURL(p *Provider) string {
+	return s.redirectURL + "/auth/oauth/" + p.Name + "/callback"
+}
+
+// getJSON fetches an API resource on behalf of the user
+func (s *Service) getJSON(ctx context.Context, endpoint, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return s.do(req, v)
+}
+
+func (s *Service) do(req *http.Request, v interface{}) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("%s returned status %d", req.URL.Host, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %w", req.URL.Host, err)
+	}
+	return nil
+}
+
+func googleIdentity(ctx context.Context, s *Service, accessToken string) (*Identity, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := s.getJSON(ctx, "https://openidconnect.googleapis.com/v1/userinfo", accessToken, &info); err != nil {
+		return nil, fmt.Errorf("failed to get Google user info: %w", err)
+	}
+	if info.Sub == "" {
+		return nil, fmt.Errorf("Google user info has no subject")
+	}
+
+	return &Identity{
+		Subject:       info.Sub,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Name:          info.Name,
+	}, nil
+}
+
+func githubIdentity(ctx context.Context, s *Service, accessToken string) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := s.getJSON(ctx, "https://api.github.com/user", accessToken, &user); err != nil {
+		return nil, fmt.Errorf("failed to get GitHub user: %w", err)
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("GitHub user has no ID")
+	}
+
+	// The profile email is optional and unverified, the primary address is taken from the
+	// email list instead
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := s.getJSON(ctx, "https://api.github.com/user/emails", accessToken, &emails); err != nil {
+		return nil, fmt.Errorf("failed to get GitHub emails: %w", err)
+	}
+
+	identity := &Identity{
+		Subject: strconv.FormatInt(user.ID, 10),
+		Name:    user.Login,
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+			break
+		}
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,101 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+
+	"carryless/internal/models"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Column widths of the item table, in millimeters. They add up to the printable width of an A4 page.
+var columnWidths = []float64{90, 20, 20, 30, 30}
+
+// WritePack renders a printable packing list for a pack loaded with GetPackWithItems.
+// Items are grouped by category with subtotals, followed by the base, worn and total weights.
+func WritePack(w io.Writer, pack *models.Pack, weightUnit string) error {
+	doc := fpdf.New("P", "mm", "A4", "")
+	doc.SetTitle(pack.Name, true)
+	doc.SetCreator("Carryless", true)
+	doc.SetMargins(15, 15, 15)
+	doc.SetAutoPageBreak(true, 15)
+	doc.AddPage()
+
+	// Core fonts are cp1252, translate item names so accents render correctly
+	tr := doc.UnicodeTranslatorFromDescriptor("")
+
+	doc.SetFont("Helvetica", "B", 18)
+	doc.CellFormat(0, 10, tr(pack.Name), "", 1, "L", false, 0, "")
+	doc.Ln(2)
+
+	packWeight, wornWeight, consumableWeight := 0, 0, 0
+	for _, packItem := range pack.Items {
+		carried := packItem.Item.WeightGrams * packItem.CarriedQuantity()
+		packWeight += carried
+		wornWeight += packItem.Item.WeightGrams * packItem.WornQuantity()
+		if packItem.IsConsumable {
+			consumableWeight += carried
+		}
+	}
+
+	doc.SetFont("Helvetica", "", 11)
+	summary := fmt.Sprintf("Base: %s    Consumables: %s    Worn: %s    Total: %s",
+		models.FormatWeight(packWeight-consumableWeight, weightUnit),
+		models.FormatWeight(consumableWeight, weightUnit),
+		models.FormatWeight(wornWeight, weightUnit),
+		models.FormatWeight(packWeight+wornWeight, weightUnit))
+	doc.CellFormat(0, 7, summary, "", 1, "L", false, 0, "")
+	doc.Ln(4)
+
+	header := []string{"Item", "Count", "Worn", "Weight", "Subtotal"}
+	doc.SetFont("Helvetica", "B", 10)
+	doc.SetFillColor(230, 230, 230)
+	for i, title := range header {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		doc.CellFormat(columnWidths[i], 7, title, "B", 0, align, true, 0, "")
+	}
+	doc.Ln(-1)
+
+	// GetPackWithItems returns items sorted by category, so groups are contiguous
+	for i := 0; i < len(pack.Items); {
+		categoryName := pack.Items[i].Item.Category.Name
+
+		doc.SetFont("Helvetica", "B", 10)
+		doc.CellFormat(0, 8, tr(categoryName), "", 1, "L", false, 0, "")
+
+		doc.SetFont("Helvetica", "", 10)
+		subtotal := 0
+		for ; i < len(pack.Items) && pack.Items[i].Item.Category.Name == categoryName; i++ {
+			packItem := pack.Items[i]
+			itemTotal := packItem.Item.WeightGrams * packItem.Count
+			subtotal += itemTotal
+
+			name := packItem.Item.Name
+			if packItem.IsConsumable {
+				name += " (consumable)"
+			}
+			doc.CellFormat(columnWidths[0], 6, tr(name), "", 0, "L", false, 0, "")
+			doc.CellFormat(columnWidths[1], 6, fmt.Sprintf("%d", packItem.Count), "", 0, "R", false, 0, "")
+			doc.CellFormat(columnWidths[2], 6, fmt.Sprintf("%d", packItem.WornCount), "", 0, "R", false, 0, "")
+			doc.CellFormat(columnWidths[3], 6, models.FormatWeight(packItem.Item.WeightGrams, weightUnit), "", 0, "R", false, 0, "")
+			doc.CellFormat(columnWidths[4], 6, models.FormatWeight(itemTotal, weightUnit), "", 1, "R", false, 0, "")
+		}
+
+		doc.SetFont("Helvetica", "I", 10)
+		labelWidth := columnWidths[0] + columnWidths[1] + columnWidths[2] + columnWidths[3]
+		doc.CellFormat(labelWidth, 6, tr(categoryName)+" subtotal", "T", 0, "R", false, 0, "")
+		doc.CellFormat(columnWidths[4], 6, models.FormatWeight(subtotal, weightUnit), "T", 1, "R", false, 0, "")
+		doc.Ln(2)
+	}
+
+	if len(pack.Items) == 0 {
+		doc.SetFont("Helvetica", "I", 10)
+		doc.CellFormat(0, 8, "This pack is empty.", "", 1, "L", false, 0, "")
+	}
+
+	return doc.Output(w)
+}
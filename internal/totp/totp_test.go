@@ -0,0 +1,50 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// TestCodeMatchesRFC6238 checks codes against the SHA-1 test vectors of RFC 6238 appendix B,
+// truncated to 6 digits
+func TestCodeMatchesRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, want := range vectors {
+		got, err := Code(secret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal("Failed to compute code:", err)
+		}
+		if got != want {
+			t.Errorf("At %d: expected %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestValidateAcceptsClockSkew(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal("Failed to generate secret:", err)
+	}
+
+	now := time.Now()
+	previous, _ := Code(secret, Step(now)-1)
+	if step, ok := Validate(secret, previous, now); !ok || step != Step(now)-1 {
+		t.Error("Expected the previous code to be accepted")
+	}
+
+	stale, _ := Code(secret, Step(now)-3)
+	if _, ok := Validate(secret, stale, now); ok {
+		t.Error("Expected a code from several periods ago to be rejected")
+	}
+}
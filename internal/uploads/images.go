@@ -0,0 +1,112 @@
+package uploads
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Dir is where uploaded files are stored on disk, served under URLPrefix by the static route
+const Dir = "./static/uploads"
+
+// URLPrefix is the public path uploaded files are served from
+const URLPrefix = "/static/uploads/"
+
+// imageExtensions maps the accepted image content types to the extension used on disk
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// SaveItemImage validates data as a JPEG or PNG image of at most maxSize bytes, writes it
+// under the user's upload directory and returns the public path to store on the item
+func SaveItemImage(userID int, data []byte, maxSize int64) (string, error) {
+	if int64(len(data)) > maxSize {
+		return "", fmt.Errorf("image too large")
+	}
+
+	ext, ok := imageExtensions[http.DetectContentType(data)]
+	if !ok {
+		return "", fmt.Errorf("unsupported image type")
+	}
+
+	userDir := filepath.Join(Dir, strconv.Itoa(userID))
+	if err := os.MkdirAll(userDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	name := uuid.New().String() + ext
+	if err := os.WriteFile(filepath.Join(userDir, name), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+
+	return URLPrefix + strconv.Itoa(userID) + "/" + name, nil
+}
+
+// Remove deletes the file behind a public upload path. Paths outside the upload
+// directory are ignored, and a file that is already gone is not an error.
+func Remove(publicPath string) error {
+	localPath, ok := localPath(publicPath)
+	if !ok {
+		return nil
+	}
+
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove upload: %w", err)
+	}
+	return nil
+}
+
+// RemoveUnreferenced deletes uploaded files whose public path is not in referenced,
+// such as images left behind by items that were permanently deleted
+func RemoveUnreferenced(referenced map[string]bool) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(Dir, path)
+		if err != nil {
+			return err
+		}
+		if referenced[URLPrefix+filepath.ToSlash(rel)] {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to remove unreferenced uploads: %w", err)
+	}
+	return removed, nil
+}
+
+// localPath maps a public upload path back to its location on disk
+func localPath(publicPath string) (string, bool) {
+	if !strings.HasPrefix(publicPath, URLPrefix) {
+		return "", false
+	}
+
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(publicPath, URLPrefix)))
+	if rel == "." || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
+		return "", false
+	}
+
+	return filepath.Join(Dir, rel), true
+}
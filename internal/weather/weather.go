@@ -0,0 +1,251 @@
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"carryless/internal/config"
+)
+
+// cacheTTL is how long a forecast is reused before asking the provider again
+const cacheTTL = 3 * time.Hour
+
+// forecastDays is how far ahead the provider forecasts, today included
+const forecastDays = 16
+
+const dateLayout = "2006-01-02"
+
+// Forecast is the daily weather expected at a location over a date range
+type Forecast struct {
+	Location  string  `json:"location"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Days      []Day   `json:"days"`
+}
+
+// Day is the forecast for a single date. Temperatures are in Celsius and
+// precipitation in millimeters.
+type Day struct {
+	Date            string   `json:"date"`
+	TempMaxC        *float64 `json:"temp_max_c"`
+	TempMinC        *float64 `json:"temp_min_c"`
+	PrecipitationMM *float64 `json:"precipitation_mm"`
+	WeatherCode     int      `json:"weather_code"`
+	Summary         string   `json:"summary"`
+}
+
+type cacheEntry struct {
+	forecast *Forecast
+	expires  time.Time
+}
+
+// Service fetches forecasts from an Open-Meteo compatible provider and caches them
+type Service struct {
+	client       *http.Client
+	forecastURL  string
+	geocodingURL string
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func NewService(cfg *config.Config) *Service {
+	return &Service{
+		client:       &http.Client{Timeout: 10 * time.Second},
+		forecastURL:  cfg.WeatherForecastURL,
+		geocodingURL: cfg.WeatherGeocodingURL,
+		cache:        make(map[string]cacheEntry),
+	}
+}
+
+// Forecast returns the daily forecast for location between start and end, inclusive.
+// Only the part of the range the provider can forecast is returned, so trips too far
+// ahead or in the past get an empty forecast without reaching the provider.
+func (s *Service) Forecast(location string, start, end time.Time) (*Forecast, error) {
+	location = strings.TrimSpace(location)
+	forecast := &Forecast{Location: location, Days: []Day{}}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start = start.UTC().Truncate(24 * time.Hour)
+	end = end.UTC().Truncate(24 * time.Hour)
+	if start.Before(today) {
+		start = today
+	}
+	if last := today.AddDate(0, 0, forecastDays-1); end.After(last) {
+		end = last
+	}
+	if location == "" || end.Before(start) {
+		return forecast, nil
+	}
+
+	key := strings.ToLower(location) + "|" + start.Format(dateLayout) + "|" + end.Format(dateLayout)
+	if cached := s.cached(key); cached != nil {
+		return cached, nil
+	}
+
+	lat, lon, name, err := s.geocode(location)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Location = name
+	forecast.Latitude = lat
+	forecast.Longitude = lon
+
+	days, err := s.daily(lat, lon, start, end)
+	if err != nil {
+		return nil, err
+	}
+	forecast.Days = days
+
+	s.store(key, forecast)
+	return forecast, nil
+}
+
+func (s *Service) cached(key string) *Forecast {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(s.cache, key)
+		return nil
+	}
+	return entry.forecast
+}
+
+func (s *Service) store(key string, forecast *Forecast) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired entries so the cache doesn't grow with every trip ever looked at
+	now := time.Now()
+	for k, entry := range s.cache {
+		if now.After(entry.expires) {
+			delete(s.cache, k)
+		}
+	}
+	s.cache[key] = cacheEntry{forecast: forecast, expires: now.Add(cacheTTL)}
+}
+
+// geocode resolves a free-form location to coordinates using the first match
+func (s *Service) geocode(location string) (float64, float64, string, error) {
+	params := url.Values{}
+	params.Set("name", location)
+	params.Set("count", "1")
+	params.Set("format", "json")
+
+	var response struct {
+		Results []struct {
+			Name      string  `json:"name"`
+			Country   string  `json:"country"`
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		} `json:"results"`
+	}
+	if err := s.getJSON(s.geocodingURL+"?"+params.Encode(), &response); err != nil {
+		return 0, 0, "", fmt.Errorf("failed to geocode location: %w", err)
+	}
+	if len(response.Results) == 0 {
+		return 0, 0, "", fmt.Errorf("location not found")
+	}
+
+	result := response.Results[0]
+	name := result.Name
+	if result.Country != "" {
+		name += ", " + result.Country
+	}
+	return result.Latitude, result.Longitude, name, nil
+}
+
+// daily fetches the daily forecast at the given coordinates
+func (s *Service) daily(lat, lon float64, start, end time.Time) ([]Day, error) {
+	params := url.Values{}
+	params.Set("latitude", fmt.Sprintf("%.4f", lat))
+	params.Set("longitude", fmt.Sprintf("%.4f", lon))
+	params.Set("daily", "weather_code,temperature_2m_max,temperature_2m_min,precipitation_sum")
+	params.Set("timezone", "auto")
+	params.Set("start_date", start.Format(dateLayout))
+	params.Set("end_date", end.Format(dateLayout))
+
+	var response struct {
+		Daily struct {
+			Time          []string   `json:"time"`
+			WeatherCode   []*int     `json:"weather_code"`
+			TempMax       []*float64 `json:"temperature_2m_max"`
+			TempMin       []*float64 `json:"temperature_2m_min"`
+			Precipitation []*float64 `json:"precipitation_sum"`
+		} `json:"daily"`
+	}
+	if err := s.getJSON(s.forecastURL+"?"+params.Encode(), &response); err != nil {
+		return nil, fmt.Errorf("failed to fetch forecast: %w", err)
+	}
+
+	daily := response.Daily
+	days := make([]Day, 0, len(daily.Time))
+	for i, date := range daily.Time {
+		day := Day{Date: date, WeatherCode: -1}
+		if i < len(daily.WeatherCode) && daily.WeatherCode[i] != nil {
+			day.WeatherCode = *daily.WeatherCode[i]
+		}
+		if i < len(daily.TempMax) {
+			day.TempMaxC = daily.TempMax[i]
+		}
+		if i < len(daily.TempMin) {
+			day.TempMinC = daily.TempMin[i]
+		}
+		if i < len(daily.Precipitation) {
+			day.PrecipitationMM = daily.Precipitation[i]
+		}
+		day.Summary = describe(day.WeatherCode)
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func (s *Service) getJSON(requestURL string, target interface{}) error {
+	resp, err := s.client.Get(requestURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// describe turns a WMO weather interpretation code into a short summary
+func describe(code int) string {
+	switch {
+	case code == 0:
+		return "Clear sky"
+	case code >= 1 && code <= 3:
+		return "Partly cloudy"
+	case code == 45 || code == 48:
+		return "Fog"
+	case code >= 51 && code <= 57:
+		return "Drizzle"
+	case code >= 61 && code <= 67:
+		return "Rain"
+	case code >= 71 && code <= 77:
+		return "Snow"
+	case code >= 80 && code <= 82:
+		return "Rain showers"
+	case code == 85 || code == 86:
+		return "Snow showers"
+	case code >= 95 && code <= 99:
+		return "Thunderstorm"
+	default:
+		return "Unknown"
+	}
+}
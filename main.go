@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"carryless/internal/config"
@@ -14,8 +20,12 @@ import (
 	"carryless/internal/email"
 	"carryless/internal/handlers"
 	"carryless/internal/logger"
+	"carryless/internal/metrics"
 	"carryless/internal/middleware"
 	"carryless/internal/models"
+	"carryless/internal/oauth"
+	"carryless/internal/uploads"
+	"carryless/internal/weather"
 
 	"github.com/gin-gonic/gin"
 )
@@ -33,7 +43,7 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	db, err := database.Initialize(cfg.DatabasePath)
+	db, err := database.Initialize(cfg.DatabasePath, cfg.DBMaxOpenConns, cfg.DBMaxIdleConns)
 	if err != nil {
 		logger.Error("Failed to initialize database", "error", err)
 		log.Fatal("Failed to initialize database:", err)
@@ -45,15 +55,50 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	if err := database.CleanupOldPackWeightSnapshots(db); err != nil {
+		logger.Warn("Failed to cleanup old pack weight snapshots", "error", err)
+	}
+
+	// Expired sessions, tokens and login challenges are removed now and then periodically
+	stopCleanup := database.StartCleanupJob(db, cfg.CleanupInterval)
+	defer stopCleanup()
+
+	if err := database.PurgeDeletedItems(db); err != nil {
+		logger.Warn("Failed to purge deleted items", "error", err)
+	}
+
+	// Remove images left behind by items that no longer exist
+	if imagePaths, err := database.GetItemImagePaths(db); err != nil {
+		logger.Warn("Failed to load item image paths", "error", err)
+	} else if _, err := uploads.RemoveUnreferenced(imagePaths); err != nil {
+		logger.Warn("Failed to cleanup unreferenced item images", "error", err)
+	}
+
 	emailService := email.NewService(cfg)
 	if emailService.IsEnabled() {
-		logger.Info("Email service enabled with Mailgun")
+		logger.Info("Email service enabled", "provider", emailService.Provider())
 	} else {
-		logger.Info("Email service disabled - Mailgun not configured")
+		logger.Info("Email service disabled - no email provider configured")
+	}
+
+	weatherService := weather.NewService(cfg)
+
+	oauthService := oauth.NewService(cfg)
+	if oauthService.IsEnabled() {
+		for _, provider := range oauthService.Providers() {
+			logger.Info("OAuth login enabled", "provider", provider.Name)
+		}
 	}
 
 	r := gin.Default()
 
+	// Only trust forwarded client IPs from known proxies, otherwise anyone could pick
+	// the IP used for rate limiting and blocking
+	if err := middleware.TrustProxies(r, cfg.TrustedProxies); err != nil {
+		logger.Error("Failed to configure trusted proxies", "error", err)
+		log.Fatal("Failed to configure trusted proxies:", err)
+	}
+
 	funcMap := template.FuncMap{
 		"jsonify": func(v interface{}) template.JS {
 			bytes, _ := json.Marshal(v)
@@ -68,14 +113,7 @@ func main() {
 		"toUpper": func(s string) string {
 			return strings.ToUpper(s)
 		},
-		"groupByCategory": func(items []models.PackItem) map[string][]models.PackItem {
-			groups := make(map[string][]models.PackItem)
-			for _, item := range items {
-				category := item.Item.Category.Name
-				groups[category] = append(groups[category], item)
-			}
-			return groups
-		},
+		"groupByCategory": models.GroupPackItemsByCategory,
 		"groupItemsByCategory": func(items []models.Item) map[string][]models.Item {
 			groups := make(map[string][]models.Item)
 			for _, item := range items {
@@ -146,6 +184,8 @@ func main() {
 				return t.Format("Jan 2")
 			}
 		},
+		"formatWeight": models.FormatWeight,
+		"formatCurrency": models.FormatCurrency,
 		"deref": func(s *string) string {
 			if s == nil {
 				return ""
@@ -160,18 +200,61 @@ func main() {
 	partials, _ := filepath.Glob("templates/partials/*.html")
 	allFiles := append(files, partials...)
 	r.LoadHTMLFiles(allFiles...)
+	r.HTMLRender = middleware.NonceHTMLRender(r.HTMLRender)
+	// Probes are registered first so no rate limiting or IP blocking applies to them
+	handlers.SetupHealthRoutes(r, db)
+
+	// Share rate limits and IP blocks between replicas when running more than one
+	if cfg.RateLimitBackend == "redis" {
+		store, err := middleware.NewRedisLimiterStore(cfg.RedisURL)
+		if err != nil {
+			logger.Error("Failed to set up Redis rate limiting", "error", err)
+			log.Fatal("Failed to set up Redis rate limiting:", err)
+		}
+		middleware.UseLimiterStore(store)
+		logger.Info("Rate limiting backed by Redis")
+	}
+
+	// Registered before the static handler so assets are compressed too
+	r.Use(middleware.Gzip())
 	r.Static("/static", "./static")
 
+	r.Use(metrics.Middleware())
 	r.Use(middleware.CORS(cfg.AllowedOrigins))
 	r.Use(middleware.IPBlocker(cfg))
 	r.Use(middleware.RateLimit(cfg))
 	r.Use(middleware.Track404AndBlock(cfg))
 
-	handlers.SetupRoutes(r, db, emailService, cfg)
+	handlers.SetupRoutes(r, db, emailService, weatherService, oauthService, cfg)
 
-	logger.Info("Server starting", "port", cfg.Port)
-	if err := r.Run(":" + cfg.Port); err != nil {
+	server := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server starting", "port", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
 		logger.Error("Server failed to start", "error", err)
 		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	// Let requests in progress finish before the deferred cleanup job stop and database close
+	logger.Info("Shutting down server", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Server did not shut down cleanly", "error", err)
 	}
 }
\ No newline at end of file